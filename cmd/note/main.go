@@ -1,23 +1,43 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"note/config"
+	"note/internal/adapter/oidc"
 	"note/internal/adapter/repository"
 	"note/internal/controllers/http/middleware"
 	v1 "note/internal/controllers/http/v1"
 	_ "note/internal/logger"
 	"note/internal/service"
+	"os"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/gorilla/mux"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
 	"go.uber.org/zap"
 )
 
-const openCons = 10
+const (
+	openCons    = 10
+	oidcTimeout = 10 * time.Second
+	// maxBody bounds request bodies: a note is at most 100000 characters,
+	// a batch has to fit as well.
+	maxBody = 4 << 20
+)
+
+const (
+	driverMySQL    = "mysql"
+	driverSQLite   = "sqlite"
+	driverPostgres = "postgres"
+	driverMemory   = "memory"
+)
 
 func main() {
 	cfg, err := config.LoadConfig(".")
@@ -27,47 +47,81 @@ func main() {
 		return
 	}
 
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?", cfg.DBUser, cfg.DBDriver, cfg.DBHost, cfg.DBPort, cfg.DBName)
-	dsn += "&charset=utf8"
-	dsn += "&interpolateParams=true"
-	dsn += "&parseTime=true"
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		err = runMigrate(context.Background(), cfg, os.Args[2:])
 
-	db, err := sql.Open("mysql", dsn)
+		if err != nil {
+			log.Printf("error: %s\n", err)
+			os.Exit(1)
+		}
 
-	if err != nil {
-		log.Printf("error: %s\n", err)
 		return
 	}
-	defer db.Close()
 
-	db.SetMaxOpenConns(openCons)
-
-	err = db.Ping()
+	notesRepo, closeStorage, err := newStorage(context.Background(), cfg)
 
 	if err != nil {
 		log.Printf("error: %s\n", err)
 		return
 	}
+	defer closeStorage()
 
-	var (
-		logger    = zap.L()
-		notesRepo = repository.NewStorage(db)
-	)
+	logger := zap.L()
 
 	handlerIndex := v1.NewIndexHandler(logger)
 	noteService := service.NewService(notesRepo)
 	handlersNotes := v1.NewNoteHandler(noteService, logger)
+	authService := service.NewAuthService(notesRepo, cfg.SessionTTL)
+	handlersAuth := v1.NewAuthHandler(authService, logger)
+	handlersPublic := v1.NewPublicHandler(noteService, logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go purgeTrash(ctx, noteService, cfg.TrashRetention, cfg.TrashPurgeInterval, logger)
+
+	limits, err := newRateLimits(cfg)
+
+	if err != nil {
+		log.Printf("error: %s\n", err)
+		return
+	}
+
+	buckets := middleware.NewMemoryBuckets()
+	limit := func(policy middleware.Policy) mux.MiddlewareFunc {
+		return func(next http.Handler) http.Handler { return middleware.RateLimit(next, buckets, policy, logger) }
+	}
 
 	router := mux.NewRouter()
 
 	router.HandleFunc("/", handlerIndex.Index)
-	router.HandleFunc("/note/{id}", handlersNotes.GetByID).Methods("GET")
-	router.HandleFunc("/note", handlersNotes.Create).Methods("POST")
-	router.HandleFunc("/note/{id}", handlersNotes.UpdateByID).Methods("PUT")
-	router.HandleFunc("/note/{id}", handlersNotes.DeleteByID).Methods("DELETE")
-	router.HandleFunc("/note", handlersNotes.GetAll).Methods("GET")
 
-	siteMux := middleware.Logger(router, logger)
+	// Logins and public links are limited per client address, which also
+	// slows down guessing passwords.
+	authRoutes := router.NewRoute().Subrouter()
+	authRoutes.Use(limit(limits.auth))
+	handlersAuth.Register(authRoutes)
+
+	if err = registerOIDC(ctx, authRoutes, cfg, notesRepo, logger); err != nil {
+		log.Printf("error: %s\n", err)
+		return
+	}
+
+	publicRoutes := router.NewRoute().Subrouter()
+	publicRoutes.Use(limit(limits.public))
+	handlersPublic.Register(publicRoutes)
+
+	// Everything else needs a session or an access token and only sees the
+	// notes of its user. The address is limited before the token is
+	// checked, the user after.
+	notes := router.NewRoute().Subrouter()
+	notes.Use(limit(limits.apiIP))
+	notes.Use(func(next http.Handler) http.Handler { return middleware.Auth(next, authService, logger) })
+	notes.Use(limit(limits.apiUser))
+	handlersNotes.Register(notes)
+	handlersAuth.RegisterTokens(notes)
+
+	siteMux := middleware.RequestID(middleware.Logger(middleware.MaxBody(router, maxBody, logger), logger))
 
 	logger.Info("Listennig on :8080")
 	err = http.ListenAndServe(":8080", siteMux)
@@ -76,3 +130,145 @@ func main() {
 		log.Printf("error: %s\n", err)
 	}
 }
+
+// registerOIDC adds the login at the OpenID Connect provider, if there is
+// one. The provider is discovered at start, so a wrong issuer stops the
+// service rather than every login.
+func registerOIDC(ctx context.Context, router *mux.Router, cfg *config.Config, storage service.UserStorage, logger *zap.Logger) error {
+	if cfg.OIDCIssuer == "" {
+		return nil
+	}
+
+	oidcConfig := oidc.Config{
+		Issuer:       cfg.OIDCIssuer,
+		ClientID:     cfg.OIDCClientID,
+		ClientSecret: cfg.OIDCClientSecret,
+		RedirectURL:  cfg.OIDCRedirectURL,
+		Scopes:       []string{"email"},
+	}
+	provider, err := oidc.New(ctx, oidcConfig, &http.Client{Timeout: oidcTimeout})
+
+	if err != nil {
+		return err
+	}
+
+	handlersOIDC := v1.NewOIDCHandler(service.NewOIDCService(storage, cfg.SessionTTL, provider), logger)
+	handlersOIDC.Register(router)
+
+	return nil
+}
+
+// rateLimits are the policies of the route groups.
+type rateLimits struct {
+	auth, public, apiIP, apiUser middleware.Policy
+}
+
+func newRateLimits(cfg *config.Config) (*rateLimits, error) {
+	limits := &rateLimits{
+		auth:    middleware.Policy{Name: "auth", Key: middleware.ByIP},
+		public:  middleware.Policy{Name: "public", Key: middleware.ByIP},
+		apiIP:   middleware.Policy{Name: "api-ip", Key: middleware.ByIP},
+		apiUser: middleware.Policy{Name: "api-user", Key: middleware.ByUser},
+	}
+
+	for policy, value := range map[*middleware.Policy]string{
+		&limits.auth:    cfg.RateLimitAuth,
+		&limits.public:  cfg.RateLimitPublic,
+		&limits.apiIP:   cfg.RateLimitAPIIP,
+		&limits.apiUser: cfg.RateLimitAPIUser,
+	} {
+		limit, err := middleware.ParseLimit(value)
+
+		if err != nil {
+			return nil, fmt.Errorf("%s rate limit: %w", policy.Name, err)
+		}
+
+		policy.Limit = limit
+	}
+
+	return limits, nil
+}
+
+func openDB(cfg *config.Config) (*sql.DB, error) {
+	switch cfg.DBDriver {
+	case driverMySQL, "":
+		dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?", cfg.DBUser, cfg.DBPassword, cfg.DBHost, cfg.DBPort, cfg.DBName)
+		dsn += "&charset=utf8"
+		dsn += "&interpolateParams=true"
+		dsn += "&parseTime=true"
+		// Updates that change nothing still find their row.
+		dsn += "&clientFoundRows=true"
+
+		db, err := sql.Open("mysql", dsn)
+
+		if err != nil {
+			return nil, err
+		}
+
+		db.SetMaxOpenConns(openCons)
+
+		return db, nil
+	case driverPostgres:
+		dsn := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s", url.QueryEscape(cfg.DBUser), url.QueryEscape(cfg.DBPassword), cfg.DBHost, cfg.DBPort, cfg.DBName, url.QueryEscape(cfg.DBSSLMode))
+		db, err := sql.Open("postgres", dsn)
+
+		if err != nil {
+			return nil, err
+		}
+
+		db.SetMaxOpenConns(openCons)
+
+		return db, nil
+	case driverSQLite:
+		db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_busy_timeout=5000&_foreign_keys=on", cfg.DBName))
+
+		if err != nil {
+			return nil, err
+		}
+
+		// SQLite serializes writers anyway and an in-memory database
+		// exists per connection, so keep a single one.
+		db.SetMaxOpenConns(1)
+
+		return db, nil
+	default:
+		return nil, fmt.Errorf("unknown db driver %q", cfg.DBDriver)
+	}
+}
+
+func newStorage(ctx context.Context, cfg *config.Config) (service.Storage, func() error, error) {
+	if cfg.DBDriver == driverMemory {
+		return repository.NewMemoryStorage(), func() error { return nil }, nil
+	}
+
+	db, err := openDB(cfg)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = db.Ping()
+
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+
+	var storage service.Storage
+
+	switch cfg.DBDriver {
+	case driverSQLite:
+		storage, err = repository.NewSQLiteStorage(ctx, db)
+	case driverPostgres:
+		storage = repository.NewPostgresStorage(db)
+	default:
+		storage = repository.NewStorage(db)
+	}
+
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+
+	return storage, db.Close, nil
+}
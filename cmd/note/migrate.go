@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"note/config"
+	"note/internal/migrate"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+const migrateUsage = "usage: note migrate up|down [steps]|status"
+
+// dbRetryInterval is how long migrate waits between tries to reach the
+// database.
+const dbRetryInterval = time.Second
+
+func runMigrate(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	dialect := cfg.DBDriver
+
+	switch dialect {
+	case "":
+		dialect = migrate.MySQL
+	case driverMemory:
+		return errors.New("memory storage has no schema to migrate")
+	}
+
+	db, err := openDB(cfg)
+
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err = waitForDB(ctx, db, cfg.DBWait); err != nil {
+		return err
+	}
+
+	migrator, err := migrate.New(db, dialect)
+
+	if err != nil {
+		return err
+	}
+
+	var versions []int64
+
+	switch args[0] {
+	case "up":
+		versions, err = migrator.Up(ctx)
+		printVersions("applied", versions)
+
+		return err
+	case "down":
+		steps := 1
+
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+
+			if err != nil || steps < 1 {
+				return errors.New(migrateUsage)
+			}
+		}
+
+		versions, err = migrator.Down(ctx, steps)
+		printVersions("rolled back", versions)
+
+		return err
+	case "status":
+		var statuses []migrate.Status
+		statuses, err = migrator.Status(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+
+		for _, st := range statuses {
+			appliedAt := "pending"
+
+			if st.AppliedAt != nil {
+				appliedAt = st.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", st.Version, st.Name, appliedAt)
+		}
+
+		return w.Flush()
+	default:
+		return errors.New(migrateUsage)
+	}
+}
+
+// waitForDB pings db until it answers, for at most wait. Under docker compose
+// migrate starts together with the database, before it accepts connections.
+func waitForDB(ctx context.Context, db *sql.DB, wait time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+
+	for {
+		err := db.PingContext(ctx)
+
+		if err == nil {
+			return nil
+		}
+
+		log.Printf("waiting for the database: %s\n", err)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("the database didn't answer in %s: %w", wait, err)
+		case <-time.After(dbRetryInterval):
+		}
+	}
+}
+
+func printVersions(action string, versions []int64) {
+	if len(versions) == 0 {
+		fmt.Println("nothing to do")
+		return
+	}
+
+	for _, v := range versions {
+		fmt.Printf("%s %04d\n", action, v)
+	}
+}
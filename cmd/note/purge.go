@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"note/internal/service"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type trashPurger interface {
+	PurgeTrash(context.Context, time.Duration) (int64, error)
+}
+
+// purgeTrash removes the notes that have been in the trash for longer than
+// retention, at start and then every interval, until ctx is done. A zero
+// retention or interval turns purging off. The purger acts for the service,
+// not for a user, so it reaches the trash of everyone.
+func purgeTrash(ctx context.Context, purger trashPurger, retention, interval time.Duration, logger *zap.Logger) {
+	if retention <= 0 || interval <= 0 {
+		logger.Info("trash purging is off")
+		return
+	}
+
+	ctx = service.AsSystem(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := purger.PurgeTrash(ctx, retention)
+
+		switch {
+		case err != nil:
+			logger.Warn("can't purge the trash", zap.Error(err))
+		case purged > 0:
+			logger.Info("purged the trash", zap.Int64("notes", purged))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -1,15 +1,41 @@
 package config
 
 import (
+	"time"
+
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	DBDriver string `mapstructure:"DB_DRIVER"`
-	DBUser   string `mapstructure:"DB_USER"`
-	DBName   string `mapstructure:"DB_NAME"`
-	DBHost   string `mapstructure:"DB_HOST"`
-	DBPort   string `mapstructure:"DB_PORT"`
+	DBDriver   string `mapstructure:"DB_DRIVER"`
+	DBUser     string `mapstructure:"DB_USER"`
+	DBPassword string `mapstructure:"DB_PASSWORD"`
+	DBName     string `mapstructure:"DB_NAME"`
+	DBHost     string `mapstructure:"DB_HOST"`
+	DBPort     string `mapstructure:"DB_PORT"`
+	DBSSLMode  string `mapstructure:"DB_SSLMODE"`
+	// DBWait is how long migrate waits for the database to accept
+	// connections.
+	DBWait time.Duration `mapstructure:"DB_WAIT"`
+	// Notes stay in the trash for TrashRetention; the purger looks for
+	// expired ones every TrashPurgeInterval.
+	TrashRetention     time.Duration `mapstructure:"TRASH_RETENTION"`
+	TrashPurgeInterval time.Duration `mapstructure:"TRASH_PURGE_INTERVAL"`
+	// SessionTTL is how long a login stays valid.
+	SessionTTL time.Duration `mapstructure:"SESSION_TTL"`
+	// Users may also log in at the OpenID Connect provider OIDCIssuer, when
+	// it is set, as client OIDCClientID.
+	OIDCIssuer       string `mapstructure:"OIDC_ISSUER"`
+	OIDCClientID     string `mapstructure:"OIDC_CLIENT_ID"`
+	OIDCClientSecret string `mapstructure:"OIDC_CLIENT_SECRET"`
+	OIDCRedirectURL  string `mapstructure:"OIDC_REDIRECT_URL"`
+	// Rate limits are written as requests per period, like 60/1m, and 0
+	// turns one off. Logins and public links are limited per client
+	// address; the API both per address and per user or access token.
+	RateLimitAuth    string `mapstructure:"RATE_LIMIT_AUTH"`
+	RateLimitPublic  string `mapstructure:"RATE_LIMIT_PUBLIC"`
+	RateLimitAPIIP   string `mapstructure:"RATE_LIMIT_API_IP"`
+	RateLimitAPIUser string `mapstructure:"RATE_LIMIT_API_USER"`
 }
 
 func LoadConfig(path string) (*Config, error) {
@@ -18,7 +44,22 @@ func LoadConfig(path string) (*Config, error) {
 	viper.AddConfigPath(path)
 	viper.SetConfigFile(".env")
 
+	// AutomaticEnv only fills keys viper knows of, so every setting that
+	// may be missing from .env needs a default.
 	viper.AutomaticEnv()
+	viper.SetDefault("DB_SSLMODE", "disable")
+	viper.SetDefault("DB_WAIT", "60s")
+	viper.SetDefault("TRASH_RETENTION", "720h")
+	viper.SetDefault("TRASH_PURGE_INTERVAL", "1h")
+	viper.SetDefault("SESSION_TTL", "720h")
+	viper.SetDefault("OIDC_ISSUER", "")
+	viper.SetDefault("OIDC_CLIENT_ID", "")
+	viper.SetDefault("OIDC_CLIENT_SECRET", "")
+	viper.SetDefault("OIDC_REDIRECT_URL", "http://localhost:8080/auth/oidc/callback")
+	viper.SetDefault("RATE_LIMIT_AUTH", "20/1m")
+	viper.SetDefault("RATE_LIMIT_PUBLIC", "60/1m")
+	viper.SetDefault("RATE_LIMIT_API_IP", "600/1m")
+	viper.SetDefault("RATE_LIMIT_API_USER", "300/1m")
 
 	err := viper.ReadInConfig()
 
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// loadIn loads the config from a .env with content, which LoadConfig looks
+// for in the working directory.
+func loadIn(t *testing.T, content string) *Config {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte(content), 0o600); err != nil {
+		t.Fatalf("can't write .env: %s", err)
+	}
+
+	wd, err := os.Getwd()
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if err = os.Chdir(dir); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	t.Cleanup(func() {
+		_ = os.Chdir(wd)
+		viper.Reset()
+	})
+
+	cfg, err := LoadConfig(dir)
+
+	if err != nil {
+		t.Fatalf("can't load the config: %s", err)
+	}
+
+	return cfg
+}
+
+func TestSSLMode(t *testing.T) {
+	if cfg := loadIn(t, "DB_Driver=postgres\n"); cfg.DBSSLMode != "disable" {
+		t.Errorf("expected sslmode disable by default, got %q", cfg.DBSSLMode)
+	}
+
+	viper.Reset()
+	t.Setenv("DB_SSLMODE", "require")
+
+	if cfg := loadIn(t, "DB_Driver=postgres\n"); cfg.DBDriver != "postgres" || cfg.DBSSLMode != "require" {
+		t.Errorf("expected sslmode from the environment, got %+v", cfg)
+	}
+}
+
+func TestDBWait(t *testing.T) {
+	if cfg := loadIn(t, "DB_Driver=mysql\n"); cfg.DBWait != time.Minute {
+		t.Errorf("expected a minute by default, got %s", cfg.DBWait)
+	}
+
+	viper.Reset()
+	t.Setenv("DB_WAIT", "5s")
+
+	if cfg := loadIn(t, "DB_Driver=mysql\n"); cfg.DBWait != 5*time.Second {
+		t.Errorf("expected the wait from the environment, got %s", cfg.DBWait)
+	}
+}
@@ -0,0 +1,313 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"note/internal/service"
+	"strings"
+	"time"
+)
+
+// leeway allows for the clocks of the provider and of the service to
+// differ a little.
+const leeway = time.Minute
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// claims are the claims of an ID token the service checks or uses.
+type claims struct {
+	Issuer          string   `json:"iss"`
+	Subject         string   `json:"sub"`
+	Audience        audience `json:"aud"`
+	AuthorizedParty string   `json:"azp"`
+	Expiry          float64  `json:"exp"`
+	IssuedAt        float64  `json:"iat"`
+	Nonce           string   `json:"nonce"`
+	Email           string   `json:"email"`
+	EmailVerified   verified `json:"email_verified"`
+}
+
+// audience is a single string or a list of them.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+
+		return nil
+	}
+
+	return json.Unmarshal(data, (*[]string)(a))
+}
+
+// verified is a boolean that some providers send as a string.
+type verified bool
+
+func (v *verified) UnmarshalJSON(data []byte) error {
+	var flag bool
+
+	if err := json.Unmarshal(data, &flag); err == nil {
+		*v = verified(flag)
+
+		return nil
+	}
+
+	var text string
+
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+
+	*v = text == "true"
+
+	return nil
+}
+
+// jwk is a public key of the JWKS of the provider.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// verify checks the signature and the claims of an ID token, as OpenID
+// Connect Core 3.1.3.7 asks. The nonce is left to the service, which knows
+// the login.
+func (p *Provider) verify(ctx context.Context, raw string) (*service.Identity, error) {
+	parts := strings.Split(raw, ".")
+
+	if len(parts) != 3 {
+		return nil, invalid("the ID token is not a signed JWT")
+	}
+
+	h := header{}
+
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, err
+	}
+
+	key, err := p.key(ctx, h.Kid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+
+	if err != nil {
+		return nil, invalid("the signature of the ID token is malformed")
+	}
+
+	if err = checkSignature(h.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	c := claims{}
+
+	if err = decodeSegment(parts[1], &c); err != nil {
+		return nil, err
+	}
+
+	if err = p.checkClaims(c); err != nil {
+		return nil, err
+	}
+
+	return &service.Identity{
+		Issuer: c.Issuer, Subject: c.Subject, Email: c.Email, EmailVerified: bool(c.EmailVerified), Nonce: c.Nonce,
+	}, nil
+}
+
+func (p *Provider) checkClaims(c claims) error {
+	now := time.Now()
+
+	switch {
+	case c.Issuer != p.config.Issuer:
+		return invalid("the ID token is issued by %q", c.Issuer)
+	case !contains(c.Audience, p.config.ClientID):
+		return invalid("the ID token is not for client %q", p.config.ClientID)
+	case len(c.Audience) > 1 && c.AuthorizedParty != p.config.ClientID:
+		return invalid("the ID token is authorized for %q", c.AuthorizedParty)
+	case c.Subject == "":
+		return invalid("the ID token has no subject")
+	case c.Expiry == 0 || now.After(time.Unix(int64(c.Expiry), 0).Add(leeway)):
+		return invalid("the ID token has expired")
+	case time.Unix(int64(c.IssuedAt), 0).After(now.Add(leeway)):
+		return invalid("the ID token is issued in the future")
+	}
+
+	return nil
+}
+
+// checkSignature takes only the algorithms OpenID Connect providers use
+// for ID tokens, so "none" and HMAC with a public key are refused.
+func checkSignature(alg string, key crypto.PublicKey, signed string, signature []byte) error {
+	sum := sha256.Sum256([]byte(signed))
+
+	switch alg {
+	case "RS256":
+		if pub, ok := key.(*rsa.PublicKey); ok && rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], signature) == nil {
+			return nil
+		}
+	case "ES256":
+		pub, ok := key.(*ecdsa.PublicKey)
+
+		if ok && len(signature) == 64 {
+			r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+
+			if ecdsa.Verify(pub, sum[:], r, s) {
+				return nil
+			}
+		}
+	default:
+		return invalid("the ID token is signed with %q, not RS256 or ES256", alg)
+	}
+
+	return invalid("the signature of the ID token is wrong")
+}
+
+// key finds the key with kid and fetches the keys again when it isn't
+// known, since providers rotate them. ID tokens come from the token
+// endpoint, not from the client, so an unknown kid can't be used to make
+// the service fetch keys over and over. A token without a kid takes the
+// only key.
+func (p *Provider) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.lookup(kid); ok {
+		return key, nil
+	}
+
+	keys, err := p.fetchKeys(ctx)
+
+	if err != nil {
+		return nil, fmt.Errorf("can't fetch the keys of %s: %w", p.config.Issuer, err)
+	}
+
+	p.keys = keys
+
+	if key, ok := p.lookup(kid); ok {
+		return key, nil
+	}
+
+	return nil, invalid("the ID token is signed with unknown key %q", kid)
+}
+
+func (p *Provider) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+
+	key, ok := p.keys[kid]
+
+	return key, ok
+}
+
+// fetchKeys reads the signing keys of the JWKS. Keys of other types and
+// uses are skipped.
+func (p *Provider) fetchKeys(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	set := struct {
+		Keys []jwk `json:"keys"`
+	}{}
+
+	if err := p.getJSON(ctx, p.metadata.JWKSURI, &set); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]crypto.PublicKey{}
+
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		key, err := k.publicKey()
+
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", k.Kid, err)
+		}
+
+		if key != nil {
+			keys[k.Kid] = key
+		}
+	}
+
+	return keys, nil
+}
+
+// publicKey is nil for key types ID tokens aren't verified with.
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch {
+	case k.Kty == "RSA":
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+
+		if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("malformed RSA key")
+		}
+
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case k.Kty == "EC" && k.Crv == "P-256":
+		x, errX := base64.RawURLEncoding.DecodeString(k.X)
+		y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+
+		if errX != nil || errY != nil || len(x) != 32 || len(y) != 32 {
+			return nil, fmt.Errorf("malformed P-256 key")
+		}
+
+		// ecdh checks that the point is on the curve.
+		if _, err := ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+
+	return nil, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+
+	if err != nil || json.Unmarshal(data, v) != nil {
+		return invalid("the ID token is malformed")
+	}
+
+	return nil
+}
+
+// invalid is an ID token that fails verification.
+func invalid(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: "+format, append([]interface{}{service.ErrUnauthorized}, args...)...)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,199 @@
+// Package oidc logs users in at an OpenID Connect identity provider with
+// the authorization code flow and PKCE. It implements
+// service.IdentityProvider with the standard library only: discovery, the
+// token request and the verification of ID tokens against the JWKS of the
+// provider.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"note/internal/service"
+	"strings"
+	"sync"
+)
+
+const (
+	discoveryPath = "/.well-known/openid-configuration"
+	// maxResponse bounds what is read from the identity provider.
+	maxResponse = 1 << 20
+)
+
+// Config names the client registered at the identity provider.
+// ClientSecret is empty for a public client, which PKCE alone protects.
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// Scopes are asked besides openid; email is needed to join existing
+	// users.
+	Scopes []string
+}
+
+// metadata is the part of the discovery document the flow needs.
+type metadata struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	CodeChallengeMethods  []string `json:"code_challenge_methods_supported"`
+}
+
+// Provider is the identity provider of one issuer, for one client.
+type Provider struct {
+	config   Config
+	client   *http.Client
+	metadata metadata
+	// keys of the provider by kid, fetched again when a token is signed
+	// with an unknown one.
+	mu   sync.Mutex
+	keys map[string]crypto.PublicKey
+}
+
+// New discovers the endpoints of the issuer. The issuer in the discovery
+// document has to be the configured one, as OpenID Connect Discovery
+// requires.
+func New(ctx context.Context, config Config, client *http.Client) (*Provider, error) {
+	p := &Provider{config: config, client: client, keys: map[string]crypto.PublicKey{}}
+
+	if err := p.getJSON(ctx, strings.TrimSuffix(config.Issuer, "/")+discoveryPath, &p.metadata); err != nil {
+		return nil, fmt.Errorf("can't discover %s: %w", config.Issuer, err)
+	}
+
+	m := p.metadata
+
+	switch {
+	case m.Issuer != config.Issuer:
+		return nil, fmt.Errorf("the discovery document is for issuer %q, not %q", m.Issuer, config.Issuer)
+	case m.AuthorizationEndpoint == "" || m.TokenEndpoint == "" || m.JWKSURI == "":
+		return nil, fmt.Errorf("the discovery document of %s misses an endpoint", config.Issuer)
+	case len(m.CodeChallengeMethods) > 0 && !contains(m.CodeChallengeMethods, "S256"):
+		return nil, fmt.Errorf("%s doesn't support S256 PKCE", config.Issuer)
+	}
+
+	return p, nil
+}
+
+// AuthURL is the authorization request of a login.
+func (p *Provider) AuthURL(state, nonce, codeChallenge string) string {
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(append([]string{"openid"}, p.config.Scopes...), " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {codeChallenge},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+
+	if strings.Contains(p.metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return p.metadata.AuthorizationEndpoint + separator + query.Encode()
+}
+
+// tokenResponse is the answer of the token endpoint, or its error.
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Exchange redeems a code at the token endpoint and verifies the ID token
+// it returns.
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier string) (*service.Identity, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"code_verifier": {codeVerifier},
+	}
+
+	if p.config.ClientSecret == "" {
+		form.Set("client_id", p.config.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	if p.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	tokens := tokenResponse{}
+
+	if err = p.do(req, &tokens, http.StatusBadRequest, http.StatusUnauthorized); err != nil {
+		return nil, fmt.Errorf("can't redeem the code: %w", err)
+	}
+
+	switch {
+	case tokens.Error != "":
+		return nil, fmt.Errorf("%w: the identity provider refused the code: %s %s", service.ErrUnauthorized, tokens.Error, tokens.ErrorDescription)
+	case tokens.IDToken == "":
+		return nil, fmt.Errorf("%w: the identity provider sent no ID token", service.ErrUnauthorized)
+	}
+
+	return p.verify(ctx, tokens.IDToken)
+}
+
+func (p *Provider) getJSON(ctx context.Context, target string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	return p.do(req, v)
+}
+
+// do sends a request and reads its JSON answer, which has to come with 200
+// or one of the also statuses: the token endpoint answers errors with 400
+// or 401 and a JSON body.
+func (p *Provider) do(req *http.Request, v interface{}, also ...int) error {
+	resp, err := p.client.Do(req)
+
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponse))
+
+	if err != nil {
+		return err
+	}
+
+	accepted := resp.StatusCode == http.StatusOK
+
+	for _, status := range also {
+		accepted = accepted || resp.StatusCode == status
+	}
+
+	if !accepted {
+		return fmt.Errorf("%s answered %d", req.URL.Host, resp.StatusCode)
+	}
+
+	if err = json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%s answered %d without JSON: %w", req.URL.Host, resp.StatusCode, err)
+	}
+
+	return nil
+}
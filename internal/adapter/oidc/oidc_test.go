@@ -0,0 +1,137 @@
+package oidc_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"note/internal/adapter/oidc"
+	"note/internal/adapter/oidc/oidctest"
+	"note/internal/service"
+	"testing"
+	"time"
+)
+
+const redirectURL = "http://localhost:8080/auth/oidc/callback"
+
+func newProvider(t *testing.T, idp *oidctest.Provider) *oidc.Provider {
+	t.Helper()
+
+	config := oidc.Config{Issuer: idp.URL, ClientID: idp.ClientID, ClientSecret: idp.ClientSecret, RedirectURL: redirectURL, Scopes: []string{"email"}}
+	provider, err := oidc.New(context.Background(), config, idp.Client())
+
+	if err != nil {
+		t.Fatalf("can't discover the provider: %s", err)
+	}
+
+	return provider
+}
+
+// login goes to the authorization endpoint like a browser and returns the
+// code the provider sends back.
+func login(t *testing.T, idp *oidctest.Provider, provider *oidc.Provider, nonce, verifier string) string {
+	t.Helper()
+
+	sum := sha256.Sum256([]byte(verifier))
+	client := idp.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err := client.Get(provider.AuthURL("state", nonce, base64.RawURLEncoding.EncodeToString(sum[:])))
+
+	if err != nil {
+		t.Fatalf("can't authorize: %s", err)
+	}
+	defer resp.Body.Close()
+
+	back, err := url.Parse(resp.Header.Get("Location"))
+
+	if err != nil || resp.StatusCode != http.StatusFound || back.Query().Get("state") != "state" {
+		t.Fatalf("unexpected authorization response %d to %s", resp.StatusCode, back)
+	}
+
+	return back.Query().Get("code")
+}
+
+func TestExchange(t *testing.T) {
+	idp := oidctest.New(t, "notes", "secret")
+	idp.Subject, idp.Email = "248289761001", "ann@example.com"
+	provider := newProvider(t, idp)
+	code := login(t, idp, provider, "nonce", "verifier of the login")
+	identity, err := provider.Exchange(context.Background(), code, "verifier of the login")
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	want := service.Identity{Issuer: idp.URL, Subject: "248289761001", Email: "ann@example.com", EmailVerified: true, Nonce: "nonce"}
+
+	if *identity != want {
+		t.Errorf("expected %+v, got %+v", want, identity)
+	}
+
+	if _, err = provider.Exchange(context.Background(), code, "verifier of the login"); !errors.Is(err, service.ErrUnauthorized) {
+		t.Errorf("expected ErrUnauthorized for a used code, got %v", err)
+	}
+
+	code = login(t, idp, provider, "nonce", "verifier of the login")
+
+	if _, err = provider.Exchange(context.Background(), code, "another verifier"); !errors.Is(err, service.ErrUnauthorized) {
+		t.Errorf("expected ErrUnauthorized for a wrong PKCE verifier, got %v", err)
+	}
+
+	// The provider rotates its key; the new one is fetched for the next login.
+	idp.RotateKey(t)
+
+	if _, err = provider.Exchange(context.Background(), login(t, idp, provider, "nonce", "verifier"), "verifier"); err != nil {
+		t.Errorf("expected a token signed with a rotated key to verify, got %v", err)
+	}
+}
+
+func TestVerification(t *testing.T) {
+	idp := oidctest.New(t, "notes", "")
+	idp.Subject = "248289761001"
+	provider := newProvider(t, idp)
+
+	for name, tamper := range map[string]func(claims map[string]interface{}){
+		"issuer":   func(claims map[string]interface{}) { claims["iss"] = "https://evil.example.com" },
+		"audience": func(claims map[string]interface{}) { claims["aud"] = "another client" },
+		"azp":      func(claims map[string]interface{}) { claims["aud"] = []string{"notes", "another client"} },
+		"expired":  func(claims map[string]interface{}) { claims["exp"] = time.Now().Add(-time.Hour).Unix() },
+		"future":   func(claims map[string]interface{}) { claims["iat"] = time.Now().Add(time.Hour).Unix() },
+		"subject":  func(claims map[string]interface{}) { delete(claims, "sub") },
+	} {
+		idp.Tamper = tamper
+		_, err := provider.Exchange(context.Background(), login(t, idp, provider, "nonce", "verifier"), "verifier")
+
+		if !errors.Is(err, service.ErrUnauthorized) {
+			t.Errorf("%s: expected ErrUnauthorized, got %v", name, err)
+		}
+	}
+
+	idp.Tamper = func(claims map[string]interface{}) {
+		claims["aud"] = []string{"notes", "another client"}
+		claims["azp"] = "notes"
+		claims["email_verified"] = "true"
+	}
+	identity, err := provider.Exchange(context.Background(), login(t, idp, provider, "nonce", "verifier"), "verifier")
+
+	if err != nil || !identity.EmailVerified {
+		t.Errorf("unexpected identity %+v, %v", identity, err)
+	}
+}
+
+func TestDiscovery(t *testing.T) {
+	idp := oidctest.New(t, "notes", "")
+	config := oidc.Config{Issuer: idp.URL + "/", ClientID: "notes", RedirectURL: redirectURL}
+
+	if _, err := oidc.New(context.Background(), config, idp.Client()); err == nil {
+		t.Error("expected an error for an issuer that isn't the one of the discovery document")
+	}
+
+	config.Issuer = idp.URL + "/tenant"
+
+	if _, err := oidc.New(context.Background(), config, idp.Client()); err == nil {
+		t.Error("expected an error for an issuer without a discovery document")
+	}
+}
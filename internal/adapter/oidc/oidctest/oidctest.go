@@ -0,0 +1,222 @@
+// Package oidctest is a stand-in OpenID Connect identity provider for
+// tests. It logs in whoever it is told to without asking, but checks the
+// client, the redirect URL and PKCE like a real one.
+package oidctest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Provider is the identity provider. Its fields say who logs in next.
+type Provider struct {
+	*httptest.Server
+	ClientID      string
+	ClientSecret  string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	// Refuse makes the next logins fail with this error, like
+	// access_denied when the user says no.
+	Refuse string
+	// Tamper changes the claims of the next ID tokens.
+	Tamper func(claims map[string]interface{})
+
+	mu    sync.Mutex
+	key   *rsa.PrivateKey
+	kid   int
+	codes map[string]grant
+}
+
+// grant is an issued code and what redeeming it needs.
+type grant struct {
+	redirectURI string
+	challenge   string
+	claims      map[string]interface{}
+}
+
+// New starts a provider for one client. A public client has no secret.
+func New(t *testing.T, clientID, clientSecret string) *Provider {
+	t.Helper()
+
+	p := &Provider{ClientID: clientID, ClientSecret: clientSecret, EmailVerified: true, codes: map[string]grant{}}
+	p.RotateKey(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/jwks", p.jwks)
+	mux.HandleFunc("/authorize", p.authorize)
+	mux.HandleFunc("/token", p.token)
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Server.Close)
+
+	return p
+}
+
+// RotateKey signs the next ID tokens with a new key, which the JWKS lists
+// instead of the old one.
+func (p *Provider) RotateKey(t *testing.T) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+
+	if err != nil {
+		t.Fatalf("can't generate a key: %s", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.key = key
+	p.kid++
+}
+
+func (p *Provider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                p.URL,
+		"authorization_endpoint":                p.URL + "/authorize",
+		"token_endpoint":                        p.URL + "/token",
+		"jwks_uri":                              p.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (p *Provider) jwks(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"keys": []map[string]string{{
+		"kty": "RSA",
+		"use": "sig",
+		"alg": "RS256",
+		"kid": strconv.Itoa(p.kid),
+		"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+	}}})
+}
+
+// authorize logs the user in at once and sends them back with a code.
+func (p *Provider) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	redirectURI, err := url.Parse(q.Get("redirect_uri"))
+
+	if err != nil || q.Get("client_id") != p.ClientID || q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "bad authorization request", http.StatusBadRequest)
+
+		return
+	}
+
+	back := url.Values{"state": {q.Get("state")}}
+
+	p.mu.Lock()
+
+	if p.Refuse != "" {
+		back.Set("error", p.Refuse)
+	} else {
+		code := random()
+		p.codes[code] = grant{redirectURI: redirectURI.String(), challenge: q.Get("code_challenge"), claims: p.claims(q.Get("nonce"))}
+		back.Set("code", code)
+	}
+
+	p.mu.Unlock()
+
+	redirectURI.RawQuery = back.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func (p *Provider) claims(nonce string) map[string]interface{} {
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss":            p.URL,
+		"sub":            p.Subject,
+		"aud":            p.ClientID,
+		"exp":            now.Add(time.Hour).Unix(),
+		"iat":            now.Unix(),
+		"nonce":          nonce,
+		"email":          p.Email,
+		"email_verified": p.EmailVerified,
+	}
+
+	if p.Tamper != nil {
+		p.Tamper(claims)
+	}
+
+	return claims
+}
+
+// token redeems a code once, for the client it was issued to.
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	clientID, secret, basic := r.BasicAuth()
+
+	if !basic {
+		clientID = r.PostFormValue("client_id")
+	}
+
+	if clientID != p.ClientID || secret != p.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	code := r.PostFormValue("code")
+	g, in := p.codes[code]
+	delete(p.codes, code)
+	sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+
+	if !in || r.PostFormValue("grant_type") != "authorization_code" || r.PostFormValue("redirect_uri") != g.redirectURI ||
+		base64.RawURLEncoding.EncodeToString(sum[:]) != g.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": random(),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     p.sign(g.claims),
+	})
+}
+
+// sign makes an RS256 JWT with the current key.
+func (p *Provider) sign(claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": strconv.Itoa(p.kid)})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sum := sha256.Sum256([]byte(signed))
+	signature, _ := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, sum[:])
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func random() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"note/internal/service"
+)
+
+// sharedRoles are the roles of a share that grant a role; owners don't get
+// theirs from shares.
+var sharedRoles = map[string][]interface{}{
+	service.RoleEditor: {service.RoleEditor},
+	service.RoleViewer: {service.RoleEditor, service.RoleViewer},
+}
+
+// permitted limits a query on notes to those the user acting in ctx has the
+// role of ctx on: their own notes, and notes shared with them or with a team
+// of theirs for editors and viewers. Columns are qualified, so the query may
+// join other tables. A system context sees every note and a context with
+// neither a user nor the system mark sees none.
+func permitted(ctx context.Context, query string, args []interface{}) (string, []interface{}) {
+	user, ok := service.UserFrom(ctx)
+
+	if !ok {
+		return unowned(ctx, query, args)
+	}
+
+	roles := sharedRoles[service.RoleFrom(ctx)]
+
+	if len(roles) == 0 {
+		return query + " AND note.owner_id=?", append(args, user.ID)
+	}
+
+	query += ` AND (note.owner_id=? OR note.id IN (SELECT note_id FROM note_share WHERE role IN (` + placeholders(len(roles)) + `)
+		AND (user_id=? OR team_id IN (SELECT team_id FROM team_member WHERE user_id=?))))`
+	args = append(append(args, user.ID), roles...)
+
+	return query, append(args, user.ID, user.ID)
+}
+
+// unowned limits a query made without a user: the system sees every row,
+// anyone else none.
+func unowned(ctx context.Context, query string, args []interface{}) (string, []interface{}) {
+	if service.IsSystem(ctx) {
+		return query, args
+	}
+
+	return query + " AND 1=0", args
+}
+
+// denied tells why a live note wasn't found with the role of ctx: the user
+// may still see it, and then lacks the role, or it isn't there for them.
+func (ns *noteStorage) denied(ctx context.Context, q dbtx, id string) error {
+	if _, ok := service.UserFrom(ctx); !ok || service.RoleFrom(ctx) == service.RoleViewer {
+		return noteNotFound(id)
+	}
+
+	var visible int
+	query, args := permitted(service.WithRole(ctx, service.RoleViewer), `SELECT COUNT(*) FROM note WHERE id=? AND deleted_at IS NULL`, []interface{}{id})
+
+	if err := q.QueryRowContext(ctx, ns.dialect.rebind(query), args...).Scan(&visible); err != nil {
+		return err
+	}
+
+	if visible == 0 {
+		return noteNotFound(id)
+	}
+
+	return fmt.Errorf("%w: you may not change note %s", service.ErrForbidden, id)
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"note/internal/models"
+	"note/internal/service"
+)
+
+// errBatchFailed rolls back an atomic batch after an operation failed.
+var errBatchFailed = errors.New("batch operation failed")
+
+func (ns *noteStorage) Batch(ctx context.Context, operations []service.NoteOperation, atomic bool) ([]service.OperationResult, error) {
+	results := make([]service.OperationResult, len(operations))
+
+	err := ns.inTx(ctx, func(tx *sql.Tx) error {
+		for i, op := range operations {
+			if atomic {
+				results[i].Note, results[i].Err = ns.apply(ctx, tx, op)
+
+				if results[i].Err != nil {
+					return errBatchFailed
+				}
+
+				continue
+			}
+
+			var err error
+
+			// A failed statement spoils the whole transaction in PostgreSQL,
+			// so every operation gets a savepoint to go back to.
+			if results[i], err = ns.applySavepoint(ctx, tx, op); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if errors.Is(err, errBatchFailed) {
+		return aborted(results), nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+func (ns *noteStorage) apply(ctx context.Context, tx *sql.Tx, op service.NoteOperation) (*models.Note, error) {
+	ctx = service.WithRole(ctx, op.Role)
+
+	switch op.Op {
+	case service.BatchCreate:
+		return ns.create(ctx, tx, op.Fields)
+	case service.BatchUpdate:
+		return nil, ns.update(ctx, tx, op.ID, op.Fields)
+	}
+
+	return nil, ns.trash(ctx, tx, op.ID, op.Fields.IfVersion)
+}
+
+// applySavepoint applies an operation and undoes it alone when it fails.
+// The error is for a transaction that can't go on.
+func (ns *noteStorage) applySavepoint(ctx context.Context, tx *sql.Tx, op service.NoteOperation) (service.OperationResult, error) {
+	result := service.OperationResult{}
+
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT batch_operation"); err != nil {
+		return result, err
+	}
+
+	result.Note, result.Err = ns.apply(ctx, tx, op)
+
+	if result.Err != nil {
+		if _, err := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT batch_operation"); err != nil {
+			return result, err
+		}
+	}
+
+	_, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT batch_operation")
+
+	return result, err
+}
+
+// aborted keeps the error of the operation that failed an atomic batch and
+// marks the others as not applied.
+func aborted(results []service.OperationResult) []service.OperationResult {
+	for i := range results {
+		results[i].Note = nil
+
+		if results[i].Err == nil {
+			results[i].Err = service.ErrBatchAborted
+		}
+	}
+
+	return results
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dialect captures the few places where the SQL backends disagree.
+// Queries are written once with ? placeholders and rebound per backend.
+type dialect struct {
+	name string
+	// numbered placeholders ($1, $2, ...) instead of ?
+	numbered bool
+	// INSERT ... RETURNING id instead of sql.Result.LastInsertId
+	returning bool
+}
+
+var (
+	mysqlDialect    = dialect{name: "mysql"}
+	sqliteDialect   = dialect{name: "sqlite"}
+	postgresDialect = dialect{name: "postgres", numbered: true, returning: true}
+)
+
+func (d dialect) rebind(query string) string {
+	if !d.numbered {
+		return query
+	}
+
+	b := strings.Builder{}
+	n := 0
+
+	for _, r := range query {
+		if r != '?' {
+			b.WriteRune(r)
+			continue
+		}
+
+		n++
+		b.WriteByte('$')
+		b.WriteString(strconv.Itoa(n))
+	}
+
+	return b.String()
+}
+
+// now is the timestamp stored for created_at/updated_at. MySQL DATETIME keeps
+// whole seconds only, so every backend stores the same precision in UTC.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
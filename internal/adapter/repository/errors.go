@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"note/internal/service"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+const (
+	mysqlDuplicateEntry = 1062
+	mysqlNoReferenced   = 1452
+	pqUniqueViolation   = "23505"
+	pqForeignKey        = "23503"
+)
+
+func noteNotFound(id string) error {
+	return fmt.Errorf("note %s %w", id, service.ErrNotFound)
+}
+
+func revisionNotFound(id string, rev int) error {
+	return fmt.Errorf("revision %d of note %s %w", rev, id, service.ErrNotFound)
+}
+
+func notebookNotFound(id string) error {
+	return fmt.Errorf("notebook %s %w", id, service.ErrNotFound)
+}
+
+func shareNotFound(noteID string, shareID int64) error {
+	return fmt.Errorf("share %d of note %s %w", shareID, noteID, service.ErrNotFound)
+}
+
+func linkNotFound(noteID string, linkID int64) error {
+	return fmt.Errorf("link %d of note %s %w", linkID, noteID, service.ErrNotFound)
+}
+
+func tokenNotFound(id int64) error {
+	return fmt.Errorf("access token %d %w", id, service.ErrNotFound)
+}
+
+func identityNotFound(issuer, subject string) error {
+	return fmt.Errorf("identity %s of %s %w", subject, issuer, service.ErrNotFound)
+}
+
+func teamNotFound(id int64) error {
+	return fmt.Errorf("team %d %w", id, service.ErrNotFound)
+}
+
+// checkID rejects ids that can't match any row before they reach the
+// database, so every backend reports them the same way.
+func checkID(id string) error {
+	if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+		return noteNotFound(id)
+	}
+
+	return nil
+}
+
+// mapError translates driver errors into service domain errors. A foreign
+// key violation means the referenced notebook was removed concurrently.
+func (d dialect) mapError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var (
+		mysqlErr *mysql.MySQLError
+		pqErr    *pq.Error
+	)
+
+	switch {
+	case errors.As(err, &mysqlErr) && (mysqlErr.Number == mysqlDuplicateEntry || mysqlErr.Number == mysqlNoReferenced),
+		errors.As(err, &pqErr) && (pqErr.Code == pqUniqueViolation || pqErr.Code == pqForeignKey),
+		// go-sqlite3 error codes are only available with cgo.
+		d.name == sqliteDialect.name && strings.Contains(err.Error(), "UNIQUE constraint failed"),
+		d.name == sqliteDialect.name && strings.Contains(err.Error(), "FOREIGN KEY constraint failed"):
+		return fmt.Errorf("%w: %s", service.ErrConflict, err)
+	}
+
+	return err
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"note/internal/models"
+	"note/internal/service"
+	"time"
+)
+
+func (ns *noteStorage) IdentityUser(ctx context.Context, issuer, subject string) (*models.User, error) {
+	user := &models.User{}
+	query := `SELECT users.id, users.email, users.password_hash, users.created_at FROM user_identity
+		JOIN users ON users.id = user_identity.user_id WHERE user_identity.issuer=? AND user_identity.subject=?`
+	err := ns.db.QueryRowContext(ctx, ns.dialect.rebind(query), issuer, subject).Scan(userFields(user)...)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, identityNotFound(issuer, subject)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("can't scan row: %w", err)
+	}
+
+	return user, nil
+}
+
+func (ns *noteStorage) CreateIdentity(ctx context.Context, issuer, subject string, userID int64) error {
+	query := `INSERT INTO user_identity (user_id, issuer, subject, created_at) VALUES (?, ?, ?, ?)`
+	_, err := ns.insert(ctx, ns.db, query, userID, issuer, subject, now())
+
+	if errors.Is(err, service.ErrConflict) {
+		return fmt.Errorf("%w: identity %s of %s belongs to a user already", service.ErrConflict, subject, issuer)
+	}
+
+	return err
+}
+
+func (ns *noteStorage) CreateLoginState(ctx context.Context, login service.LoginState) error {
+	return ns.inTx(ctx, func(tx *sql.Tx) error {
+		// Logins that were never finished are dropped here, like expired
+		// sessions in CreateSession.
+		if _, err := tx.ExecContext(ctx, ns.dialect.rebind(`DELETE FROM login_state WHERE expires_at < ?`), now()); err != nil {
+			return err
+		}
+
+		query := `INSERT INTO login_state (state_hash, nonce, code_verifier, expires_at) VALUES (?, ?, ?, ?)`
+		_, err := tx.ExecContext(ctx, ns.dialect.rebind(query), login.StateHash, login.Nonce, login.CodeVerifier, login.ExpiresAt.UTC())
+
+		return ns.dialect.mapError(err)
+	})
+}
+
+func (ns *noteStorage) TakeLoginState(ctx context.Context, stateHash string, t time.Time) (*service.LoginState, error) {
+	login := &service.LoginState{}
+
+	err := ns.inTx(ctx, func(tx *sql.Tx) error {
+		query := `SELECT state_hash, nonce, code_verifier, expires_at FROM login_state WHERE state_hash=? AND expires_at > ?`
+		err := tx.QueryRowContext(ctx, ns.dialect.rebind(query), stateHash, t.UTC()).Scan(&login.StateHash, &login.Nonce, &login.CodeVerifier, &login.ExpiresAt)
+
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("login %w", service.ErrNotFound)
+		}
+
+		if err != nil {
+			return fmt.Errorf("can't scan row: %w", err)
+		}
+
+		// Only the request that deletes the state may use it.
+		result, err := tx.ExecContext(ctx, ns.dialect.rebind(`DELETE FROM login_state WHERE state_hash=?`), stateHash)
+
+		if err != nil {
+			return err
+		}
+
+		if row, _ := result.RowsAffected(); row == 0 {
+			return fmt.Errorf("login %w", service.ErrNotFound)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return login, nil
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"note/internal/models"
+	"note/internal/service"
+	"strconv"
+)
+
+const linkColumns = "id, note_id, COALESCE(password_hash, ''), views, expires_at, created_at, revoked_at"
+
+func linkFields(link *models.Link) []interface{} {
+	return []interface{}{&link.ID, &link.NoteID, &link.PasswordHash, &link.Views, &link.ExpiresAt, &link.CreatedAt, &link.RevokedAt}
+}
+
+// nullable stores an empty string as NULL.
+func nullable(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+
+	return s
+}
+
+func (ns *noteStorage) CreateLink(ctx context.Context, fields service.LinkFields) (*models.Link, error) {
+	link := &models.Link{
+		PasswordHash: fields.PasswordHash, Protected: fields.PasswordHash != "", ExpiresAt: fields.ExpiresAt.UTC(), CreatedAt: now(),
+	}
+
+	err := ns.inTx(ctx, func(tx *sql.Tx) error {
+		if err := ns.checkLive(ctx, tx, fields.NoteID); err != nil {
+			return err
+		}
+
+		link.NoteID, _ = strconv.ParseInt(fields.NoteID, 10, 64)
+		query := `INSERT INTO note_link (note_id, token_hash, password_hash, expires_at, created_at) VALUES (?, ?, ?, ?, ?)`
+		id, err := ns.insert(ctx, tx, query, link.NoteID, fields.TokenHash, nullable(fields.PasswordHash), link.ExpiresAt, link.CreatedAt)
+		link.ID = id
+
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return link, nil
+}
+
+func (ns *noteStorage) Links(ctx context.Context, noteID string) ([]*models.Link, error) {
+	if err := ns.checkLive(ctx, ns.db, noteID); err != nil {
+		return nil, err
+	}
+
+	rows, err := ns.db.QueryContext(ctx, ns.dialect.rebind("SELECT "+linkColumns+" FROM note_link WHERE note_id=? ORDER BY id"), noteID)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []*models.Link{}
+
+	for rows.Next() {
+		link := &models.Link{}
+
+		if err = rows.Scan(linkFields(link)...); err != nil {
+			return nil, err
+		}
+
+		link.Protected = link.PasswordHash != ""
+		links = append(links, link)
+	}
+
+	return links, rows.Err()
+}
+
+func (ns *noteStorage) RevokeLink(ctx context.Context, noteID string, linkID int64) error {
+	return ns.inTx(ctx, func(tx *sql.Tx) error {
+		if err := ns.checkLive(ctx, tx, noteID); err != nil {
+			return err
+		}
+
+		query := `UPDATE note_link SET revoked_at=? WHERE id=? AND note_id=? AND revoked_at IS NULL`
+		result, err := tx.ExecContext(ctx, ns.dialect.rebind(query), now(), linkID, noteID)
+
+		if err != nil {
+			return err
+		}
+
+		if row, _ := result.RowsAffected(); row == 0 {
+			return linkNotFound(noteID, linkID)
+		}
+
+		return nil
+	})
+}
+
+func (ns *noteStorage) Link(ctx context.Context, tokenHash string) (*models.Link, error) {
+	link := &models.Link{}
+	err := ns.db.QueryRowContext(ctx, ns.dialect.rebind("SELECT "+linkColumns+" FROM note_link WHERE token_hash=?"), tokenHash).Scan(linkFields(link)...)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("link %w", service.ErrNotFound)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("can't scan row: %w", err)
+	}
+
+	link.Protected = link.PasswordHash != ""
+
+	return link, nil
+}
+
+func (ns *noteStorage) LinkedNote(ctx context.Context, linkID int64) (*models.Note, error) {
+	note := &models.Note{}
+	query := "SELECT " + noteColumns + " FROM note WHERE id=(SELECT note_id FROM note_link WHERE id=?) AND deleted_at IS NULL"
+	err := ns.db.QueryRowContext(ctx, ns.dialect.rebind(query), linkID).Scan(noteFields(note)...)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("link %w", service.ErrNotFound)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("can't scan row: %w", err)
+	}
+
+	if err = ns.loadTags(ctx, ns.db, []*models.Note{note}); err != nil {
+		return nil, err
+	}
+
+	return note, nil
+}
+
+func (ns *noteStorage) CountView(ctx context.Context, linkID int64) error {
+	_, err := ns.db.ExecContext(ctx, ns.dialect.rebind(`UPDATE note_link SET views=views+1 WHERE id=?`), linkID)
+
+	return err
+}
@@ -0,0 +1,742 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"note/internal/models"
+	"note/internal/service"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type memoryStorage struct {
+	mu             sync.RWMutex
+	lastID         int64
+	notes          map[int64]*models.Note
+	lastNotebookID int64
+	notebooks      map[int64]*models.Notebook
+	revisions      map[int64][]*models.Revision
+	// owners of notes and notebooks by id; 0 is no owner, like a NULL
+	// owner_id.
+	owners         map[int64]int64
+	notebookOwners map[int64]int64
+	lastUserID     int64
+	users          map[int64]*models.User
+	sessions       map[string]memorySession
+	lastShareID    int64
+	shares         map[int64]*models.Share
+	lastTeamID     int64
+	teams          map[int64]*models.Team
+	// members of teams by team id.
+	members     map[int64]map[int64]bool
+	lastLinkID  int64
+	links       map[int64]*memoryLink
+	lastTokenID int64
+	tokens      map[int64]*memoryToken
+	// identities maps external identities to user ids.
+	identities map[memoryIdentity]int64
+	logins     map[string]service.LoginState
+}
+
+func NewMemoryStorage() *memoryStorage {
+	return &memoryStorage{
+		notes:          make(map[int64]*models.Note),
+		notebooks:      make(map[int64]*models.Notebook),
+		revisions:      make(map[int64][]*models.Revision),
+		owners:         make(map[int64]int64),
+		notebookOwners: make(map[int64]int64),
+		users:          make(map[int64]*models.User),
+		sessions:       make(map[string]memorySession),
+		shares:         make(map[int64]*models.Share),
+		teams:          make(map[int64]*models.Team),
+		members:        make(map[int64]map[int64]bool),
+		links:          make(map[int64]*memoryLink),
+		tokens:         make(map[int64]*memoryToken),
+		identities:     make(map[memoryIdentity]int64),
+		logins:         make(map[string]service.LoginState),
+	}
+}
+
+func (ms *memoryStorage) Create(ctx context.Context, fields service.NoteFields) (*models.Note, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	return ms.create(ctx, fields)
+}
+
+func (ms *memoryStorage) create(ctx context.Context, fields service.NoteFields) (*models.Note, error) {
+	t := now()
+	ms.lastID++
+	note := newNote(fields, t)
+	note.ID = ms.lastID
+	note.Tags = sortedTags(fields.Tags)
+	note.NotebookID = ref(fields.NotebookID)
+
+	if note.NotebookID != nil && ms.notebooks[*note.NotebookID] == nil {
+		return nil, fmt.Errorf("%w: notebook %d does not exist", service.ErrConflict, *note.NotebookID)
+	}
+
+	ms.notes[note.ID] = note
+	ms.owners[note.ID] = ownerID(ctx)
+	ms.addRevision(note)
+
+	return clone(note), nil
+}
+
+func (ms *memoryStorage) Update(ctx context.Context, id string, fields service.NoteFields) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	return ms.update(ctx, id, fields)
+}
+
+func (ms *memoryStorage) update(ctx context.Context, id string, fields service.NoteFields) error {
+	note, err := ms.lookupVersion(ctx, id, fields.IfVersion)
+
+	if err != nil {
+		return err
+	}
+
+	note.Version++
+
+	note.Text = fields.Text
+	note.UpdatedAt = now()
+
+	if fields.Tags != nil {
+		note.Tags = sortedTags(fields.Tags)
+	}
+
+	if fields.Title != nil {
+		note.Title = *fields.Title
+	}
+
+	if fields.Summary != nil {
+		note.Summary = *fields.Summary
+	}
+
+	if fields.Color != nil {
+		note.Color = *fields.Color
+	}
+
+	if fields.Pinned != nil {
+		note.Pinned = *fields.Pinned
+	}
+
+	if fields.Archived != nil {
+		note.Archived = *fields.Archived
+	}
+
+	ms.addRevision(note)
+
+	return nil
+}
+
+func (ms *memoryStorage) Delete(ctx context.Context, id string, version int64) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	return ms.trash(ctx, id, version)
+}
+
+func (ms *memoryStorage) trash(ctx context.Context, id string, version int64) error {
+	note, err := ms.lookupVersion(ctx, id, version)
+
+	if err != nil {
+		return err
+	}
+
+	t := now()
+	note.DeletedAt = &t
+	note.Version++
+
+	return nil
+}
+
+func (ms *memoryStorage) Get(ctx context.Context, id string) (*models.Note, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	note, err := ms.lookup(ctx, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return clone(note), nil
+}
+
+func (ms *memoryStorage) GetAll(ctx context.Context, q service.NotesQuery) ([]*models.Note, error) {
+	order, err := checkSort(q.Sort)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Same order as SQL: field by field, the first difference decides.
+	before := func(a, b *models.Note) bool {
+		for _, f := range order {
+			x, y := a, b
+
+			if f.Desc {
+				x, y = b, a
+			}
+
+			switch less := noteLess[f.Field]; {
+			case less(x, y):
+				return true
+			case less(y, x):
+				return false
+			}
+		}
+
+		return false
+	}
+
+	ms.mu.RLock()
+	notes := make([]*models.Note, 0, len(ms.notes))
+
+	for _, note := range ms.notes {
+		if (note.DeletedAt != nil) != q.Trashed || !ms.permits(ctx, note.ID) {
+			continue
+		}
+
+		if q.After != nil && !before(q.After, note) {
+			continue
+		}
+
+		if len(q.Tags) > 0 && !hasTags(note, q.Tags, q.AnyTag) {
+			continue
+		}
+
+		if len(q.Notebooks) > 0 && !inNotebooks(note, q.Notebooks) {
+			continue
+		}
+
+		if !matchesMeta(note, q) {
+			continue
+		}
+
+		notes = append(notes, clone(note))
+	}
+	ms.mu.RUnlock()
+
+	sort.Slice(notes, func(i, j int) bool { return before(notes[i], notes[j]) })
+
+	if q.Limit > 0 && len(notes) > q.Limit {
+		notes = notes[:q.Limit]
+	}
+
+	return notes, nil
+}
+
+func (ms *memoryStorage) Search(ctx context.Context, q service.SearchQuery) ([]*service.SearchResult, error) {
+	results := []*service.SearchResult{}
+
+	ms.mu.RLock()
+	for _, note := range ms.notes {
+		if note.DeletedAt != nil || !ms.permits(ctx, note.ID) {
+			continue
+		}
+
+		if score := rank(note.Text, q.Terms); score > 0 {
+			results = append(results, &service.SearchResult{Note: clone(note), Rank: score})
+		}
+	}
+	ms.mu.RUnlock()
+
+	return bestFirst(results, q.Limit), nil
+}
+
+func (ms *memoryStorage) Tags(ctx context.Context) ([]*models.Tag, error) {
+	counts := map[string]int{}
+
+	ms.mu.RLock()
+	for _, note := range ms.notes {
+		if note.DeletedAt != nil || !ms.permits(ctx, note.ID) {
+			continue
+		}
+
+		for _, tag := range note.Tags {
+			counts[tag]++
+		}
+	}
+	ms.mu.RUnlock()
+
+	tags := make([]*models.Tag, 0, len(counts))
+
+	for name, count := range counts {
+		tags = append(tags, &models.Tag{Name: name, Count: count})
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+
+		return tags[i].Name < tags[j].Name
+	})
+
+	return tags, nil
+}
+
+func (ms *memoryStorage) CreateNotebook(ctx context.Context, fields service.NotebookFields) (*models.Notebook, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if fields.ParentID != nil && ms.notebooks[*fields.ParentID] == nil {
+		return nil, fmt.Errorf("%w: notebook %d does not exist", service.ErrConflict, *fields.ParentID)
+	}
+
+	t := now()
+	ms.lastNotebookID++
+	notebook := &models.Notebook{ID: ms.lastNotebookID, Name: fields.Name, ParentID: ref(fields.ParentID), CreatedAt: t, UpdatedAt: t}
+	ms.notebooks[notebook.ID] = notebook
+	ms.notebookOwners[notebook.ID] = ownerID(ctx)
+
+	return cloneNotebook(notebook), nil
+}
+
+func (ms *memoryStorage) GetNotebook(ctx context.Context, id string) (*models.Notebook, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	notebook, err := ms.lookupNotebook(ctx, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cloneNotebook(notebook), nil
+}
+
+func (ms *memoryStorage) UpdateNotebook(ctx context.Context, id string, fields service.NotebookFields) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	notebook, err := ms.lookupNotebook(ctx, id)
+
+	if err != nil {
+		return err
+	}
+
+	for parent := fields.ParentID; parent != nil; parent = ms.notebooks[*parent].ParentID {
+		if *parent == notebook.ID {
+			return fmt.Errorf("%w: notebook %s can't be moved into itself or its descendants", service.ErrConflict, id)
+		}
+
+		if ms.notebooks[*parent] == nil || !visible(ctx, ms.notebookOwners[*parent]) {
+			return notebookNotFound(fmt.Sprint(*parent))
+		}
+	}
+
+	notebook.Name = fields.Name
+	notebook.ParentID = ref(fields.ParentID)
+	notebook.UpdatedAt = now()
+
+	return nil
+}
+
+func (ms *memoryStorage) DeleteNotebook(ctx context.Context, id string, recursive bool) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	notebook, err := ms.lookupNotebook(ctx, id)
+
+	if err != nil {
+		return err
+	}
+
+	ids := []int64{notebook.ID}
+
+	for i := 0; i < len(ids); i++ {
+		for _, child := range ms.notebooks {
+			if child.ParentID != nil && *child.ParentID == ids[i] {
+				ids = append(ids, child.ID)
+			}
+		}
+	}
+
+	notes := []int64{}
+
+	for _, note := range ms.notes {
+		if note.DeletedAt == nil && inNotebooks(note, ids) {
+			notes = append(notes, note.ID)
+		}
+	}
+
+	if !recursive && len(ids)+len(notes) > 1 {
+		return fmt.Errorf("%w: notebook %s is not empty", service.ErrConflict, id)
+	}
+
+	for _, note := range ms.notes {
+		if note.DeletedAt != nil && inNotebooks(note, ids) {
+			note.NotebookID = nil
+		}
+	}
+
+	t := now()
+
+	for _, noteID := range notes {
+		note := ms.notes[noteID]
+		note.DeletedAt = &t
+		note.NotebookID = nil
+		note.Version++
+	}
+
+	for _, notebookID := range ids {
+		delete(ms.notebooks, notebookID)
+		delete(ms.notebookOwners, notebookID)
+	}
+
+	return nil
+}
+
+func (ms *memoryStorage) Notebooks(ctx context.Context) ([]*models.Notebook, error) {
+	ms.mu.RLock()
+	notebooks := make([]*models.Notebook, 0, len(ms.notebooks))
+
+	for _, notebook := range ms.notebooks {
+		if visible(ctx, ms.notebookOwners[notebook.ID]) {
+			notebooks = append(notebooks, cloneNotebook(notebook))
+		}
+	}
+	ms.mu.RUnlock()
+
+	sort.Slice(notebooks, func(i, j int) bool {
+		if notebooks[i].Name != notebooks[j].Name {
+			return notebooks[i].Name < notebooks[j].Name
+		}
+
+		return notebooks[i].ID < notebooks[j].ID
+	})
+
+	return notebooks, nil
+}
+
+func (ms *memoryStorage) MoveNote(ctx context.Context, id string, notebookID *int64) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	note, err := ms.lookup(ctx, id)
+
+	if err != nil {
+		return err
+	}
+
+	if notebookID != nil && ms.notebooks[*notebookID] == nil {
+		return fmt.Errorf("%w: notebook %d does not exist", service.ErrConflict, *notebookID)
+	}
+
+	note.NotebookID = ref(notebookID)
+	note.UpdatedAt = now()
+	note.Version++
+
+	return nil
+}
+
+func (ms *memoryStorage) Restore(ctx context.Context, id string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	note, err := ms.lookupTrashed(ctx, id)
+
+	if err != nil {
+		return err
+	}
+
+	note.DeletedAt = nil
+	note.Version++
+
+	return nil
+}
+
+func (ms *memoryStorage) Purge(ctx context.Context, id string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	note, err := ms.lookupTrashed(ctx, id)
+
+	if err != nil {
+		return err
+	}
+
+	ms.remove(note.ID)
+
+	return nil
+}
+
+func (ms *memoryStorage) PurgeTrashed(ctx context.Context, before time.Time) (int64, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	var purged int64
+
+	for id, note := range ms.notes {
+		if note.DeletedAt != nil && note.DeletedAt.Before(before) {
+			ms.remove(id)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+func (ms *memoryStorage) Revisions(ctx context.Context, id string) ([]*models.Revision, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	note, err := ms.lookup(ctx, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	stored := ms.revisions[note.ID]
+	revisions := make([]*models.Revision, 0, len(stored))
+
+	for i := len(stored) - 1; i >= 0; i-- {
+		revision := *stored[i]
+		revisions = append(revisions, &revision)
+	}
+
+	return revisions, nil
+}
+
+func (ms *memoryStorage) Revision(ctx context.Context, id string, rev int) (*models.Revision, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	note, err := ms.lookup(ctx, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	stored := ms.revisions[note.ID]
+
+	if rev < 1 || rev > len(stored) {
+		return nil, revisionNotFound(id, rev)
+	}
+
+	revision := *stored[rev-1]
+
+	return &revision, nil
+}
+
+// addRevision mirrors noteStorage.addRevision.
+func (ms *memoryStorage) addRevision(note *models.Note) {
+	ms.revisions[note.ID] = append(ms.revisions[note.ID], &models.Revision{
+		NoteID:    note.ID,
+		Revision:  len(ms.revisions[note.ID]) + 1,
+		Title:     note.Title,
+		Text:      note.Text,
+		Summary:   note.Summary,
+		CreatedAt: note.UpdatedAt,
+	})
+}
+
+// remove deletes a note for good, with its history, shares and links.
+func (ms *memoryStorage) remove(id int64) {
+	delete(ms.notes, id)
+	delete(ms.revisions, id)
+	delete(ms.owners, id)
+
+	for shareID, share := range ms.shares {
+		if share.NoteID == id {
+			delete(ms.shares, shareID)
+		}
+	}
+
+	for linkID, link := range ms.links {
+		if link.NoteID == id {
+			delete(ms.links, linkID)
+		}
+	}
+}
+
+func (ms *memoryStorage) lookupNotebook(ctx context.Context, id string) (*models.Notebook, error) {
+	key, err := parseNotebookID(id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	notebook, in := ms.notebooks[key]
+
+	if !in || !visible(ctx, ms.notebookOwners[key]) {
+		return nil, notebookNotFound(id)
+	}
+
+	return notebook, nil
+}
+
+// lookup finds a note that isn't in the trash.
+func (ms *memoryStorage) lookup(ctx context.Context, id string) (*models.Note, error) {
+	note, err := ms.find(ctx, id)
+
+	if err != nil || note.DeletedAt == nil {
+		return note, err
+	}
+
+	return nil, noteNotFound(id)
+}
+
+// lookupVersion mirrors ifVersion.
+func (ms *memoryStorage) lookupVersion(ctx context.Context, id string, version int64) (*models.Note, error) {
+	note, err := ms.lookup(ctx, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if version != 0 && note.Version != version {
+		return nil, fmt.Errorf("%w: note %s is no longer at version %d", service.ErrPreconditionFailed, id, version)
+	}
+
+	return note, nil
+}
+
+func (ms *memoryStorage) lookupTrashed(ctx context.Context, id string) (*models.Note, error) {
+	note, err := ms.find(ctx, id)
+
+	if err != nil || note.DeletedAt != nil {
+		return note, err
+	}
+
+	return nil, noteNotFound(id)
+}
+
+func (ms *memoryStorage) find(ctx context.Context, id string) (*models.Note, error) {
+	key, err := strconv.ParseInt(id, 10, 64)
+
+	if err != nil {
+		return nil, noteNotFound(id)
+	}
+
+	note, in := ms.notes[key]
+
+	if !in {
+		return nil, noteNotFound(id)
+	}
+
+	if !ms.permits(ctx, key) {
+		return nil, ms.denied(ctx, note)
+	}
+
+	return note, nil
+}
+
+// visible mirrors owned: only a system context sees every owner without a
+// user. Notes use permits.
+func visible(ctx context.Context, owner int64) bool {
+	user, ok := service.UserFrom(ctx)
+
+	if !ok {
+		return service.IsSystem(ctx)
+	}
+
+	return user.ID == owner
+}
+
+// ownerID is the owner of a note or notebook created in ctx.
+func ownerID(ctx context.Context) int64 {
+	if user, ok := service.UserFrom(ctx); ok {
+		return user.ID
+	}
+
+	return 0
+}
+
+// noteLess mirrors the columns noteStorage.GetAll can be ordered by.
+var noteLess = map[string]func(a, b *models.Note) bool{
+	"id":         func(a, b *models.Note) bool { return a.ID < b.ID },
+	"text":       func(a, b *models.Note) bool { return service.SortText(a.Text) < service.SortText(b.Text) },
+	"created_at": func(a, b *models.Note) bool { return a.CreatedAt.Before(b.CreatedAt) },
+	"updated_at": func(a, b *models.Note) bool { return a.UpdatedAt.Before(b.UpdatedAt) },
+}
+
+// clone copies a stored note so callers can't change it through the result.
+func clone(note *models.Note) *models.Note {
+	cp := *note
+	cp.Tags = append([]string{}, note.Tags...)
+	cp.NotebookID = ref(note.NotebookID)
+
+	if note.DeletedAt != nil {
+		deletedAt := *note.DeletedAt
+		cp.DeletedAt = &deletedAt
+	}
+
+	return &cp
+}
+
+func cloneNotebook(notebook *models.Notebook) *models.Notebook {
+	cp := *notebook
+	cp.ParentID = ref(notebook.ParentID)
+
+	return &cp
+}
+
+// ref copies a nullable id so stored values never alias the caller's.
+func ref(id *int64) *int64 {
+	if id == nil {
+		return nil
+	}
+
+	cp := *id
+
+	return &cp
+}
+
+func inNotebooks(note *models.Note, ids []int64) bool {
+	if note.NotebookID == nil {
+		return false
+	}
+
+	for _, id := range ids {
+		if *note.NotebookID == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sortedTags(tags []string) []string {
+	sorted := append([]string{}, tags...)
+	sort.Strings(sorted)
+
+	return sorted
+}
+
+func hasTags(note *models.Note, tags []string, anyTag bool) bool {
+	found := 0
+
+	for _, tag := range tags {
+		for _, own := range note.Tags {
+			if own == tag {
+				found++
+				break
+			}
+		}
+	}
+
+	if anyTag {
+		return found > 0
+	}
+
+	return found == len(tags)
+}
+
+// matchesMeta mirrors metaFilter.
+func matchesMeta(note *models.Note, q service.NotesQuery) bool {
+	switch {
+	case q.Pinned != nil && note.Pinned != *q.Pinned,
+		q.Archived != nil && note.Archived != *q.Archived,
+		q.Color != "" && note.Color != q.Color:
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"note/internal/models"
+	"note/internal/service"
+)
+
+// Batch holds the lock for the whole batch. Memory operations fail before
+// they change anything, so only an atomic batch needs undoing, from a copy
+// of the notes taken before it.
+func (ms *memoryStorage) Batch(ctx context.Context, operations []service.NoteOperation, atomic bool) ([]service.OperationResult, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	var (
+		lastID    = ms.lastID
+		notes     map[int64]*models.Note
+		revisions map[int64][]*models.Revision
+		owners    map[int64]int64
+	)
+
+	if atomic {
+		notes, revisions, owners = ms.copyNotes(), ms.copyRevisions(), ms.copyOwners()
+	}
+
+	results := make([]service.OperationResult, len(operations))
+
+	for i, op := range operations {
+		results[i].Note, results[i].Err = ms.apply(ctx, op)
+
+		if results[i].Err != nil && atomic {
+			ms.lastID, ms.notes, ms.revisions, ms.owners = lastID, notes, revisions, owners
+
+			return aborted(results), nil
+		}
+	}
+
+	return results, nil
+}
+
+func (ms *memoryStorage) apply(ctx context.Context, op service.NoteOperation) (*models.Note, error) {
+	ctx = service.WithRole(ctx, op.Role)
+
+	switch op.Op {
+	case service.BatchCreate:
+		return ms.create(ctx, op.Fields)
+	case service.BatchUpdate:
+		return nil, ms.update(ctx, op.ID, op.Fields)
+	}
+
+	return nil, ms.trash(ctx, op.ID, op.Fields.IfVersion)
+}
+
+func (ms *memoryStorage) copyNotes() map[int64]*models.Note {
+	notes := make(map[int64]*models.Note, len(ms.notes))
+
+	for id, note := range ms.notes {
+		notes[id] = clone(note)
+	}
+
+	return notes
+}
+
+// copyRevisions copies the lists only: revisions never change once added.
+func (ms *memoryStorage) copyRevisions() map[int64][]*models.Revision {
+	revisions := make(map[int64][]*models.Revision, len(ms.revisions))
+
+	for id, list := range ms.revisions {
+		revisions[id] = append([]*models.Revision(nil), list...)
+	}
+
+	return revisions
+}
+
+func (ms *memoryStorage) copyOwners() map[int64]int64 {
+	owners := make(map[int64]int64, len(ms.owners))
+
+	for id, owner := range ms.owners {
+		owners[id] = owner
+	}
+
+	return owners
+}
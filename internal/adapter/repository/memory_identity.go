@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"note/internal/models"
+	"note/internal/service"
+	"time"
+)
+
+// memoryIdentity is an external identity; identities map them to users.
+type memoryIdentity struct {
+	issuer  string
+	subject string
+}
+
+func (ms *memoryStorage) IdentityUser(ctx context.Context, issuer, subject string) (*models.User, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	userID, in := ms.identities[memoryIdentity{issuer: issuer, subject: subject}]
+
+	if !in {
+		return nil, identityNotFound(issuer, subject)
+	}
+
+	cp := *ms.users[userID]
+
+	return &cp, nil
+}
+
+func (ms *memoryStorage) CreateIdentity(ctx context.Context, issuer, subject string, userID int64) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	identity := memoryIdentity{issuer: issuer, subject: subject}
+
+	if _, in := ms.identities[identity]; in {
+		return fmt.Errorf("%w: identity %s of %s belongs to a user already", service.ErrConflict, subject, issuer)
+	}
+
+	if ms.users[userID] == nil {
+		return fmt.Errorf("%w: user %d does not exist", service.ErrConflict, userID)
+	}
+
+	ms.identities[identity] = userID
+
+	return nil
+}
+
+func (ms *memoryStorage) CreateLoginState(ctx context.Context, login service.LoginState) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if _, in := ms.logins[login.StateHash]; in {
+		return fmt.Errorf("%w: the login exists", service.ErrConflict)
+	}
+
+	t := now()
+
+	for hash, other := range ms.logins {
+		if other.ExpiresAt.Before(t) {
+			delete(ms.logins, hash)
+		}
+	}
+
+	login.ExpiresAt = login.ExpiresAt.UTC()
+	ms.logins[login.StateHash] = login
+
+	return nil
+}
+
+func (ms *memoryStorage) TakeLoginState(ctx context.Context, stateHash string, t time.Time) (*service.LoginState, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	login, in := ms.logins[stateHash]
+
+	if !in || !login.ExpiresAt.After(t) {
+		return nil, fmt.Errorf("login %w", service.ErrNotFound)
+	}
+
+	delete(ms.logins, stateHash)
+
+	return &login, nil
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"note/internal/models"
+	"note/internal/service"
+	"sort"
+)
+
+// memoryLink is a stored link with the hash of its token.
+type memoryLink struct {
+	models.Link
+	tokenHash string
+}
+
+func (ms *memoryStorage) CreateLink(ctx context.Context, fields service.LinkFields) (*models.Link, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	note, err := ms.lookup(ctx, fields.NoteID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, in := ms.linkByHash(fields.TokenHash); in {
+		return nil, fmt.Errorf("%w: the link exists", service.ErrConflict)
+	}
+
+	ms.lastLinkID++
+	link := &memoryLink{
+		Link: models.Link{
+			ID: ms.lastLinkID, NoteID: note.ID, PasswordHash: fields.PasswordHash, Protected: fields.PasswordHash != "",
+			ExpiresAt: fields.ExpiresAt.UTC(), CreatedAt: now(),
+		},
+		tokenHash: fields.TokenHash,
+	}
+	ms.links[link.ID] = link
+
+	return link.clone(), nil
+}
+
+func (ms *memoryStorage) Links(ctx context.Context, noteID string) ([]*models.Link, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	note, err := ms.lookup(ctx, noteID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	links := []*models.Link{}
+
+	for _, link := range ms.links {
+		if link.NoteID == note.ID {
+			links = append(links, link.clone())
+		}
+	}
+
+	sort.Slice(links, func(i, j int) bool { return links[i].ID < links[j].ID })
+
+	return links, nil
+}
+
+func (ms *memoryStorage) RevokeLink(ctx context.Context, noteID string, linkID int64) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	note, err := ms.lookup(ctx, noteID)
+
+	if err != nil {
+		return err
+	}
+
+	link, in := ms.links[linkID]
+
+	if !in || link.NoteID != note.ID || link.RevokedAt != nil {
+		return linkNotFound(noteID, linkID)
+	}
+
+	t := now()
+	link.RevokedAt = &t
+
+	return nil
+}
+
+func (ms *memoryStorage) Link(ctx context.Context, tokenHash string) (*models.Link, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	link, in := ms.linkByHash(tokenHash)
+
+	if !in {
+		return nil, fmt.Errorf("link %w", service.ErrNotFound)
+	}
+
+	return link.clone(), nil
+}
+
+func (ms *memoryStorage) LinkedNote(ctx context.Context, linkID int64) (*models.Note, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	link, in := ms.links[linkID]
+
+	if !in || ms.notes[link.NoteID] == nil || ms.notes[link.NoteID].DeletedAt != nil {
+		return nil, fmt.Errorf("link %w", service.ErrNotFound)
+	}
+
+	return clone(ms.notes[link.NoteID]), nil
+}
+
+func (ms *memoryStorage) CountView(ctx context.Context, linkID int64) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if link, in := ms.links[linkID]; in {
+		link.Views++
+	}
+
+	return nil
+}
+
+func (ms *memoryStorage) linkByHash(tokenHash string) (*memoryLink, bool) {
+	for _, link := range ms.links {
+		if link.tokenHash == tokenHash {
+			return link, true
+		}
+	}
+
+	return nil, false
+}
+
+func (link *memoryLink) clone() *models.Link {
+	cp := link.Link
+
+	if link.RevokedAt != nil {
+		revokedAt := *link.RevokedAt
+		cp.RevokedAt = &revokedAt
+	}
+
+	return &cp
+}
@@ -0,0 +1,254 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"note/internal/models"
+	"note/internal/service"
+	"sort"
+	"strconv"
+)
+
+// permits mirrors permitted.
+func (ms *memoryStorage) permits(ctx context.Context, noteID int64) bool {
+	user, ok := service.UserFrom(ctx)
+
+	if !ok {
+		return service.IsSystem(ctx)
+	}
+
+	return service.Grants(ms.role(user.ID, noteID), service.RoleFrom(ctx))
+}
+
+// role is the highest role a user has on a note, "" for none.
+func (ms *memoryStorage) role(userID, noteID int64) string {
+	if ms.owners[noteID] == userID {
+		return service.RoleOwner
+	}
+
+	role := ""
+
+	for _, share := range ms.shares {
+		mine := share.UserID != nil && *share.UserID == userID || share.TeamID != nil && ms.members[*share.TeamID][userID]
+
+		if share.NoteID == noteID && mine && (role == "" || service.Grants(share.Role, role)) {
+			role = share.Role
+		}
+	}
+
+	return role
+}
+
+// denied mirrors noteStorage.denied.
+func (ms *memoryStorage) denied(ctx context.Context, note *models.Note) error {
+	id := strconv.FormatInt(note.ID, 10)
+
+	if note.DeletedAt != nil || !ms.permits(service.WithRole(ctx, service.RoleViewer), note.ID) {
+		return noteNotFound(id)
+	}
+
+	if _, ok := service.UserFrom(ctx); !ok || service.RoleFrom(ctx) == service.RoleViewer {
+		return noteNotFound(id)
+	}
+
+	return fmt.Errorf("%w: you may not change note %s", service.ErrForbidden, id)
+}
+
+func (ms *memoryStorage) CreateShare(ctx context.Context, fields service.ShareFields) (*models.Share, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	note, err := ms.lookup(ctx, fields.NoteID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, share := range ms.shares {
+		sameUser := share.UserID != nil && fields.UserID != nil && *share.UserID == *fields.UserID
+		sameTeam := share.TeamID != nil && fields.TeamID != nil && *share.TeamID == *fields.TeamID
+
+		if share.NoteID == note.ID && (sameUser || sameTeam) {
+			return nil, fmt.Errorf("%w: note %s is already shared with them", service.ErrConflict, fields.NoteID)
+		}
+	}
+
+	ms.lastShareID++
+	share := &models.Share{
+		ID: ms.lastShareID, NoteID: note.ID, UserID: ref(fields.UserID), TeamID: ref(fields.TeamID), Role: fields.Role, CreatedAt: now(),
+	}
+	ms.shares[share.ID] = share
+	cp := *share
+
+	return &cp, nil
+}
+
+func (ms *memoryStorage) Shares(ctx context.Context, noteID string) ([]*models.Share, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	note, err := ms.lookup(ctx, noteID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	shares := []*models.Share{}
+
+	for _, stored := range ms.shares {
+		if stored.NoteID != note.ID {
+			continue
+		}
+
+		share := *stored
+
+		if share.UserID != nil {
+			share.Email = ms.users[*share.UserID].Email
+		}
+
+		if share.TeamID != nil {
+			share.Team = ms.teams[*share.TeamID].Name
+		}
+
+		shares = append(shares, &share)
+	}
+
+	sort.Slice(shares, func(i, j int) bool { return shares[i].ID < shares[j].ID })
+
+	return shares, nil
+}
+
+func (ms *memoryStorage) DeleteShare(ctx context.Context, noteID string, shareID int64) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	note, err := ms.lookup(ctx, noteID)
+
+	if err != nil {
+		return err
+	}
+
+	if share, in := ms.shares[shareID]; !in || share.NoteID != note.ID {
+		return shareNotFound(noteID, shareID)
+	}
+
+	delete(ms.shares, shareID)
+
+	return nil
+}
+
+func (ms *memoryStorage) CreateTeam(ctx context.Context, name string, ownerID int64) (*models.Team, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if ms.users[ownerID] == nil {
+		return nil, fmt.Errorf("%w: user %d does not exist", service.ErrConflict, ownerID)
+	}
+
+	ms.lastTeamID++
+	team := &models.Team{ID: ms.lastTeamID, Name: name, OwnerID: ownerID, CreatedAt: now()}
+	ms.teams[team.ID] = team
+	ms.members[team.ID] = map[int64]bool{ownerID: true}
+
+	return ms.team(team.ID)
+}
+
+func (ms *memoryStorage) Teams(ctx context.Context, userID int64) ([]*models.Team, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	teams := []*models.Team{}
+
+	for id, team := range ms.teams {
+		if ms.members[id][userID] {
+			cp := *team
+			teams = append(teams, &cp)
+		}
+	}
+
+	sort.Slice(teams, func(i, j int) bool {
+		if teams[i].Name != teams[j].Name {
+			return teams[i].Name < teams[j].Name
+		}
+
+		return teams[i].ID < teams[j].ID
+	})
+
+	return teams, nil
+}
+
+func (ms *memoryStorage) Team(ctx context.Context, id int64) (*models.Team, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	return ms.team(id)
+}
+
+func (ms *memoryStorage) team(id int64) (*models.Team, error) {
+	stored, in := ms.teams[id]
+
+	if !in {
+		return nil, teamNotFound(id)
+	}
+
+	team := *stored
+	team.Members = []*models.TeamMember{}
+
+	for userID := range ms.members[id] {
+		team.Members = append(team.Members, &models.TeamMember{UserID: userID, Email: ms.users[userID].Email})
+	}
+
+	sort.Slice(team.Members, func(i, j int) bool { return team.Members[i].Email < team.Members[j].Email })
+
+	return &team, nil
+}
+
+func (ms *memoryStorage) DeleteTeam(ctx context.Context, id int64) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if ms.teams[id] == nil {
+		return teamNotFound(id)
+	}
+
+	delete(ms.teams, id)
+	delete(ms.members, id)
+
+	for shareID, share := range ms.shares {
+		if share.TeamID != nil && *share.TeamID == id {
+			delete(ms.shares, shareID)
+		}
+	}
+
+	return nil
+}
+
+func (ms *memoryStorage) AddMember(ctx context.Context, teamID, userID int64) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if ms.teams[teamID] == nil || ms.users[userID] == nil {
+		return fmt.Errorf("%w: team %d or user %d does not exist", service.ErrConflict, teamID, userID)
+	}
+
+	if ms.members[teamID][userID] {
+		return fmt.Errorf("%w: user %d is a member of team %d already", service.ErrConflict, userID, teamID)
+	}
+
+	ms.members[teamID][userID] = true
+
+	return nil
+}
+
+func (ms *memoryStorage) RemoveMember(ctx context.Context, teamID, userID int64) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if !ms.members[teamID][userID] {
+		return fmt.Errorf("member %d of team %d %w", userID, teamID, service.ErrNotFound)
+	}
+
+	delete(ms.members[teamID], userID)
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"note/internal/service"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestMemoryStorageConcurrent(t *testing.T) {
+	ctx := service.AsSystem(context.Background())
+	repo := NewMemoryStorage()
+
+	const workers = 16
+
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			if _, err := repo.Create(ctx, service.NoteFields{Text: strconv.Itoa(i)}); err != nil {
+				t.Errorf("unexpected err: %s", err)
+			}
+
+			if _, err := repo.GetAll(ctx, service.NotesQuery{Sort: service.Sort{{Field: "text"}}}); err != nil {
+				t.Errorf("unexpected err: %s", err)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	notes, err := repo.GetAll(ctx, service.NotesQuery{})
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if len(notes) != workers {
+		t.Fatalf("expected %d notes, got %d", workers, len(notes))
+	}
+
+	for i, note := range notes {
+		if note.ID != int64(i+1) {
+			t.Errorf("expected id order, got %v", notes)
+			break
+		}
+	}
+
+	// Returned notes are copies; mutating them must not leak into storage.
+	notes[0].Text = "changed"
+	note, err := repo.Get(ctx, "1")
+
+	if err != nil || note.Text == "changed" {
+		t.Errorf("storage was mutated through a returned note: %v, %v", note, err)
+	}
+
+	if _, err = repo.GetAll(ctx, service.NotesQuery{Sort: service.Sort{{Field: "unknown"}}}); err == nil {
+		t.Error("expected error, got nil")
+	}
+
+	if err = repo.Delete(ctx, "100", 0); err == nil {
+		t.Error("expected error, got nil")
+	}
+}
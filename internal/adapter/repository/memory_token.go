@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"note/internal/models"
+	"note/internal/service"
+	"sort"
+	"time"
+)
+
+// memoryToken is a stored access token with the hash of the token.
+type memoryToken struct {
+	models.AccessToken
+	tokenHash string
+}
+
+func (ms *memoryStorage) CreateToken(ctx context.Context, fields service.TokenFields) (*models.AccessToken, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if ms.users[fields.UserID] == nil {
+		return nil, fmt.Errorf("%w: user %d does not exist", service.ErrConflict, fields.UserID)
+	}
+
+	for _, token := range ms.tokens {
+		if token.tokenHash == fields.TokenHash {
+			return nil, fmt.Errorf("%w: the access token exists", service.ErrConflict)
+		}
+
+		if token.UserID == fields.UserID && token.Name == fields.Name {
+			return nil, fmt.Errorf("%w: a token named %q exists", service.ErrConflict, fields.Name)
+		}
+	}
+
+	ms.lastTokenID++
+	token := &memoryToken{
+		AccessToken: models.AccessToken{
+			ID: ms.lastTokenID, UserID: fields.UserID, Name: fields.Name, Scopes: append([]string{}, fields.Scopes...), CreatedAt: now(),
+		},
+		tokenHash: fields.TokenHash,
+	}
+	ms.tokens[token.ID] = token
+
+	return token.clone(), nil
+}
+
+func (ms *memoryStorage) Tokens(ctx context.Context, userID int64) ([]*models.AccessToken, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	tokens := []*models.AccessToken{}
+
+	for _, token := range ms.tokens {
+		if token.UserID == userID {
+			tokens = append(tokens, token.clone())
+		}
+	}
+
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].ID < tokens[j].ID })
+
+	return tokens, nil
+}
+
+func (ms *memoryStorage) DeleteToken(ctx context.Context, userID, id int64) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if token, in := ms.tokens[id]; !in || token.UserID != userID {
+		return tokenNotFound(id)
+	}
+
+	delete(ms.tokens, id)
+
+	return nil
+}
+
+func (ms *memoryStorage) TokenUser(ctx context.Context, tokenHash string, t time.Time) (*models.AccessToken, *models.User, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	for _, token := range ms.tokens {
+		if token.tokenHash == tokenHash {
+			lastUsed := t.UTC()
+			token.LastUsedAt = &lastUsed
+			user := *ms.users[token.UserID]
+
+			return token.clone(), &user, nil
+		}
+	}
+
+	return nil, nil, fmt.Errorf("access token %w", service.ErrNotFound)
+}
+
+func (token *memoryToken) clone() *models.AccessToken {
+	cp := token.AccessToken
+	cp.Scopes = append([]string{}, token.Scopes...)
+
+	if token.LastUsedAt != nil {
+		lastUsed := *token.LastUsedAt
+		cp.LastUsedAt = &lastUsed
+	}
+
+	return &cp
+}
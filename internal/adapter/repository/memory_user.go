@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"note/internal/models"
+	"note/internal/service"
+	"time"
+)
+
+type memorySession struct {
+	userID    int64
+	expiresAt time.Time
+}
+
+func (ms *memoryStorage) CreateUser(ctx context.Context, email, passwordHash string) (*models.User, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	for _, user := range ms.users {
+		if user.Email == email {
+			return nil, fmt.Errorf("%w: email %s is already registered", service.ErrConflict, email)
+		}
+	}
+
+	ms.lastUserID++
+	user := &models.User{ID: ms.lastUserID, Email: email, PasswordHash: passwordHash, CreatedAt: now()}
+	ms.users[user.ID] = user
+	cp := *user
+
+	return &cp, nil
+}
+
+func (ms *memoryStorage) UserByEmail(ctx context.Context, email string) (*models.User, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	for _, user := range ms.users {
+		if user.Email == email {
+			cp := *user
+
+			return &cp, nil
+		}
+	}
+
+	return nil, fmt.Errorf("user %s %w", email, service.ErrNotFound)
+}
+
+func (ms *memoryStorage) CreateSession(ctx context.Context, tokenHash string, userID int64, expiresAt time.Time) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if ms.users[userID] == nil {
+		return fmt.Errorf("%w: user %d does not exist", service.ErrConflict, userID)
+	}
+
+	if _, in := ms.sessions[tokenHash]; in {
+		return fmt.Errorf("%w: the session exists", service.ErrConflict)
+	}
+
+	t := now()
+
+	for hash, session := range ms.sessions {
+		if session.userID == userID && session.expiresAt.Before(t) {
+			delete(ms.sessions, hash)
+		}
+	}
+
+	ms.sessions[tokenHash] = memorySession{userID: userID, expiresAt: expiresAt.UTC()}
+
+	return nil
+}
+
+func (ms *memoryStorage) SessionUser(ctx context.Context, tokenHash string, t time.Time) (*models.User, error) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	session, in := ms.sessions[tokenHash]
+
+	if !in || !session.expiresAt.After(t) {
+		return nil, fmt.Errorf("session %w", service.ErrNotFound)
+	}
+
+	cp := *ms.users[session.userID]
+
+	return &cp, nil
+}
+
+func (ms *memoryStorage) DeleteSession(ctx context.Context, tokenHash string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if _, in := ms.sessions[tokenHash]; !in {
+		return fmt.Errorf("session %w", service.ErrNotFound)
+	}
+
+	delete(ms.sessions, tokenHash)
+
+	return nil
+}
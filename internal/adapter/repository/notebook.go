@@ -0,0 +1,265 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"note/internal/models"
+	"note/internal/service"
+	"strconv"
+)
+
+const notebookColumns = "id, name, parent_id, created_at, updated_at"
+
+func notebookFields(notebook *models.Notebook) []interface{} {
+	return []interface{}{&notebook.ID, &notebook.Name, &notebook.ParentID, &notebook.CreatedAt, &notebook.UpdatedAt}
+}
+
+func parseNotebookID(id string) (int64, error) {
+	key, err := strconv.ParseInt(id, 10, 64)
+
+	if err != nil {
+		return 0, notebookNotFound(id)
+	}
+
+	return key, nil
+}
+
+func (ns *noteStorage) CreateNotebook(ctx context.Context, fields service.NotebookFields) (*models.Notebook, error) {
+	t := now()
+	notebook := &models.Notebook{Name: fields.Name, ParentID: fields.ParentID, CreatedAt: t, UpdatedAt: t}
+	query := `INSERT INTO notebook (name, parent_id, owner_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`
+	id, err := ns.insert(ctx, ns.db, query, notebook.Name, notebook.ParentID, owner(ctx), t, t)
+
+	if err != nil {
+		return nil, err
+	}
+
+	notebook.ID = id
+
+	return notebook, nil
+}
+
+func (ns *noteStorage) GetNotebook(ctx context.Context, id string) (*models.Notebook, error) {
+	key, err := parseNotebookID(id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ns.getNotebook(ctx, ns.db, key)
+}
+
+func (ns *noteStorage) getNotebook(ctx context.Context, q dbtx, id int64) (*models.Notebook, error) {
+	notebook := &models.Notebook{}
+	query, args := owned(ctx, "SELECT "+notebookColumns+" FROM notebook WHERE id=?", []interface{}{id})
+	err := q.QueryRowContext(ctx, ns.dialect.rebind(query), args...).Scan(notebookFields(notebook)...)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, notebookNotFound(strconv.FormatInt(id, 10))
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("can't scan row: %w", err)
+	}
+
+	return notebook, nil
+}
+
+// UpdateNotebook walks up from the new parent in the same transaction as the
+// update, so concurrent moves can't close a cycle. The notebook is looked up
+// rather than judged by the rows affected, which MySQL counts as zero when
+// nothing changes.
+func (ns *noteStorage) UpdateNotebook(ctx context.Context, id string, fields service.NotebookFields) error {
+	key, err := parseNotebookID(id)
+
+	if err != nil {
+		return err
+	}
+
+	return ns.inTx(ctx, func(tx *sql.Tx) error {
+		if _, getErr := ns.getNotebook(ctx, tx, key); getErr != nil {
+			return getErr
+		}
+
+		for parent := fields.ParentID; parent != nil; {
+			if *parent == key {
+				return fmt.Errorf("%w: notebook %s can't be moved into itself or its descendants", service.ErrConflict, id)
+			}
+
+			ancestor, getErr := ns.getNotebook(ctx, tx, *parent)
+
+			if getErr != nil {
+				return getErr
+			}
+
+			parent = ancestor.ParentID
+		}
+
+		query, args := owned(ctx, `UPDATE notebook SET name=?, parent_id=?, updated_at=? WHERE id=?`, []interface{}{fields.Name, fields.ParentID, now(), key})
+
+		if _, execErr := tx.ExecContext(ctx, ns.dialect.rebind(query), args...); execErr != nil {
+			return ns.dialect.mapError(execErr)
+		}
+
+		return nil
+	})
+}
+
+// DeleteNotebook moves the notes of the whole subtree to the trash first and
+// then removes the notebooks deepest first, rather than relying on nested
+// cascades, which MySQL stops after 15 levels.
+func (ns *noteStorage) DeleteNotebook(ctx context.Context, id string, recursive bool) error {
+	key, err := parseNotebookID(id)
+
+	if err != nil {
+		return err
+	}
+
+	return ns.inTx(ctx, func(tx *sql.Tx) error {
+		if _, getErr := ns.getNotebook(ctx, tx, key); getErr != nil {
+			return getErr
+		}
+
+		ids, listErr := ns.subtree(ctx, tx, key)
+
+		if listErr != nil {
+			return listErr
+		}
+
+		if !recursive {
+			var notes int
+
+			err = tx.QueryRowContext(ctx, ns.dialect.rebind(`SELECT COUNT(*) FROM note WHERE notebook_id=? AND deleted_at IS NULL`), key).Scan(&notes)
+
+			if err != nil {
+				return err
+			}
+
+			if notes > 0 || len(ids) > 1 {
+				return fmt.Errorf("%w: notebook %s is not empty", service.ErrConflict, id)
+			}
+		}
+
+		args := make([]interface{}, 0, len(ids))
+
+		for _, notebookID := range ids {
+			args = append(args, notebookID)
+		}
+
+		// Trashed notes stay in the trash and are restored to the top level.
+		query := `UPDATE note SET notebook_id=NULL WHERE deleted_at IS NOT NULL AND notebook_id IN (` + placeholders(len(ids)) + `)`
+
+		if _, err = tx.ExecContext(ctx, ns.dialect.rebind(query), args...); err != nil {
+			return err
+		}
+
+		// Live notes go to the trash like deleted ones, so a misclick on a
+		// notebook can be undone.
+		query = `UPDATE note SET deleted_at=?, notebook_id=NULL, version=version+1 WHERE deleted_at IS NULL AND notebook_id IN (` + placeholders(len(ids)) + `)`
+
+		if _, err = tx.ExecContext(ctx, ns.dialect.rebind(query), append([]interface{}{now()}, args...)...); err != nil {
+			return err
+		}
+
+		for i := len(ids) - 1; i >= 0; i-- {
+			if _, err = tx.ExecContext(ctx, ns.dialect.rebind(`DELETE FROM notebook WHERE id=?`), ids[i]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// subtree returns the id of a notebook and of all its descendants, parents
+// before children.
+func (ns *noteStorage) subtree(ctx context.Context, q dbtx, id int64) ([]int64, error) {
+	ids := []int64{id}
+
+	for i := 0; i < len(ids); i++ {
+		children, err := ns.children(ctx, q, ids[i])
+
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, children...)
+	}
+
+	return ids, nil
+}
+
+func (ns *noteStorage) children(ctx context.Context, q dbtx, id int64) ([]int64, error) {
+	rows, err := q.QueryContext(ctx, ns.dialect.rebind(`SELECT id FROM notebook WHERE parent_id=? ORDER BY id`), id)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+
+	for rows.Next() {
+		var child int64
+
+		if err = rows.Scan(&child); err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, child)
+	}
+
+	return ids, rows.Err()
+}
+
+func (ns *noteStorage) Notebooks(ctx context.Context) ([]*models.Notebook, error) {
+	query, args := "SELECT "+notebookColumns+" FROM notebook", []interface{}{}
+
+	if user, ok := service.UserFrom(ctx); ok {
+		query, args = query+" WHERE owner_id=?", append(args, user.ID)
+	} else if !service.IsSystem(ctx) {
+		return []*models.Notebook{}, nil
+	}
+
+	rows, err := ns.db.QueryContext(ctx, ns.dialect.rebind(query+" ORDER BY name, id"), args...)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notebooks := []*models.Notebook{}
+
+	for rows.Next() {
+		notebook := &models.Notebook{}
+
+		if err = rows.Scan(notebookFields(notebook)...); err != nil {
+			return nil, err
+		}
+
+		notebooks = append(notebooks, notebook)
+	}
+
+	return notebooks, rows.Err()
+}
+
+func (ns *noteStorage) MoveNote(ctx context.Context, id string, notebookID *int64) error {
+	if err := checkID(id); err != nil {
+		return err
+	}
+
+	query, args := permitted(ctx, `UPDATE note SET notebook_id=?, updated_at=?, version=version+1 WHERE id=? AND deleted_at IS NULL`, []interface{}{notebookID, now(), id})
+	result, err := ns.db.ExecContext(ctx, ns.dialect.rebind(query), args...)
+
+	if err != nil {
+		return ns.dialect.mapError(err)
+	}
+
+	if row, _ := result.RowsAffected(); row == 0 {
+		return ns.notUpdated(ctx, ns.db, id, 0)
+	}
+
+	return nil
+}
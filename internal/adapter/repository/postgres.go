@@ -0,0 +1,9 @@
+package repository
+
+import (
+	"database/sql"
+)
+
+func NewPostgresStorage(db *sql.DB) *noteStorage {
+	return &noteStorage{db: db, dialect: postgresDialect}
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"note/internal/models"
+)
+
+const revisionColumns = "note_id, revision, title, text, summary, created_at"
+
+func revisionFields(revision *models.Revision) []interface{} {
+	return []interface{}{&revision.NoteID, &revision.Revision, &revision.Title, &revision.Text, &revision.Summary, &revision.CreatedAt}
+}
+
+// addRevision copies the stored content of a note into its next revision,
+// dated by its updated_at. It runs right after the note row is written,
+// which holds the row lock until the transaction ends, so revision numbers
+// don't race.
+func (ns *noteStorage) addRevision(ctx context.Context, q dbtx, noteID int64) error {
+	_, err := q.ExecContext(ctx, ns.dialect.rebind(`INSERT INTO note_revision (`+revisionColumns+`)
+		SELECT id, COALESCE((SELECT MAX(revision) FROM note_revision WHERE note_id = ?), 0) + 1, title, text, summary, updated_at
+		FROM note WHERE id = ?`), noteID, noteID)
+
+	return ns.dialect.mapError(err)
+}
+
+// checkLive reports a note that doesn't exist or is in the trash as not
+// found, and one the user may see but lacks the role for as forbidden.
+func (ns *noteStorage) checkLive(ctx context.Context, q dbtx, id string) error {
+	if err := checkID(id); err != nil {
+		return err
+	}
+
+	var live int
+	query, args := permitted(ctx, `SELECT COUNT(*) FROM note WHERE id=? AND deleted_at IS NULL`, []interface{}{id})
+	err := q.QueryRowContext(ctx, ns.dialect.rebind(query), args...).Scan(&live)
+
+	if err != nil {
+		return err
+	}
+
+	if live == 0 {
+		return ns.denied(ctx, q, id)
+	}
+
+	return nil
+}
+
+func (ns *noteStorage) Revisions(ctx context.Context, id string) ([]*models.Revision, error) {
+	if err := ns.checkLive(ctx, ns.db, id); err != nil {
+		return nil, err
+	}
+
+	rows, err := ns.db.QueryContext(ctx, ns.dialect.rebind("SELECT "+revisionColumns+" FROM note_revision WHERE note_id=? ORDER BY revision DESC"), id)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*models.Revision{}
+
+	for rows.Next() {
+		revision := &models.Revision{}
+
+		if err = rows.Scan(revisionFields(revision)...); err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, rows.Err()
+}
+
+func (ns *noteStorage) Revision(ctx context.Context, id string, rev int) (*models.Revision, error) {
+	if err := ns.checkLive(ctx, ns.db, id); err != nil {
+		return nil, err
+	}
+
+	revision := &models.Revision{}
+	query := "SELECT " + revisionColumns + " FROM note_revision WHERE note_id=? AND revision=?"
+	err := ns.db.QueryRowContext(ctx, ns.dialect.rebind(query), id, rev).Scan(revisionFields(revision)...)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, revisionNotFound(id, rev)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("can't scan row: %w", err)
+	}
+
+	return revision, nil
+}
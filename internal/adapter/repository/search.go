@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"context"
+	"note/internal/models"
+	"note/internal/service"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Full-text queries per backend. Terms hold only letters and digits, so the
+// generated expressions can't carry operators of their own; they are still
+// passed as arguments.
+const (
+	mysqlSearch = "SELECT " + noteColumns + `, MATCH (text) AGAINST (? IN BOOLEAN MODE) AS score
+		FROM note WHERE MATCH (text) AGAINST (? IN BOOLEAN MODE) AND deleted_at IS NULL`
+	postgresSearch = "SELECT " + noteColumns + `, ts_rank(to_tsvector('simple', COALESCE(text, '')), query) AS score
+		FROM note, to_tsquery('simple', ?) query WHERE to_tsvector('simple', COALESCE(text, '')) @@ query AND deleted_at IS NULL`
+	// note_fts has a text column of its own.
+	sqliteSearch = `SELECT note.id, note.title, note.text, note.summary, note.color, note.pinned, note.archived, note.notebook_id,
+		note.created_at, note.updated_at, note.version, note.deleted_at FROM note JOIN note_fts ON note_fts.docid = note.id
+		WHERE note_fts MATCH ? AND note.deleted_at IS NULL`
+	// rankedOrder ends the MySQL and Postgres queries, once they are scoped
+	// to the notes the user may see.
+	rankedOrder = " ORDER BY score DESC, id LIMIT ?"
+	// sqliteOrder ends the SQLite query. FTS4 can't rank, so the shortest
+	// matches are read, which are the densest, and ranked in Go.
+	sqliteOrder = " ORDER BY length(note.text), note.id LIMIT ?"
+)
+
+// sqliteCandidates is how many matches SQLite reads per result it returns,
+// so a broad query reads a bounded number of rows.
+const sqliteCandidates = 5
+
+// mysqlBoolean builds a boolean mode expression like +"quick brown" +fox +pre*.
+func mysqlBoolean(terms []service.SearchTerm) string {
+	parts := make([]string, 0, len(terms))
+
+	for _, t := range terms {
+		part := strings.Join(t.Words, " ")
+
+		switch {
+		case len(t.Words) > 1:
+			part = `"` + part + `"`
+		case t.Prefix:
+			part += "*"
+		}
+
+		parts = append(parts, "+"+part)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// tsquery builds a to_tsquery expression like quick <-> brown & fox & pre:*.
+func tsquery(terms []service.SearchTerm) string {
+	parts := make([]string, 0, len(terms))
+
+	for _, t := range terms {
+		part := strings.Join(t.Words, " <-> ")
+
+		if t.Prefix {
+			part += ":*"
+		}
+
+		parts = append(parts, part)
+	}
+
+	return strings.Join(parts, " & ")
+}
+
+// ftsMatch builds an FTS4 MATCH expression like "quick brown" "fox" "pre*".
+func ftsMatch(terms []service.SearchTerm) string {
+	parts := make([]string, 0, len(terms))
+
+	for _, t := range terms {
+		part := `"` + strings.Join(t.Words, " ")
+
+		if t.Prefix {
+			part += "*"
+		}
+
+		parts = append(parts, part+`"`)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func (ns *noteStorage) Search(ctx context.Context, q service.SearchQuery) ([]*service.SearchResult, error) {
+	var (
+		query string
+		args  []interface{}
+	)
+
+	switch ns.dialect.name {
+	case postgresDialect.name:
+		query, args = permitted(ctx, postgresSearch, []interface{}{tsquery(q.Terms)})
+		query, args = query+rankedOrder, append(args, q.Limit)
+	case sqliteDialect.name:
+		query, args = permitted(ctx, sqliteSearch, []interface{}{ftsMatch(q.Terms)})
+		query, args = query+sqliteOrder, append(args, q.Limit*sqliteCandidates)
+	default:
+		expr := mysqlBoolean(q.Terms)
+		query, args = permitted(ctx, mysqlSearch, []interface{}{expr, expr})
+		query, args = query+rankedOrder, append(args, q.Limit)
+	}
+
+	rows, err := ns.db.QueryContext(ctx, ns.dialect.rebind(query), args...)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []*service.SearchResult{}
+
+	for rows.Next() {
+		result := &service.SearchResult{Note: &models.Note{}}
+		dest := noteFields(result.Note)
+
+		if ns.dialect.name != sqliteDialect.name {
+			dest = append(dest, &result.Rank)
+		}
+
+		if err = rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		results = append(results, result)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows.Close()
+	notes := make([]*models.Note, 0, len(results))
+
+	for _, result := range results {
+		notes = append(notes, result.Note)
+	}
+
+	if err = ns.loadTags(ctx, ns.db, notes); err != nil {
+		return nil, err
+	}
+
+	// FTS4 has no built-in ranking function.
+	if ns.dialect.name == sqliteDialect.name {
+		for _, result := range results {
+			result.Rank = rank(result.Note.Text, q.Terms)
+		}
+
+		results = bestFirst(results, q.Limit)
+	}
+
+	return results, nil
+}
+
+// rank scores text by how dense the terms are in it, or 0 if any term is
+// missing.
+func rank(text string, terms []service.SearchTerm) float64 {
+	hits := 0
+
+	for _, t := range terms {
+		n := len(t.Occurrences(text))
+
+		if n == 0 {
+			return 0
+		}
+
+		hits += n
+	}
+
+	words := strings.FieldsFunc(text, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+
+	return float64(hits) / float64(len(words))
+}
+
+func bestFirst(results []*service.SearchResult, limit int) []*service.SearchResult {
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+
+		return results[i].Note.ID < results[j].Note.ID
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results
+}
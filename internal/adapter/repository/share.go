@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"note/internal/models"
+	"note/internal/service"
+	"strconv"
+)
+
+const teamColumns = "team.id, team.name, team.owner_id, team.created_at"
+
+func teamFields(team *models.Team) []interface{} {
+	return []interface{}{&team.ID, &team.Name, &team.OwnerID, &team.CreatedAt}
+}
+
+func (ns *noteStorage) CreateShare(ctx context.Context, fields service.ShareFields) (*models.Share, error) {
+	share := &models.Share{UserID: fields.UserID, TeamID: fields.TeamID, Role: fields.Role, CreatedAt: now()}
+
+	err := ns.inTx(ctx, func(tx *sql.Tx) error {
+		if err := ns.checkLive(ctx, tx, fields.NoteID); err != nil {
+			return err
+		}
+
+		share.NoteID, _ = strconv.ParseInt(fields.NoteID, 10, 64)
+		query := `INSERT INTO note_share (note_id, user_id, team_id, role, created_at) VALUES (?, ?, ?, ?, ?)`
+		id, err := ns.insert(ctx, tx, query, share.NoteID, share.UserID, share.TeamID, share.Role, share.CreatedAt)
+
+		if errors.Is(err, service.ErrConflict) {
+			return fmt.Errorf("%w: note %s is already shared with them", service.ErrConflict, fields.NoteID)
+		}
+
+		share.ID = id
+
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return share, nil
+}
+
+func (ns *noteStorage) Shares(ctx context.Context, noteID string) ([]*models.Share, error) {
+	if err := ns.checkLive(ctx, ns.db, noteID); err != nil {
+		return nil, err
+	}
+
+	query := `SELECT note_share.id, note_share.note_id, note_share.user_id, COALESCE(users.email, ''), note_share.team_id,
+		COALESCE(team.name, ''), note_share.role, note_share.created_at FROM note_share
+		LEFT JOIN users ON users.id = note_share.user_id LEFT JOIN team ON team.id = note_share.team_id
+		WHERE note_share.note_id=? ORDER BY note_share.id`
+	rows, err := ns.db.QueryContext(ctx, ns.dialect.rebind(query), noteID)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shares := []*models.Share{}
+
+	for rows.Next() {
+		share := &models.Share{}
+		err = rows.Scan(&share.ID, &share.NoteID, &share.UserID, &share.Email, &share.TeamID, &share.Team, &share.Role, &share.CreatedAt)
+
+		if err != nil {
+			return nil, err
+		}
+
+		shares = append(shares, share)
+	}
+
+	return shares, rows.Err()
+}
+
+func (ns *noteStorage) DeleteShare(ctx context.Context, noteID string, shareID int64) error {
+	return ns.inTx(ctx, func(tx *sql.Tx) error {
+		if err := ns.checkLive(ctx, tx, noteID); err != nil {
+			return err
+		}
+
+		result, err := tx.ExecContext(ctx, ns.dialect.rebind(`DELETE FROM note_share WHERE id=? AND note_id=?`), shareID, noteID)
+
+		if err != nil {
+			return err
+		}
+
+		if row, _ := result.RowsAffected(); row == 0 {
+			return shareNotFound(noteID, shareID)
+		}
+
+		return nil
+	})
+}
+
+func (ns *noteStorage) CreateTeam(ctx context.Context, name string, ownerID int64) (*models.Team, error) {
+	team := &models.Team{Name: name, OwnerID: ownerID, CreatedAt: now()}
+
+	err := ns.inTx(ctx, func(tx *sql.Tx) error {
+		id, err := ns.insert(ctx, tx, `INSERT INTO team (name, owner_id, created_at) VALUES (?, ?, ?)`, name, ownerID, team.CreatedAt)
+
+		if err != nil {
+			return err
+		}
+
+		team.ID = id
+		_, err = tx.ExecContext(ctx, ns.dialect.rebind(`INSERT INTO team_member (team_id, user_id) VALUES (?, ?)`), id, ownerID)
+
+		return ns.dialect.mapError(err)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ns.Team(ctx, team.ID)
+}
+
+func (ns *noteStorage) Teams(ctx context.Context, userID int64) ([]*models.Team, error) {
+	query := "SELECT " + teamColumns + ` FROM team JOIN team_member ON team_member.team_id = team.id
+		WHERE team_member.user_id=? ORDER BY team.name, team.id`
+	rows, err := ns.db.QueryContext(ctx, ns.dialect.rebind(query), userID)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	teams := []*models.Team{}
+
+	for rows.Next() {
+		team := &models.Team{}
+
+		if err = rows.Scan(teamFields(team)...); err != nil {
+			return nil, err
+		}
+
+		teams = append(teams, team)
+	}
+
+	return teams, rows.Err()
+}
+
+func (ns *noteStorage) Team(ctx context.Context, id int64) (*models.Team, error) {
+	team := &models.Team{}
+	err := ns.db.QueryRowContext(ctx, ns.dialect.rebind("SELECT "+teamColumns+" FROM team WHERE id=?"), id).Scan(teamFields(team)...)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, teamNotFound(id)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("can't scan row: %w", err)
+	}
+
+	query := `SELECT users.id, users.email FROM team_member JOIN users ON users.id = team_member.user_id
+		WHERE team_member.team_id=? ORDER BY users.email`
+	rows, err := ns.db.QueryContext(ctx, ns.dialect.rebind(query), id)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	team.Members = []*models.TeamMember{}
+
+	for rows.Next() {
+		member := &models.TeamMember{}
+
+		if err = rows.Scan(&member.UserID, &member.Email); err != nil {
+			return nil, err
+		}
+
+		team.Members = append(team.Members, member)
+	}
+
+	return team, rows.Err()
+}
+
+func (ns *noteStorage) DeleteTeam(ctx context.Context, id int64) error {
+	result, err := ns.db.ExecContext(ctx, ns.dialect.rebind(`DELETE FROM team WHERE id=?`), id)
+
+	if err != nil {
+		return err
+	}
+
+	if row, _ := result.RowsAffected(); row == 0 {
+		return teamNotFound(id)
+	}
+
+	return nil
+}
+
+func (ns *noteStorage) AddMember(ctx context.Context, teamID, userID int64) error {
+	_, err := ns.db.ExecContext(ctx, ns.dialect.rebind(`INSERT INTO team_member (team_id, user_id) VALUES (?, ?)`), teamID, userID)
+
+	if err = ns.dialect.mapError(err); errors.Is(err, service.ErrConflict) {
+		return fmt.Errorf("%w: user %d is a member of team %d already", service.ErrConflict, userID, teamID)
+	}
+
+	return err
+}
+
+func (ns *noteStorage) RemoveMember(ctx context.Context, teamID, userID int64) error {
+	result, err := ns.db.ExecContext(ctx, ns.dialect.rebind(`DELETE FROM team_member WHERE team_id=? AND user_id=?`), teamID, userID)
+
+	if err != nil {
+		return err
+	}
+
+	if row, _ := result.RowsAffected(); row == 0 {
+		return fmt.Errorf("member %d of team %d %w", userID, teamID, service.ErrNotFound)
+	}
+
+	return nil
+}
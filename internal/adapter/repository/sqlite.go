@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"note/internal/migrate"
+)
+
+// NewSQLiteStorage applies pending migrations before returning, so a fresh
+// database file is ready to use without a separate deploy step.
+func NewSQLiteStorage(ctx context.Context, db *sql.DB) (*noteStorage, error) {
+	migrator, err := migrate.New(db, migrate.SQLite)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = migrator.Up(ctx); err != nil {
+		return nil, err
+	}
+
+	return &noteStorage{db: db, dialect: sqliteDialect}, nil
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"note/internal/service"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func newSQLiteDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", "file::memory:?_foreign_keys=on")
+
+	if err != nil {
+		t.Fatalf("can't open sqlite: %s", err)
+	}
+
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func TestSQLiteBootstrap(t *testing.T) {
+	ctx := service.AsSystem(context.Background())
+	db := newSQLiteDB(t)
+
+	repo, err := NewSQLiteStorage(ctx, db)
+
+	if err != nil {
+		t.Fatalf("can't bootstrap schema: %s", err)
+	}
+
+	if _, err = repo.Create(ctx, service.NoteFields{Text: "kept"}); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	// Bootstrapping an existing database must keep its data.
+	repo, err = NewSQLiteStorage(ctx, db)
+
+	if err != nil {
+		t.Fatalf("second bootstrap failed: %s", err)
+	}
+
+	note, err := repo.Get(ctx, "1")
+
+	if err != nil || note.Text != "kept" {
+		t.Errorf("expected note to survive bootstrap, got %v, %v", note, err)
+	}
+}
+
+func TestSQLiteSearchWindow(t *testing.T) {
+	ctx := service.AsSystem(context.Background())
+	repo, err := NewSQLiteStorage(ctx, newSQLiteDB(t))
+
+	if err != nil {
+		t.Fatalf("can't bootstrap schema: %s", err)
+	}
+
+	// Far more matches than the window, the densest one written last.
+	for i := 0; i < 4*sqliteCandidates; i++ {
+		if _, err = repo.Create(ctx, service.NoteFields{Text: "plan " + strings.Repeat("filler ", 20+i)}); err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+	}
+
+	dense, err := repo.Create(ctx, service.NoteFields{Text: "plan plan"})
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	results, err := repo.Search(ctx, service.SearchQuery{Terms: []service.SearchTerm{{Words: []string{"plan"}}}, Limit: 2})
+
+	if err != nil || len(results) != 2 || results[0].Note.ID != dense.ID || results[1].Note.ID != 1 {
+		t.Fatalf("expected the densest notes first, got %v, %v", results, err)
+	}
+}
@@ -6,88 +6,350 @@ import (
 	"errors"
 	"fmt"
 	"note/internal/models"
+	"note/internal/service"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
+const noteColumns = "id, title, text, summary, color, pinned, archived, notebook_id, created_at, updated_at, version, deleted_at"
+
 type noteStorage struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect dialect
 }
 
 func NewStorage(db *sql.DB) *noteStorage {
-	return &noteStorage{db: db}
+	return &noteStorage{db: db, dialect: mysqlDialect}
 }
 
-func (ns *noteStorage) Create(ctx context.Context, text string) error {
-	t := time.Now()
-	result, err := ns.db.Exec(`INSERT INTO note (text, created_at, updated_at) VALUES (?, ?, ?)`, text, t, t)
+func (ns *noteStorage) Create(ctx context.Context, fields service.NoteFields) (*models.Note, error) {
+	var note *models.Note
+
+	err := ns.inTx(ctx, func(tx *sql.Tx) error {
+		var err error
+
+		note, err = ns.create(ctx, tx, fields)
 
-	if err != nil {
 		return err
-	}
+	})
 
-	if row, _ := result.RowsAffected(); row == 0 {
-		return errors.New("afftected 0 row")
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return note, nil
 }
 
-func (ns *noteStorage) Update(ctx context.Context, id, text string) error {
-	result, err := ns.db.Exec(`UPDATE note SET text=?, updated_at=? WHERE id=?`, text, time.Now(), id)
+func (ns *noteStorage) create(ctx context.Context, tx *sql.Tx, fields service.NoteFields) (*models.Note, error) {
+	t := now()
+	note := newNote(fields, t)
+	query := `INSERT INTO note (title, text, summary, color, pinned, archived, notebook_id, owner_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	id, err := ns.insert(ctx, tx, query, note.Title, note.Text, note.Summary, note.Color, note.Pinned, note.Archived, note.NotebookID, owner(ctx), t, t)
 
 	if err != nil {
+		return nil, err
+	}
+
+	note.ID = id
+
+	if err = ns.addRevision(ctx, tx, id); err != nil {
+		return nil, err
+	}
+
+	if len(note.Tags) > 0 {
+		if err = ns.setTags(ctx, tx, id, note.Tags); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Strings(note.Tags)
+
+	return note, nil
+}
+
+func (ns *noteStorage) Update(ctx context.Context, id string, fields service.NoteFields) error {
+	if err := checkID(id); err != nil {
+		return err
+	}
+
+	// The revision is written in the same transaction, so the history never
+	// misses an update.
+	return ns.inTx(ctx, func(tx *sql.Tx) error {
+		return ns.update(ctx, tx, id, fields)
+	})
+}
+
+func (ns *noteStorage) update(ctx context.Context, tx *sql.Tx, id string, fields service.NoteFields) error {
+	if err := checkID(id); err != nil {
 		return err
 	}
 
+	// Unset metadata is passed as NULL and keeps the stored value.
+	query := `UPDATE note SET text=?, title=COALESCE(?, title), summary=COALESCE(?, summary), color=COALESCE(?, color),
+		pinned=COALESCE(?, pinned), archived=COALESCE(?, archived), updated_at=?, version=version+1 WHERE id=? AND deleted_at IS NULL`
+	args := []interface{}{fields.Text, fields.Title, fields.Summary, fields.Color, fields.Pinned, fields.Archived, now(), id}
+	query, args = permitted(ctx, query, args)
+	query, args = ifVersion(query, args, fields.IfVersion)
+	result, err := tx.ExecContext(ctx, ns.dialect.rebind(query), args...)
+
+	if err != nil {
+		return ns.dialect.mapError(err)
+	}
+
 	if row, _ := result.RowsAffected(); row == 0 {
-		return errors.New("afftected 0 row")
+		return ns.notUpdated(ctx, tx, id, fields.IfVersion)
 	}
 
-	return nil
+	noteID, _ := strconv.ParseInt(id, 10, 64)
+
+	if err = ns.addRevision(ctx, tx, noteID); err != nil {
+		return err
+	}
+
+	if fields.Tags == nil {
+		return nil
+	}
+
+	return ns.setTags(ctx, tx, noteID, fields.Tags)
 }
 
-func (ns *noteStorage) Delete(ctx context.Context, id string) error {
-	result, err := ns.db.Exec(`DELETE FROM note WHERE id=?`, id)
+// Delete moves a note to the trash.
+func (ns *noteStorage) Delete(ctx context.Context, id string, version int64) error {
+	return ns.trash(ctx, ns.db, id, version)
+}
 
-	if err != nil {
+func (ns *noteStorage) trash(ctx context.Context, q dbtx, id string, version int64) error {
+	if err := checkID(id); err != nil {
 		return err
 	}
 
+	query, args := permitted(ctx, `UPDATE note SET deleted_at=?, version=version+1 WHERE id=? AND deleted_at IS NULL`, []interface{}{now(), id})
+	query, args = ifVersion(query, args, version)
+	result, err := q.ExecContext(ctx, ns.dialect.rebind(query), args...)
+
+	if err != nil {
+		return ns.dialect.mapError(err)
+	}
+
 	if row, _ := result.RowsAffected(); row == 0 {
-		return errors.New("afftected 0 row")
+		return ns.notUpdated(ctx, q, id, version)
 	}
 
 	return nil
 }
 
 func (ns *noteStorage) Get(ctx context.Context, id string) (*models.Note, error) {
+	if err := checkID(id); err != nil {
+		return nil, err
+	}
+
 	note := &models.Note{}
+	query, args := permitted(ctx, "SELECT "+noteColumns+" FROM note WHERE id=? AND deleted_at IS NULL", []interface{}{id})
+	err := ns.db.QueryRowContext(ctx, ns.dialect.rebind(query), args...).Scan(noteFields(note)...)
 
-	err := ns.db.QueryRow("SELECT id, text, created_at, updated_at FROM note WHERE id=?", id).Scan(&note.ID, &note.Text, &note.CreatedAt, &note.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, noteNotFound(id)
+	}
 
 	if err != nil {
-		return nil, errors.New("can't scan row")
+		return nil, fmt.Errorf("can't scan row: %w", err)
+	}
+
+	if err = ns.loadTags(ctx, ns.db, []*models.Note{note}); err != nil {
+		return nil, err
 	}
 
 	return note, nil
 }
 
-func (ns *noteStorage) GetAll(ctx context.Context, orderBy string) ([]*models.Note, error) {
-	if orderBy == "" {
-		orderBy = "id"
+// noteFields are the scan destinations for noteColumns.
+func noteFields(note *models.Note) []interface{} {
+	return []interface{}{
+		&note.ID, &note.Title, &note.Text, &note.Summary, &note.Color, &note.Pinned, &note.Archived,
+		&note.NotebookID, &note.CreatedAt, &note.UpdatedAt, &note.Version, &note.DeletedAt,
+	}
+}
+
+// newNote is the note Create stores, with unset metadata empty.
+func newNote(fields service.NoteFields, t time.Time) *models.Note {
+	note := &models.Note{Text: fields.Text, Tags: append([]string{}, fields.Tags...), NotebookID: fields.NotebookID, CreatedAt: t, UpdatedAt: t, Version: 1}
+
+	if fields.Title != nil {
+		note.Title = *fields.Title
+	}
+
+	if fields.Summary != nil {
+		note.Summary = *fields.Summary
+	}
+
+	if fields.Color != nil {
+		note.Color = *fields.Color
+	}
+
+	note.Pinned = fields.Pinned != nil && *fields.Pinned
+	note.Archived = fields.Archived != nil && *fields.Archived
+
+	return note
+}
+
+// metaFilter is the condition for the pinned, archived and color filters.
+func metaFilter(q service.NotesQuery) ([]string, []interface{}) {
+	conds := []string{}
+	args := []interface{}{}
+
+	if q.Pinned != nil {
+		conds = append(conds, "pinned = ?")
+		args = append(args, *q.Pinned)
+	}
+
+	if q.Archived != nil {
+		conds = append(conds, "archived = ?")
+		args = append(args, *q.Archived)
+	}
+
+	if q.Color != "" {
+		conds = append(conds, "color = ?")
+		args = append(args, q.Color)
+	}
+
+	return conds, args
+}
+
+// sortColumn is the SQL expression a sort field orders by and the anchor
+// value a keyset condition compares it against.
+type sortColumn struct {
+	expr  string
+	value func(*models.Note) interface{}
+}
+
+// sortColumns maps the sortable fields to their columns. Only these
+// expressions are ever put into SQL.
+var sortColumns = map[string]sortColumn{
+	"id":         {"id", func(n *models.Note) interface{} { return n.ID }},
+	"text":       {fmt.Sprintf("SUBSTR(text, 1, %d)", service.SortTextLength), func(n *models.Note) interface{} { return service.SortText(n.Text) }},
+	"created_at": {"created_at", func(n *models.Note) interface{} { return n.CreatedAt }},
+	"updated_at": {"updated_at", func(n *models.Note) interface{} { return n.UpdatedAt }},
+}
+
+// checkSort rejects fields that aren't columns and makes the order total by
+// ending it with id.
+func checkSort(order service.Sort) (service.Sort, error) {
+	checked := service.Sort{}
+
+	for _, f := range order {
+		if _, in := sortColumns[f.Field]; !in {
+			return nil, fmt.Errorf("%w: can't order by %q", service.ErrInvalidInput, f.Field)
+		}
+
+		checked = append(checked, f)
+
+		if f.Field == "id" {
+			return checked, nil
+		}
+	}
+
+	return append(checked, service.SortField{Field: "id"}), nil
+}
+
+// keyset builds the condition for rows strictly after the anchor:
+// (a > ?) OR (a = ? AND b < ?) OR ... with < for descending fields.
+func keyset(order service.Sort, after *models.Note) (string, []interface{}) {
+	terms := make([]string, 0, len(order))
+	args := []interface{}{}
+
+	for i, f := range order {
+		conds := []string{}
+
+		for _, prev := range order[:i] {
+			conds = append(conds, sortColumns[prev.Field].expr+" = ?")
+			args = append(args, sortColumns[prev.Field].value(after))
+		}
+
+		op := " > ?"
+
+		if f.Desc {
+			op = " < ?"
+		}
+
+		conds = append(conds, sortColumns[f.Field].expr+op)
+		args = append(args, sortColumns[f.Field].value(after))
+		terms = append(terms, "("+strings.Join(conds, " AND ")+")")
+	}
+
+	return strings.Join(terms, " OR "), args
+}
+
+func (ns *noteStorage) GetAll(ctx context.Context, q service.NotesQuery) ([]*models.Note, error) {
+	order, err := checkSort(q.Sort)
+
+	if err != nil {
+		return nil, err
+	}
+
+	query := "SELECT " + noteColumns + " FROM note"
+	conds := []string{"deleted_at IS NULL"}
+	args := []interface{}{}
+
+	if q.Trashed {
+		conds[0] = "deleted_at IS NOT NULL"
+	}
+
+	if len(q.Notebooks) > 0 {
+		conds = append(conds, "notebook_id IN ("+placeholders(len(q.Notebooks))+")")
+
+		for _, notebookID := range q.Notebooks {
+			args = append(args, notebookID)
+		}
+	}
+
+	if len(q.Tags) > 0 {
+		cond, condArgs := tagFilter(q.Tags, q.AnyTag)
+		conds = append(conds, cond)
+		args = append(args, condArgs...)
+	}
+
+	metaConds, metaArgs := metaFilter(q)
+	conds = append(conds, metaConds...)
+	args = append(args, metaArgs...)
+
+	if q.After != nil {
+		cond, condArgs := keyset(order, q.After)
+		conds = append(conds, "("+cond+")")
+		args = append(args, condArgs...)
+	}
+
+	query, args = permitted(ctx, query+" WHERE "+strings.Join(conds, " AND "), args)
+
+	columns := make([]string, 0, len(order))
+
+	for _, f := range order {
+		if f.Desc {
+			columns = append(columns, sortColumns[f.Field].expr+" DESC")
+		} else {
+			columns = append(columns, sortColumns[f.Field].expr)
+		}
+	}
+
+	query += " ORDER BY " + strings.Join(columns, ", ")
+
+	if q.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, q.Limit)
 	}
 
 	notes := []*models.Note{}
-	query := fmt.Sprintf("SELECT id, text, created_at, updated_at FROM note ORDER BY %s", orderBy)
-	rows, err := ns.db.Query(query)
+	rows, err := ns.db.QueryContext(ctx, ns.dialect.rebind(query), args...)
 
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		note := &models.Note{}
-		err = rows.Scan(&note.ID, &note.Text, &note.CreatedAt, &note.UpdatedAt)
+		err = rows.Scan(noteFields(note)...)
 
 		if err != nil {
 			return nil, err
@@ -95,7 +357,35 @@ func (ns *noteStorage) GetAll(ctx context.Context, orderBy string) ([]*models.No
 
 		notes = append(notes, note)
 	}
-	defer rows.Close()
 
-	return notes, nil
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows.Close()
+
+	return notes, ns.loadTags(ctx, ns.db, notes)
+}
+
+// insert runs an INSERT and returns the generated id, using RETURNING where
+// the backend has no LastInsertId.
+func (ns *noteStorage) insert(ctx context.Context, q dbtx, query string, args ...interface{}) (int64, error) {
+	if ns.dialect.returning {
+		var id int64
+		err := q.QueryRowContext(ctx, ns.dialect.rebind(query+" RETURNING id"), args...).Scan(&id)
+
+		return id, ns.dialect.mapError(err)
+	}
+
+	result, err := q.ExecContext(ctx, query, args...)
+
+	if err != nil {
+		return 0, ns.dialect.mapError(err)
+	}
+
+	if row, _ := result.RowsAffected(); row == 0 {
+		return 0, errors.New("afftected 0 row")
+	}
+
+	return result.LastInsertId()
 }
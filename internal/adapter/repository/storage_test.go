@@ -2,13 +2,16 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"testing"
 	"time"
 
 	"note/internal/models"
+	"note/internal/service"
 	"reflect"
 
+	"github.com/go-sql-driver/mysql"
 	"gopkg.in/DATA-DOG/go-sqlmock.v1"
 )
 
@@ -22,7 +25,7 @@ func TestGet(t *testing.T) {
 	defer db.Close()
 
 	var id string = "1"
-	var ctx context.Context
+	ctx := service.AsSystem(context.Background())
 
 	ti := time.Now()
 
@@ -30,12 +33,16 @@ func TestGet(t *testing.T) {
 	expect := &models.Note{
 		ID:        1,
 		Text:      "text message",
+		Tags:      []string{"home", "work"},
 		CreatedAt: ti,
 		UpdatedAt: ti,
+		Version:   1,
 	}
 	repo := NewStorage(db)
+	tagRows := sqlmock.NewRows([]string{"note_id", "name"}).AddRow(1, "home").AddRow(1, "work")
 
-	mock.ExpectQuery("SELECT id, text, created_at, updated_at FROM note WHERE").WithArgs(id).WillReturnRows(rows)
+	mock.ExpectQuery(`SELECT id, title, text, summary, color, pinned, archived, notebook_id, created_at, updated_at, version, deleted_at FROM note WHERE id=\? AND deleted_at IS NULL`).WithArgs(id).WillReturnRows(rows)
+	mock.ExpectQuery("SELECT note_tag.note_id, tag.name FROM note_tag").WithArgs(1).WillReturnRows(tagRows)
 
 	note, err := repo.Get(ctx, id)
 
@@ -54,7 +61,7 @@ func TestGet(t *testing.T) {
 		return
 	}
 
-	mock.ExpectQuery("SELECT id, text, created_at, updated_at FROM note WHERE").WithArgs(id).WillReturnError(errors.New("some error"))
+	mock.ExpectQuery(`SELECT id, title, text, summary, color, pinned, archived, notebook_id, created_at, updated_at, version, deleted_at FROM note WHERE id=\? AND deleted_at IS NULL`).WithArgs(id).WillReturnError(errors.New("some error"))
 
 	_, err = repo.Get(ctx, "1")
 
@@ -76,9 +83,30 @@ func TestGet(t *testing.T) {
 	}
 }
 
-func testCUDperation(t *testing.T, operationName string, operation func() error, mock sqlmock.Sqlmock) {
+// testCUDperation checks an operation for success, a failure and zero
+// affected rows. Creates and updates run in a transaction that also writes
+// a revision.
+func testCUDperation(t *testing.T, operationName string, operation func() error, mock sqlmock.Sqlmock, inTx bool) {
+	begin := func() {
+		if inTx {
+			mock.ExpectBegin()
+		}
+	}
+
+	rollback := func() {
+		if inTx {
+			mock.ExpectRollback()
+		}
+	}
+
+	begin()
 	mock.ExpectExec(operationName).WillReturnResult(sqlmock.NewResult(1, 1))
 
+	if inTx {
+		expectRevision(mock)
+		mock.ExpectCommit()
+	}
+
 	err := operation()
 
 	if err != nil {
@@ -91,7 +119,9 @@ func testCUDperation(t *testing.T, operationName string, operation func() error,
 		return
 	}
 
+	begin()
 	mock.ExpectExec(operationName).WillReturnError(errors.New("some error"))
+	rollback()
 
 	err = operation()
 
@@ -105,7 +135,9 @@ func testCUDperation(t *testing.T, operationName string, operation func() error,
 		return
 	}
 
+	begin()
 	mock.ExpectExec(operationName).WillReturnResult(sqlmock.NewResult(0, 0))
+	rollback()
 
 	err = operation()
 
@@ -120,6 +152,11 @@ func testCUDperation(t *testing.T, operationName string, operation func() error,
 	}
 }
 
+func expectRevision(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(`INSERT INTO note_revision \(note_id, revision, title, text, summary, created_at\)\s+SELECT id, COALESCE`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
 func TestCreate(t *testing.T) {
 	db, mock, err := sqlmock.New()
 
@@ -129,20 +166,43 @@ func TestCreate(t *testing.T) {
 	}
 	defer db.Close()
 
-	var ctx context.Context
+	ctx := service.AsSystem(context.Background())
 	repo := NewStorage(db)
 
 	operation := func() error {
-		return repo.Create(ctx, "message")
+		_, err := repo.Create(ctx, service.NoteFields{Text: "message"})
+		return err
 	}
-	testCUDperation(t, "INSERT INTO note", operation, mock)
+	testCUDperation(t, "INSERT INTO note", operation, mock, true)
 
-	err = repo.Create(ctx, "")
+	_, err = repo.Create(ctx, service.NoteFields{Text: ""})
 
 	if err == nil {
 		t.Error("expected error, got nil")
 		return
 	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO note").WithArgs("", "message", "", "", false, false, nil, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(7, 1))
+	expectRevision(mock)
+	mock.ExpectCommit()
+
+	note, err := repo.Create(ctx, service.NoteFields{Text: "message"})
+
+	if err != nil {
+		t.Errorf("unexpected err: %s", err)
+		return
+	}
+
+	if note.ID != 7 || note.Text != "message" || note.CreatedAt.IsZero() || !note.CreatedAt.Equal(note.UpdatedAt) {
+		t.Errorf("unexpected note: %+v", note)
+		return
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+		return
+	}
 }
 
 func TestUpdate(t *testing.T) {
@@ -154,22 +214,22 @@ func TestUpdate(t *testing.T) {
 	}
 	defer db.Close()
 
-	var ctx context.Context
+	ctx := service.AsSystem(context.Background())
 	repo := NewStorage(db)
 
 	operation := func() error {
-		return repo.Update(ctx, "1", "message")
+		return repo.Update(ctx, "1", service.NoteFields{Text: "message"})
 	}
-	testCUDperation(t, "UPDATE note", operation, mock)
+	testCUDperation(t, "UPDATE note", operation, mock, true)
 
-	err = repo.Update(ctx, "1", "")
+	err = repo.Update(ctx, "1", service.NoteFields{Text: ""})
 
 	if err == nil {
 		t.Error("expected error, got nil")
 		return
 	}
 
-	err = repo.Update(ctx, "", "message")
+	err = repo.Update(ctx, "", service.NoteFields{Text: "message"})
 
 	if err == nil {
 		t.Error("expected error, got nil")
@@ -186,15 +246,15 @@ func TestDelete(t *testing.T) {
 	}
 	defer db.Close()
 
-	var ctx context.Context
+	ctx := service.AsSystem(context.Background())
 	repo := NewStorage(db)
 
 	operation := func() error {
-		return repo.Delete(ctx, "1")
+		return repo.Delete(ctx, "1", 0)
 	}
-	testCUDperation(t, "DELETE FROM note WHERE", operation, mock)
+	testCUDperation(t, `UPDATE note SET deleted_at=\?, version=version\+1 WHERE id=\? AND deleted_at IS NULL`, operation, mock, false)
 
-	err = repo.Delete(ctx, "")
+	err = repo.Delete(ctx, "", 0)
 
 	if err == nil {
 		t.Error("expected error, got nil")
@@ -204,9 +264,9 @@ func TestDelete(t *testing.T) {
 
 func getRows(count int, text string, ti time.Time) *sqlmock.Rows {
 
-	rows := sqlmock.NewRows([]string{"id", "text", "created_at", "updated_at"})
+	rows := sqlmock.NewRows([]string{"id", "title", "text", "summary", "color", "pinned", "archived", "notebook_id", "created_at", "updated_at", "version", "deleted_at"})
 	for i := 0; i < count; i++ {
-		rows.AddRow(i+1, text, ti, ti)
+		rows.AddRow(i+1, "", text, "", "", false, false, nil, ti, ti, 1, nil)
 	}
 
 	return rows
@@ -221,7 +281,7 @@ func TestGetList(t *testing.T) {
 	}
 	defer db.Close()
 
-	var ctx context.Context
+	ctx := service.AsSystem(context.Background())
 	repo := NewStorage(db)
 	ti := time.Now()
 
@@ -230,26 +290,33 @@ func TestGetList(t *testing.T) {
 		{
 			ID:        1,
 			Text:      "text message",
+			Tags:      []string{},
 			CreatedAt: ti,
 			UpdatedAt: ti,
+			Version:   1,
 		},
 		{
 			ID:        2,
 			Text:      "text message",
+			Tags:      []string{},
 			CreatedAt: ti,
 			UpdatedAt: ti,
+			Version:   1,
 		},
 		{
 			ID:        3,
 			Text:      "text message",
+			Tags:      []string{},
 			CreatedAt: ti,
 			UpdatedAt: ti,
+			Version:   1,
 		},
 	}
 
-	mock.ExpectQuery("SELECT id, text, created_at, updated_at FROM note ORDER BY").WillReturnRows(rows)
+	mock.ExpectQuery("SELECT id, title, text, summary, color, pinned, archived, notebook_id, created_at, updated_at, version, deleted_at FROM note WHERE deleted_at IS NULL ORDER BY").WillReturnRows(rows)
+	mock.ExpectQuery("FROM note_tag").WithArgs(1, 2, 3).WillReturnRows(sqlmock.NewRows([]string{"note_id", "name"}))
 
-	notes, err := repo.GetAll(ctx, "id")
+	notes, err := repo.GetAll(ctx, service.NotesQuery{Sort: service.Sort{{Field: "id"}}})
 
 	if err != nil {
 		t.Errorf("unexpected err: %s", err)
@@ -266,9 +333,9 @@ func TestGetList(t *testing.T) {
 		return
 	}
 
-	mock.ExpectQuery("SELECT id, text, created_at, updated_at FROM note ORDER BY").WillReturnError(errors.New("some error"))
+	mock.ExpectQuery("SELECT id, title, text, summary, color, pinned, archived, notebook_id, created_at, updated_at, version, deleted_at FROM note WHERE deleted_at IS NULL ORDER BY").WillReturnError(errors.New("some error"))
 
-	_, err = repo.GetAll(ctx, "id")
+	_, err = repo.GetAll(ctx, service.NotesQuery{Sort: service.Sort{{Field: "id"}}})
 
 	if err == nil {
 		t.Error("expected error, got nil")
@@ -282,9 +349,9 @@ func TestGetList(t *testing.T) {
 
 	errorRows := sqlmock.NewRows([]string{"time"}).AddRow(time.Now()).AddRow(time.Now())
 
-	mock.ExpectQuery("SELECT id, text, created_at, updated_at FROM note ORDER BY").WillReturnRows(errorRows)
+	mock.ExpectQuery("SELECT id, title, text, summary, color, pinned, archived, notebook_id, created_at, updated_at, version, deleted_at FROM note WHERE deleted_at IS NULL ORDER BY").WillReturnRows(errorRows)
 
-	_, err = repo.GetAll(ctx, "id")
+	_, err = repo.GetAll(ctx, service.NotesQuery{Sort: service.Sort{{Field: "id"}}})
 
 	if err == nil {
 		t.Error("expected error, got nil")
@@ -298,9 +365,10 @@ func TestGetList(t *testing.T) {
 
 	rows = getRows(3, "text message", ti)
 
-	mock.ExpectQuery("SELECT id, text, created_at, updated_at FROM note ORDER BY").WillReturnRows(rows)
+	mock.ExpectQuery("SELECT id, title, text, summary, color, pinned, archived, notebook_id, created_at, updated_at, version, deleted_at FROM note WHERE deleted_at IS NULL ORDER BY").WillReturnRows(rows)
+	mock.ExpectQuery("FROM note_tag").WithArgs(1, 2, 3).WillReturnRows(sqlmock.NewRows([]string{"note_id", "name"}))
 
-	notes, err = repo.GetAll(ctx, "")
+	notes, err = repo.GetAll(ctx, service.NotesQuery{})
 
 	if err != nil {
 		t.Errorf("unexpected err: %s", err)
@@ -317,10 +385,698 @@ func TestGetList(t *testing.T) {
 		return
 	}
 
-	_, err = repo.GetAll(ctx, "test")
+	_, err = repo.GetAll(ctx, service.NotesQuery{Sort: service.Sort{{Field: "test"}}})
 
 	if err == nil {
 		t.Error("expected error, got nil")
 		return
 	}
+
+	after := &models.Note{ID: 3, Text: "text message"}
+
+	mock.ExpectQuery(`FROM note WHERE deleted_at IS NULL AND \(\(SUBSTR\(text, 1, 100\) > \?\) OR \(SUBSTR\(text, 1, 100\) = \? AND id > \?\)\) ORDER BY SUBSTR\(text, 1, 100\), id LIMIT \?`).
+		WithArgs("text message", "text message", 3, 2).
+		WillReturnRows(getRows(0, "", ti))
+
+	if _, err = repo.GetAll(ctx, service.NotesQuery{Sort: service.Sort{{Field: "text"}}, Limit: 2, After: after}); err != nil {
+		t.Errorf("unexpected err: %s", err)
+		return
+	}
+
+	mock.ExpectQuery(`FROM note WHERE deleted_at IS NULL AND \(\(id > \?\)\) ORDER BY id LIMIT \?`).
+		WithArgs(3, 2).
+		WillReturnRows(getRows(0, "", ti))
+
+	if _, err = repo.GetAll(ctx, service.NotesQuery{Sort: service.Sort{{Field: "id"}}, Limit: 2, After: after}); err != nil {
+		t.Errorf("unexpected err: %s", err)
+		return
+	}
+
+	after.UpdatedAt = ti
+	mixed := service.Sort{{Field: "updated_at", Desc: true}, {Field: "id"}}
+
+	mock.ExpectQuery(`FROM note WHERE deleted_at IS NULL AND \(\(updated_at < \?\) OR \(updated_at = \? AND id > \?\)\) ORDER BY updated_at DESC, id LIMIT \?`).
+		WithArgs(ti, ti, 3, 2).
+		WillReturnRows(getRows(0, "", ti))
+
+	if _, err = repo.GetAll(ctx, service.NotesQuery{Sort: mixed, Limit: 2, After: after}); err != nil {
+		t.Errorf("unexpected err: %s", err)
+		return
+	}
+
+	// Unknown fields never reach the database.
+	injection := service.Sort{{Field: "id; DROP TABLE note"}}
+
+	if _, err = repo.GetAll(ctx, service.NotesQuery{Sort: injection}); !errors.Is(err, service.ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput, got %v", err)
+		return
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestDomainErrors(t *testing.T) {
+	db, mock, err := sqlmock.New()
+
+	if err != nil {
+		t.Fatalf("can't create mock: %s", err)
+		return
+	}
+	defer db.Close()
+
+	ctx := service.AsSystem(context.Background())
+	repo := NewStorage(db)
+
+	mock.ExpectQuery(`SELECT id, title, text, summary, color, pinned, archived, notebook_id, created_at, updated_at, version, deleted_at FROM note WHERE id=\? AND deleted_at IS NULL`).WithArgs("1").WillReturnError(sql.ErrNoRows)
+
+	_, err = repo.Get(ctx, "1")
+
+	if !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+		return
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE note").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	if err = repo.Update(ctx, "1", service.NoteFields{Text: "message"}); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+		return
+	}
+
+	mock.ExpectExec("UPDATE note SET deleted_at").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err = repo.Delete(ctx, "1", 0); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+		return
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO note").WillReturnError(&mysql.MySQLError{Number: mysqlDuplicateEntry, Message: "Duplicate entry"})
+	mock.ExpectRollback()
+
+	if _, err = repo.Create(ctx, service.NoteFields{Text: "message"}); !errors.Is(err, service.ErrConflict) {
+		t.Errorf("expected ErrConflict, got %v", err)
+		return
+	}
+
+	if _, err = repo.Get(ctx, "abc"); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for malformed id, got %v", err)
+		return
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+		return
+	}
+}
+
+func TestSearch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+
+	if err != nil {
+		t.Fatalf("can't create mock: %s", err)
+		return
+	}
+	defer db.Close()
+
+	ctx := service.AsSystem(context.Background())
+	repo := NewStorage(db)
+	ti := time.Now()
+	terms := []service.SearchTerm{
+		{Words: []string{"quick", "brown"}},
+		{Words: []string{"fox"}},
+		{Words: []string{"jump"}, Prefix: true},
+	}
+	expr := `+"quick brown" +fox +jump*`
+	rows := sqlmock.NewRows([]string{"id", "title", "text", "summary", "color", "pinned", "archived", "notebook_id", "created_at", "updated_at", "version", "deleted_at", "score"}).
+		AddRow(2, "", "the quick brown fox jumps", "", "", false, false, nil, ti, ti, 1, nil, 1.5)
+
+	mock.ExpectQuery(`MATCH \(text\) AGAINST \(\? IN BOOLEAN MODE\)`).WithArgs(expr, expr, 10).WillReturnRows(rows)
+	mock.ExpectQuery("FROM note_tag").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"note_id", "name"}))
+
+	results, err := repo.Search(ctx, service.SearchQuery{Terms: terms, Limit: 10})
+
+	if err != nil {
+		t.Errorf("unexpected err: %s", err)
+		return
+	}
+
+	if len(results) != 1 || results[0].Note.ID != 2 || results[0].Rank != 1.5 {
+		t.Errorf("unexpected results: %+v", results)
+		return
+	}
+
+	mock.ExpectQuery("MATCH").WillReturnError(errors.New("some error"))
+
+	if _, err = repo.Search(ctx, service.SearchQuery{Terms: terms, Limit: 10}); err == nil {
+		t.Error("expected error, got nil")
+		return
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestSearchExpressions(t *testing.T) {
+	terms := []service.SearchTerm{
+		{Words: []string{"quick", "brown"}},
+		{Words: []string{"fox"}},
+		{Words: []string{"jump"}, Prefix: true},
+	}
+
+	if got := tsquery(terms); got != "quick <-> brown & fox & jump:*" {
+		t.Errorf("unexpected tsquery: %q", got)
+	}
+
+	if got := ftsMatch(terms); got != `"quick brown" "fox" "jump*"` {
+		t.Errorf("unexpected MATCH expression: %q", got)
+	}
+}
+
+func TestTags(t *testing.T) {
+	db, mock, err := sqlmock.New()
+
+	if err != nil {
+		t.Fatalf("can't create mock: %s", err)
+		return
+	}
+	defer db.Close()
+
+	ctx := service.AsSystem(context.Background())
+	repo := NewStorage(db)
+	fields := service.NoteFields{Text: "message", Tags: []string{"work", "home"}}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO note").WillReturnResult(sqlmock.NewResult(7, 1))
+	expectRevision(mock)
+	mock.ExpectExec("DELETE FROM note_tag WHERE note_id").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT IGNORE INTO tag").WithArgs("work").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO note_tag").WithArgs(7, "work").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT IGNORE INTO tag").WithArgs("home").WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec("INSERT INTO note_tag").WithArgs(7, "home").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	note, err := repo.Create(ctx, fields)
+
+	if err != nil {
+		t.Errorf("unexpected err: %s", err)
+		return
+	}
+
+	if note.ID != 7 || !reflect.DeepEqual(note.Tags, []string{"home", "work"}) || fields.Tags[0] != "work" {
+		t.Errorf("unexpected note: %+v", note)
+		return
+	}
+
+	// A failed tag write rolls back the whole update.
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE note").WillReturnResult(sqlmock.NewResult(0, 1))
+	expectRevision(mock)
+	mock.ExpectExec("DELETE FROM note_tag WHERE note_id").WithArgs(7).WillReturnError(errors.New("some error"))
+	mock.ExpectRollback()
+
+	if err = repo.Update(ctx, "7", fields); err == nil {
+		t.Error("expected error, got nil")
+		return
+	}
+
+	// Nil tags keep the current ones.
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE note").WillReturnResult(sqlmock.NewResult(0, 1))
+	expectRevision(mock)
+	mock.ExpectCommit()
+
+	if err = repo.Update(ctx, "7", service.NoteFields{Text: "message"}); err != nil {
+		t.Errorf("unexpected err: %s", err)
+		return
+	}
+
+	mock.ExpectQuery(`FROM note WHERE deleted_at IS NULL AND id IN \(SELECT note_tag.note_id FROM note_tag JOIN tag ON tag.id = note_tag.tag_id `+
+		`WHERE tag.name IN \(\?, \?\) GROUP BY note_tag.note_id HAVING COUNT\(\*\) = \?\) ORDER BY id`).
+		WithArgs("home", "work", 2).
+		WillReturnRows(getRows(0, "", time.Now()))
+
+	if _, err = repo.GetAll(ctx, service.NotesQuery{Tags: []string{"home", "work"}}); err != nil {
+		t.Errorf("unexpected err: %s", err)
+		return
+	}
+
+	mock.ExpectQuery(`FROM note WHERE deleted_at IS NULL AND id IN \(SELECT .* WHERE tag.name IN \(\?, \?\)\) ORDER BY id`).
+		WithArgs("home", "work").
+		WillReturnRows(getRows(0, "", time.Now()))
+
+	if _, err = repo.GetAll(ctx, service.NotesQuery{Tags: []string{"home", "work"}, AnyTag: true}); err != nil {
+		t.Errorf("unexpected err: %s", err)
+		return
+	}
+
+	counts := sqlmock.NewRows([]string{"name", "uses"}).AddRow("work", 2).AddRow("home", 1)
+	mock.ExpectQuery("SELECT tag.name, COUNT").WillReturnRows(counts)
+
+	tags, err := repo.Tags(ctx)
+
+	if err != nil {
+		t.Errorf("unexpected err: %s", err)
+		return
+	}
+
+	if len(tags) != 2 || *tags[0] != (models.Tag{Name: "work", Count: 2}) {
+		t.Errorf("unexpected tags: %+v", tags)
+		return
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func notebookRows(id int64, parentID interface{}) *sqlmock.Rows {
+	ti := time.Now()
+
+	return sqlmock.NewRows([]string{"id", "name", "parent_id", "created_at", "updated_at"}).AddRow(id, "notebook", parentID, ti, ti)
+}
+
+func TestNotebooks(t *testing.T) {
+	db, mock, err := sqlmock.New()
+
+	if err != nil {
+		t.Fatalf("can't create mock: %s", err)
+		return
+	}
+	defer db.Close()
+
+	ctx := service.AsSystem(context.Background())
+	repo := NewStorage(db)
+	parent := int64(3)
+
+	// Moving 1 under 3 walks up 3 -> 2 -> 1 and finds a cycle.
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, name, parent_id, created_at, updated_at FROM notebook WHERE").WithArgs(1).WillReturnRows(notebookRows(1, nil))
+	mock.ExpectQuery("SELECT id, name, parent_id, created_at, updated_at FROM notebook WHERE").WithArgs(3).WillReturnRows(notebookRows(3, 2))
+	mock.ExpectQuery("SELECT id, name, parent_id, created_at, updated_at FROM notebook WHERE").WithArgs(2).WillReturnRows(notebookRows(2, 1))
+	mock.ExpectRollback()
+
+	err = repo.UpdateNotebook(ctx, "1", service.NotebookFields{Name: "notebook", ParentID: &parent})
+
+	if !errors.Is(err, service.ErrConflict) {
+		t.Errorf("expected ErrConflict, got %v", err)
+		return
+	}
+
+	// MySQL counts no rows affected when nothing changes, which is no 404.
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM notebook WHERE id").WithArgs(1).WillReturnRows(notebookRows(1, nil))
+	mock.ExpectQuery("FROM notebook WHERE id").WithArgs(3).WillReturnRows(notebookRows(3, nil))
+	mock.ExpectExec("UPDATE notebook SET").WithArgs("notebook", 3, sqlmock.AnyArg(), 1).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	if err = repo.UpdateNotebook(ctx, "1", service.NotebookFields{Name: "notebook", ParentID: &parent}); err != nil {
+		t.Errorf("unexpected err: %s", err)
+		return
+	}
+
+	// A notebook with notes is only deleted recursively.
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM notebook WHERE id").WithArgs(1).WillReturnRows(notebookRows(1, nil))
+	mock.ExpectQuery("SELECT id FROM notebook WHERE parent_id").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("SELECT COUNT").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectRollback()
+
+	if err = repo.DeleteNotebook(ctx, "1", false); !errors.Is(err, service.ErrConflict) {
+		t.Errorf("expected ErrConflict, got %v", err)
+		return
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM notebook WHERE id").WithArgs(1).WillReturnRows(notebookRows(1, nil))
+	mock.ExpectQuery("SELECT id FROM notebook WHERE parent_id").WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery("SELECT id FROM notebook WHERE parent_id").WithArgs(2).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec(`UPDATE note SET notebook_id=NULL WHERE deleted_at IS NOT NULL AND notebook_id IN \(\?, \?\)`).WithArgs(1, 2).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`UPDATE note SET deleted_at=\?, notebook_id=NULL, version=version\+1 WHERE deleted_at IS NULL AND notebook_id IN \(\?, \?\)`).
+		WithArgs(sqlmock.AnyArg(), 1, 2).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("DELETE FROM notebook WHERE id").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM notebook WHERE id").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err = repo.DeleteNotebook(ctx, "1", true); err != nil {
+		t.Errorf("unexpected err: %s", err)
+		return
+	}
+
+	if err = repo.DeleteNotebook(ctx, "abc", true); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+		return
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	db, mock, err := sqlmock.New()
+
+	if err != nil {
+		t.Fatalf("can't create mock: %s", err)
+		return
+	}
+	defer db.Close()
+
+	ctx := service.AsSystem(context.Background())
+	repo := NewStorage(db)
+	pinned := true
+
+	// Only the fields that are set change, the others stay as stored.
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE note SET text=\?, title=COALESCE\(\?, title\)`).
+		WithArgs("message", nil, nil, nil, true, nil, sqlmock.AnyArg(), "7").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO note_revision").WithArgs(int64(7), int64(7)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err = repo.Update(ctx, "7", service.NoteFields{Text: "message", Pinned: &pinned}); err != nil {
+		t.Errorf("unexpected err: %s", err)
+		return
+	}
+
+	mock.ExpectQuery(`FROM note WHERE deleted_at IS NULL AND pinned = \? AND color = \? ORDER BY id`).
+		WithArgs(true, "red").
+		WillReturnRows(getRows(0, "", time.Now()))
+
+	if _, err = repo.GetAll(ctx, service.NotesQuery{Pinned: &pinned, Color: "red"}); err != nil {
+		t.Errorf("unexpected err: %s", err)
+		return
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestTrash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+
+	if err != nil {
+		t.Fatalf("can't create mock: %s", err)
+		return
+	}
+	defer db.Close()
+
+	ctx := service.AsSystem(context.Background())
+	repo := NewStorage(db)
+
+	mock.ExpectQuery(`FROM note WHERE deleted_at IS NOT NULL ORDER BY id`).WillReturnRows(getRows(0, "", time.Now()))
+
+	if _, err = repo.GetAll(ctx, service.NotesQuery{Trashed: true}); err != nil {
+		t.Errorf("unexpected err: %s", err)
+		return
+	}
+
+	mock.ExpectExec(`UPDATE note SET deleted_at=NULL, version=version\+1 WHERE id=\? AND deleted_at IS NOT NULL`).WithArgs("7").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err = repo.Restore(ctx, "7"); err != nil {
+		t.Errorf("unexpected err: %s", err)
+		return
+	}
+
+	mock.ExpectExec(`DELETE FROM note WHERE id=\? AND deleted_at IS NOT NULL`).WithArgs("7").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err = repo.Purge(ctx, "7"); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+		return
+	}
+
+	before := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectExec(`DELETE FROM note WHERE deleted_at IS NOT NULL AND deleted_at < \?`).WithArgs(before).WillReturnResult(sqlmock.NewResult(0, 3))
+
+	if purged, purgeErr := repo.PurgeTrashed(ctx, before); purgeErr != nil || purged != 3 {
+		t.Errorf("expected 3 purged notes, got %d, %v", purged, purgeErr)
+		return
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestRevisions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+
+	if err != nil {
+		t.Fatalf("can't create mock: %s", err)
+		return
+	}
+	defer db.Close()
+
+	ctx := service.AsSystem(context.Background())
+	repo := NewStorage(db)
+	ti := time.Now()
+	live := func(count int) {
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM note WHERE id=\? AND deleted_at IS NULL`).WithArgs("7").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(count))
+	}
+	columns := []string{"note_id", "revision", "title", "text", "summary", "created_at"}
+
+	live(1)
+	mock.ExpectQuery(`SELECT note_id, revision, title, text, summary, created_at FROM note_revision WHERE note_id=\? ORDER BY revision DESC`).
+		WithArgs("7").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(7, 2, "", "second", "", ti).AddRow(7, 1, "", "first", "", ti))
+
+	revisions, err := repo.Revisions(ctx, "7")
+
+	if err != nil || len(revisions) != 2 || revisions[0].Revision != 2 || revisions[1].Text != "first" {
+		t.Errorf("unexpected revisions: %+v, %v", revisions, err)
+		return
+	}
+
+	live(1)
+	mock.ExpectQuery(`FROM note_revision WHERE note_id=\? AND revision=\?`).WithArgs("7", 3).WillReturnError(sql.ErrNoRows)
+
+	if _, err = repo.Revision(ctx, "7", 3); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+		return
+	}
+
+	live(0)
+
+	if _, err = repo.Revision(ctx, "7", 1); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+		return
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestVersions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+
+	if err != nil {
+		t.Fatalf("can't create mock: %s", err)
+		return
+	}
+	defer db.Close()
+
+	ctx := service.AsSystem(context.Background())
+	repo := NewStorage(db)
+	live := func(count int) {
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM note WHERE id=\? AND deleted_at IS NULL`).WithArgs("7").
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(count))
+	}
+
+	// The version is checked by the UPDATE itself.
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE note SET .* version=version\+1 WHERE id=\? AND deleted_at IS NULL AND version=\?`).
+		WithArgs("message", nil, nil, nil, nil, nil, sqlmock.AnyArg(), "7", int64(3)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	live(1)
+	mock.ExpectRollback()
+
+	if err = repo.Update(ctx, "7", service.NoteFields{Text: "message", IfVersion: 3}); !errors.Is(err, service.ErrPreconditionFailed) {
+		t.Errorf("expected ErrPreconditionFailed, got %v", err)
+		return
+	}
+
+	mock.ExpectExec(`UPDATE note SET deleted_at=\?, version=version\+1 WHERE id=\? AND deleted_at IS NULL AND version=\?`).
+		WithArgs(sqlmock.AnyArg(), "7", int64(3)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	live(0)
+
+	if err = repo.Delete(ctx, "7", 3); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+		return
+	}
+
+	mock.ExpectExec(`UPDATE note SET deleted_at=\?, version=version\+1 WHERE id=\? AND deleted_at IS NULL AND version=\?`).
+		WithArgs(sqlmock.AnyArg(), "7", int64(3)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err = repo.Delete(ctx, "7", 3); err != nil {
+		t.Errorf("unexpected err: %s", err)
+		return
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+
+	if err != nil {
+		t.Fatalf("can't create mock: %s", err)
+		return
+	}
+	defer db.Close()
+
+	ctx := service.AsSystem(context.Background())
+	repo := NewStorage(db)
+	operations := []service.NoteOperation{
+		{Op: service.BatchDelete, ID: "7"},
+		{Op: service.BatchDelete, ID: "8"},
+	}
+
+	// Every operation of a partial batch is undone alone.
+	mock.ExpectBegin()
+	mock.ExpectExec(`SAVEPOINT batch_operation`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`UPDATE note SET deleted_at=\?, version=version\+1 WHERE id=\?`).WithArgs(sqlmock.AnyArg(), "7").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`ROLLBACK TO SAVEPOINT batch_operation`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`RELEASE SAVEPOINT batch_operation`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SAVEPOINT batch_operation`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`UPDATE note SET deleted_at=\?, version=version\+1 WHERE id=\?`).WithArgs(sqlmock.AnyArg(), "8").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`RELEASE SAVEPOINT batch_operation`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	results, err := repo.Batch(ctx, operations, false)
+
+	if err != nil || !errors.Is(results[0].Err, service.ErrNotFound) || results[1].Err != nil {
+		t.Errorf("unexpected results: %+v, %v", results, err)
+		return
+	}
+
+	// The first failure rolls back an atomic batch.
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE note SET deleted_at=\?, version=version\+1 WHERE id=\?`).WithArgs(sqlmock.AnyArg(), "7").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	results, err = repo.Batch(ctx, operations, true)
+
+	if err != nil || !errors.Is(results[0].Err, service.ErrNotFound) || !errors.Is(results[1].Err, service.ErrBatchAborted) {
+		t.Errorf("unexpected results: %+v, %v", results, err)
+		return
+	}
+
+	mock.ExpectBegin().WillReturnError(errors.New("some error"))
+
+	if _, err = repo.Batch(ctx, operations, true); err == nil {
+		t.Errorf("expected an error")
+		return
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestOwners(t *testing.T) {
+	db, mock, err := sqlmock.New()
+
+	if err != nil {
+		t.Fatalf("can't create mock: %s", err)
+		return
+	}
+	defer db.Close()
+
+	ctx := service.WithUser(context.Background(), &models.User{ID: 5})
+	repo := NewStorage(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO note \(.*owner_id.*\)`).
+		WithArgs("", "message", "", "", false, false, nil, int64(5), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(7, 1))
+	mock.ExpectExec("INSERT INTO note_revision").WithArgs(int64(7), int64(7)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if _, err = repo.Create(ctx, service.NoteFields{Text: "message"}); err != nil {
+		t.Errorf("unexpected err: %s", err)
+		return
+	}
+
+	// The owner goes before the version, like the conditions are appended.
+	mock.ExpectExec(`UPDATE note SET deleted_at=\?, version=version\+1 WHERE id=\? AND deleted_at IS NULL AND note.owner_id=\? AND version=\?`).
+		WithArgs(sqlmock.AnyArg(), "7", int64(5), int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM note WHERE id=\? AND deleted_at IS NULL AND note.owner_id=\?`).WithArgs("7", int64(5)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	// Not found for the owner, the note may still be shared with the user.
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM note WHERE id=\? AND deleted_at IS NULL AND \(note.owner_id=\? OR note.id IN`).
+		WithArgs("7", int64(5), service.RoleEditor, service.RoleViewer, int64(5), int64(5)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+	if err = repo.Delete(ctx, "7", 2); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+		return
+	}
+
+	mock.ExpectQuery(`FROM note WHERE deleted_at IS NULL AND note.owner_id=\? ORDER BY id LIMIT \?`).WithArgs(int64(5), 10).
+		WillReturnRows(getRows(0, "", time.Now()))
+
+	if _, err = repo.GetAll(ctx, service.NotesQuery{Limit: 10}); err != nil {
+		t.Errorf("unexpected err: %s", err)
+		return
+	}
+
+	expr := mysqlBoolean([]service.SearchTerm{{Words: []string{"plan"}}})
+	mock.ExpectQuery(`AND deleted_at IS NULL AND note.owner_id=\? ORDER BY score DESC, id LIMIT \?`).WithArgs(expr, expr, int64(5), 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	if _, err = repo.Search(ctx, service.SearchQuery{Terms: []service.SearchTerm{{Words: []string{"plan"}}}, Limit: 10}); err != nil {
+		t.Errorf("unexpected err: %s", err)
+		return
+	}
+
+	mock.ExpectQuery(`WHERE note.deleted_at IS NULL AND note.owner_id=\? GROUP BY tag.name`).WithArgs(int64(5)).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "uses"}))
+	mock.ExpectQuery(`FROM notebook WHERE owner_id=\? ORDER BY name, id`).WithArgs(int64(5)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "parent_id", "created_at", "updated_at"}))
+
+	if _, err = repo.Tags(ctx); err != nil {
+		t.Errorf("unexpected err: %s", err)
+		return
+	}
+
+	if _, err = repo.Notebooks(ctx); err != nil {
+		t.Errorf("unexpected err: %s", err)
+		return
+	}
+
+	// Editors and viewers also reach the notes shared with them or their
+	// teams.
+	mock.ExpectExec(`WHERE id=\? AND deleted_at IS NULL AND \(note.owner_id=\? OR note.id IN \(SELECT note_id FROM note_share WHERE role IN \(\?\)`).
+		WithArgs(nil, sqlmock.AnyArg(), "7", int64(5), service.RoleEditor, int64(5), int64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM note WHERE id=\? AND deleted_at IS NULL AND \(note.owner_id=\? OR note.id IN`).
+		WithArgs("7", int64(5), service.RoleEditor, int64(5), int64(5)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM note WHERE id=\? AND deleted_at IS NULL AND \(note.owner_id=\? OR note.id IN`).
+		WithArgs("7", int64(5), service.RoleEditor, service.RoleViewer, int64(5), int64(5)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	editing := service.WithRole(ctx, service.RoleEditor)
+
+	if err = repo.MoveNote(editing, "7", nil); !errors.Is(err, service.ErrForbidden) {
+		t.Errorf("expected ErrForbidden, got %v", err)
+		return
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
 }
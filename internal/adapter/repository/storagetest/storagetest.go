@@ -0,0 +1,1576 @@
+// Package storagetest is a conformance suite for service.Storage
+// implementations. Every backend runs the same suite, so MySQL, Postgres,
+// SQLite and the in-memory storage are held to identical behaviour.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"note/internal/models"
+	"note/internal/service"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Factory returns an empty storage. It is called once per subtest.
+type Factory func(t *testing.T) service.Storage
+
+func Run(t *testing.T, newStorage Factory) {
+	t.Run("CreateAndGet", func(t *testing.T) { testCreateAndGet(t, newStorage(t)) })
+	t.Run("NotFound", func(t *testing.T) { testNotFound(t, newStorage(t)) })
+	t.Run("Update", func(t *testing.T) { testUpdate(t, newStorage(t)) })
+	t.Run("Delete", func(t *testing.T) { testDelete(t, newStorage(t)) })
+	t.Run("Ordering", func(t *testing.T) { testOrdering(t, newStorage(t)) })
+	t.Run("TextOrdering", func(t *testing.T) { testTextOrdering(t, newStorage(t)) })
+	t.Run("Pagination", func(t *testing.T) { testPagination(t, newStorage(t)) })
+	t.Run("Timestamps", func(t *testing.T) { testTimestamps(t, newStorage(t)) })
+	t.Run("Search", func(t *testing.T) { testSearch(t, newStorage(t)) })
+	t.Run("Tags", func(t *testing.T) { testTags(t, newStorage(t)) })
+	t.Run("Notebooks", func(t *testing.T) { testNotebooks(t, newStorage(t)) })
+	t.Run("Metadata", func(t *testing.T) { testMetadata(t, newStorage(t)) })
+	t.Run("Trash", func(t *testing.T) { testTrash(t, newStorage(t)) })
+	t.Run("NotebookTrash", func(t *testing.T) { testNotebookTrash(t, newStorage(t)) })
+	t.Run("Revisions", func(t *testing.T) { testRevisions(t, newStorage(t)) })
+	t.Run("LongText", func(t *testing.T) { testLongText(t, newStorage(t)) })
+	t.Run("Versions", func(t *testing.T) { testVersions(t, newStorage(t)) })
+	t.Run("Batch", func(t *testing.T) { testBatch(t, newStorage(t)) })
+	t.Run("Users", func(t *testing.T) { testUsers(t, newStorage(t)) })
+	t.Run("Owners", func(t *testing.T) { testOwners(t, newStorage(t)) })
+	t.Run("Shares", func(t *testing.T) { testShares(t, newStorage(t)) })
+	t.Run("Links", func(t *testing.T) { testLinks(t, newStorage(t)) })
+	t.Run("Tokens", func(t *testing.T) { testTokens(t, newStorage(t)) })
+	t.Run("Identities", func(t *testing.T) { testIdentities(t, newStorage(t)) })
+}
+
+func mustCreate(t *testing.T, storage service.Storage, texts ...string) []*models.Note {
+	t.Helper()
+	ctx := service.AsSystem(context.Background())
+
+	notes := make([]*models.Note, 0, len(texts))
+
+	for _, text := range texts {
+		note, err := storage.Create(ctx, service.NoteFields{Text: text, Tags: []string{}})
+
+		if err != nil {
+			t.Fatalf("can't create note: %s", err)
+		}
+
+		notes = append(notes, note)
+	}
+
+	return notes
+}
+
+func id(note *models.Note) string {
+	return strconv.FormatInt(note.ID, 10)
+}
+
+func sameNote(a, b *models.Note) bool {
+	return a.ID == b.ID && a.Text == b.Text && a.CreatedAt.Equal(b.CreatedAt) && a.UpdatedAt.Equal(b.UpdatedAt)
+}
+
+func testCreateAndGet(t *testing.T, storage service.Storage) {
+	ctx := service.AsSystem(context.Background())
+	notes := mustCreate(t, storage, "first", "second", "")
+	listed, err := storage.GetAll(ctx, service.NotesQuery{})
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if len(listed) != len(notes) {
+		t.Fatalf("expected %d notes, got %d", len(notes), len(listed))
+	}
+
+	for i, text := range []string{"first", "second", ""} {
+		if notes[i].Text != text {
+			t.Errorf("expected created text %q, got %q", text, notes[i].Text)
+		}
+
+		note, err := storage.Get(ctx, id(notes[i]))
+
+		if err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+
+		if !sameNote(note, notes[i]) {
+			t.Errorf("Get and Create disagree: %+v vs %+v", note, notes[i])
+		}
+
+		if !sameNote(listed[i], notes[i]) {
+			t.Errorf("GetAll and Create disagree: %+v vs %+v", listed[i], notes[i])
+		}
+	}
+
+	if notes[0].ID >= notes[1].ID || notes[1].ID >= notes[2].ID {
+		t.Errorf("expected increasing ids, got %d, %d, %d", notes[0].ID, notes[1].ID, notes[2].ID)
+	}
+}
+
+func testNotFound(t *testing.T, storage service.Storage) {
+	ctx := service.AsSystem(context.Background())
+	notes := mustCreate(t, storage, "only")
+	missing := strconv.FormatInt(notes[0].ID+1000, 10)
+
+	if _, err := storage.Get(ctx, missing); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("Get: expected ErrNotFound for missing note, got %v", err)
+	}
+
+	if err := storage.Update(ctx, missing, service.NoteFields{Text: "text"}); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("Update: expected ErrNotFound for missing note, got %v", err)
+	}
+
+	if err := storage.Delete(ctx, missing, 0); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("Delete: expected ErrNotFound for missing note, got %v", err)
+	}
+
+	if _, err := storage.Get(ctx, "not-a-number"); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("Get: expected ErrNotFound for malformed id, got %v", err)
+	}
+}
+
+func testUpdate(t *testing.T, storage service.Storage) {
+	ctx := service.AsSystem(context.Background())
+	notes := mustCreate(t, storage, "before", "untouched")
+
+	if err := storage.Update(ctx, id(notes[0]), service.NoteFields{Text: "after"}); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	note, err := storage.Get(ctx, id(notes[0]))
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if note.Text != "after" {
+		t.Errorf("expected updated text, got %q", note.Text)
+	}
+
+	other, err := storage.Get(ctx, id(notes[1]))
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if !sameNote(other, notes[1]) {
+		t.Errorf("update leaked into another note: %+v", other)
+	}
+}
+
+func testDelete(t *testing.T, storage service.Storage) {
+	ctx := service.AsSystem(context.Background())
+	notes := mustCreate(t, storage, "keep", "drop")
+
+	if err := storage.Delete(ctx, id(notes[1]), 0); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if _, err := storage.Get(ctx, id(notes[1])); err == nil {
+		t.Error("expected deleted note to be gone")
+	}
+
+	if err := storage.Delete(ctx, id(notes[1]), 0); err == nil {
+		t.Error("expected error deleting twice, got nil")
+	}
+
+	left, err := storage.GetAll(ctx, service.NotesQuery{})
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if len(left) != 1 || !sameNote(left[0], notes[0]) {
+		t.Errorf("unexpected notes after delete: %v", left)
+	}
+}
+
+func texts(notes []*models.Note) []string {
+	result := make([]string, 0, len(notes))
+
+	for _, note := range notes {
+		result = append(result, note.Text)
+	}
+
+	return result
+}
+
+func testOrdering(t *testing.T, storage service.Storage) {
+	ctx := service.AsSystem(context.Background())
+	created := mustCreate(t, storage, "b", "c", "a")
+
+	if err := storage.Update(ctx, id(created[0]), service.NoteFields{Text: "d"}); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	byID := func(a, b *models.Note) bool { return a.ID < b.ID }
+	cases := []struct {
+		sort service.Sort
+		less func(a, b *models.Note) bool
+	}{
+		{nil, byID},
+		{service.Sort{{Field: "id"}}, byID},
+		{service.Sort{{Field: "id", Desc: true}}, func(a, b *models.Note) bool { return a.ID > b.ID }},
+		{service.Sort{{Field: "text"}}, func(a, b *models.Note) bool { return a.Text < b.Text }},
+		{service.Sort{{Field: "text", Desc: true}}, func(a, b *models.Note) bool { return a.Text > b.Text }},
+		{service.Sort{{Field: "created_at"}}, func(a, b *models.Note) bool {
+			return a.CreatedAt.Before(b.CreatedAt) || a.CreatedAt.Equal(b.CreatedAt) && a.ID < b.ID
+		}},
+		{service.Sort{{Field: "updated_at", Desc: true}, {Field: "id"}}, func(a, b *models.Note) bool {
+			return a.UpdatedAt.After(b.UpdatedAt) || a.UpdatedAt.Equal(b.UpdatedAt) && a.ID < b.ID
+		}},
+		{service.Sort{{Field: "created_at", Desc: true}, {Field: "id", Desc: true}}, func(a, b *models.Note) bool {
+			return a.CreatedAt.After(b.CreatedAt) || a.CreatedAt.Equal(b.CreatedAt) && a.ID > b.ID
+		}},
+	}
+
+	for _, c := range cases {
+		notes, err := storage.GetAll(ctx, service.NotesQuery{Sort: c.sort})
+
+		if err != nil {
+			t.Fatalf("order_by=%q: unexpected err: %s", c.sort, err)
+		}
+
+		if len(notes) != len(created) {
+			t.Fatalf("order_by=%q: expected %d notes, got %d", c.sort, len(created), len(notes))
+		}
+
+		if !sort.SliceIsSorted(notes, func(i, j int) bool { return c.less(notes[i], notes[j]) }) {
+			t.Errorf("order_by=%q: notes are not sorted: %v", c.sort, texts(notes))
+		}
+	}
+
+	notes, err := storage.GetAll(ctx, service.NotesQuery{Sort: service.Sort{{Field: "text"}}})
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if got := texts(notes); got[0] != "a" || got[1] != "c" || got[2] != "d" {
+		t.Errorf("unexpected text order: %v", got)
+	}
+
+	if _, err = storage.GetAll(ctx, service.NotesQuery{Sort: service.Sort{{Field: "no_such_column"}}}); err == nil {
+		t.Error("expected error for unknown column, got nil")
+	}
+}
+
+// testTextOrdering checks that order_by=text only compares the first
+// service.SortTextLength characters, so a cursor holding them is exact.
+func testTextOrdering(t *testing.T, storage service.Storage) {
+	ctx := service.AsSystem(context.Background())
+	prefix := strings.Repeat("x", service.SortTextLength)
+	created := mustCreate(t, storage, prefix+"b", "b", prefix+"a", "a")
+	// The long texts differ after the prefix, so they are in id order.
+	want := []int64{created[3].ID, created[1].ID, created[0].ID, created[2].ID}
+	after := (*models.Note)(nil)
+
+	for i, wantID := range want {
+		notes, err := storage.GetAll(ctx, service.NotesQuery{Sort: service.Sort{{Field: "text"}, {Field: "id"}}, Limit: 1, After: after})
+
+		if err != nil || len(notes) != 1 || notes[0].ID != wantID {
+			t.Fatalf("page %d: expected note %d, got %v, %v", i, wantID, texts(notes), err)
+		}
+
+		after = &models.Note{ID: notes[0].ID, Text: service.SortText(notes[0].Text)}
+	}
+}
+
+func testPagination(t *testing.T, storage service.Storage) {
+	ctx := service.AsSystem(context.Background())
+	// Repeated texts and same-second timestamps make the id tie-break matter.
+	mustCreate(t, storage, "b", "a", "b", "c", "a")
+
+	sorts := []service.Sort{
+		{{Field: "id"}},
+		{{Field: "id", Desc: true}},
+		{{Field: "text"}},
+		{{Field: "text", Desc: true}},
+		{{Field: "created_at"}},
+		{{Field: "updated_at", Desc: true}, {Field: "id"}},
+		{{Field: "text"}, {Field: "created_at", Desc: true}, {Field: "id", Desc: true}},
+	}
+
+	for _, order := range sorts {
+		all, err := storage.GetAll(ctx, service.NotesQuery{Sort: order})
+
+		if err != nil {
+			t.Fatalf("order_by=%q: unexpected err: %s", order, err)
+		}
+
+		paged := []*models.Note{}
+		query := service.NotesQuery{Sort: order, Limit: 2}
+
+		for i := 0; i <= len(all); i++ {
+			var page []*models.Note
+			page, err = storage.GetAll(ctx, query)
+
+			if err != nil {
+				t.Fatalf("order_by=%q: unexpected err: %s", order, err)
+			}
+
+			if len(page) > query.Limit {
+				t.Fatalf("order_by=%q: expected at most %d notes, got %d", order, query.Limit, len(page))
+			}
+
+			if len(page) == 0 {
+				break
+			}
+
+			paged = append(paged, page...)
+			query.After = page[len(page)-1]
+		}
+
+		if len(paged) != len(all) {
+			t.Fatalf("order_by=%q: pages hold %d notes, expected %d", order, len(paged), len(all))
+		}
+
+		for i := range all {
+			if !sameNote(paged[i], all[i]) {
+				t.Errorf("order_by=%q: note %d differs between pages and full list: %+v vs %+v", order, i, paged[i], all[i])
+			}
+		}
+	}
+}
+
+func testTimestamps(t *testing.T, storage service.Storage) {
+	ctx := service.AsSystem(context.Background())
+	before := time.Now().Add(-time.Second)
+	note := mustCreate(t, storage, "stamp")[0]
+	after := time.Now().Add(time.Second)
+
+	if note.CreatedAt.Before(before) || note.CreatedAt.After(after) {
+		t.Errorf("created_at %v is outside [%v, %v]", note.CreatedAt, before, after)
+	}
+
+	if !note.UpdatedAt.Equal(note.CreatedAt) {
+		t.Errorf("expected updated_at == created_at on create, got %v and %v", note.UpdatedAt, note.CreatedAt)
+	}
+
+	if err := storage.Update(ctx, id(note), service.NoteFields{Text: "stamp 2"}); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	updated, err := storage.Get(ctx, id(note))
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if !updated.CreatedAt.Equal(note.CreatedAt) {
+		t.Errorf("update changed created_at: %v -> %v", note.CreatedAt, updated.CreatedAt)
+	}
+
+	if updated.UpdatedAt.Before(note.UpdatedAt) {
+		t.Errorf("updated_at went backwards: %v -> %v", note.UpdatedAt, updated.UpdatedAt)
+	}
+}
+
+func searchIDs(t *testing.T, storage service.Storage, limit int, terms ...service.SearchTerm) []int64 {
+	t.Helper()
+
+	results, err := storage.Search(service.AsSystem(context.Background()), service.SearchQuery{Terms: terms, Limit: limit})
+
+	if err != nil {
+		t.Fatalf("can't search: %s", err)
+	}
+
+	ids := []int64{}
+
+	for _, result := range results {
+		ids = append(ids, result.Note.ID)
+	}
+
+	return ids
+}
+
+func sameIDs(got []int64, want ...int64) bool {
+	if len(got) != len(want) {
+		return false
+	}
+
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func testSearch(t *testing.T, storage service.Storage) {
+	ctx := service.AsSystem(context.Background())
+	// Words are longer than MySQL's minimum token size and aren't stopwords.
+	notes := mustCreate(t, storage,
+		"apple banana cherry grape melon",
+		"apple apple apple",
+		"green apple pie recipe",
+		"banana bread recipe",
+	)
+
+	word := func(w string) service.SearchTerm { return service.SearchTerm{Words: []string{w}} }
+
+	if got := searchIDs(t, storage, 10, word("apple")); !sameIDs(got, notes[1].ID, notes[0].ID, notes[2].ID) &&
+		!sameIDs(got, notes[1].ID, notes[2].ID, notes[0].ID) {
+		t.Errorf("expected the note repeating the word first, got %v", got)
+	}
+
+	if got := searchIDs(t, storage, 10, word("recipe"), word("banana")); !sameIDs(got, notes[3].ID) {
+		t.Errorf("expected every term to be required, got %v", got)
+	}
+
+	phrase := service.SearchTerm{Words: []string{"apple", "recipe"}}
+
+	if got := searchIDs(t, storage, 10, phrase); len(got) != 0 {
+		t.Errorf("expected no note with the phrase, got %v", got)
+	}
+
+	phrase = service.SearchTerm{Words: []string{"apple", "pie"}}
+
+	if got := searchIDs(t, storage, 10, phrase); !sameIDs(got, notes[2].ID) {
+		t.Errorf("unexpected phrase matches: %v", got)
+	}
+
+	prefix := service.SearchTerm{Words: []string{"recip"}, Prefix: true}
+
+	if got := searchIDs(t, storage, 10, prefix); len(got) != 2 {
+		t.Errorf("expected 2 prefix matches, got %v", got)
+	}
+
+	if got := searchIDs(t, storage, 1, word("apple")); len(got) != 1 {
+		t.Errorf("expected limit to apply, got %v", got)
+	}
+
+	if err := storage.Update(ctx, id(notes[3]), service.NoteFields{Text: "sourdough bread"}); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if err := storage.Delete(ctx, id(notes[2]), 0); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if got := searchIDs(t, storage, 10, prefix); len(got) != 0 {
+		t.Errorf("expected the index to follow updates and deletes, got %v", got)
+	}
+
+	if got := searchIDs(t, storage, 10, word("sourdough")); !sameIDs(got, notes[3].ID) {
+		t.Errorf("expected the updated text to be found, got %v", got)
+	}
+}
+
+func testTags(t *testing.T, storage service.Storage) {
+	ctx := service.AsSystem(context.Background())
+	notes := make([]*models.Note, 0, 3)
+
+	for _, tags := range [][]string{{"work", "urgent"}, {"work"}, {"home"}} {
+		note, err := storage.Create(ctx, service.NoteFields{Text: "tagged", Tags: tags})
+
+		if err != nil {
+			t.Fatalf("can't create note: %s", err)
+		}
+
+		notes = append(notes, note)
+	}
+
+	if got := notes[0].Tags; len(got) != 2 || got[0] != "urgent" || got[1] != "work" {
+		t.Errorf("expected sorted tags on create, got %v", got)
+	}
+
+	got, err := storage.Get(ctx, id(notes[0]))
+
+	if err != nil || len(got.Tags) != 2 || got.Tags[0] != "urgent" {
+		t.Errorf("unexpected note: %+v, %v", got, err)
+	}
+
+	filter := func(anyTag bool, tags ...string) []int64 {
+		t.Helper()
+		list, listErr := storage.GetAll(ctx, service.NotesQuery{Tags: tags, AnyTag: anyTag})
+
+		if listErr != nil {
+			t.Fatalf("unexpected err: %s", listErr)
+		}
+
+		ids := []int64{}
+
+		for _, note := range list {
+			ids = append(ids, note.ID)
+		}
+
+		return ids
+	}
+
+	if ids := filter(false, "work"); !sameIDs(ids, notes[0].ID, notes[1].ID) {
+		t.Errorf("unexpected notes tagged work: %v", ids)
+	}
+
+	if ids := filter(false, "work", "urgent"); !sameIDs(ids, notes[0].ID) {
+		t.Errorf("unexpected notes tagged work and urgent: %v", ids)
+	}
+
+	if ids := filter(true, "urgent", "home"); !sameIDs(ids, notes[0].ID, notes[2].ID) {
+		t.Errorf("unexpected notes tagged urgent or home: %v", ids)
+	}
+
+	if ids := filter(false, "missing"); len(ids) != 0 {
+		t.Errorf("expected no notes for an unknown tag, got %v", ids)
+	}
+
+	// Nil tags keep the current ones, an empty list removes them.
+	if err = storage.Update(ctx, id(notes[0]), service.NoteFields{Text: "kept"}); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if got, err = storage.Get(ctx, id(notes[0])); err != nil || len(got.Tags) != 2 {
+		t.Errorf("expected tags to be kept, got %+v, %v", got, err)
+	}
+
+	if err = storage.Update(ctx, id(notes[1]), service.NoteFields{Text: "moved", Tags: []string{"home"}}); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if err = storage.Update(ctx, id(notes[2]), service.NoteFields{Text: "cleared", Tags: []string{}}); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if got, err = storage.Get(ctx, id(notes[2])); err != nil || got.Tags == nil || len(got.Tags) != 0 {
+		t.Errorf("expected no tags, got %+v, %v", got, err)
+	}
+
+	if err = storage.Delete(ctx, id(notes[0]), 0); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	tags, err := storage.Tags(ctx)
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if len(tags) != 1 || *tags[0] != (models.Tag{Name: "home", Count: 1}) {
+		t.Errorf("unexpected tag counts: %+v", tags)
+	}
+}
+
+func testNotebooks(t *testing.T, storage service.Storage) {
+	ctx := service.AsSystem(context.Background())
+	create := func(name string, parent *models.Notebook) *models.Notebook {
+		t.Helper()
+		fields := service.NotebookFields{Name: name}
+
+		if parent != nil {
+			fields.ParentID = &parent.ID
+		}
+
+		notebook, err := storage.CreateNotebook(ctx, fields)
+
+		if err != nil {
+			t.Fatalf("can't create notebook: %s", err)
+		}
+
+		return notebook
+	}
+	nbID := func(notebook *models.Notebook) string { return strconv.FormatInt(notebook.ID, 10) }
+
+	work := create("work", nil)
+	projects := create("projects", work)
+	archive := create("archive", projects)
+	home := create("home", nil)
+
+	if projects.ParentID == nil || *projects.ParentID != work.ID || work.ParentID != nil {
+		t.Errorf("unexpected parents: %+v, %+v", work, projects)
+	}
+
+	got, err := storage.GetNotebook(ctx, nbID(archive))
+
+	if err != nil || got.Name != "archive" || got.ParentID == nil || *got.ParentID != projects.ID {
+		t.Errorf("unexpected notebook: %+v, %v", got, err)
+	}
+
+	if _, err = storage.GetNotebook(ctx, "999999"); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	list, err := storage.Notebooks(ctx)
+
+	if err != nil || len(list) != 4 || list[0].Name != "archive" || list[3].Name != "work" {
+		t.Errorf("expected notebooks by name, got %+v, %v", list, err)
+	}
+
+	// A notebook can't end up inside its own subtree.
+	for _, parent := range []*models.Notebook{work, archive} {
+		err = storage.UpdateNotebook(ctx, nbID(work), service.NotebookFields{Name: "work", ParentID: &parent.ID})
+
+		if !errors.Is(err, service.ErrConflict) {
+			t.Errorf("expected ErrConflict moving work under %s, got %v", parent.Name, err)
+		}
+	}
+
+	if err = storage.UpdateNotebook(ctx, nbID(archive), service.NotebookFields{Name: "old", ParentID: &home.ID}); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if got, err = storage.GetNotebook(ctx, nbID(archive)); err != nil || got.Name != "old" || *got.ParentID != home.ID {
+		t.Errorf("unexpected moved notebook: %+v, %v", got, err)
+	}
+
+	note, err := storage.Create(ctx, service.NoteFields{Text: "plan", Tags: []string{}, NotebookID: &projects.ID})
+
+	if err != nil || note.NotebookID == nil || *note.NotebookID != projects.ID {
+		t.Fatalf("unexpected note: %+v, %v", note, err)
+	}
+
+	loose := mustCreate(t, storage, "loose")[0]
+
+	if err = storage.MoveNote(ctx, id(loose), &work.ID); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	inWork, err := storage.GetAll(ctx, service.NotesQuery{Notebooks: []int64{work.ID}})
+
+	if err != nil || len(inWork) != 1 || inWork[0].ID != loose.ID || *inWork[0].NotebookID != work.ID {
+		t.Errorf("unexpected notes in work: %+v, %v", inWork, err)
+	}
+
+	inTree, err := storage.GetAll(ctx, service.NotesQuery{Notebooks: []int64{work.ID, projects.ID}})
+
+	if err != nil || len(inTree) != 2 {
+		t.Errorf("expected both notes in the work tree, got %+v, %v", inTree, err)
+	}
+
+	if err = storage.MoveNote(ctx, "999999", nil); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	if err = storage.DeleteNotebook(ctx, nbID(work), false); !errors.Is(err, service.ErrConflict) {
+		t.Errorf("expected ErrConflict deleting a non-empty notebook, got %v", err)
+	}
+
+	if err = storage.DeleteNotebook(ctx, nbID(work), true); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	for _, gone := range []*models.Notebook{work, projects} {
+		if _, err = storage.GetNotebook(ctx, nbID(gone)); !errors.Is(err, service.ErrNotFound) {
+			t.Errorf("expected %s to be deleted, got %v", gone.Name, err)
+		}
+	}
+
+	for _, gone := range []*models.Note{note, loose} {
+		if _, err = storage.Get(ctx, id(gone)); !errors.Is(err, service.ErrNotFound) {
+			t.Errorf("expected note %d to be deleted, got %v", gone.ID, err)
+		}
+	}
+
+	if _, err = storage.GetNotebook(ctx, nbID(archive)); err != nil {
+		t.Errorf("expected the moved notebook to survive, got %v", err)
+	}
+
+	if err = storage.DeleteNotebook(ctx, nbID(archive), false); err != nil {
+		t.Errorf("unexpected err deleting an empty notebook: %s", err)
+	}
+}
+
+// testNotebookTrash checks that deleting a notebook recursively moves its
+// notes to the trash rather than losing them.
+func testNotebookTrash(t *testing.T, storage service.Storage) {
+	ctx := service.AsSystem(context.Background())
+	work, err := storage.CreateNotebook(ctx, service.NotebookFields{Name: "work"})
+
+	if err != nil {
+		t.Fatalf("can't create notebook: %s", err)
+	}
+
+	projects, err := storage.CreateNotebook(ctx, service.NotebookFields{Name: "projects", ParentID: &work.ID})
+
+	if err != nil {
+		t.Fatalf("can't create notebook: %s", err)
+	}
+
+	notes := mustCreate(t, storage, "plan", "report")
+
+	for i, notebook := range []*models.Notebook{work, projects} {
+		if err = storage.MoveNote(ctx, id(notes[i]), &notebook.ID); err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+	}
+
+	moved, err := storage.Get(ctx, id(notes[1]))
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if err = storage.DeleteNotebook(ctx, strconv.FormatInt(work.ID, 10), true); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	trash, err := storage.GetAll(ctx, service.NotesQuery{Trashed: true})
+
+	if err != nil || len(trash) != len(notes) {
+		t.Fatalf("expected the notes of the notebook in the trash, got %+v, %v", trash, err)
+	}
+
+	for _, note := range trash {
+		if note.DeletedAt == nil || note.NotebookID != nil {
+			t.Errorf("unexpected trashed note %+v", note)
+		}
+	}
+
+	for _, note := range notes {
+		if err = storage.Restore(ctx, id(note)); err != nil {
+			t.Fatalf("unexpected err restoring note %d: %s", note.ID, err)
+		}
+	}
+
+	restored, err := storage.Get(ctx, id(notes[1]))
+
+	if err != nil || restored.Text != "report" || restored.NotebookID != nil || restored.DeletedAt != nil || restored.Version <= moved.Version {
+		t.Errorf("expected the note back at the top level with a new version, got %+v, %v", restored, err)
+	}
+}
+
+func testMetadata(t *testing.T, storage service.Storage) {
+	ctx := service.AsSystem(context.Background())
+	title, summary, color, yes := "Groceries", "for the weekend", "green", true
+
+	note, err := storage.Create(ctx, service.NoteFields{Text: "milk", Tags: []string{}, Title: &title, Summary: &summary, Color: &color, Pinned: &yes})
+
+	if err != nil {
+		t.Fatalf("can't create note: %s", err)
+	}
+
+	got, err := storage.Get(ctx, id(note))
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if got.Title != title || got.Summary != summary || got.Color != color || !got.Pinned || got.Archived {
+		t.Errorf("unexpected metadata: %+v", got)
+	}
+
+	plain := mustCreate(t, storage, "plain")[0]
+
+	if plain.Title != "" || plain.Pinned || plain.Archived {
+		t.Errorf("expected empty metadata by default, got %+v", plain)
+	}
+
+	// Unset fields are kept on update.
+	if err = storage.Update(ctx, id(note), service.NoteFields{Text: "milk, eggs", Archived: &yes}); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if got, err = storage.Get(ctx, id(note)); err != nil || got.Title != title || got.Color != color || !got.Pinned || !got.Archived {
+		t.Errorf("expected metadata to be kept, got %+v, %v", got, err)
+	}
+
+	no := false
+	filter := func(q service.NotesQuery) []int64 {
+		t.Helper()
+		list, listErr := storage.GetAll(ctx, q)
+
+		if listErr != nil {
+			t.Fatalf("unexpected err: %s", listErr)
+		}
+
+		ids := []int64{}
+
+		for _, n := range list {
+			ids = append(ids, n.ID)
+		}
+
+		return ids
+	}
+
+	if ids := filter(service.NotesQuery{Pinned: &yes}); !sameIDs(ids, note.ID) {
+		t.Errorf("unexpected pinned notes: %v", ids)
+	}
+
+	if ids := filter(service.NotesQuery{Archived: &no}); !sameIDs(ids, plain.ID) {
+		t.Errorf("unexpected unarchived notes: %v", ids)
+	}
+
+	if ids := filter(service.NotesQuery{Pinned: &yes, Archived: &no}); len(ids) != 0 {
+		t.Errorf("expected no pinned unarchived notes, got %v", ids)
+	}
+
+	if ids := filter(service.NotesQuery{Color: "green"}); !sameIDs(ids, note.ID) {
+		t.Errorf("unexpected green notes: %v", ids)
+	}
+}
+
+func testTrash(t *testing.T, storage service.Storage) {
+	ctx := service.AsSystem(context.Background())
+	notebook, err := storage.CreateNotebook(ctx, service.NotebookFields{Name: "inbox"})
+
+	if err != nil {
+		t.Fatalf("can't create notebook: %s", err)
+	}
+
+	trashed, err := storage.Create(ctx, service.NoteFields{Text: "apple pie", Tags: []string{"food"}, NotebookID: &notebook.ID})
+
+	if err != nil {
+		t.Fatalf("can't create note: %s", err)
+	}
+
+	kept := mustCreate(t, storage, "apple juice")[0]
+
+	if err = storage.Delete(ctx, id(trashed), 0); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	for name, err := range map[string]error{
+		"update":  storage.Update(ctx, id(trashed), service.NoteFields{Text: "changed"}),
+		"move":    storage.MoveNote(ctx, id(trashed), nil),
+		"purge":   storage.Purge(ctx, id(kept)),
+		"restore": storage.Restore(ctx, id(kept)),
+	} {
+		if !errors.Is(err, service.ErrNotFound) {
+			t.Errorf("%s: expected ErrNotFound, got %v", name, err)
+		}
+	}
+
+	if ids := searchIDs(t, storage, 10, service.SearchTerm{Words: []string{"apple"}}); !sameIDs(ids, kept.ID) {
+		t.Errorf("expected trashed notes to be left out of search, got %v", ids)
+	}
+
+	if tags, tagsErr := storage.Tags(ctx); tagsErr != nil || len(tags) != 0 {
+		t.Errorf("expected tags of trashed notes to be left out, got %v, %v", tags, tagsErr)
+	}
+
+	list, err := storage.GetAll(ctx, service.NotesQuery{Trashed: true})
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if len(list) != 1 || list[0].ID != trashed.ID || list[0].DeletedAt == nil {
+		t.Fatalf("unexpected trash: %v", list)
+	}
+
+	// A trashed note doesn't keep its notebook from being deleted and is
+	// restored to the top level.
+	if err = storage.DeleteNotebook(ctx, strconv.FormatInt(notebook.ID, 10), false); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if err = storage.Restore(ctx, id(trashed)); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	got, err := storage.Get(ctx, id(trashed))
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if got.DeletedAt != nil || got.NotebookID != nil || got.Text != "apple pie" || len(got.Tags) != 1 {
+		t.Errorf("unexpected restored note: %+v", got)
+	}
+
+	if err = storage.Delete(ctx, id(got), 0); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if err = storage.Purge(ctx, id(got)); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if err = storage.Restore(ctx, id(got)); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected purged note to be gone, got %v", err)
+	}
+
+	if err = storage.Delete(ctx, id(kept), 0); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if purged, purgeErr := storage.PurgeTrashed(ctx, time.Now().Add(-time.Hour)); purgeErr != nil || purged != 0 {
+		t.Errorf("expected nothing to expire yet, got %d, %v", purged, purgeErr)
+	}
+
+	if purged, purgeErr := storage.PurgeTrashed(ctx, time.Now().Add(time.Hour)); purgeErr != nil || purged != 1 {
+		t.Errorf("expected one expired note, got %d, %v", purged, purgeErr)
+	}
+
+	if list, err = storage.GetAll(ctx, service.NotesQuery{Trashed: true}); err != nil || len(list) != 0 {
+		t.Errorf("expected empty trash, got %v, %v", list, err)
+	}
+}
+
+func testRevisions(t *testing.T, storage service.Storage) {
+	ctx := service.AsSystem(context.Background())
+	note := mustCreate(t, storage, "first")[0]
+	title := "Plan"
+
+	if err := storage.Update(ctx, id(note), service.NoteFields{Text: "second", Title: &title}); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	// Metadata-only changes are revisions too.
+	if err := storage.Update(ctx, id(note), service.NoteFields{Text: "second", Pinned: new(bool)}); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	revisions, err := storage.Revisions(ctx, id(note))
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if len(revisions) != 3 || revisions[0].Revision != 3 || revisions[2].Revision != 1 {
+		t.Fatalf("expected revisions 3, 2, 1, got %+v", revisions)
+	}
+
+	first, err := storage.Revision(ctx, id(note), 1)
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if first.NoteID != note.ID || first.Text != "first" || first.Title != "" || first.CreatedAt.IsZero() {
+		t.Errorf("unexpected first revision: %+v", first)
+	}
+
+	if second, _ := storage.Revision(ctx, id(note), 2); second == nil || second.Text != "second" || second.Title != title {
+		t.Errorf("unexpected second revision: %+v", second)
+	}
+
+	if _, err = storage.Revision(ctx, id(note), 4); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a missing revision, got %v", err)
+	}
+
+	other := mustCreate(t, storage, "other")[0]
+
+	if revisions, err = storage.Revisions(ctx, id(other)); err != nil || len(revisions) != 1 || revisions[0].Revision != 1 {
+		t.Errorf("expected numbering per note, got %+v, %v", revisions, err)
+	}
+
+	if err = storage.Delete(ctx, id(note), 0); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if _, err = storage.Revisions(ctx, id(note)); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a trashed note, got %v", err)
+	}
+
+	if _, err = storage.Revisions(ctx, "999"); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a missing note, got %v", err)
+	}
+}
+
+// testLongText stores notes of service.MaxTextLength characters, each taking
+// more than one byte, in the note and in its revisions.
+func testLongText(t *testing.T, storage service.Storage) {
+	ctx := service.AsSystem(context.Background())
+	first := strings.Repeat("ж", service.MaxTextLength)
+	second := strings.Repeat("я", service.MaxTextLength)
+	note := mustCreate(t, storage, first)[0]
+
+	if err := storage.Update(ctx, id(note), service.NoteFields{Text: second}); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	got, err := storage.Get(ctx, id(note))
+
+	if err != nil || got.Text != second {
+		t.Fatalf("expected the long text back, got %v", err)
+	}
+
+	for i, text := range []string{first, second} {
+		revision, err := storage.Revision(ctx, id(note), i+1)
+
+		if err != nil || revision.Text != text {
+			t.Errorf("expected revision %d to keep the long text, got %v", i+1, err)
+		}
+	}
+}
+
+func testVersions(t *testing.T, storage service.Storage) {
+	ctx := service.AsSystem(context.Background())
+	note := mustCreate(t, storage, "first")[0]
+
+	if note.Version != 1 {
+		t.Fatalf("expected version 1, got %d", note.Version)
+	}
+
+	if err := storage.Update(ctx, id(note), service.NoteFields{Text: "second", IfVersion: 1}); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	// The first writer wins, the second one holds a stale version.
+	if err := storage.Update(ctx, id(note), service.NoteFields{Text: "third", IfVersion: 1}); !errors.Is(err, service.ErrPreconditionFailed) {
+		t.Errorf("expected ErrPreconditionFailed, got %v", err)
+	}
+
+	if err := storage.Delete(ctx, id(note), 1); !errors.Is(err, service.ErrPreconditionFailed) {
+		t.Errorf("expected ErrPreconditionFailed, got %v", err)
+	}
+
+	got, err := storage.Get(ctx, id(note))
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if got.Version != 2 || got.Text != "second" {
+		t.Errorf("expected version 2 with the first update, got %d %q", got.Version, got.Text)
+	}
+
+	// Without a version the write is unconditional.
+	if err = storage.Update(ctx, id(note), service.NoteFields{Text: "third"}); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if err = storage.Delete(ctx, id(note), 3); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if err = storage.Update(ctx, id(note), service.NoteFields{Text: "fourth", IfVersion: 4}); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a trashed note, got %v", err)
+	}
+
+	if err = storage.Restore(ctx, id(note)); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if got, _ = storage.Get(ctx, id(note)); got == nil || got.Version != 5 {
+		t.Errorf("expected version 5 after delete and restore, got %+v", got)
+	}
+
+	if err = storage.Delete(ctx, "999", 1); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a missing note, got %v", err)
+	}
+}
+
+func testBatch(t *testing.T, storage service.Storage) {
+	ctx := service.AsSystem(context.Background())
+	note := mustCreate(t, storage, "first")[0]
+	update := service.NoteFields{Text: "second", Tags: []string{"work"}}
+	create := service.NoteFields{Text: "new", Tags: []string{}}
+
+	results, err := storage.Batch(ctx, []service.NoteOperation{
+		{Op: service.BatchCreate, Fields: create},
+		{Op: service.BatchUpdate, ID: id(note), Fields: update},
+		{Op: service.BatchDelete, ID: "999"},
+	}, true)
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if len(results) != 3 || !errors.Is(results[0].Err, service.ErrBatchAborted) || !errors.Is(results[1].Err, service.ErrBatchAborted) ||
+		!errors.Is(results[2].Err, service.ErrNotFound) || results[0].Note != nil {
+		t.Fatalf("unexpected results of a failed atomic batch: %+v", results)
+	}
+
+	// Nothing of the failed batch is left.
+	if notes, _ := storage.GetAll(ctx, service.NotesQuery{Sort: service.Sort{{Field: "id"}}, Limit: 10}); len(notes) != 1 || notes[0].Text != "first" {
+		t.Fatalf("expected the failed batch to be rolled back, got %+v", notes)
+	}
+
+	update.IfVersion = 1
+	results, err = storage.Batch(ctx, []service.NoteOperation{
+		{Op: service.BatchUpdate, ID: id(note), Fields: update},
+		{Op: service.BatchUpdate, ID: id(note), Fields: update},
+		{Op: service.BatchCreate, Fields: create},
+		{Op: service.BatchDelete, ID: "999"},
+	}, false)
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if len(results) != 4 || results[0].Err != nil || !errors.Is(results[1].Err, service.ErrPreconditionFailed) ||
+		results[2].Err != nil || results[2].Note == nil || !errors.Is(results[3].Err, service.ErrNotFound) {
+		t.Fatalf("unexpected results of a partial batch: %+v", results)
+	}
+
+	if got, _ := storage.Get(ctx, id(note)); got == nil || got.Text != "second" || got.Version != 2 || len(got.Tags) != 1 {
+		t.Errorf("expected one update to apply, got %+v", got)
+	}
+
+	if got, _ := storage.Get(ctx, id(results[2].Note)); got == nil || got.Text != "new" {
+		t.Errorf("expected the created note, got %+v", got)
+	}
+
+	results, err = storage.Batch(ctx, []service.NoteOperation{
+		{Op: service.BatchDelete, ID: id(note), Fields: service.NoteFields{IfVersion: 2}},
+		{Op: service.BatchCreate, Fields: create},
+	}, true)
+
+	if err != nil || results[0].Err != nil || results[1].Err != nil {
+		t.Fatalf("unexpected results: %+v, %v", results, err)
+	}
+
+	if _, err = storage.Get(ctx, id(note)); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected the note to be deleted, got %v", err)
+	}
+}
+
+func mustCreateUser(t *testing.T, storage service.Storage, email string) *models.User {
+	t.Helper()
+
+	user, err := storage.CreateUser(context.Background(), email, "hash of "+email)
+
+	if err != nil {
+		t.Fatalf("can't create user: %s", err)
+	}
+
+	return user
+}
+
+func testUsers(t *testing.T, storage service.Storage) {
+	ctx := service.AsSystem(context.Background())
+	user := mustCreateUser(t, storage, "ann@example.com")
+
+	if _, err := storage.CreateUser(ctx, "ann@example.com", "other"); !errors.Is(err, service.ErrConflict) {
+		t.Errorf("expected ErrConflict for a taken email, got %v", err)
+	}
+
+	got, err := storage.UserByEmail(ctx, "ann@example.com")
+
+	if err != nil || got.ID != user.ID || got.PasswordHash != "hash of ann@example.com" {
+		t.Errorf("expected %+v, got %+v, %v", user, got, err)
+	}
+
+	if _, err = storage.UserByEmail(ctx, "bob@example.com"); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	t0 := time.Now().UTC().Truncate(time.Second)
+
+	if err = storage.CreateSession(ctx, "live", user.ID, t0.Add(time.Hour)); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if err = storage.CreateSession(ctx, "expired", user.ID, t0.Add(-time.Hour)); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if got, err = storage.SessionUser(ctx, "live", t0); err != nil || got.ID != user.ID || got.Email != user.Email {
+		t.Errorf("expected %+v, got %+v, %v", user, got, err)
+	}
+
+	for _, hash := range []string{"expired", "unknown"} {
+		if _, err = storage.SessionUser(ctx, hash, t0); !errors.Is(err, service.ErrNotFound) {
+			t.Errorf("%s: expected ErrNotFound, got %v", hash, err)
+		}
+	}
+
+	if _, err = storage.SessionUser(ctx, "live", t0.Add(2*time.Hour)); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound once the session expired, got %v", err)
+	}
+
+	if err = storage.DeleteSession(ctx, "live"); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if _, err = storage.SessionUser(ctx, "live", t0); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound after logout, got %v", err)
+	}
+
+	if err = storage.DeleteSession(ctx, "live"); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an ended session, got %v", err)
+	}
+}
+
+// testOwners checks that a user doesn't see the notes and notebooks of
+// another one, a system context sees them all and a context without a user
+// sees none.
+func testOwners(t *testing.T, storage service.Storage) {
+	ann := service.WithUser(context.Background(), mustCreateUser(t, storage, "ann@example.com"))
+	bob := service.WithUser(context.Background(), mustCreateUser(t, storage, "bob@example.com"))
+
+	notebook, err := storage.CreateNotebook(ann, service.NotebookFields{Name: "ann"})
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	note, err := storage.Create(ann, service.NoteFields{Text: "secret plan", Tags: []string{"private"}, NotebookID: &notebook.ID})
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	trashed, err := storage.Create(ann, service.NoteFields{Text: "old plan", Tags: []string{}})
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if err = storage.Delete(ann, id(trashed), 0); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if _, err = storage.Create(bob, service.NoteFields{Text: "bob plan", Tags: []string{}}); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	notFound := map[string]error{}
+	_, notFound["Get"] = storage.Get(bob, id(note))
+	notFound["Update"] = storage.Update(bob, id(note), service.NoteFields{Text: "stolen"})
+	notFound["Delete"] = storage.Delete(bob, id(note), 0)
+	notFound["MoveNote"] = storage.MoveNote(bob, id(note), nil)
+	_, notFound["Revisions"] = storage.Revisions(bob, id(note))
+	notFound["Restore"] = storage.Restore(bob, id(trashed))
+	notFound["Purge"] = storage.Purge(bob, id(trashed))
+	_, notFound["GetNotebook"] = storage.GetNotebook(bob, strconv.FormatInt(notebook.ID, 10))
+	notFound["UpdateNotebook"] = storage.UpdateNotebook(bob, strconv.FormatInt(notebook.ID, 10), service.NotebookFields{Name: "stolen"})
+	notFound["DeleteNotebook"] = storage.DeleteNotebook(bob, strconv.FormatInt(notebook.ID, 10), true)
+
+	for name, scopeErr := range notFound {
+		if !errors.Is(scopeErr, service.ErrNotFound) {
+			t.Errorf("%s: expected ErrNotFound for a note of another user, got %v", name, scopeErr)
+		}
+	}
+
+	results, batchErr := storage.Batch(bob, []service.NoteOperation{{Op: service.BatchUpdate, ID: id(note), Fields: service.NoteFields{Text: "stolen"}}}, false)
+
+	if batchErr != nil || !errors.Is(results[0].Err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound in a batch, got %v, %v", results, batchErr)
+	}
+
+	counts := func(ctx context.Context) []int {
+		notes, _ := storage.GetAll(ctx, service.NotesQuery{})
+		trash, _ := storage.GetAll(ctx, service.NotesQuery{Trashed: true})
+		found, _ := storage.Search(ctx, service.SearchQuery{Terms: []service.SearchTerm{{Words: []string{"plan"}}}, Limit: 10})
+		tags, _ := storage.Tags(ctx)
+		notebooks, _ := storage.Notebooks(ctx)
+
+		return []int{len(notes), len(trash), len(found), len(tags), len(notebooks)}
+	}
+
+	for name, c := range map[string]struct {
+		ctx  context.Context
+		want []int
+	}{
+		"ann":     {ann, []int{1, 1, 1, 1, 1}},
+		"bob":     {bob, []int{1, 0, 1, 0, 0}},
+		"system":  {service.AsSystem(context.Background()), []int{2, 1, 2, 1, 1}},
+		"no user": {context.Background(), []int{0, 0, 0, 0, 0}},
+	} {
+		if got := counts(c.ctx); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: expected notes, trash, search, tags and notebooks %v, got %v", name, c.want, got)
+		}
+	}
+
+	// A context that forgot its user gets nothing rather than everything.
+	if _, err := storage.Get(context.Background(), id(note)); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound without a user, got %v", err)
+	}
+
+	if err := storage.Update(context.Background(), id(note), service.NoteFields{Text: "stolen"}); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an update without a user, got %v", err)
+	}
+
+	got, err := storage.Get(ann, id(note))
+
+	if err != nil || got.Text != "secret plan" || got.Version != 1 {
+		t.Errorf("expected the note of ann unchanged, got %+v, %v", got, err)
+	}
+}
+
+func testShares(t *testing.T, storage service.Storage) {
+	annUser := mustCreateUser(t, storage, "ann@example.com")
+	bobUser := mustCreateUser(t, storage, "bob@example.com")
+	caraUser := mustCreateUser(t, storage, "cara@example.com")
+	ann := service.WithUser(context.Background(), annUser)
+	bob := service.WithUser(context.Background(), bobUser)
+	cara := service.WithUser(context.Background(), caraUser)
+	reading := func(ctx context.Context) context.Context { return service.WithRole(ctx, service.RoleViewer) }
+	editing := func(ctx context.Context) context.Context { return service.WithRole(ctx, service.RoleEditor) }
+	note, err := storage.Create(ann, service.NoteFields{Text: "shared plan", Tags: []string{"plan"}})
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	share, err := storage.CreateShare(ann, service.ShareFields{NoteID: id(note), UserID: &bobUser.ID, Role: service.RoleViewer})
+
+	if err != nil || share.ID == 0 || share.NoteID != note.ID || share.Role != service.RoleViewer {
+		t.Fatalf("unexpected share %+v, %v", share, err)
+	}
+
+	if _, err = storage.CreateShare(ann, service.ShareFields{NoteID: id(note), UserID: &bobUser.ID, Role: service.RoleEditor}); !errors.Is(err, service.ErrConflict) {
+		t.Errorf("expected ErrConflict for a second share with bob, got %v", err)
+	}
+
+	if got, getErr := storage.Get(reading(bob), id(note)); getErr != nil || got.Text != "shared plan" {
+		t.Errorf("expected a viewer to get the note, got %+v, %v", got, getErr)
+	}
+
+	if notes, _ := storage.GetAll(reading(bob), service.NotesQuery{}); len(notes) != 1 {
+		t.Errorf("expected the shared note among the notes of a viewer, got %d", len(notes))
+	}
+
+	if notes, _ := storage.GetAll(bob, service.NotesQuery{}); len(notes) != 0 {
+		t.Errorf("expected only own notes for the owner role, got %d", len(notes))
+	}
+
+	forbidden := map[string]error{}
+	forbidden["Update"] = storage.Update(editing(bob), id(note), service.NoteFields{Text: "changed"})
+	forbidden["Delete"] = storage.Delete(bob, id(note), 0)
+	forbidden["MoveNote"] = storage.MoveNote(bob, id(note), nil)
+	_, forbidden["Shares"] = storage.Shares(bob, id(note))
+	_, forbidden["CreateShare"] = storage.CreateShare(bob, service.ShareFields{NoteID: id(note), UserID: &caraUser.ID, Role: service.RoleViewer})
+
+	for name, accessErr := range forbidden {
+		if !errors.Is(accessErr, service.ErrForbidden) {
+			t.Errorf("%s: expected ErrForbidden for a viewer, got %v", name, accessErr)
+		}
+	}
+
+	if _, err = storage.Get(reading(cara), id(note)); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a user the note isn't shared with, got %v", err)
+	}
+
+	if err = storage.Update(editing(cara), id(note), service.NoteFields{Text: "changed"}); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a write of a user the note isn't shared with, got %v", err)
+	}
+
+	team, err := storage.CreateTeam(ann, "crew", annUser.ID)
+
+	if err != nil || team.OwnerID != annUser.ID || len(team.Members) != 1 {
+		t.Fatalf("unexpected team %+v, %v", team, err)
+	}
+
+	if err = storage.AddMember(ann, team.ID, caraUser.ID); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if err = storage.AddMember(ann, team.ID, caraUser.ID); !errors.Is(err, service.ErrConflict) {
+		t.Errorf("expected ErrConflict for a member added twice, got %v", err)
+	}
+
+	if teams, _ := storage.Teams(cara, caraUser.ID); len(teams) != 1 || teams[0].Name != "crew" {
+		t.Errorf("expected cara in crew, got %+v", teams)
+	}
+
+	if _, err = storage.CreateShare(ann, service.ShareFields{NoteID: id(note), TeamID: &team.ID, Role: service.RoleEditor}); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if err = storage.Update(editing(cara), id(note), service.NoteFields{Text: "team plan"}); err != nil {
+		t.Errorf("expected a team editor to update the note, got %v", err)
+	}
+
+	shares, err := storage.Shares(ann, id(note))
+
+	if err != nil || len(shares) != 2 || shares[0].Email != "bob@example.com" || shares[1].Team != "crew" {
+		t.Errorf("expected the shares with bob and crew, got %+v, %v", shares, err)
+	}
+
+	if err = storage.RemoveMember(ann, team.ID, caraUser.ID); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if _, err = storage.Get(reading(cara), id(note)); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound once cara left the team, got %v", err)
+	}
+
+	if err = storage.DeleteShare(ann, id(note), share.ID); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if err = storage.DeleteShare(ann, id(note), share.ID); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a deleted share, got %v", err)
+	}
+
+	if _, err = storage.Get(reading(bob), id(note)); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound once the share is deleted, got %v", err)
+	}
+
+	if err = storage.DeleteTeam(ann, team.ID); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if shares, _ = storage.Shares(ann, id(note)); len(shares) != 0 {
+		t.Errorf("expected the shares of a deleted team to go, got %+v", shares)
+	}
+
+	if _, err = storage.Team(ann, team.ID); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a deleted team, got %v", err)
+	}
+}
+
+func testLinks(t *testing.T, storage service.Storage) {
+	ann := service.WithUser(context.Background(), mustCreateUser(t, storage, "ann@example.com"))
+	bob := service.WithUser(context.Background(), mustCreateUser(t, storage, "bob@example.com"))
+	note, err := storage.Create(ann, service.NoteFields{Text: "public plan", Tags: []string{}})
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	link, err := storage.CreateLink(ann, service.LinkFields{NoteID: id(note), TokenHash: "hash", PasswordHash: "secret", ExpiresAt: expiresAt})
+
+	if err != nil || link.ID == 0 || link.NoteID != note.ID || !link.Protected || !link.ExpiresAt.Equal(expiresAt) {
+		t.Fatalf("unexpected link %+v, %v", link, err)
+	}
+
+	if _, err = storage.CreateLink(bob, service.LinkFields{NoteID: id(note), TokenHash: "other", ExpiresAt: expiresAt}); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a link to a note of another user, got %v", err)
+	}
+
+	if err = storage.CountView(context.Background(), link.ID); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	found, err := storage.Link(context.Background(), "hash")
+
+	if err != nil || found.ID != link.ID || found.Views != 1 || found.PasswordHash != "secret" || found.RevokedAt != nil {
+		t.Errorf("unexpected link %+v, %v", found, err)
+	}
+
+	if _, err = storage.Link(context.Background(), "missing"); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an unknown token, got %v", err)
+	}
+
+	// The link opens its note without a user, and only its note.
+	if linked, linkedErr := storage.LinkedNote(context.Background(), link.ID); linkedErr != nil || linked.ID != note.ID || linked.Text != "public plan" {
+		t.Errorf("unexpected linked note %+v, %v", linked, linkedErr)
+	}
+
+	if _, err = storage.LinkedNote(context.Background(), link.ID+1); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an unknown link, got %v", err)
+	}
+
+	if err = storage.RevokeLink(bob, id(note), link.ID); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a revoke by another user, got %v", err)
+	}
+
+	if err = storage.RevokeLink(ann, id(note), link.ID); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if err = storage.RevokeLink(ann, id(note), link.ID); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a revoked link, got %v", err)
+	}
+
+	links, err := storage.Links(ann, id(note))
+
+	if err != nil || len(links) != 1 || links[0].RevokedAt == nil || links[0].Views != 1 {
+		t.Errorf("expected the revoked link listed, got %+v, %v", links, err)
+	}
+
+	if err = storage.Delete(ann, id(note), 0); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if _, err = storage.LinkedNote(context.Background(), link.ID); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a link to a note in the trash, got %v", err)
+	}
+
+	if err = storage.Purge(ann, id(note)); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if _, err = storage.Link(context.Background(), "hash"); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected the links of a purged note to go, got %v", err)
+	}
+}
+
+func testTokens(t *testing.T, storage service.Storage) {
+	ctx := service.AsSystem(context.Background())
+	ann := mustCreateUser(t, storage, "ann@example.com")
+	bob := mustCreateUser(t, storage, "bob@example.com")
+	scopes := []string{service.ScopeNotesRead, service.ScopeNotesWrite}
+	token, err := storage.CreateToken(ctx, service.TokenFields{UserID: ann.ID, Name: "backup", TokenHash: "hash", Scopes: scopes})
+
+	if err != nil || token.ID == 0 || token.Name != "backup" || !reflect.DeepEqual(token.Scopes, scopes) || token.LastUsedAt != nil {
+		t.Fatalf("unexpected token %+v, %v", token, err)
+	}
+
+	if _, err = storage.CreateToken(ctx, service.TokenFields{UserID: ann.ID, Name: "backup", TokenHash: "other", Scopes: scopes}); !errors.Is(err, service.ErrConflict) {
+		t.Errorf("expected ErrConflict for a repeated name, got %v", err)
+	}
+
+	if _, err = storage.CreateToken(ctx, service.TokenFields{UserID: bob.ID, Name: "backup", TokenHash: "bob", Scopes: scopes[:1]}); err != nil {
+		t.Errorf("expected the name free for another user, got %v", err)
+	}
+
+	used := time.Now().UTC().Truncate(time.Second)
+	found, user, err := storage.TokenUser(ctx, "hash", used)
+
+	if err != nil || found.ID != token.ID || user.ID != ann.ID || found.LastUsedAt == nil || !found.LastUsedAt.Equal(used) {
+		t.Fatalf("unexpected token %+v of %+v, %v", found, user, err)
+	}
+
+	if _, _, err = storage.TokenUser(ctx, "missing", used); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an unknown token, got %v", err)
+	}
+
+	tokens, err := storage.Tokens(ctx, ann.ID)
+
+	if err != nil || len(tokens) != 1 || tokens[0].LastUsedAt == nil || !tokens[0].LastUsedAt.Equal(used) || !reflect.DeepEqual(tokens[0].Scopes, scopes) {
+		t.Fatalf("unexpected tokens %+v, %v", tokens, err)
+	}
+
+	if err = storage.DeleteToken(ctx, bob.ID, token.ID); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a token of another user, got %v", err)
+	}
+
+	if err = storage.DeleteToken(ctx, ann.ID, token.ID); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if _, _, err = storage.TokenUser(ctx, "hash", used); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a deleted token, got %v", err)
+	}
+}
+
+func testIdentities(t *testing.T, storage service.Storage) {
+	ctx := service.AsSystem(context.Background())
+	ann := mustCreateUser(t, storage, "ann@example.com")
+	bob := mustCreateUser(t, storage, "bob@example.com")
+	issuer := "https://idp.example.com"
+
+	if _, err := storage.IdentityUser(ctx, issuer, "1"); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an unknown identity, got %v", err)
+	}
+
+	if err := storage.CreateIdentity(ctx, issuer, "1", ann.ID); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if err := storage.CreateIdentity(ctx, issuer, "1", bob.ID); !errors.Is(err, service.ErrConflict) {
+		t.Errorf("expected ErrConflict for a linked identity, got %v", err)
+	}
+
+	if err := storage.CreateIdentity(ctx, "https://other.example.com", "1", bob.ID); err != nil {
+		t.Errorf("expected the subject free at another issuer, got %v", err)
+	}
+
+	if user, err := storage.IdentityUser(ctx, issuer, "1"); err != nil || user.ID != ann.ID || user.Email != ann.Email {
+		t.Errorf("unexpected user %+v, %v", user, err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	login := service.LoginState{StateHash: "hash", Nonce: "nonce", CodeVerifier: "verifier", ExpiresAt: now.Add(time.Minute)}
+
+	for _, l := range []service.LoginState{login, {StateHash: "expired", Nonce: "n", CodeVerifier: "v", ExpiresAt: now.Add(-time.Minute)}} {
+		if err := storage.CreateLoginState(ctx, l); err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+	}
+
+	if _, err := storage.TakeLoginState(ctx, "expired", now); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an expired login, got %v", err)
+	}
+
+	taken, err := storage.TakeLoginState(ctx, "hash", now)
+
+	if err != nil || taken.Nonce != login.Nonce || taken.CodeVerifier != login.CodeVerifier || !taken.ExpiresAt.Equal(login.ExpiresAt) {
+		t.Fatalf("unexpected login %+v, %v", taken, err)
+	}
+
+	if _, err = storage.TakeLoginState(ctx, "hash", now); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a login taken twice, got %v", err)
+	}
+}
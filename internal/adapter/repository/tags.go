@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"note/internal/models"
+	"strings"
+)
+
+// insertTag adds a tag unless it already exists.
+func (d dialect) insertTag() string {
+	switch d.name {
+	case postgresDialect.name:
+		return `INSERT INTO tag (name) VALUES (?) ON CONFLICT (name) DO NOTHING`
+	case sqliteDialect.name:
+		return `INSERT OR IGNORE INTO tag (name) VALUES (?)`
+	default:
+		return `INSERT IGNORE INTO tag (name) VALUES (?)`
+	}
+}
+
+// placeholders returns "?, ?, ?" for n arguments.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// setTags replaces the tags of a note.
+func (ns *noteStorage) setTags(ctx context.Context, q dbtx, noteID int64, tags []string) error {
+	if _, err := q.ExecContext(ctx, ns.dialect.rebind(`DELETE FROM note_tag WHERE note_id = ?`), noteID); err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		if _, err := q.ExecContext(ctx, ns.dialect.rebind(ns.dialect.insertTag()), tag); err != nil {
+			return err
+		}
+
+		_, err := q.ExecContext(ctx, ns.dialect.rebind(`INSERT INTO note_tag (note_id, tag_id) SELECT ?, id FROM tag WHERE name = ?`), noteID, tag)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadTags fills in the tags of the notes with a single query.
+func (ns *noteStorage) loadTags(ctx context.Context, q dbtx, notes []*models.Note) error {
+	if len(notes) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*models.Note, len(notes))
+	args := make([]interface{}, 0, len(notes))
+
+	for _, note := range notes {
+		note.Tags = []string{}
+		byID[note.ID] = note
+		args = append(args, note.ID)
+	}
+
+	query := `SELECT note_tag.note_id, tag.name FROM note_tag JOIN tag ON tag.id = note_tag.tag_id
+		WHERE note_tag.note_id IN (` + placeholders(len(notes)) + `) ORDER BY tag.name`
+	rows, err := q.QueryContext(ctx, ns.dialect.rebind(query), args...)
+
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			noteID int64
+			name   string
+		)
+
+		if err = rows.Scan(&noteID, &name); err != nil {
+			return err
+		}
+
+		if note, in := byID[noteID]; in {
+			note.Tags = append(note.Tags, name)
+		}
+	}
+
+	return rows.Err()
+}
+
+// tagFilter is the condition for notes carrying all (or any) of the tags.
+func tagFilter(tags []string, anyTag bool) (string, []interface{}) {
+	args := make([]interface{}, 0, len(tags)+1)
+
+	for _, tag := range tags {
+		args = append(args, tag)
+	}
+
+	cond := `id IN (SELECT note_tag.note_id FROM note_tag JOIN tag ON tag.id = note_tag.tag_id WHERE tag.name IN (` + placeholders(len(tags)) + `)`
+
+	if anyTag {
+		return cond + ")", args
+	}
+
+	return cond + " GROUP BY note_tag.note_id HAVING COUNT(*) = ?)", append(args, len(tags))
+}
+
+func (ns *noteStorage) Tags(ctx context.Context) ([]*models.Tag, error) {
+	query, args := permitted(ctx, `SELECT tag.name, COUNT(*) AS uses FROM tag JOIN note_tag ON note_tag.tag_id = tag.id
+		JOIN note ON note.id = note_tag.note_id WHERE note.deleted_at IS NULL`, []interface{}{})
+	rows, err := ns.db.QueryContext(ctx, ns.dialect.rebind(query+" GROUP BY tag.name ORDER BY uses DESC, tag.name"), args...)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*models.Tag{}
+
+	for rows.Next() {
+		tag := &models.Tag{}
+
+		if err = rows.Scan(&tag.Name, &tag.Count); err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"note/internal/models"
+	"note/internal/service"
+	"strings"
+	"time"
+)
+
+// Scopes are stored in one column, separated by spaces as in OAuth.
+const tokenColumns = "access_token.id, access_token.user_id, access_token.name, access_token.scopes, access_token.created_at, access_token.last_used_at"
+
+func tokenFields(token *models.AccessToken, scopes *string) []interface{} {
+	return []interface{}{&token.ID, &token.UserID, &token.Name, scopes, &token.CreatedAt, &token.LastUsedAt}
+}
+
+func (ns *noteStorage) CreateToken(ctx context.Context, fields service.TokenFields) (*models.AccessToken, error) {
+	token := &models.AccessToken{UserID: fields.UserID, Name: fields.Name, Scopes: fields.Scopes, CreatedAt: now()}
+	query := `INSERT INTO access_token (user_id, name, token_hash, scopes, created_at) VALUES (?, ?, ?, ?, ?)`
+	id, err := ns.insert(ctx, ns.db, query, fields.UserID, fields.Name, fields.TokenHash, strings.Join(fields.Scopes, " "), token.CreatedAt)
+
+	if errors.Is(err, service.ErrConflict) {
+		return nil, fmt.Errorf("%w: a token named %q exists", service.ErrConflict, fields.Name)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	token.ID = id
+
+	return token, nil
+}
+
+func (ns *noteStorage) Tokens(ctx context.Context, userID int64) ([]*models.AccessToken, error) {
+	query := "SELECT " + tokenColumns + " FROM access_token WHERE user_id=? ORDER BY id"
+	rows, err := ns.db.QueryContext(ctx, ns.dialect.rebind(query), userID)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*models.AccessToken{}
+
+	for rows.Next() {
+		token := &models.AccessToken{}
+		scopes := ""
+
+		if err = rows.Scan(tokenFields(token, &scopes)...); err != nil {
+			return nil, err
+		}
+
+		token.Scopes = strings.Fields(scopes)
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+func (ns *noteStorage) DeleteToken(ctx context.Context, userID, id int64) error {
+	result, err := ns.db.ExecContext(ctx, ns.dialect.rebind(`DELETE FROM access_token WHERE id=? AND user_id=?`), id, userID)
+
+	if err != nil {
+		return err
+	}
+
+	if row, _ := result.RowsAffected(); row == 0 {
+		return tokenNotFound(id)
+	}
+
+	return nil
+}
+
+func (ns *noteStorage) TokenUser(ctx context.Context, tokenHash string, t time.Time) (*models.AccessToken, *models.User, error) {
+	token := &models.AccessToken{}
+	user := &models.User{}
+	scopes := ""
+	query := "SELECT " + tokenColumns + `, users.id, users.email, users.password_hash, users.created_at FROM access_token
+		JOIN users ON users.id = access_token.user_id WHERE access_token.token_hash=?`
+	err := ns.db.QueryRowContext(ctx, ns.dialect.rebind(query), tokenHash).Scan(append(tokenFields(token, &scopes), userFields(user)...)...)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, fmt.Errorf("access token %w", service.ErrNotFound)
+	}
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("can't scan row: %w", err)
+	}
+
+	if _, err = ns.db.ExecContext(ctx, ns.dialect.rebind(`UPDATE access_token SET last_used_at=? WHERE id=?`), t.UTC(), token.ID); err != nil {
+		return nil, nil, err
+	}
+
+	lastUsed := t.UTC()
+	token.Scopes = strings.Fields(scopes)
+	token.LastUsedAt = &lastUsed
+
+	return token, user, nil
+}
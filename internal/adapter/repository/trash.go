@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+func (ns *noteStorage) Restore(ctx context.Context, id string) error {
+	if err := checkID(id); err != nil {
+		return err
+	}
+
+	query, args := permitted(ctx, `UPDATE note SET deleted_at=NULL, version=version+1 WHERE id=? AND deleted_at IS NOT NULL`, []interface{}{id})
+	result, err := ns.db.ExecContext(ctx, ns.dialect.rebind(query), args...)
+
+	if err != nil {
+		return ns.dialect.mapError(err)
+	}
+
+	if row, _ := result.RowsAffected(); row == 0 {
+		return noteNotFound(id)
+	}
+
+	return nil
+}
+
+// Purge removes a trashed note for good; notes that aren't in the trash are
+// not found.
+func (ns *noteStorage) Purge(ctx context.Context, id string) error {
+	if err := checkID(id); err != nil {
+		return err
+	}
+
+	query, args := permitted(ctx, `DELETE FROM note WHERE id=? AND deleted_at IS NOT NULL`, []interface{}{id})
+	result, err := ns.db.ExecContext(ctx, ns.dialect.rebind(query), args...)
+
+	if err != nil {
+		return ns.dialect.mapError(err)
+	}
+
+	if row, _ := result.RowsAffected(); row == 0 {
+		return noteNotFound(id)
+	}
+
+	return nil
+}
+
+func (ns *noteStorage) PurgeTrashed(ctx context.Context, before time.Time) (int64, error) {
+	result, err := ns.db.ExecContext(ctx, ns.dialect.rebind(`DELETE FROM note WHERE deleted_at IS NOT NULL AND deleted_at < ?`), before.UTC())
+
+	if err != nil {
+		return 0, ns.dialect.mapError(err)
+	}
+
+	return result.RowsAffected()
+}
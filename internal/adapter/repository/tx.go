@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+)
+
+// dbtx is implemented by both *sql.DB and *sql.Tx, so helpers can run on
+// their own or as a part of a transaction.
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// inTx runs fn in a transaction, committing if it returns nil.
+func (ns *noteStorage) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := ns.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	if err = fn(tx); err != nil {
+		_ = tx.Rollback()
+
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"note/internal/models"
+	"note/internal/service"
+	"time"
+)
+
+const userColumns = "id, email, password_hash, created_at"
+
+func userFields(user *models.User) []interface{} {
+	return []interface{}{&user.ID, &user.Email, &user.PasswordHash, &user.CreatedAt}
+}
+
+// owned limits a query on notebooks to those of the user acting in ctx, the
+// way ifVersion limits it to a version; permitted does it for notes. Like
+// there, only a system context sees every row without a user.
+func owned(ctx context.Context, query string, args []interface{}) (string, []interface{}) {
+	user, ok := service.UserFrom(ctx)
+
+	if !ok {
+		return unowned(ctx, query, args)
+	}
+
+	return query + " AND owner_id=?", append(args, user.ID)
+}
+
+// owner is the owner_id of a row created in ctx, NULL without a user.
+func owner(ctx context.Context) *int64 {
+	user, ok := service.UserFrom(ctx)
+
+	if !ok {
+		return nil
+	}
+
+	return &user.ID
+}
+
+func (ns *noteStorage) CreateUser(ctx context.Context, email, passwordHash string) (*models.User, error) {
+	user := &models.User{Email: email, PasswordHash: passwordHash, CreatedAt: now()}
+	id, err := ns.insert(ctx, ns.db, `INSERT INTO users (email, password_hash, created_at) VALUES (?, ?, ?)`, email, passwordHash, user.CreatedAt)
+
+	if errors.Is(err, service.ErrConflict) {
+		return nil, fmt.Errorf("%w: email %s is already registered", service.ErrConflict, email)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	user.ID = id
+
+	return user, nil
+}
+
+func (ns *noteStorage) UserByEmail(ctx context.Context, email string) (*models.User, error) {
+	user := &models.User{}
+	err := ns.db.QueryRowContext(ctx, ns.dialect.rebind("SELECT "+userColumns+" FROM users WHERE email=?"), email).Scan(userFields(user)...)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("user %s %w", email, service.ErrNotFound)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("can't scan row: %w", err)
+	}
+
+	return user, nil
+}
+
+func (ns *noteStorage) CreateSession(ctx context.Context, tokenHash string, userID int64, expiresAt time.Time) error {
+	t := now()
+
+	return ns.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, ns.dialect.rebind(`DELETE FROM session WHERE user_id=? AND expires_at < ?`), userID, t); err != nil {
+			return err
+		}
+
+		query := `INSERT INTO session (token_hash, user_id, created_at, expires_at) VALUES (?, ?, ?, ?)`
+		_, err := tx.ExecContext(ctx, ns.dialect.rebind(query), tokenHash, userID, t, expiresAt.UTC())
+
+		return ns.dialect.mapError(err)
+	})
+}
+
+func (ns *noteStorage) SessionUser(ctx context.Context, tokenHash string, t time.Time) (*models.User, error) {
+	user := &models.User{}
+	query := `SELECT users.id, users.email, users.password_hash, users.created_at FROM session
+		JOIN users ON users.id = session.user_id WHERE session.token_hash=? AND session.expires_at > ?`
+	err := ns.db.QueryRowContext(ctx, ns.dialect.rebind(query), tokenHash, t.UTC()).Scan(userFields(user)...)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("session %w", service.ErrNotFound)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("can't scan row: %w", err)
+	}
+
+	return user, nil
+}
+
+func (ns *noteStorage) DeleteSession(ctx context.Context, tokenHash string) error {
+	result, err := ns.db.ExecContext(ctx, ns.dialect.rebind(`DELETE FROM session WHERE token_hash=?`), tokenHash)
+
+	if err != nil {
+		return err
+	}
+
+	if row, _ := result.RowsAffected(); row == 0 {
+		return fmt.Errorf("session %w", service.ErrNotFound)
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"note/internal/service"
+)
+
+// ifVersion makes a write apply only to the given version of the note, so
+// the check and the write are one statement. 0 means any version.
+func ifVersion(query string, args []interface{}, version int64) (string, []interface{}) {
+	if version == 0 {
+		return query, args
+	}
+
+	return query + " AND version=?", append(args, version)
+}
+
+// notUpdated tells why a write guarded by ifVersion and permitted changed no
+// rows.
+func (ns *noteStorage) notUpdated(ctx context.Context, q dbtx, id string, version int64) error {
+	if _, ok := service.UserFrom(ctx); !ok && version == 0 {
+		return noteNotFound(id)
+	}
+
+	if err := ns.checkLive(ctx, q, id); err != nil {
+		return err
+	}
+
+	if version == 0 {
+		return noteNotFound(id)
+	}
+
+	return fmt.Errorf("%w: note %s is no longer at version %d", service.ErrPreconditionFailed, id, version)
+}
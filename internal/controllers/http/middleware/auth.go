@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"note/internal/models"
+	"note/internal/service"
+	"note/internal/tools"
+
+	"go.uber.org/zap"
+)
+
+const (
+	problemUnauthorized = "/problems/unauthorized"
+	problemForbidden    = "/problems/forbidden"
+)
+
+// Authenticator finds the user a session token or a personal access token
+// belongs to.
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (*models.User, error)
+	// AuthenticateToken also returns the scopes the access token grants.
+	AuthenticateToken(ctx context.Context, token string) (*models.User, []string, error)
+}
+
+// Auth lets through requests with a valid bearer token and runs them for
+// the user of the token, so the storage only sees that user's notes. Others
+// are answered with 401. An access token also needs the scope of the
+// request, or it is answered with 403.
+func Auth(next http.Handler, auth Authenticator, logger *zap.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := tools.BearerToken(r)
+		scope := scopeFor(r.Method)
+		ctx, err := authenticate(r.Context(), auth, token, scope)
+
+		if err == nil {
+			next.ServeHTTP(w, r.WithContext(ctx))
+
+			return
+		}
+
+		problem := tools.NewProblem(http.StatusInternalServerError, "can't check the session")
+
+		switch {
+		case errors.Is(err, service.ErrUnauthorized):
+			problem = tools.NewProblem(http.StatusUnauthorized, err.Error())
+			problem.Type = problemUnauthorized
+			w.Header().Set("WWW-Authenticate", challenge(token))
+		case errors.Is(err, service.ErrForbidden):
+			problem = tools.NewProblem(http.StatusForbidden, err.Error())
+			problem.Type = problemForbidden
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="note", error="insufficient_scope", scope="%s"`, scope))
+		default:
+			logger.Warn(err.Error())
+		}
+
+		if err = tools.WriteProblem(w, r, problem); err != nil {
+			logger.Warn(err.Error())
+		}
+	})
+}
+
+// authenticate returns ctx acting for the user of a session or of an access
+// token with scope.
+func authenticate(ctx context.Context, auth Authenticator, token, scope string) (context.Context, error) {
+	if !service.IsAccessToken(token) {
+		user, err := auth.Authenticate(ctx, token)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return service.WithUser(ctx, user), nil
+	}
+
+	user, scopes, err := auth.AuthenticateToken(ctx, token)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !service.HasScope(scopes, scope) {
+		return nil, fmt.Errorf("%w: the access token lacks the %s scope", service.ErrForbidden, scope)
+	}
+
+	return service.WithScopes(service.WithUser(ctx, user), scopes), nil
+}
+
+// scopeFor is the scope an access token needs for a request: reading for
+// the safe methods, writing for the rest.
+func scopeFor(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return service.ScopeNotesRead
+	}
+
+	return service.ScopeNotesWrite
+}
+
+// challenge tells the client to log in, or that the token it sent is no
+// good, as RFC 6750 has it.
+func challenge(token string) string {
+	if token == "" {
+		return `Bearer realm="note"`
+	}
+
+	return `Bearer realm="note", error="invalid_token"`
+}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"note/internal/models"
+	"note/internal/service"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+type tokens map[string]*models.User
+
+func (t tokens) Authenticate(ctx context.Context, token string) (*models.User, error) {
+	if token == "broken" {
+		return nil, errors.New("storage is down")
+	}
+
+	if user, in := t[token]; in {
+		return user, nil
+	}
+
+	return nil, fmt.Errorf("%w: bad token", service.ErrUnauthorized)
+}
+
+// AuthenticateToken takes the scope of an access token from its name.
+func (t tokens) AuthenticateToken(ctx context.Context, token string) (*models.User, []string, error) {
+	if user, in := t[token]; in {
+		return user, []string{"notes:" + strings.TrimPrefix(token, "pat_")}, nil
+	}
+
+	return nil, nil, fmt.Errorf("%w: bad access token", service.ErrUnauthorized)
+}
+
+func TestAuth(t *testing.T) {
+	var (
+		seen   *models.User
+		scoped bool
+	)
+
+	handler := Auth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = service.UserFrom(r.Context())
+		_, scoped = service.ScopesFrom(r.Context())
+	}), tokens{"good": {ID: 7}, "pat_read": {ID: 7}, "pat_write": {ID: 7}}, zap.NewNop())
+
+	insufficient := `Bearer realm="note", error="insufficient_scope", scope="notes:write"`
+	cases := []struct {
+		method    string
+		header    string
+		code      int
+		challenge string
+	}{
+		{"GET", "Bearer good", http.StatusOK, ""},
+		{"GET", "bearer good", http.StatusOK, ""},
+		{"GET", "", http.StatusUnauthorized, `Bearer realm="note"`},
+		{"GET", "Basic good", http.StatusUnauthorized, `Bearer realm="note"`},
+		{"GET", "Bearer bad", http.StatusUnauthorized, `Bearer realm="note", error="invalid_token"`},
+		{"GET", "Bearer broken", http.StatusInternalServerError, ""},
+		{"GET", "Bearer pat_read", http.StatusOK, ""},
+		{"HEAD", "Bearer pat_read", http.StatusOK, ""},
+		{"POST", "Bearer pat_read", http.StatusForbidden, insufficient},
+		{"DELETE", "Bearer pat_write", http.StatusOK, ""},
+		{"GET", "Bearer pat_write", http.StatusForbidden, `Bearer realm="note", error="insufficient_scope", scope="notes:read"`},
+		{"GET", "Bearer pat_bad", http.StatusUnauthorized, `Bearer realm="note", error="invalid_token"`},
+	}
+
+	for _, c := range cases {
+		seen, scoped = nil, false
+		req := httptest.NewRequest(c.method, "/note", nil)
+
+		if c.header != "" {
+			req.Header.Set("Authorization", c.header)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != c.code || w.Header().Get("WWW-Authenticate") != c.challenge {
+			t.Errorf("%s %q: expected %d %q, got %d %q", c.method, c.header, c.code, c.challenge, w.Code, w.Header().Get("WWW-Authenticate"))
+		}
+
+		if (seen != nil && seen.ID == 7) != (c.code == http.StatusOK) {
+			t.Errorf("%s %q: handler ran for %v", c.method, c.header, seen)
+		}
+
+		if scoped != (c.code == http.StatusOK && strings.Contains(c.header, "pat_")) {
+			t.Errorf("%s %q: expected scopes only for access tokens", c.method, c.header)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"note/internal/tools"
+
+	"go.uber.org/zap"
+)
+
+// MaxBody refuses request bodies over limit bytes with 413. A body without
+// a Content-Length is cut off at the limit, so reading it fails with an
+// *http.MaxBytesError the handler answers.
+func MaxBody(next http.Handler, limit int64, logger *zap.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > limit {
+			problem := tools.NewProblem(http.StatusRequestEntityTooLarge, fmt.Sprintf("the body must be at most %d bytes", limit))
+
+			if err := tools.WriteProblem(w, r, problem); err != nil {
+				logger.Warn(err.Error())
+			}
+
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestMaxBody(t *testing.T) {
+	var readErr error
+
+	handler := MaxBody(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, readErr = io.ReadAll(r.Body)
+	}), 8, zap.NewNop())
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("POST", "/note", strings.NewReader("too long a body")))
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for a long Content-Length, got %d", w.Code)
+	}
+
+	// Without a Content-Length the body is cut off while it is read.
+	r := httptest.NewRequest("POST", "/note", io.NopCloser(strings.NewReader("too long a body")))
+	r.ContentLength = -1
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	var tooLarge *http.MaxBytesError
+
+	if !errors.As(readErr, &tooLarge) {
+		t.Errorf("expected *http.MaxBytesError, got %v", readErr)
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/note", strings.NewReader("short")))
+
+	if readErr != nil {
+		t.Errorf("unexpected err: %s", readErr)
+	}
+}
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"net/http"
+	"note/internal/tools"
 	"time"
 
 	"go.uber.org/zap"
@@ -12,6 +13,6 @@ func Logger(next http.Handler, logger *zap.Logger) http.Handler {
 		t := time.Now()
 		next.ServeHTTP(w, r)
 
-		logger.Sugar().Infof("method=[%s] path=[%s] timeAnswer=%v", r.Method, r.URL.Path, time.Since(t))
+		logger.Sugar().Infof("method=[%s] path=[%s] requestID=[%s] timeAnswer=%v", r.Method, r.URL.Path, tools.RequestID(r.Context()), time.Since(t))
 	})
 }
@@ -0,0 +1,175 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"note/internal/service"
+	"note/internal/tools"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const problemTooManyRequests = "/problems/too-many-requests"
+
+// Limit is a token bucket: it holds Requests tokens and is refilled with
+// Requests tokens every Period, so bursts up to Requests pass at once. The
+// zero Limit doesn't limit anything.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// ParseLimit reads a limit written as requests per period, like "60/1m".
+// An empty string or "0" is no limit.
+func ParseLimit(s string) (Limit, error) {
+	if s == "" || s == "0" {
+		return Limit{}, nil
+	}
+
+	requests, period, found := strings.Cut(s, "/")
+	n, errN := strconv.Atoi(requests)
+	d, errD := time.ParseDuration(period)
+
+	// A period shorter than a nanosecond per request couldn't refill.
+	if !found || errN != nil || errD != nil || n <= 0 || d < time.Duration(n) {
+		return Limit{}, fmt.Errorf("limit %q is not requests per period, like 60/1m", s)
+	}
+
+	return Limit{Requests: n, Period: d}, nil
+}
+
+func (l Limit) enabled() bool {
+	return l.Requests > 0 && l.Period > 0
+}
+
+// interval is how long the bucket takes to get one token back.
+func (l Limit) interval() time.Duration {
+	return l.Period / time.Duration(l.Requests)
+}
+
+// Bucket is the state of a bucket after a request took a token from it, or
+// failed to.
+type Bucket struct {
+	Allowed   bool
+	Remaining int
+	// RetryAfter is how long until the next token, when none is left.
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+}
+
+// BucketStore keeps the buckets. Take has to be atomic per key, so a store
+// shared by several instances of the service limits them together.
+type BucketStore interface {
+	Take(ctx context.Context, key string, limit Limit) (Bucket, error)
+}
+
+// Policy limits a route group. Key says whose bucket a request takes from;
+// requests with an empty key aren't limited.
+type Policy struct {
+	Name  string
+	Limit Limit
+	Key   func(*http.Request) string
+}
+
+// RateLimit answers requests over the limit of policy with 429. Every
+// answer tells the client how much of the limit is left in the RateLimit
+// headers of the IETF httpapi draft. When the store fails, requests are let
+// through rather than lost.
+func RateLimit(next http.Handler, store BucketStore, policy Policy, logger *zap.Logger) http.Handler {
+	if !policy.Limit.enabled() {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := policy.Key(r)
+
+		if key == "" {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		bucket, err := store.Take(r.Context(), policy.Name+":"+key, policy.Limit)
+
+		if err != nil {
+			logger.Warn(fmt.Sprintf("can't check the %s rate limit: %s", policy.Name, err))
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		setLimitHeaders(w.Header(), policy.Limit, bucket)
+
+		if bucket.Allowed {
+			next.ServeHTTP(w, r)
+
+			return
+		}
+
+		w.Header().Set("Retry-After", strconv.Itoa(seconds(bucket.RetryAfter)))
+		problem := tools.NewProblem(http.StatusTooManyRequests, fmt.Sprintf("the %s rate limit of %d requests per %s is exceeded", policy.Name, policy.Limit.Requests, policy.Limit.Period))
+		problem.Type = problemTooManyRequests
+
+		if err = tools.WriteProblem(w, r, problem); err != nil {
+			logger.Warn(err.Error())
+		}
+	})
+}
+
+// setLimitHeaders describes the bucket closest to its limit, so a policy
+// inside another one only replaces its headers when it has less left.
+func setLimitHeaders(header http.Header, limit Limit, bucket Bucket) {
+	if left, err := strconv.Atoi(header.Get("RateLimit-Remaining")); err == nil && left < bucket.Remaining {
+		return
+	}
+
+	header.Set("RateLimit-Limit", strconv.Itoa(limit.Requests))
+	header.Set("RateLimit-Remaining", strconv.Itoa(bucket.Remaining))
+	header.Set("RateLimit-Reset", strconv.Itoa(seconds(bucket.Reset)))
+	header.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Requests, seconds(limit.Period)))
+}
+
+// seconds rounds d up, since a client retrying early is refused again.
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// ByIP keys requests by the address of the client. Behind a proxy that is
+// the address of the proxy, since X-Forwarded-For can be made up.
+func ByIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// ByUser keys requests by their user, once Auth has found it. Each access
+// token has a bucket of its own, so a runaway script doesn't lock its user
+// out of the front page.
+func ByUser(r *http.Request) string {
+	user, ok := service.UserFrom(r.Context())
+
+	if !ok {
+		return ""
+	}
+
+	if token := tools.BearerToken(r); service.IsAccessToken(token) {
+		sum := sha256.Sum256([]byte(token))
+
+		return "token:" + hex.EncodeToString(sum[:])
+	}
+
+	return "user:" + strconv.FormatInt(user.ID, 10)
+}
@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often the memory store forgets full buckets.
+const sweepInterval = time.Minute
+
+type memoryBucket struct {
+	tokens  float64
+	updated time.Time
+	// full is when the bucket has all its tokens back and can be forgotten.
+	full time.Time
+}
+
+// MemoryBuckets keeps the buckets in the memory of the process, so every
+// instance of the service has limits of its own.
+type MemoryBuckets struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	nextSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryBuckets() *MemoryBuckets {
+	return &MemoryBuckets{buckets: map[string]*memoryBucket{}, now: time.Now}
+}
+
+// Take refills the bucket of key for the time since it was last used and
+// takes a token from it. A bucket that was full is the same as no bucket,
+// so only buckets in use are kept.
+func (mb *MemoryBuckets) Take(ctx context.Context, key string, limit Limit) (Bucket, error) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	now := mb.now()
+	mb.sweep(now)
+
+	capacity := float64(limit.Requests)
+	interval := limit.interval()
+	b, ok := mb.buckets[key]
+
+	if !ok {
+		b = &memoryBucket{tokens: capacity, updated: now}
+		mb.buckets[key] = b
+	}
+
+	b.tokens += float64(now.Sub(b.updated)) / float64(interval)
+	b.updated = now
+
+	if b.tokens > capacity {
+		b.tokens = capacity
+	}
+
+	bucket := Bucket{Allowed: b.tokens >= 1}
+
+	if bucket.Allowed {
+		b.tokens--
+	} else {
+		bucket.RetryAfter = time.Duration((1 - b.tokens) * float64(interval))
+	}
+
+	bucket.Remaining = int(b.tokens)
+	bucket.Reset = time.Duration((capacity - b.tokens) * float64(interval))
+	b.full = now.Add(bucket.Reset)
+
+	return bucket, nil
+}
+
+func (mb *MemoryBuckets) sweep(now time.Time) {
+	if now.Before(mb.nextSweep) {
+		return
+	}
+
+	for key, b := range mb.buckets {
+		if !now.Before(b.full) {
+			delete(mb.buckets, key)
+		}
+	}
+
+	mb.nextSweep = now.Add(sweepInterval)
+}
@@ -0,0 +1,162 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"note/internal/models"
+	"note/internal/service"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// clock is a time the test moves by hand.
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+type brokenStore struct{}
+
+func (brokenStore) Take(context.Context, string, Limit) (Bucket, error) {
+	return Bucket{}, errors.New("store is down")
+}
+
+func TestRateLimit(t *testing.T) {
+	c := &clock{t: time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)}
+	store := NewMemoryBuckets()
+	store.now = c.now
+	policy := Policy{Name: "api", Limit: Limit{Requests: 2, Period: time.Minute}, Key: ByIP}
+	handler := RateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), store, policy, zap.NewNop())
+	send := func(addr string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/note", nil)
+		r.RemoteAddr = addr
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		return w
+	}
+
+	for i, want := range []string{"1", "0"} {
+		w := send("10.0.0.1:1234")
+
+		if w.Code != http.StatusOK || w.Header().Get("RateLimit-Remaining") != want || w.Header().Get("RateLimit-Limit") != "2" {
+			t.Fatalf("request %d: unexpected response %d %v", i, w.Code, w.Header())
+		}
+	}
+
+	// Another port is the same client.
+	w := send("10.0.0.1:5678")
+
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "30" || w.Header().Get("RateLimit-Reset") != "60" {
+		t.Errorf("expected 429 with Retry-After, got %d %v", w.Code, w.Header())
+	}
+
+	if w.Header().Get("RateLimit-Policy") != "2;w=60" || w.Header().Get("Content-Type") != "application/problem+json" {
+		t.Errorf("unexpected headers %v", w.Header())
+	}
+
+	if w = send("10.0.0.2:1234"); w.Code != http.StatusOK {
+		t.Errorf("expected another client to have its own bucket, got %d", w.Code)
+	}
+
+	// Half the period gives one token back, not the whole bucket.
+	c.t = c.t.Add(30 * time.Second)
+
+	if w = send("10.0.0.1:1234"); w.Code != http.StatusOK || w.Header().Get("RateLimit-Remaining") != "0" {
+		t.Errorf("expected a refilled token, got %d %v", w.Code, w.Header())
+	}
+
+	if w = send("10.0.0.1:1234"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected 429, got %d", w.Code)
+	}
+
+	// Full buckets are forgotten.
+	c.t = c.t.Add(time.Hour)
+	send("10.0.0.3:1234")
+
+	if len(store.buckets) != 1 {
+		t.Errorf("expected only the bucket in use to be kept, got %d", len(store.buckets))
+	}
+}
+
+func TestRateLimitUsers(t *testing.T) {
+	store := NewMemoryBuckets()
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	perUser := RateLimit(ok, store, Policy{Name: "api-user", Limit: Limit{Requests: 1, Period: time.Hour}, Key: ByUser}, zap.NewNop())
+	// The client address has room for more than a user.
+	handler := RateLimit(perUser, store, Policy{Name: "api-ip", Limit: Limit{Requests: 10, Period: time.Hour}, Key: ByIP}, zap.NewNop())
+	send := func(user *models.User, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/note", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+
+		if user != nil {
+			r = r.WithContext(service.WithUser(r.Context(), user))
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		return w
+	}
+
+	w := send(&models.User{ID: 7}, "session")
+
+	if w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "1" || w.Header().Get("RateLimit-Remaining") != "0" {
+		t.Errorf("expected the headers of the user bucket, got %d %v", w.Code, w.Header())
+	}
+
+	if w = send(&models.User{ID: 7}, "another session"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected the sessions of a user to share a bucket, got %d", w.Code)
+	}
+
+	if w = send(&models.User{ID: 7}, "pat_script"); w.Code != http.StatusOK {
+		t.Errorf("expected an access token to have its own bucket, got %d", w.Code)
+	}
+
+	if w = send(&models.User{ID: 8}, "session"); w.Code != http.StatusOK {
+		t.Errorf("expected another user to have its own bucket, got %d", w.Code)
+	}
+
+	// Without a user only the client address is limited.
+	if w = send(nil, ""); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "10" || w.Header().Get("RateLimit-Remaining") != "5" {
+		t.Errorf("expected the headers of the address bucket, got %d %v", w.Code, w.Header())
+	}
+}
+
+func TestRateLimitStoreDown(t *testing.T) {
+	policy := Policy{Name: "api", Limit: Limit{Requests: 1, Period: time.Minute}, Key: ByIP}
+	handler := RateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), brokenStore{}, policy, zap.NewNop())
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/note", nil))
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected requests to pass when the store is down, got %d", w.Code)
+		}
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	cases := map[string]Limit{
+		"":       {},
+		"0":      {},
+		"60/1m":  {Requests: 60, Period: time.Minute},
+		"5/1.5s": {Requests: 5, Period: 1500 * time.Millisecond},
+	}
+
+	for s, want := range cases {
+		if limit, err := ParseLimit(s); err != nil || limit != want {
+			t.Errorf("%q: expected %+v, got %+v, %v", s, want, limit, err)
+		}
+	}
+
+	for _, s := range []string{"60", "60/", "/1m", "-1/1m", "60/0s", "60/1ns", "sixty/1m"} {
+		if _, err := ParseLimit(s); err == nil {
+			t.Errorf("%q: expected an error", s)
+		}
+	}
+}
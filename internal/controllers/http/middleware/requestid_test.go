@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"note/internal/tools"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	var seen string
+
+	handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = tools.RequestID(r.Context())
+	}))
+
+	cases := []struct {
+		incoming string
+		keep     bool
+	}{
+		{"", false},
+		{"abc-123", true},
+		{"bad id\nwith newline", false},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "/", nil)
+
+		if c.incoming != "" {
+			req.Header.Set(requestIDHeader, c.incoming)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if seen == "" || w.Header().Get(requestIDHeader) != seen {
+			t.Errorf("expected id in context and response, got %q and %q", seen, w.Header().Get(requestIDHeader))
+		}
+
+		if (seen == c.incoming) != c.keep {
+			t.Errorf("incoming %q: unexpected id %q", c.incoming, seen)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"note/internal/models"
+	"note/internal/service"
+	"note/internal/tools"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+type AuthService interface {
+	Register(context.Context, service.Credentials) (*models.User, error)
+	Login(context.Context, service.Credentials) (*service.Session, error)
+	Logout(context.Context, string) error
+	CreateToken(context.Context, service.CreateToken) (*models.AccessToken, error)
+	Tokens(context.Context) (*service.TokenList, error)
+	RevokeToken(context.Context, service.RevokeToken) error
+}
+
+type authHandlers struct {
+	handlers
+	authService AuthService
+}
+
+func NewAuthHandler(service AuthService, logger *zap.Logger) authHandlers {
+	return authHandlers{handlers: handlers{Logger: logger}, authService: service}
+}
+
+// Register adds the routes that don't need a session. Logout takes the
+// token it ends from the request, like the routes behind middleware.Auth.
+func (h *authHandlers) Register(router *mux.Router) {
+	router.HandleFunc("/auth/register", h.SignUp).Methods("POST")
+	router.HandleFunc("/auth/login", h.Login).Methods("POST")
+	router.HandleFunc("/auth/logout", h.Logout).Methods("POST")
+}
+
+func (h *authHandlers) SignUp(w http.ResponseWriter, r *http.Request) {
+	credentials, ok := h.credentials(w, r)
+
+	if !ok {
+		return
+	}
+
+	user, err := h.authService.Register(r.Context(), credentials)
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't register a user")
+
+		return
+	}
+
+	err = tools.WriteJSONStatus(w, http.StatusCreated, user)
+
+	if err != nil {
+		h.Logger.Warn(err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (h *authHandlers) Login(w http.ResponseWriter, r *http.Request) {
+	credentials, ok := h.credentials(w, r)
+
+	if !ok {
+		return
+	}
+
+	session, err := h.authService.Login(r.Context(), credentials)
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't log in")
+
+		return
+	}
+
+	// The token is a credential: no cache may keep it.
+	headers := http.Header{}
+	headers.Set("Cache-Control", "no-store")
+
+	err = tools.WriteJSON(w, session, headers)
+
+	if err != nil {
+		h.Logger.Warn(err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (h *authHandlers) Logout(w http.ResponseWriter, r *http.Request) {
+	err := h.authService.Logout(r.Context(), tools.BearerToken(r))
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't log out")
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *authHandlers) credentials(w http.ResponseWriter, r *http.Request) (service.Credentials, bool) {
+	credentials := service.Credentials{}
+
+	if r.Header.Get("Content-type") != contentType {
+		h.Logger.Warn("not found application/json header")
+		h.problem(w, r, http.StatusBadRequest, "not found application/json header")
+
+		return credentials, false
+	}
+
+	data, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+
+	if err != nil {
+		h.readError(w, r, err)
+
+		return credentials, false
+	}
+
+	if err = json.Unmarshal(data, &credentials); err != nil {
+		h.Logger.Warn(fmt.Sprintf("can't read credentials: %s", err))
+		h.problem(w, r, http.StatusBadRequest, "can't read json")
+
+		return credentials, false
+	}
+
+	return credentials, true
+}
@@ -0,0 +1,91 @@
+package v1
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"note/internal/models"
+	"note/internal/service"
+	"note/internal/tools"
+)
+
+type batchResult struct {
+	Status int            `json:"status"`
+	Note   *models.Note   `json:"note,omitempty"`
+	Error  *tools.Problem `json:"error,omitempty"`
+}
+
+type batchResponse struct {
+	Mode      string        `json:"mode"`
+	Committed bool          `json:"committed"`
+	Results   []batchResult `json:"results"`
+}
+
+// Batch runs many creates, updates and deletes in one transaction. Every
+// operation gets the status it would have alone; the response is 200 when
+// all of them succeeded and 207 Multi-Status otherwise.
+func (h *handlers) Batch(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Content-type") != contentType {
+		h.Logger.Warn("not found application/json header")
+		h.problem(w, r, http.StatusBadRequest, "not found application/json header")
+
+		return
+	}
+
+	data, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+
+	if err != nil {
+		h.readError(w, r, err)
+
+		return
+	}
+
+	batch := service.Batch{}
+	err = json.Unmarshal(data, &batch)
+
+	if err != nil {
+		h.Logger.Warn(err.Error())
+		h.problem(w, r, http.StatusBadRequest, "can't read json")
+
+		return
+	}
+
+	results, err := h.noteService.Batch(r.Context(), batch)
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't run the batch")
+
+		return
+	}
+
+	response := batchResponse{Mode: results.Mode, Committed: results.Committed, Results: make([]batchResult, 0, len(results.Results))}
+	status := http.StatusOK
+
+	for i, result := range results.Results {
+		item := batchResult{Status: http.StatusOK, Note: result.Note}
+
+		if batch.Operations[i].Op == service.BatchCreate {
+			item.Status = http.StatusCreated
+		}
+
+		if result.Err != nil {
+			item.Error = errorProblem(result.Err, "can't apply the operation")
+			item.Status = item.Error.Status
+			status = http.StatusMultiStatus
+
+			if item.Status == http.StatusInternalServerError {
+				h.Logger.Warn(result.Err.Error())
+			}
+		}
+
+		response.Results = append(response.Results, item)
+	}
+
+	err = tools.WriteJSONStatus(w, status, response)
+
+	if err != nil {
+		h.Logger.Warn(err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
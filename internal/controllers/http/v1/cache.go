@@ -0,0 +1,78 @@
+package v1
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Clients may keep note reads but have to revalidate them on every use,
+// which costs a 304 without a body while nothing changed.
+const cacheControl = "private, no-cache"
+
+// weakETag tags a representation by its content. It is weak because equal
+// JSON is all it promises, not equal bytes.
+func weakETag(data interface{}) (string, error) {
+	body, err := json.Marshal(data)
+
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(body)
+
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`, nil
+}
+
+// notModified sets the cache headers of a read and answers 304 when the copy
+// the client validates is still current. A zero modified leaves out
+// Last-Modified.
+func (h *handlers) notModified(w http.ResponseWriter, r *http.Request, headers http.Header, etag string, modified time.Time) bool {
+	headers.Set("ETag", etag)
+	headers.Set("Cache-Control", cacheControl)
+
+	if !modified.IsZero() {
+		headers.Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+
+	if !fresh(r, etag, modified) {
+		return false
+	}
+
+	for key, values := range headers {
+		w.Header()[key] = values
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+
+	return true
+}
+
+// fresh evaluates If-None-Match and If-Modified-Since as RFC 9110 does for
+// GET: entity tags are compared weakly, and If-Modified-Since only counts
+// without If-None-Match.
+func fresh(r *http.Request, etag string, modified time.Time) bool {
+	if header := r.Header.Get("If-None-Match"); header != "" {
+		for _, tag := range strings.Split(header, ",") {
+			tag = strings.TrimSpace(tag)
+
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+
+	if err != nil || modified.IsZero() {
+		return false
+	}
+
+	// HTTP dates have no fractions of a second.
+	return !modified.Truncate(time.Second).After(since)
+}
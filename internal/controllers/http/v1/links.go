@@ -0,0 +1,82 @@
+package v1
+
+import (
+	"net/http"
+	"note/internal/service"
+	"note/internal/tools"
+
+	"github.com/gorilla/mux"
+)
+
+func (h *handlers) registerLinks(router *mux.Router) {
+	router.HandleFunc("/note/{id}/links", h.Links).Methods("GET")
+	router.HandleFunc("/note/{id}/links", h.CreateLink).Methods("POST")
+	router.HandleFunc("/note/{id}/links/{linkId}", h.RevokeLink).Methods("DELETE")
+}
+
+func (h *handlers) Links(w http.ResponseWriter, r *http.Request) {
+	links, err := h.noteService.Links(r.Context(), service.GetLinks{ID: mux.Vars(r)["id"]})
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't get links")
+
+		return
+	}
+
+	err = tools.WriteJSON(w, links)
+
+	if err != nil {
+		h.Logger.Warn("server can't write")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// CreateLink answers with the token of the link, which is never shown
+// again, and its public URL in Location.
+func (h *handlers) CreateLink(w http.ResponseWriter, r *http.Request) {
+	cl := service.CreateLink{}
+
+	if !h.readJSON(w, r, &cl) {
+		return
+	}
+
+	cl.ID = mux.Vars(r)["id"]
+	link, err := h.noteService.CreateLink(r.Context(), cl)
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't create a link")
+
+		return
+	}
+
+	headers := http.Header{}
+	headers.Set("Location", "/s/"+link.Token)
+	headers.Set("Cache-Control", "no-store")
+
+	err = tools.WriteJSONStatus(w, http.StatusCreated, link, headers)
+
+	if err != nil {
+		h.Logger.Warn(err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (h *handlers) RevokeLink(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	err := h.noteService.RevokeLink(r.Context(), service.RevokeLink{ID: vars["id"], LinkID: vars["linkId"]})
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't revoke a link")
+
+		return
+	}
+
+	err = tools.WriteJSON(w, struct {
+		Response string `json:"response"`
+	}{"successfully revoked"})
+
+	if err != nil {
+		h.Logger.Warn("server can't write")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
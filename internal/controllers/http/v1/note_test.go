@@ -2,13 +2,16 @@ package v1
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"note/internal/models"
 	"note/internal/models/mocks"
 	"note/internal/service"
+	"note/internal/tools"
 	"strings"
 	"testing"
 
@@ -255,9 +258,9 @@ func TestCreate(t *testing.T) {
 
 	tes := []tester{
 		{
-			returning:    srv.EXPECT().Create(ctx, service.CreateNote{Text: "test1"}).Return(nil),
-			code:         http.StatusOK,
-			errorMessage: "expected 200, got:",
+			returning:    srv.EXPECT().Create(ctx, service.CreateNote{Text: "test1"}).Return(&models.Note{ID: 1, Text: "test1"}, nil),
+			code:         http.StatusCreated,
+			errorMessage: "expected 201, got:",
 			router: router{
 				method:       "POST",
 				path:         "/note",
@@ -266,7 +269,7 @@ func TestCreate(t *testing.T) {
 			},
 		},
 		{
-			returning:    srv.EXPECT().Create(ctx, service.CreateNote{Text: "test1"}).Return(errors.New("some error")),
+			returning:    srv.EXPECT().Create(ctx, service.CreateNote{Text: "test1"}).Return(nil, errors.New("some error")),
 			code:         http.StatusInternalServerError,
 			errorMessage: "expected 500, got:",
 			router: router{
@@ -277,7 +280,7 @@ func TestCreate(t *testing.T) {
 			},
 		},
 		{
-			returning:    srv.EXPECT().Create(ctx, service.CreateNote{Text: "test1"}).Return(errors.New("some error")),
+			returning:    srv.EXPECT().Create(ctx, service.CreateNote{Text: "test1"}).Return(nil, errors.New("some error")),
 			code:         http.StatusInternalServerError,
 			errorMessage: "expected 500, got:",
 			router: router{
@@ -289,8 +292,8 @@ func TestCreate(t *testing.T) {
 			isBadWriter: true,
 		},
 		{
-			code:         http.StatusInternalServerError,
-			errorMessage: "expected 500, got:",
+			code:         http.StatusBadRequest,
+			errorMessage: "expected 400, got:",
 			router: router{
 				method:       "POST",
 				path:         "/note",
@@ -299,8 +302,8 @@ func TestCreate(t *testing.T) {
 			},
 		},
 		{
-			code:         http.StatusInternalServerError,
-			errorMessage: "expected 500, got:",
+			code:         http.StatusBadRequest,
+			errorMessage: "expected 400, got:",
 			router: router{
 				method:       "POST",
 				path:         "/note",
@@ -350,9 +353,9 @@ func TestCreate(t *testing.T) {
 			isBadWriter: true,
 		},
 		{
-			returning:    srv.EXPECT().Create(ctx, service.CreateNote{Text: "test1"}).Return(nil),
-			code:         http.StatusInternalServerError,
-			errorMessage: "expected 500, got:",
+			returning:    srv.EXPECT().Create(ctx, service.CreateNote{Text: "test1"}).Return(&models.Note{ID: 1, Text: "test1"}, nil),
+			code:         http.StatusCreated,
+			errorMessage: "status is sent before the body, expected 201, got:",
 			isBadWriter:  true,
 			router: router{
 				method:       "POST",
@@ -379,6 +382,11 @@ func TestCreate(t *testing.T) {
 			t.Errorf(test.errorMessage+"%d", w.Code)
 			return
 		}
+
+		if w.Code == http.StatusCreated && w.Header().Get("Location") != "/note/1" {
+			t.Errorf("expected Location /note/1, got: %q", w.Header().Get("Location"))
+			return
+		}
 	}
 }
 
@@ -396,7 +404,7 @@ func TestUpdate(t *testing.T) {
 
 	tes := []tester{
 		{
-			returning:    srv.EXPECT().Update(ctx, service.UpdateNote{ID: "1", Text: "test1"}).Return(nil),
+			returning:    srv.EXPECT().Update(ctx, service.UpdateNote{ID: "1", Text: "test1"}).Return(&models.Note{ID: 1, Text: "test1", Version: 2}, nil),
 			code:         http.StatusOK,
 			errorMessage: "expected 200, got:",
 			router: router{
@@ -408,7 +416,7 @@ func TestUpdate(t *testing.T) {
 			},
 		},
 		{
-			returning:    srv.EXPECT().Update(ctx, service.UpdateNote{ID: "1", Text: "test1"}).Return(errors.New("some error")),
+			returning:    srv.EXPECT().Update(ctx, service.UpdateNote{ID: "1", Text: "test1"}).Return(nil, errors.New("some error")),
 			code:         http.StatusInternalServerError,
 			errorMessage: "expected 500, got:",
 			router: router{
@@ -420,7 +428,7 @@ func TestUpdate(t *testing.T) {
 			},
 		},
 		{
-			returning:    srv.EXPECT().Update(ctx, service.UpdateNote{ID: "1", Text: "test1"}).Return(errors.New("some error")),
+			returning:    srv.EXPECT().Update(ctx, service.UpdateNote{ID: "1", Text: "test1"}).Return(nil, errors.New("some error")),
 			code:         http.StatusInternalServerError,
 			errorMessage: "expected 500, got:",
 			router: router{
@@ -473,8 +481,8 @@ func TestUpdate(t *testing.T) {
 			isBadWriter: true,
 		},
 		{
-			code:         http.StatusInternalServerError,
-			errorMessage: "expected 500, got:",
+			code:         http.StatusBadRequest,
+			errorMessage: "expected 400, got:",
 			router: router{
 				method:       "PUT",
 				path:         "/note/{id}",
@@ -484,8 +492,8 @@ func TestUpdate(t *testing.T) {
 			},
 		},
 		{
-			code:         http.StatusInternalServerError,
-			errorMessage: "expected 500, got:",
+			code:         http.StatusBadRequest,
+			errorMessage: "expected 400, got:",
 			router: router{
 				method:       "PUT",
 				path:         "/note/{id}",
@@ -519,7 +527,7 @@ func TestUpdate(t *testing.T) {
 			isBadWriter: true,
 		},
 		{
-			returning:    srv.EXPECT().Update(ctx, service.UpdateNote{ID: "1", Text: "test1"}).Return(nil),
+			returning:    srv.EXPECT().Update(ctx, service.UpdateNote{ID: "1", Text: "test1"}).Return(&models.Note{ID: 1, Text: "test1", Version: 2}, nil),
 			code:         http.StatusInternalServerError,
 			errorMessage: "expected 500, got:",
 			isBadWriter:  true,
@@ -562,7 +570,7 @@ func TestGetAll(t *testing.T) {
 
 	tes := []tester{
 		{
-			returning:    srv.EXPECT().GetAll(ctx, service.GetNotes{OrderBy: "id"}).Return([]*models.Note{}, nil),
+			returning:    srv.EXPECT().GetAll(ctx, service.GetNotes{OrderBy: "id"}).Return(&service.NotesPage{}, nil),
 			code:         http.StatusOK,
 			errorMessage: "expected 200, got:",
 			router: router{
@@ -590,7 +598,7 @@ func TestGetAll(t *testing.T) {
 			},
 		},
 		{
-			returning:    srv.EXPECT().GetAll(ctx, service.GetNotes{OrderBy: "id"}).Return([]*models.Note{}, nil),
+			returning:    srv.EXPECT().GetAll(ctx, service.GetNotes{OrderBy: "id"}).Return(&service.NotesPage{}, nil),
 			code:         http.StatusInternalServerError,
 			errorMessage: "expected 500, got:",
 			isBadWriter:  true,
@@ -619,3 +627,112 @@ func TestGetAll(t *testing.T) {
 		}
 	}
 }
+
+func TestDomainErrors(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	srv := mocks.NewMockService(ctrl)
+	handler := NewNoteHandler(srv, zap.L())
+	vars := map[string]string{"id": "1"}
+	ctx := gomock.Any()
+
+	statuses := []struct {
+		err  error
+		code int
+	}{
+		{fmt.Errorf("note 1 %w", service.ErrNotFound), http.StatusNotFound},
+		{&service.ValidationError{Fields: []service.FieldError{{Field: "text", Reason: "must not be empty"}}}, http.StatusBadRequest},
+		{fmt.Errorf("%w: duplicate", service.ErrConflict), http.StatusConflict},
+		{errors.New("some error"), http.StatusInternalServerError},
+	}
+
+	for _, st := range statuses {
+		calls := []struct {
+			name    string
+			expect  func()
+			method  string
+			body    string
+			vars    map[string]string
+			handler func(http.ResponseWriter, *http.Request)
+		}{
+			{
+				name:    "GetByID",
+				expect:  func() { srv.EXPECT().Get(ctx, service.GetNote{ID: "1"}).Return(nil, st.err) },
+				method:  "GET",
+				vars:    vars,
+				handler: handler.GetByID,
+			},
+			{
+				name:    "Create",
+				expect:  func() { srv.EXPECT().Create(ctx, service.CreateNote{Text: "test1"}).Return(nil, st.err) },
+				method:  "POST",
+				body:    `{"text": "test1"}`,
+				handler: handler.Create,
+			},
+			{
+				name:    "UpdateByID",
+				expect:  func() { srv.EXPECT().Update(ctx, service.UpdateNote{ID: "1", Text: "test1"}).Return(nil, st.err) },
+				method:  "PUT",
+				body:    `{"text": "test1"}`,
+				vars:    vars,
+				handler: handler.UpdateByID,
+			},
+			{
+				name:    "DeleteByID",
+				expect:  func() { srv.EXPECT().Delete(ctx, service.DeleteNote{ID: "1"}).Return(st.err) },
+				method:  "DELETE",
+				vars:    vars,
+				handler: handler.DeleteByID,
+			},
+			{
+				name:    "GetAll",
+				expect:  func() { srv.EXPECT().GetAll(ctx, service.GetNotes{}).Return(nil, st.err) },
+				method:  "GET",
+				handler: handler.GetAll,
+			},
+		}
+
+		for _, call := range calls {
+			call.expect()
+			w, req := getRequestRecorder(call.method, "/note", call.body, call.vars, nil)
+
+			if call.body != "" {
+				req.Header.Add("Content-type", "application/json")
+			}
+
+			call.handler(w, req)
+
+			if w.Code != st.code {
+				t.Errorf("%s with %q: expected %d, got: %d", call.name, st.err, st.code, w.Code)
+			}
+
+			if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+				t.Errorf("%s: expected problem+json, got: %q", call.name, ct)
+			}
+
+			problem := tools.Problem{}
+
+			if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+				t.Errorf("%s: can't decode problem: %s", call.name, err)
+				continue
+			}
+
+			if problem.Status != st.code || problem.Title != http.StatusText(st.code) || problem.Instance != "/note" {
+				t.Errorf("%s: unexpected problem: %+v", call.name, problem)
+			}
+
+			if st.code != http.StatusInternalServerError && problem.Detail != st.err.Error() {
+				t.Errorf("%s: expected detail %q, got: %q", call.name, st.err.Error(), problem.Detail)
+			}
+
+			if st.code == http.StatusInternalServerError && strings.Contains(problem.Detail, st.err.Error()) {
+				t.Errorf("%s: internal error leaked into detail: %q", call.name, problem.Detail)
+			}
+
+			if st.code == http.StatusBadRequest && (len(problem.InvalidParams) != 1 || problem.InvalidParams[0].Name != "text") {
+				t.Errorf("%s: expected invalid-params for text, got: %+v", call.name, problem.InvalidParams)
+			}
+		}
+	}
+}
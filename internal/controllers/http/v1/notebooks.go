@@ -0,0 +1,251 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"note/internal/service"
+	"note/internal/tools"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+func (h *handlers) registerNotebooks(router *mux.Router) {
+	router.HandleFunc("/notebook", h.Notebooks).Methods("GET")
+	router.HandleFunc("/notebook", h.CreateNotebook).Methods("POST")
+	router.HandleFunc("/notebook/{id}", h.GetNotebook).Methods("GET")
+	router.HandleFunc("/notebook/{id}", h.UpdateNotebook).Methods("PUT")
+	router.HandleFunc("/notebook/{id}", h.DeleteNotebook).Methods("DELETE")
+	router.HandleFunc("/notebook/{id}/contents", h.NotebookContents).Methods("GET")
+	router.HandleFunc("/note/{id}/notebook", h.MoveNote).Methods("PUT")
+}
+
+// readJSON decodes a JSON request body into v, answering with a problem and
+// returning false if it can't.
+func (h *handlers) readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if r.Header.Get("Content-type") != contentType {
+		h.Logger.Warn("not found application/json header")
+		h.problem(w, r, http.StatusBadRequest, "not found application/json header")
+
+		return false
+	}
+
+	data, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+
+	if err != nil {
+		h.readError(w, r, err)
+
+		return false
+	}
+
+	if err = json.Unmarshal(data, v); err != nil {
+		h.Logger.Warn(err.Error())
+		h.problem(w, r, http.StatusBadRequest, "can't read json")
+
+		return false
+	}
+
+	return true
+}
+
+// recursive reads the optional ?recursive=true flag.
+func (h *handlers) recursive(w http.ResponseWriter, r *http.Request) (bool, bool) {
+	value := r.URL.Query().Get("recursive")
+
+	if value == "" {
+		return false, true
+	}
+
+	recursive, err := strconv.ParseBool(value)
+
+	if err != nil {
+		problem := tools.NewProblem(http.StatusBadRequest, "recursive must be true or false")
+		problem.Type = problemInvalidInput
+		problem.InvalidParams = []tools.InvalidParam{{Name: "recursive", Reason: "must be true or false"}}
+		h.writeProblem(w, r, problem)
+
+		return false, false
+	}
+
+	return recursive, true
+}
+
+func (h *handlers) Notebooks(w http.ResponseWriter, r *http.Request) {
+	notebooks, err := h.noteService.Notebooks(r.Context())
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't get notebooks")
+
+		return
+	}
+
+	err = tools.WriteJSON(w, notebooks)
+
+	if err != nil {
+		h.Logger.Warn("server can't write")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (h *handlers) CreateNotebook(w http.ResponseWriter, r *http.Request) {
+	cn := service.CreateNotebook{}
+
+	if !h.readJSON(w, r, &cn) {
+		return
+	}
+
+	notebook, err := h.noteService.CreateNotebook(r.Context(), cn)
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't create a notebook")
+
+		return
+	}
+
+	headers := http.Header{}
+	headers.Set("Location", fmt.Sprintf("/notebook/%d", notebook.ID))
+
+	err = tools.WriteJSONStatus(w, http.StatusCreated, notebook, headers)
+
+	if err != nil {
+		h.Logger.Warn(err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (h *handlers) GetNotebook(w http.ResponseWriter, r *http.Request) {
+	notebook, err := h.noteService.GetNotebook(r.Context(), service.GetNotebook{ID: mux.Vars(r)["id"]})
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't get a notebook")
+
+		return
+	}
+
+	err = tools.WriteJSON(w, notebook)
+
+	if err != nil {
+		h.Logger.Warn("server can't write")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (h *handlers) UpdateNotebook(w http.ResponseWriter, r *http.Request) {
+	un := service.UpdateNotebook{}
+
+	if !h.readJSON(w, r, &un) {
+		return
+	}
+
+	un.ID = mux.Vars(r)["id"]
+	err := h.noteService.UpdateNotebook(r.Context(), un)
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't update a notebook")
+
+		return
+	}
+
+	err = tools.WriteJSON(w, struct {
+		Response string `json:"response"`
+	}{"successfully updated"})
+
+	if err != nil {
+		h.Logger.Warn("server can't write")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (h *handlers) DeleteNotebook(w http.ResponseWriter, r *http.Request) {
+	recursive, ok := h.recursive(w, r)
+
+	if !ok {
+		return
+	}
+
+	err := h.noteService.DeleteNotebook(r.Context(), service.DeleteNotebook{ID: mux.Vars(r)["id"], Recursive: recursive})
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't delete a notebook")
+
+		return
+	}
+
+	err = tools.WriteJSON(w, struct {
+		Response string `json:"response"`
+	}{"successfully deleted"})
+
+	if err != nil {
+		h.Logger.Warn("server can't write")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (h *handlers) NotebookContents(w http.ResponseWriter, r *http.Request) {
+	recursive, ok := h.recursive(w, r)
+
+	if !ok {
+		return
+	}
+
+	queries := r.URL.Query()
+	gc := service.GetNotebookContents{
+		ID:        mux.Vars(r)["id"],
+		Recursive: recursive,
+		Notes:     getNotes(queries),
+	}
+
+	contents, err := h.noteService.GetNotebookContents(r.Context(), gc)
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't get notebook contents")
+
+		return
+	}
+
+	headers := http.Header{}
+
+	if contents.NextCursor != "" {
+		next := *r.URL
+		queries.Set("cursor", contents.NextCursor)
+		next.RawQuery = queries.Encode()
+		headers.Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
+	}
+
+	err = tools.WriteJSON(w, contents, headers)
+
+	if err != nil {
+		h.Logger.Warn("server can't write")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// MoveNote takes {"notebookId": 3}, or null for the top level.
+func (h *handlers) MoveNote(w http.ResponseWriter, r *http.Request) {
+	mn := service.MoveNote{}
+
+	if !h.readJSON(w, r, &mn) {
+		return
+	}
+
+	mn.ID = mux.Vars(r)["id"]
+	err := h.noteService.MoveNote(r.Context(), mn)
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't move a note")
+
+		return
+	}
+
+	err = tools.WriteJSON(w, struct {
+		Response string `json:"response"`
+	}{"successfully moved"})
+
+	if err != nil {
+		h.Logger.Warn("server can't write")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
@@ -4,11 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"mime"
 	"net/http"
+	"net/url"
 	"note/internal/models"
 	"note/internal/service"
 	"note/internal/tools"
+	"time"
 
 	"github.com/gorilla/mux"
 	"go.uber.org/zap"
@@ -16,10 +20,40 @@ import (
 
 type Service interface {
 	Get(context.Context, service.GetNote) (*models.Note, error)
-	Create(context.Context, service.CreateNote) error
-	Update(context.Context, service.UpdateNote) error
+	Create(context.Context, service.CreateNote) (*models.Note, error)
+	Update(context.Context, service.UpdateNote) (*models.Note, error)
+	Patch(context.Context, service.PatchNote) (*models.Note, error)
+	Batch(context.Context, service.Batch) (*service.BatchResults, error)
 	Delete(context.Context, service.DeleteNote) error
-	GetAll(context.Context, service.GetNotes) ([]*models.Note, error)
+	GetAll(context.Context, service.GetNotes) (*service.NotesPage, error)
+	Search(context.Context, service.SearchNotes) (*service.SearchResults, error)
+	Tags(context.Context) (*service.TagList, error)
+	Notebooks(context.Context) (*service.NotebookList, error)
+	GetNotebook(context.Context, service.GetNotebook) (*models.Notebook, error)
+	CreateNotebook(context.Context, service.CreateNotebook) (*models.Notebook, error)
+	UpdateNotebook(context.Context, service.UpdateNotebook) error
+	DeleteNotebook(context.Context, service.DeleteNotebook) error
+	GetNotebookContents(context.Context, service.GetNotebookContents) (*service.NotebookContents, error)
+	MoveNote(context.Context, service.MoveNote) error
+	Trash(context.Context, service.GetNotes) (*service.NotesPage, error)
+	Restore(context.Context, service.RestoreNote) (*models.Note, error)
+	Purge(context.Context, service.PurgeNote) error
+	Revisions(context.Context, service.GetRevisions) (*service.RevisionList, error)
+	Revision(context.Context, service.GetRevision) (*models.Revision, error)
+	DiffRevisions(context.Context, service.DiffRevisions) (*service.Diff, error)
+	RestoreRevision(context.Context, service.RestoreRevision) (*models.Note, error)
+	ShareNote(context.Context, service.ShareNote) (*models.Share, error)
+	Shares(context.Context, service.GetShares) (*service.ShareList, error)
+	DeleteShare(context.Context, service.DeleteShare) error
+	Teams(context.Context) (*service.TeamList, error)
+	CreateTeam(context.Context, service.CreateTeam) (*models.Team, error)
+	GetTeam(context.Context, service.GetTeam) (*models.Team, error)
+	DeleteTeam(context.Context, service.DeleteTeam) error
+	AddMember(context.Context, service.AddMember) (*models.Team, error)
+	RemoveMember(context.Context, service.RemoveMember) error
+	CreateLink(context.Context, service.CreateLink) (*models.Link, error)
+	Links(context.Context, service.GetLinks) (*service.LinkList, error)
+	RevokeLink(context.Context, service.RevokeLink) error
 }
 
 type handlers struct {
@@ -31,16 +65,34 @@ func NewNoteHandler(service Service, logger *zap.Logger) handlers {
 	return handlers{noteService: service, Logger: logger}
 }
 
-const contentType = "application/json"
+const (
+	contentType = "application/json"
+	acceptPatch = service.MergePatch + ", " + service.JSONPatch
+)
+
+func (h *handlers) Register(router *mux.Router) {
+	// Registered before /note/{id} so "search" isn't taken for an id.
+	router.HandleFunc("/note/search", h.Search).Methods("GET")
+	router.HandleFunc("/note/{id}", h.GetByID).Methods("GET")
+	router.HandleFunc("/note", h.Create).Methods("POST")
+	router.HandleFunc("/note/batch", h.Batch).Methods("POST")
+	router.HandleFunc("/note/{id}", h.UpdateByID).Methods("PUT")
+	router.HandleFunc("/note/{id}", h.PatchByID).Methods("PATCH")
+	router.HandleFunc("/note/{id}", h.DeleteByID).Methods("DELETE")
+	router.HandleFunc("/note", h.GetAll).Methods("GET")
+	router.HandleFunc("/tags", h.Tags).Methods("GET")
+	h.registerNotebooks(router)
+	h.registerTrash(router)
+	h.registerRevisions(router)
+	h.registerShares(router)
+	h.registerTeams(router)
+	h.registerLinks(router)
+}
 
 func (h *handlers) Create(w http.ResponseWriter, r *http.Request) {
 	if r.Header.Get("Content-type") != contentType {
 		h.Logger.Warn("not found application/json header")
-		err := tools.ErrorJSON(w, errors.New("not found application/json header"), http.StatusBadRequest)
-
-		if err != nil {
-			h.Logger.Warn(err.Error())
-		}
+		h.problem(w, r, http.StatusBadRequest, "not found application/json header")
 
 		return
 	}
@@ -49,12 +101,7 @@ func (h *handlers) Create(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
 	if err != nil {
-		h.Logger.Warn(err.Error())
-		err = tools.ErrorJSON(w, errors.New("can't read from body"), http.StatusInternalServerError)
-
-		if err != nil {
-			h.Logger.Warn(err.Error())
-		}
+		h.readError(w, r, err)
 
 		return
 	}
@@ -64,31 +111,24 @@ func (h *handlers) Create(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		h.Logger.Warn(err.Error())
-		err = tools.ErrorJSON(w, errors.New("can't read json"), http.StatusInternalServerError)
-
-		if err != nil {
-			h.Logger.Warn(err.Error())
-		}
+		h.problem(w, r, http.StatusBadRequest, "can't read json")
 
 		return
 	}
 
-	err = h.noteService.Create(r.Context(), cr)
+	note, err := h.noteService.Create(r.Context(), cr)
 
 	if err != nil {
-		h.Logger.Warn(err.Error())
-		err = tools.ErrorJSON(w, errors.New("can't create a note"), http.StatusInternalServerError)
-
-		if err != nil {
-			h.Logger.Warn(err.Error())
-		}
+		h.serviceError(w, r, err, "can't create a note")
 
 		return
 	}
 
-	err = tools.WriteJSON(w, struct {
-		Response string `json:"response"`
-	}{"successfully created"})
+	headers := http.Header{}
+	headers.Set("Location", fmt.Sprintf("/note/%d", note.ID))
+	headers.Set("ETag", etag(note))
+
+	err = tools.WriteJSONStatus(w, http.StatusCreated, note, headers)
 
 	if err != nil {
 		h.Logger.Warn(err.Error())
@@ -99,11 +139,7 @@ func (h *handlers) Create(w http.ResponseWriter, r *http.Request) {
 func (h *handlers) UpdateByID(w http.ResponseWriter, r *http.Request) {
 	if r.Header.Get("Content-type") != contentType {
 		h.Logger.Warn("not found application/json header")
-		err := tools.ErrorJSON(w, errors.New("not found application/json header"), http.StatusBadRequest)
-
-		if err != nil {
-			h.Logger.Warn(err.Error())
-		}
+		h.problem(w, r, http.StatusBadRequest, "not found application/json header")
 
 		return
 	}
@@ -114,11 +150,7 @@ func (h *handlers) UpdateByID(w http.ResponseWriter, r *http.Request) {
 
 	if !in {
 		h.Logger.Warn("id not found")
-		err := tools.ErrorJSON(w, errors.New("id not found"), http.StatusBadRequest)
-
-		if err != nil {
-			h.Logger.Warn(err.Error())
-		}
+		h.problem(w, r, http.StatusBadRequest, "id not found")
 
 		return
 	}
@@ -127,12 +159,7 @@ func (h *handlers) UpdateByID(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
 	if err != nil {
-		h.Logger.Warn(err.Error())
-		err = tools.ErrorJSON(w, errors.New("can't read from body"), http.StatusInternalServerError)
-
-		if err != nil {
-			h.Logger.Warn(err.Error())
-		}
+		h.readError(w, r, err)
 
 		return
 	}
@@ -142,31 +169,75 @@ func (h *handlers) UpdateByID(w http.ResponseWriter, r *http.Request) {
 
 	if err != nil {
 		h.Logger.Warn(err.Error())
-		err = tools.ErrorJSON(w, errors.New("can't read the json"), http.StatusInternalServerError)
+		h.problem(w, r, http.StatusBadRequest, "can't read the json")
 
-		if err != nil {
-			h.Logger.Warn(err.Error())
-		}
+		return
+	}
 
+	if up.IfVersion, in = h.ifMatch(w, r); !in {
 		return
 	}
 
-	err = h.noteService.Update(r.Context(), up)
+	note, err := h.noteService.Update(r.Context(), up)
 
 	if err != nil {
-		h.Logger.Warn(err.Error())
-		err = tools.ErrorJSON(w, errors.New("can't update a note"), http.StatusInternalServerError)
+		h.serviceError(w, r, err, "can't update a note")
 
-		if err != nil {
-			h.Logger.Warn(err.Error())
-		}
+		return
+	}
+
+	headers := http.Header{}
+	headers.Set("ETag", etag(note))
+
+	err = tools.WriteJSON(w, note, headers)
+
+	if err != nil {
+		h.Logger.Warn("server can't write")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// PatchByID changes a note with a merge patch or a JSON patch, told apart
+// by Content-Type, and answers with the patched note.
+func (h *handlers) PatchByID(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	if err != nil || (mediaType != service.MergePatch && mediaType != service.JSONPatch) {
+		h.Logger.Warn("unsupported patch type " + r.Header.Get("Content-Type"))
+		w.Header().Set("Accept-Patch", acceptPatch)
+		h.problem(w, r, http.StatusUnsupportedMediaType, "Content-Type must be one of "+acceptPatch)
 
 		return
 	}
 
-	err = tools.WriteJSON(w, struct {
-		Response string `json:"response"`
-	}{"successfully updated"})
+	data, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+
+	if err != nil {
+		h.readError(w, r, err)
+
+		return
+	}
+
+	pn := service.PatchNote{ID: mux.Vars(r)["id"], Type: mediaType, Patch: data}
+	var ok bool
+
+	if pn.IfVersion, ok = h.ifMatch(w, r); !ok {
+		return
+	}
+
+	note, err := h.noteService.Patch(r.Context(), pn)
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't patch a note")
+
+		return
+	}
+
+	headers := http.Header{}
+	headers.Set("ETag", etag(note))
+
+	err = tools.WriteJSON(w, note, headers)
 
 	if err != nil {
 		h.Logger.Warn("server can't write")
@@ -181,25 +252,21 @@ func (h *handlers) DeleteByID(w http.ResponseWriter, r *http.Request) {
 
 	if !in {
 		h.Logger.Warn("id not found")
-		err := tools.ErrorJSON(w, errors.New("id not found"), http.StatusBadRequest)
-
-		if err != nil {
-			h.Logger.Warn(err.Error())
-		}
+		h.problem(w, r, http.StatusBadRequest, "id not found")
 
 		return
 	}
 
 	dn := service.DeleteNote{ID: id}
+
+	if dn.IfVersion, in = h.ifMatch(w, r); !in {
+		return
+	}
+
 	err := h.noteService.Delete(r.Context(), dn)
 
 	if err != nil {
-		h.Logger.Warn(err.Error())
-		err = tools.ErrorJSON(w, errors.New("can't delete a note"), http.StatusInternalServerError)
-
-		if err != nil {
-			h.Logger.Warn(err.Error())
-		}
+		h.serviceError(w, r, err, "can't delete a note")
 
 		return
 	}
@@ -221,11 +288,7 @@ func (h *handlers) GetByID(w http.ResponseWriter, r *http.Request) {
 
 	if !in {
 		h.Logger.Warn("id not found")
-		err := tools.ErrorJSON(w, errors.New("id not found"), http.StatusBadRequest)
-
-		if err != nil {
-			h.Logger.Warn(err.Error())
-		}
+		h.problem(w, r, http.StatusBadRequest, "id not found")
 
 		return
 	}
@@ -234,17 +297,18 @@ func (h *handlers) GetByID(w http.ResponseWriter, r *http.Request) {
 	note, err := h.noteService.Get(r.Context(), gn)
 
 	if err != nil {
-		h.Logger.Warn(err.Error())
-		err = tools.ErrorJSON(w, errors.New("can't get a note"), http.StatusInternalServerError)
+		h.serviceError(w, r, err, "can't get a note")
 
-		if err != nil {
-			h.Logger.Warn(err.Error())
-		}
+		return
+	}
 
+	headers := http.Header{}
+
+	if h.notModified(w, r, headers, etag(note), note.UpdatedAt) {
 		return
 	}
 
-	err = tools.WriteJSON(w, note)
+	err = tools.WriteJSON(w, note, headers)
 
 	if err != nil {
 		h.Logger.Warn("server can't write")
@@ -253,24 +317,187 @@ func (h *handlers) GetByID(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *handlers) GetAll(w http.ResponseWriter, r *http.Request) {
-	queries := r.URL.Query().Get("order_by")
-	notes, err := h.noteService.GetAll(r.Context(), service.GetNotes{OrderBy: queries})
+	queries := r.URL.Query()
+	page, err := h.noteService.GetAll(r.Context(), getNotes(queries))
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't get notes")
+
+		return
+	}
+
+	headers := http.Header{}
+
+	if page.NextCursor != "" {
+		next := *r.URL
+		queries.Set("cursor", page.NextCursor)
+		next.RawQuery = queries.Encode()
+		headers.Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
+	}
+
+	// A page changes without any of its notes getting newer, when a note
+	// leaves it, so it is validated by content and has no Last-Modified.
+	tag, err := weakETag(page)
 
 	if err != nil {
 		h.Logger.Warn(err.Error())
-		err = tools.ErrorJSON(w, errors.New("can't get notes"), http.StatusInternalServerError)
+		h.problem(w, r, http.StatusInternalServerError, "can't get notes")
 
-		if err != nil {
-			h.Logger.Warn(err.Error())
-		}
+		return
+	}
+
+	if h.notModified(w, r, headers, tag, time.Time{}) {
+		return
+	}
+
+	err = tools.WriteJSON(w, page, headers)
+
+	if err != nil {
+		h.Logger.Warn("server can't write")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// getNotes reads the paging, ordering and filter parameters shared by the
+// note listings.
+func getNotes(queries url.Values) service.GetNotes {
+	return service.GetNotes{
+		OrderBy:  queries.Get("order_by"),
+		Limit:    queries.Get("limit"),
+		Cursor:   queries.Get("cursor"),
+		Tags:     queries["tag"],
+		TagMode:  queries.Get("tag_mode"),
+		Pinned:   queries.Get("pinned"),
+		Archived: queries.Get("archived"),
+		Color:    queries.Get("color"),
+	}
+}
+
+func (h *handlers) Search(w http.ResponseWriter, r *http.Request) {
+	queries := r.URL.Query()
+	sn := service.SearchNotes{
+		Query: queries.Get("q"),
+		Limit: queries.Get("limit"),
+	}
+
+	results, err := h.noteService.Search(r.Context(), sn)
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't search notes")
 
 		return
 	}
 
-	err = tools.WriteJSON(w, notes)
+	err = tools.WriteJSON(w, results)
 
 	if err != nil {
 		h.Logger.Warn("server can't write")
 		w.WriteHeader(http.StatusInternalServerError)
 	}
 }
+
+func (h *handlers) Tags(w http.ResponseWriter, r *http.Request) {
+	tags, err := h.noteService.Tags(r.Context())
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't get tags")
+
+		return
+	}
+
+	err = tools.WriteJSON(w, tags)
+
+	if err != nil {
+		h.Logger.Warn("server can't write")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+const (
+	problemNotFound     = "/problems/not-found"
+	problemInvalidInput = "/problems/invalid-input"
+	problemConflict     = "/problems/conflict"
+	problemPrecondition = "/problems/precondition-failed"
+	problemBatchAborted = "/problems/batch-aborted"
+	problemUnauthorized = "/problems/unauthorized"
+	problemForbidden    = "/problems/forbidden"
+)
+
+func (h *handlers) problem(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	h.writeProblem(w, r, tools.NewProblem(status, detail))
+}
+
+func (h *handlers) writeProblem(w http.ResponseWriter, r *http.Request, problem *tools.Problem) {
+	err := tools.WriteProblem(w, r, problem)
+
+	if err != nil {
+		h.Logger.Warn(err.Error())
+	}
+}
+
+// readError answers a request whose body couldn't be read, with 413 when
+// it is over the limit of middleware.MaxBody.
+func (h *handlers) readError(w http.ResponseWriter, r *http.Request, err error) {
+	h.Logger.Warn(err.Error())
+
+	var tooLarge *http.MaxBytesError
+
+	if errors.As(err, &tooLarge) {
+		h.problem(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("the body must be at most %d bytes", tooLarge.Limit))
+
+		return
+	}
+
+	h.problem(w, r, http.StatusInternalServerError, "can't read from body")
+}
+
+func preconditionFailed(detail string) *tools.Problem {
+	problem := tools.NewProblem(http.StatusPreconditionFailed, detail)
+	problem.Type = problemPrecondition
+
+	return problem
+}
+
+// serviceError answers with the problem matching a domain error. Details of
+// domain errors are safe to show; anything else is reported as message.
+func (h *handlers) serviceError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	h.Logger.Warn(err.Error())
+	h.writeProblem(w, r, errorProblem(err, message))
+}
+
+func errorProblem(err error, message string) *tools.Problem {
+	problem := tools.NewProblem(http.StatusInternalServerError, message)
+
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		problem = tools.NewProblem(http.StatusNotFound, err.Error())
+		problem.Type = problemNotFound
+	case errors.Is(err, service.ErrInvalidInput):
+		problem = tools.NewProblem(http.StatusBadRequest, err.Error())
+		problem.Type = problemInvalidInput
+	case errors.Is(err, service.ErrConflict):
+		problem = tools.NewProblem(http.StatusConflict, err.Error())
+		problem.Type = problemConflict
+	case errors.Is(err, service.ErrPreconditionFailed):
+		problem = preconditionFailed(err.Error())
+	case errors.Is(err, service.ErrBatchAborted):
+		problem = tools.NewProblem(http.StatusFailedDependency, err.Error())
+		problem.Type = problemBatchAborted
+	case errors.Is(err, service.ErrUnauthorized):
+		problem = tools.NewProblem(http.StatusUnauthorized, err.Error())
+		problem.Type = problemUnauthorized
+	case errors.Is(err, service.ErrForbidden):
+		problem = tools.NewProblem(http.StatusForbidden, err.Error())
+		problem.Type = problemForbidden
+	}
+
+	validationErr := &service.ValidationError{}
+
+	if errors.As(err, &validationErr) {
+		for _, field := range validationErr.Fields {
+			problem.InvalidParams = append(problem.InvalidParams, tools.InvalidParam{Name: field.Field, Reason: field.Reason})
+		}
+	}
+
+	return problem
+}
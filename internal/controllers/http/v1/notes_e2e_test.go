@@ -0,0 +1,1069 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"note/internal/adapter/oidc"
+	"note/internal/adapter/oidc/oidctest"
+	"note/internal/adapter/repository"
+	"note/internal/controllers/http/middleware"
+	"note/internal/models"
+	"note/internal/service"
+	"note/internal/tools"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// newTestServer serves the notes of one user, who every request acts for
+// without logging in.
+func newTestServer(t *testing.T) *httptest.Server {
+	storage := repository.NewMemoryStorage()
+	user, err := storage.CreateUser(context.Background(), "ann@example.com", "hash")
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	handler := NewNoteHandler(service.NewService(storage), zap.NewNop())
+	router := mux.NewRouter()
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(service.WithUser(r.Context(), user)))
+		})
+	})
+	handler.Register(router)
+
+	srv := httptest.NewServer(middleware.RequestID(router))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func doRequest(t *testing.T, method, url, body string) (int, []byte) {
+	code, _, data := doRequestHeader(t, method, url, body, http.Header{})
+
+	return code, data
+}
+
+// doRequestHeader sends a request with extra headers and also returns the
+// response headers.
+func doRequestHeader(t *testing.T, method, url, body string, header http.Header) (int, http.Header, []byte) {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+
+	if err != nil {
+		t.Fatalf("can't create request: %s", err)
+	}
+
+	req.Header = header
+
+	if body != "" && header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+
+	if err != nil {
+		t.Fatalf("can't do request: %s", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+
+	if err != nil {
+		t.Fatalf("can't read body: %s", err)
+	}
+
+	return resp.StatusCode, resp.Header, data
+}
+
+func TestNotesEndToEnd(t *testing.T) {
+	srv := newTestServer(t)
+
+	for i, text := range []string{"b", "a", "c"} {
+		code, data := doRequest(t, "POST", srv.URL+"/note", `{"text": "`+text+`"}`)
+
+		if code != http.StatusCreated {
+			t.Fatalf("expected 201, got: %d", code)
+		}
+
+		created := models.Note{}
+
+		if err := json.Unmarshal(data, &created); err != nil || created.ID != int64(i+1) || created.Text != text {
+			t.Fatalf("unexpected created note: %s", data)
+		}
+	}
+
+	code, data := doRequest(t, "GET", srv.URL+"/note?order_by=text", "")
+
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got: %d", code)
+	}
+
+	page := service.NotesPage{}
+
+	if err := json.Unmarshal(data, &page); err != nil {
+		t.Fatalf("can't decode notes: %s", err)
+	}
+
+	if len(page.Notes) != 3 || page.Notes[0].Text != "a" || page.Notes[0].ID != 2 || page.NextCursor != "" {
+		t.Fatalf("unexpected notes: %s", data)
+	}
+
+	resp, err := http.Get(srv.URL + "/note?order_by=text&limit=2")
+
+	if err != nil {
+		t.Fatalf("can't do request: %s", err)
+	}
+
+	page = service.NotesPage{}
+	err = json.NewDecoder(resp.Body).Decode(&page)
+	resp.Body.Close()
+
+	if err != nil || len(page.Notes) != 2 || page.NextCursor == "" {
+		t.Fatalf("unexpected first page: %+v, %v", page, err)
+	}
+
+	next := "/note?cursor=" + page.NextCursor + "&limit=2&order_by=text"
+
+	if link := resp.Header.Get("Link"); link != "<"+next+">; rel=\"next\"" {
+		t.Errorf("unexpected Link header: %q", link)
+	}
+
+	code, data = doRequest(t, "GET", srv.URL+next, "")
+	page = service.NotesPage{}
+
+	if err = json.Unmarshal(data, &page); err != nil || code != http.StatusOK {
+		t.Fatalf("unexpected response %d: %s", code, data)
+	}
+
+	if len(page.Notes) != 1 || page.Notes[0].Text != "c" || page.NextCursor != "" {
+		t.Errorf("unexpected last page: %s", data)
+	}
+
+	code, data = doRequest(t, "GET", srv.URL+"/note?order_by=-text,id", "")
+	page = service.NotesPage{}
+
+	if err = json.Unmarshal(data, &page); err != nil || code != http.StatusOK {
+		t.Fatalf("unexpected response %d: %s", code, data)
+	}
+
+	if len(page.Notes) != 3 || page.Notes[0].Text != "c" || page.Notes[2].Text != "a" {
+		t.Errorf("unexpected descending order: %s", data)
+	}
+
+	code, data = doRequest(t, "GET", srv.URL+"/note?order_by=text%3BDROP%20TABLE%20note", "")
+	problem := tools.Problem{}
+
+	if err = json.Unmarshal(data, &problem); err != nil || code != http.StatusBadRequest {
+		t.Fatalf("unexpected response %d: %s", code, data)
+	}
+
+	if len(problem.InvalidParams) != 1 || problem.InvalidParams[0].Name != "order_by" {
+		t.Errorf("unexpected problem: %s", data)
+	}
+
+	code, data = doRequest(t, "GET", srv.URL+"/note/search?q=c", "")
+	found := service.SearchResults{}
+
+	if err = json.Unmarshal(data, &found); err != nil || code != http.StatusOK {
+		t.Fatalf("unexpected response %d: %s", code, data)
+	}
+
+	if len(found.Results) != 1 || found.Results[0].Note.ID != 3 || found.Results[0].Snippet != "<mark>c</mark>" {
+		t.Errorf("unexpected search results: %s", data)
+	}
+
+	code, _ = doRequest(t, "GET", srv.URL+"/note/search", "")
+
+	if code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an empty query, got: %d", code)
+	}
+
+	code, _ = doRequest(t, "PUT", srv.URL+"/note/1", `{"text": "b", "tags": ["work", "Home"]}`)
+
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got: %d", code)
+	}
+
+	code, _ = doRequest(t, "POST", srv.URL+"/note", `{"text": "d", "tags": ["work"]}`)
+
+	if code != http.StatusCreated {
+		t.Fatalf("expected 201, got: %d", code)
+	}
+
+	code, data = doRequest(t, "GET", srv.URL+"/note?tag=work&tag=home", "")
+	page = service.NotesPage{}
+
+	if err = json.Unmarshal(data, &page); err != nil || code != http.StatusOK {
+		t.Fatalf("unexpected response %d: %s", code, data)
+	}
+
+	if len(page.Notes) != 1 || page.Notes[0].ID != 1 || len(page.Notes[0].Tags) != 2 {
+		t.Errorf("unexpected notes tagged work and home: %s", data)
+	}
+
+	code, _ = doRequest(t, "PUT", srv.URL+"/note/3", `{"text": "c", "title": "Third", "pinned": true, "color": "red"}`)
+
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got: %d", code)
+	}
+
+	code, data = doRequest(t, "GET", srv.URL+"/note?pinned=true&archived=false&color=red", "")
+	page = service.NotesPage{}
+
+	if err = json.Unmarshal(data, &page); err != nil || code != http.StatusOK {
+		t.Fatalf("unexpected response %d: %s", code, data)
+	}
+
+	if len(page.Notes) != 1 || page.Notes[0].ID != 3 || page.Notes[0].Title != "Third" || len(page.Notes[0].Tags) != 0 {
+		t.Errorf("unexpected pinned notes: %s", data)
+	}
+
+	code, data = doRequest(t, "GET", srv.URL+"/note?pinned=maybe", "")
+
+	if code != http.StatusBadRequest || !strings.Contains(string(data), `"pinned"`) {
+		t.Errorf("expected 400 for pinned, got %d: %s", code, data)
+	}
+
+	code, data = doRequest(t, "GET", srv.URL+"/tags", "")
+	tags := service.TagList{}
+
+	if err = json.Unmarshal(data, &tags); err != nil || code != http.StatusOK {
+		t.Fatalf("unexpected response %d: %s", code, data)
+	}
+
+	if len(tags.Tags) != 2 || tags.Tags[0].Name != "work" || tags.Tags[0].Count != 2 {
+		t.Errorf("unexpected tags: %s", data)
+	}
+
+	code, _ = doRequest(t, "PUT", srv.URL+"/note/2", `{"text": "updated"}`)
+
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got: %d", code)
+	}
+
+	code, data = doRequest(t, "GET", srv.URL+"/note/2", "")
+	note := models.Note{}
+
+	if err := json.Unmarshal(data, &note); err != nil || code != http.StatusOK {
+		t.Fatalf("unexpected response %d: %s", code, data)
+	}
+
+	if note.Text != "updated" || note.UpdatedAt.Before(note.CreatedAt) {
+		t.Errorf("unexpected note: %+v", note)
+	}
+
+	code, _ = doRequest(t, "DELETE", srv.URL+"/note/2", "")
+
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got: %d", code)
+	}
+
+	code, _ = doRequest(t, "GET", srv.URL+"/note/2", "")
+
+	if code != http.StatusNotFound {
+		t.Errorf("expected 404 for deleted note, got: %d", code)
+	}
+
+	code, _ = doRequest(t, "DELETE", srv.URL+"/note/2", "")
+
+	if code != http.StatusNotFound {
+		t.Errorf("expected 404 deleting twice, got: %d", code)
+	}
+
+	code, _ = doRequest(t, "PUT", srv.URL+"/note/abc", `{"text": "x"}`)
+
+	if code != http.StatusBadRequest {
+		t.Errorf("expected 400 for malformed id, got: %d", code)
+	}
+
+	code, data = doRequest(t, "POST", srv.URL+"/note", `{"text": "  "}`)
+	problem = tools.Problem{}
+
+	if err := json.Unmarshal(data, &problem); err != nil || code != http.StatusBadRequest {
+		t.Fatalf("unexpected response %d: %s", code, data)
+	}
+
+	if problem.Type != "/problems/invalid-input" || problem.RequestID == "" || len(problem.InvalidParams) != 1 {
+		t.Errorf("unexpected problem: %s", data)
+	}
+}
+
+func TestNotebooksEndToEnd(t *testing.T) {
+	srv := newTestServer(t)
+
+	for _, body := range []string{`{"name": "work"}`, `{"name": "projects", "parentId": 1}`} {
+		if code, data := doRequest(t, "POST", srv.URL+"/notebook", body); code != http.StatusCreated {
+			t.Fatalf("expected 201, got %d: %s", code, data)
+		}
+	}
+
+	code, data := doRequest(t, "POST", srv.URL+"/note", `{"text": "plan", "notebookId": 2}`)
+	note := models.Note{}
+
+	if err := json.Unmarshal(data, &note); err != nil || code != http.StatusCreated || note.NotebookID == nil || *note.NotebookID != 2 {
+		t.Fatalf("unexpected response %d: %s", code, data)
+	}
+
+	doRequest(t, "POST", srv.URL+"/note", `{"text": "loose"}`)
+
+	if code, data = doRequest(t, "PUT", srv.URL+"/note/2/notebook", `{"notebookId": 1}`); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", code, data)
+	}
+
+	code, data = doRequest(t, "GET", srv.URL+"/notebook/1/contents?recursive=true&limit=1", "")
+	contents := service.NotebookContents{}
+
+	if err := json.Unmarshal(data, &contents); err != nil || code != http.StatusOK {
+		t.Fatalf("unexpected response %d: %s", code, data)
+	}
+
+	if len(contents.Notebooks) != 1 || len(contents.Notes) != 1 || contents.NextCursor == "" {
+		t.Errorf("unexpected contents: %s", data)
+	}
+
+	if code, data = doRequest(t, "PUT", srv.URL+"/notebook/1", `{"name": "work", "parentId": 2}`); code != http.StatusConflict {
+		t.Errorf("expected 409 for a cycle, got %d: %s", code, data)
+	}
+
+	if code, data = doRequest(t, "DELETE", srv.URL+"/notebook/1", ""); code != http.StatusConflict {
+		t.Errorf("expected 409 for a non-empty notebook, got %d: %s", code, data)
+	}
+
+	if code, data = doRequest(t, "DELETE", srv.URL+"/notebook/1?recursive=maybe", ""); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a malformed flag, got %d: %s", code, data)
+	}
+
+	if code, data = doRequest(t, "DELETE", srv.URL+"/notebook/1?recursive=true", ""); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", code, data)
+	}
+
+	code, data = doRequest(t, "GET", srv.URL+"/notebook", "")
+	list := service.NotebookList{}
+
+	if err := json.Unmarshal(data, &list); err != nil || code != http.StatusOK || len(list.Notebooks) != 0 {
+		t.Errorf("expected no notebooks, got %d: %s", code, data)
+	}
+
+	if code, _ = doRequest(t, "GET", srv.URL+"/note/1", ""); code != http.StatusNotFound {
+		t.Errorf("expected the note to be deleted with its notebook, got %d", code)
+	}
+}
+
+func TestTrashEndToEnd(t *testing.T) {
+	srv := newTestServer(t)
+
+	doRequest(t, "POST", srv.URL+"/note", `{"text": "draft"}`)
+	doRequest(t, "POST", srv.URL+"/note", `{"text": "final"}`)
+
+	if code, data := doRequest(t, "DELETE", srv.URL+"/note/1", ""); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", code, data)
+	}
+
+	if code, data := doRequest(t, "GET", srv.URL+"/note/1", ""); code != http.StatusNotFound {
+		t.Errorf("expected 404 for a trashed note, got %d: %s", code, data)
+	}
+
+	code, data := doRequest(t, "GET", srv.URL+"/trash", "")
+	page := service.NotesPage{}
+
+	if err := json.Unmarshal(data, &page); err != nil || code != http.StatusOK {
+		t.Fatalf("unexpected response %d: %s", code, data)
+	}
+
+	if len(page.Notes) != 1 || page.Notes[0].ID != 1 || page.Notes[0].DeletedAt == nil {
+		t.Errorf("unexpected trash: %s", data)
+	}
+
+	if code, data = doRequest(t, "POST", srv.URL+"/note/1/restore", ""); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", code, data)
+	}
+
+	if code, data = doRequest(t, "POST", srv.URL+"/note/1/restore", ""); code != http.StatusNotFound {
+		t.Errorf("expected 404 restoring a note that isn't trashed, got %d: %s", code, data)
+	}
+
+	if code, data = doRequest(t, "DELETE", srv.URL+"/trash/1", ""); code != http.StatusNotFound {
+		t.Errorf("expected 404 purging a note that isn't trashed, got %d: %s", code, data)
+	}
+
+	doRequest(t, "DELETE", srv.URL+"/note/1", "")
+
+	if code, data = doRequest(t, "DELETE", srv.URL+"/trash/1", ""); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", code, data)
+	}
+
+	code, data = doRequest(t, "GET", srv.URL+"/trash", "")
+	page = service.NotesPage{}
+
+	if err := json.Unmarshal(data, &page); err != nil || code != http.StatusOK || len(page.Notes) != 0 {
+		t.Errorf("expected empty trash, got %d: %s", code, data)
+	}
+}
+
+func TestRevisionsEndToEnd(t *testing.T) {
+	srv := newTestServer(t)
+
+	doRequest(t, "POST", srv.URL+"/note", `{"text": "milk\neggs"}`)
+
+	if code, data := doRequest(t, "PUT", srv.URL+"/note/1", `{"text": "milk\nbread"}`); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", code, data)
+	}
+
+	code, data := doRequest(t, "GET", srv.URL+"/note/1/revisions", "")
+	list := service.RevisionList{}
+
+	if err := json.Unmarshal(data, &list); err != nil || code != http.StatusOK || len(list.Revisions) != 2 {
+		t.Fatalf("unexpected response %d: %s", code, data)
+	}
+
+	code, data = doRequest(t, "GET", srv.URL+"/note/1/revisions/1/diff?to=2", "")
+	diff := service.Diff{}
+
+	if err := json.Unmarshal(data, &diff); err != nil || code != http.StatusOK || len(diff.Lines) != 3 || diff.Lines[1].Text != "eggs" {
+		t.Errorf("unexpected diff %d: %s", code, data)
+	}
+
+	if code, data = doRequest(t, "GET", srv.URL+"/note/1/revisions/5", ""); code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d: %s", code, data)
+	}
+
+	if code, data = doRequest(t, "GET", srv.URL+"/note/1/revisions/first", ""); code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d: %s", code, data)
+	}
+
+	code, data = doRequest(t, "POST", srv.URL+"/note/1/revisions/1/restore", "")
+	note := models.Note{}
+
+	if err := json.Unmarshal(data, &note); err != nil || code != http.StatusOK || note.Text != "milk\neggs" {
+		t.Errorf("unexpected response %d: %s", code, data)
+	}
+
+	if code, data = doRequest(t, "GET", srv.URL+"/note/1/revisions/3", ""); code != http.StatusOK {
+		t.Errorf("expected the restore to add revision 3, got %d: %s", code, data)
+	}
+}
+
+func TestVersionsEndToEnd(t *testing.T) {
+	srv := newTestServer(t)
+	ifMatch := func(tag string) http.Header {
+		return http.Header{"If-Match": []string{tag}}
+	}
+
+	if code, header, data := doRequestHeader(t, "POST", srv.URL+"/note", `{"text": "first"}`, http.Header{}); code != http.StatusCreated || header.Get("ETag") != `"1"` {
+		t.Fatalf("expected 201 with ETag \"1\", got %d %q: %s", code, header.Get("ETag"), data)
+	}
+
+	if code, header, data := doRequestHeader(t, "GET", srv.URL+"/note/1", "", http.Header{}); code != http.StatusOK || header.Get("ETag") != `"1"` {
+		t.Fatalf("expected 200 with ETag \"1\", got %d %q: %s", code, header.Get("ETag"), data)
+	}
+
+	// The answer carries the new version, ready for the next If-Match.
+	code, header, data := doRequestHeader(t, "PUT", srv.URL+"/note/1", `{"text": "second"}`, ifMatch(`"1"`))
+	note := models.Note{}
+
+	if err := json.Unmarshal(data, &note); err != nil || code != http.StatusOK || header.Get("ETag") != `"2"` || note.Version != 2 || note.Text != "second" {
+		t.Fatalf("expected 200 with the note at version 2, got %d %q: %s", code, header.Get("ETag"), data)
+	}
+
+	// The other writer still holds version 1.
+	code, _, data = doRequestHeader(t, "PUT", srv.URL+"/note/1", `{"text": "third"}`, ifMatch(`"1"`))
+	problem := tools.Problem{}
+
+	if err := json.Unmarshal(data, &problem); err != nil || code != http.StatusPreconditionFailed || problem.Type != problemPrecondition {
+		t.Errorf("expected 412, got %d: %s", code, data)
+	}
+
+	// If-Match is optional: without it the last write wins.
+	if code, header, data = doRequestHeader(t, "PUT", srv.URL+"/note/1", `{"text": "third"}`, http.Header{}); code != http.StatusOK || header.Get("ETag") != `"3"` {
+		t.Fatalf("expected 200 with ETag \"3\", got %d %q: %s", code, header.Get("ETag"), data)
+	}
+
+	for _, tag := range []string{`W/"3"`, "3", `"two"`} {
+		if code, _, data = doRequestHeader(t, "DELETE", srv.URL+"/note/1", "", ifMatch(tag)); code != http.StatusPreconditionFailed {
+			t.Errorf("expected 412 for If-Match %s, got %d: %s", tag, code, data)
+		}
+	}
+
+	if code, _, data = doRequestHeader(t, "DELETE", srv.URL+"/note/1", "", ifMatch(`"3"`)); code != http.StatusOK {
+		t.Errorf("expected 200, got %d: %s", code, data)
+	}
+
+	if code, _, data = doRequestHeader(t, "DELETE", srv.URL+"/note/1", "", ifMatch(`"4"`)); code != http.StatusNotFound {
+		t.Errorf("expected 404 for a deleted note, got %d: %s", code, data)
+	}
+}
+
+func TestConditionalGetEndToEnd(t *testing.T) {
+	srv := newTestServer(t)
+	ifNoneMatch := func(tag string) http.Header {
+		return http.Header{"If-None-Match": []string{tag}}
+	}
+
+	doRequest(t, "POST", srv.URL+"/note", `{"text": "first"}`)
+
+	code, header, data := doRequestHeader(t, "GET", srv.URL+"/note/1", "", http.Header{})
+	modified := header.Get("Last-Modified")
+
+	if code != http.StatusOK || header.Get("Cache-Control") != cacheControl || modified == "" {
+		t.Fatalf("expected cache headers, got %d %v: %s", code, header, data)
+	}
+
+	if code, header, data = doRequestHeader(t, "GET", srv.URL+"/note/1", "", ifNoneMatch(`W/"1"`)); code != http.StatusNotModified || len(data) != 0 || header.Get("ETag") != `"1"` {
+		t.Errorf("expected 304 with the ETag, got %d %v: %s", code, header, data)
+	}
+
+	if code, _, _ = doRequestHeader(t, "GET", srv.URL+"/note/1", "", http.Header{"If-Modified-Since": []string{modified}}); code != http.StatusNotModified {
+		t.Errorf("expected 304 for If-Modified-Since, got %d", code)
+	}
+
+	// If-None-Match wins over If-Modified-Since.
+	header = http.Header{"If-None-Match": []string{`"2"`}, "If-Modified-Since": []string{modified}}
+
+	if code, _, _ = doRequestHeader(t, "GET", srv.URL+"/note/1", "", header); code != http.StatusOK {
+		t.Errorf("expected 200 for another ETag, got %d", code)
+	}
+
+	code, header, _ = doRequestHeader(t, "GET", srv.URL+"/note", "", http.Header{})
+	list := header.Get("ETag")
+
+	if code != http.StatusOK || !strings.HasPrefix(list, `W/"`) || header.Get("Last-Modified") != "" {
+		t.Fatalf("expected a weak ETag for the list, got %d %v", code, header)
+	}
+
+	if code, _, data = doRequestHeader(t, "GET", srv.URL+"/note", "", ifNoneMatch(`"x", `+list)); code != http.StatusNotModified || len(data) != 0 {
+		t.Errorf("expected 304 for the list, got %d: %s", code, data)
+	}
+
+	doRequest(t, "PUT", srv.URL+"/note/1", `{"text": "second"}`)
+
+	if code, _, _ = doRequestHeader(t, "GET", srv.URL+"/note/1", "", ifNoneMatch(`"1"`)); code != http.StatusOK {
+		t.Errorf("expected 200 after an update, got %d", code)
+	}
+
+	if code, _, _ = doRequestHeader(t, "GET", srv.URL+"/note", "", ifNoneMatch(list)); code != http.StatusOK {
+		t.Errorf("expected 200 for the list after an update, got %d", code)
+	}
+}
+
+func TestPatchEndToEnd(t *testing.T) {
+	srv := newTestServer(t)
+	patch := func(kind, body, ifMatch string) (int, http.Header, []byte) {
+		header := http.Header{"Content-Type": []string{kind}}
+
+		if ifMatch != "" {
+			header.Set("If-Match", ifMatch)
+		}
+
+		return doRequestHeader(t, "PATCH", srv.URL+"/note/1", body, header)
+	}
+
+	doRequest(t, "POST", srv.URL+"/note", `{"text": "milk", "tags": ["home"]}`)
+
+	code, header, data := patch(service.MergePatch+"; charset=utf-8", `{"title": "Shopping"}`, "")
+	note := models.Note{}
+
+	if err := json.Unmarshal(data, &note); err != nil || code != http.StatusOK || note.Title != "Shopping" || header.Get("ETag") != `"2"` {
+		t.Fatalf("unexpected response %d %v: %s", code, header, data)
+	}
+
+	code, _, data = patch(service.JSONPatch, `[{"op": "add", "path": "/tags/-", "value": "shop"}]`, `"2"`)
+
+	if err := json.Unmarshal(data, &note); err != nil || code != http.StatusOK || len(note.Tags) != 2 {
+		t.Errorf("unexpected response %d: %s", code, data)
+	}
+
+	if code, _, data = patch(service.JSONPatch, `[{"op": "replace", "path": "/text", "value": "x"}]`, `"2"`); code != http.StatusPreconditionFailed {
+		t.Errorf("expected 412, got %d: %s", code, data)
+	}
+
+	if code, _, data = patch(service.JSONPatch, `[{"op": "test", "path": "/text", "value": "bread"}]`, ""); code != http.StatusConflict {
+		t.Errorf("expected 409, got %d: %s", code, data)
+	}
+
+	if code, _, data = patch(service.MergePatch, `{"id": 2}`, ""); code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d: %s", code, data)
+	}
+
+	if code, header, data = patch(contentType, `{"text": "x"}`, ""); code != http.StatusUnsupportedMediaType || header.Get("Accept-Patch") != acceptPatch {
+		t.Errorf("expected 415 with Accept-Patch, got %d %v: %s", code, header, data)
+	}
+}
+
+func TestBatchEndToEnd(t *testing.T) {
+	srv := newTestServer(t)
+	response := struct {
+		Committed bool `json:"committed"`
+		Results   []struct {
+			Status int           `json:"status"`
+			Note   *models.Note  `json:"note"`
+			Error  tools.Problem `json:"error"`
+		} `json:"results"`
+	}{}
+
+	code, data := doRequest(t, "POST", srv.URL+"/note/batch", `{"operations": [
+		{"op": "create", "note": {"text": "first"}},
+		{"op": "create", "note": {"text": "second"}},
+		{"op": "update", "id": 1, "note": {"text": "third"}}
+	]}`)
+
+	if err := json.Unmarshal(data, &response); err != nil || code != http.StatusOK || !response.Committed || len(response.Results) != 3 ||
+		response.Results[0].Status != http.StatusCreated || response.Results[0].Note.ID != 1 || response.Results[2].Status != http.StatusOK {
+		t.Fatalf("unexpected response %d: %s", code, data)
+	}
+
+	code, data = doRequest(t, "POST", srv.URL+"/note/batch", `{"operations": [
+		{"op": "delete", "id": "1"},
+		{"op": "update", "id": 5, "note": {"text": "x"}}
+	]}`)
+
+	if err := json.Unmarshal(data, &response); err != nil || code != http.StatusMultiStatus || response.Committed ||
+		response.Results[0].Status != http.StatusFailedDependency || response.Results[1].Status != http.StatusNotFound {
+		t.Fatalf("unexpected response %d: %s", code, data)
+	}
+
+	code, data = doRequest(t, "POST", srv.URL+"/note/batch", `{"mode": "partial", "operations": [
+		{"op": "delete", "id": "1", "ifVersion": 1},
+		{"op": "delete", "id": 2}
+	]}`)
+
+	if err := json.Unmarshal(data, &response); err != nil || code != http.StatusMultiStatus || !response.Committed ||
+		response.Results[0].Status != http.StatusPreconditionFailed || response.Results[1].Status != http.StatusOK {
+		t.Fatalf("unexpected response %d: %s", code, data)
+	}
+
+	if code, data = doRequest(t, "POST", srv.URL+"/note/batch", `{"operations": []}`); code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d: %s", code, data)
+	}
+}
+
+// newAuthTestServer wires the routes like main: the auth and public link
+// routes are open and the notes need a session.
+func newAuthTestServer(t *testing.T) *httptest.Server {
+	storage := repository.NewMemoryStorage()
+	authService := service.NewAuthService(storage, time.Hour)
+	authHandler := NewAuthHandler(authService, zap.NewNop())
+	noteService := service.NewService(storage)
+	handler := NewNoteHandler(noteService, zap.NewNop())
+	publicHandler := NewPublicHandler(noteService, zap.NewNop())
+	router := mux.NewRouter()
+	authHandler.Register(router)
+	publicHandler.Register(router)
+
+	notes := router.NewRoute().Subrouter()
+	notes.Use(func(next http.Handler) http.Handler { return middleware.Auth(next, authService, zap.NewNop()) })
+	handler.Register(notes)
+	authHandler.RegisterTokens(notes)
+
+	srv := httptest.NewServer(middleware.RequestID(router))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func login(t *testing.T, srv *httptest.Server, email string) http.Header {
+	credentials := `{"email": "` + email + `", "password": "long enough"}`
+
+	if code, data := doRequest(t, "POST", srv.URL+"/auth/register", credentials); code != http.StatusCreated || strings.Contains(string(data), "long enough") {
+		t.Fatalf("unexpected register response %d: %s", code, data)
+	}
+
+	code, header, data := doRequestHeader(t, "POST", srv.URL+"/auth/login", credentials, http.Header{})
+	session := service.Session{}
+
+	if err := json.Unmarshal(data, &session); err != nil || code != http.StatusOK || session.Token == "" || header.Get("Cache-Control") != "no-store" {
+		t.Fatalf("unexpected login response %d: %s", code, data)
+	}
+
+	return http.Header{"Authorization": {"Bearer " + session.Token}}
+}
+
+func TestAuthEndToEnd(t *testing.T) {
+	srv := newAuthTestServer(t)
+
+	code, header, _ := doRequestHeader(t, "GET", srv.URL+"/note", "", http.Header{})
+
+	if code != http.StatusUnauthorized || header.Get("WWW-Authenticate") == "" {
+		t.Fatalf("expected 401 with a challenge, got %d %v", code, header)
+	}
+
+	ann, bob := login(t, srv, "ann@example.com"), login(t, srv, "bob@example.com")
+
+	if code, data := doRequest(t, "POST", srv.URL+"/auth/register", `{"email": "ann@example.com", "password": "long enough"}`); code != http.StatusConflict {
+		t.Errorf("expected 409 for a taken email, got %d: %s", code, data)
+	}
+
+	if code, data := doRequest(t, "POST", srv.URL+"/auth/login", `{"email": "ann@example.com", "password": "wrong password"}`); code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for a wrong password, got %d: %s", code, data)
+	}
+
+	if code, _, data := doRequestHeader(t, "POST", srv.URL+"/note", `{"text": "ann"}`, ann.Clone()); code != http.StatusCreated {
+		t.Fatalf("unexpected response %d: %s", code, data)
+	}
+
+	if code, _, data := doRequestHeader(t, "GET", srv.URL+"/note/1", "", bob.Clone()); code != http.StatusNotFound {
+		t.Errorf("expected 404 for a note of another user, got %d: %s", code, data)
+	}
+
+	page := service.NotesPage{}
+	_, _, data := doRequestHeader(t, "GET", srv.URL+"/note", "", bob.Clone())
+
+	if err := json.Unmarshal(data, &page); err != nil || len(page.Notes) != 0 {
+		t.Errorf("expected no notes for bob, got %s", data)
+	}
+
+	_, _, data = doRequestHeader(t, "GET", srv.URL+"/note", "", ann.Clone())
+
+	if err := json.Unmarshal(data, &page); err != nil || len(page.Notes) != 1 {
+		t.Errorf("expected the note of ann, got %s", data)
+	}
+
+	if code, _, data = doRequestHeader(t, "POST", srv.URL+"/auth/logout", "", ann.Clone()); code != http.StatusNoContent {
+		t.Fatalf("unexpected logout response %d: %s", code, data)
+	}
+
+	if code, _, data = doRequestHeader(t, "GET", srv.URL+"/note", "", ann.Clone()); code != http.StatusUnauthorized {
+		t.Errorf("expected 401 after logout, got %d: %s", code, data)
+	}
+}
+
+func TestSharesEndToEnd(t *testing.T) {
+	srv := newAuthTestServer(t)
+	ann, bob, cara := login(t, srv, "ann@example.com"), login(t, srv, "bob@example.com"), login(t, srv, "cara@example.com")
+
+	if code, _, data := doRequestHeader(t, "POST", srv.URL+"/note", `{"text": "plan"}`, ann.Clone()); code != http.StatusCreated {
+		t.Fatalf("unexpected response %d: %s", code, data)
+	}
+
+	code, header, data := doRequestHeader(t, "POST", srv.URL+"/note/1/shares", `{"email": "bob@example.com", "role": "viewer"}`, ann.Clone())
+
+	if code != http.StatusCreated || header.Get("Location") != "/note/1/shares/1" {
+		t.Fatalf("unexpected share response %d %v: %s", code, header, data)
+	}
+
+	if code, _, data = doRequestHeader(t, "GET", srv.URL+"/note/1", "", bob.Clone()); code != http.StatusOK {
+		t.Errorf("expected a viewer to get the note, got %d: %s", code, data)
+	}
+
+	code, header, data = doRequestHeader(t, "PUT", srv.URL+"/note/1", `{"text": "changed"}`, bob.Clone())
+
+	if code != http.StatusForbidden || !strings.Contains(string(data), "/problems/forbidden") {
+		t.Errorf("expected 403 for an update by a viewer, got %d %v: %s", code, header, data)
+	}
+
+	if code, _, data = doRequestHeader(t, "GET", srv.URL+"/note/1/shares", "", bob.Clone()); code != http.StatusForbidden {
+		t.Errorf("expected 403 for shares listed by a viewer, got %d: %s", code, data)
+	}
+
+	if code, _, data = doRequestHeader(t, "GET", srv.URL+"/note/1", "", cara.Clone()); code != http.StatusNotFound {
+		t.Errorf("expected 404 for a user the note isn't shared with, got %d: %s", code, data)
+	}
+
+	team := models.Team{}
+	_, _, data = doRequestHeader(t, "POST", srv.URL+"/team", `{"name": "crew"}`, ann.Clone())
+
+	if err := json.Unmarshal(data, &team); err != nil || team.ID == 0 {
+		t.Fatalf("unexpected team: %s", data)
+	}
+
+	if code, _, data = doRequestHeader(t, "POST", srv.URL+"/team/1/members", `{"email": "cara@example.com"}`, ann.Clone()); code != http.StatusOK {
+		t.Fatalf("unexpected response %d: %s", code, data)
+	}
+
+	if code, _, data = doRequestHeader(t, "POST", srv.URL+"/note/1/shares", `{"teamId": 1, "role": "editor"}`, ann.Clone()); code != http.StatusCreated {
+		t.Fatalf("unexpected response %d: %s", code, data)
+	}
+
+	if code, _, data = doRequestHeader(t, "PUT", srv.URL+"/note/1", `{"text": "team plan"}`, cara.Clone()); code != http.StatusOK {
+		t.Errorf("expected a team editor to update the note, got %d: %s", code, data)
+	}
+
+	if code, _, data = doRequestHeader(t, "DELETE", srv.URL+"/note/1", "", cara.Clone()); code != http.StatusForbidden {
+		t.Errorf("expected 403 for a delete by an editor, got %d: %s", code, data)
+	}
+
+	shares := service.ShareList{}
+	_, _, data = doRequestHeader(t, "GET", srv.URL+"/note/1/shares", "", ann.Clone())
+
+	if err := json.Unmarshal(data, &shares); err != nil || len(shares.Shares) != 2 || shares.Shares[1].Team != "crew" {
+		t.Errorf("expected the shares with bob and crew, got %s", data)
+	}
+
+	if code, _, data = doRequestHeader(t, "DELETE", srv.URL+"/note/1/shares/1", "", ann.Clone()); code != http.StatusOK {
+		t.Errorf("unexpected response %d: %s", code, data)
+	}
+
+	if code, _, data = doRequestHeader(t, "GET", srv.URL+"/note/1", "", bob.Clone()); code != http.StatusNotFound {
+		t.Errorf("expected 404 once the share is deleted, got %d: %s", code, data)
+	}
+}
+
+func TestLinksEndToEnd(t *testing.T) {
+	srv := newAuthTestServer(t)
+	ann := login(t, srv, "ann@example.com")
+
+	if code, _, data := doRequestHeader(t, "POST", srv.URL+"/note", `{"title": "Plan", "text": "<b>public</b> plan"}`, ann.Clone()); code != http.StatusCreated {
+		t.Fatalf("unexpected response %d: %s", code, data)
+	}
+
+	if code, _, data := doRequestHeader(t, "POST", srv.URL+"/note/1/links", `{"expiresIn": "soon"}`, ann.Clone()); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a bad expiresIn, got %d: %s", code, data)
+	}
+
+	link := models.Link{}
+	code, header, data := doRequestHeader(t, "POST", srv.URL+"/note/1/links", `{"password": "open sesame"}`, ann.Clone())
+
+	if err := json.Unmarshal(data, &link); err != nil || code != http.StatusCreated || header.Get("Location") != "/s/"+link.Token || !link.Protected {
+		t.Fatalf("unexpected link response %d %v: %s", code, header, data)
+	}
+
+	// A browser gets the password form, then the note once the form is posted.
+	browser := http.Header{"Accept": {"text/html,application/xhtml+xml,*/*;q=0.8"}}
+	code, header, data = doRequestHeader(t, "GET", srv.URL+"/s/"+link.Token, "", browser.Clone())
+
+	if code != http.StatusUnauthorized || !strings.Contains(string(data), `name="password"`) || header.Get("Referrer-Policy") != "no-referrer" {
+		t.Errorf("expected the password form, got %d %v: %s", code, header, data)
+	}
+
+	form := browser.Clone()
+	form.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	if code, _, data = doRequestHeader(t, "POST", srv.URL+"/s/"+link.Token, "password=wrong+one", form.Clone()); code != http.StatusUnauthorized || !strings.Contains(string(data), "Wrong password") {
+		t.Errorf("expected the form again for a wrong password, got %d: %s", code, data)
+	}
+
+	code, header, data = doRequestHeader(t, "POST", srv.URL+"/s/"+link.Token, "password=open+sesame", form.Clone())
+
+	if code != http.StatusOK || !strings.Contains(string(data), "&lt;b&gt;public&lt;/b&gt; plan") || !strings.HasPrefix(header.Get("Content-Type"), "text/html") {
+		t.Errorf("expected the escaped note page, got %d %v: %s", code, header, data)
+	}
+
+	// Scripts get JSON and send the password in the body. They see the
+	// content of the note, not how its owner keeps it.
+	note := map[string]interface{}{}
+	code, _, data = doRequestHeader(t, "POST", srv.URL+"/s/"+link.Token, `{"password": "open sesame"}`, http.Header{})
+
+	if err := json.Unmarshal(data, &note); err != nil || code != http.StatusOK || note["title"] != "Plan" {
+		t.Errorf("unexpected note response %d: %s", code, data)
+	}
+
+	for _, field := range []string{"id", "notebookId", "version", "color", "pinned", "archived"} {
+		if _, in := note[field]; in {
+			t.Errorf("expected no %s in the public note: %s", field, data)
+		}
+	}
+
+	if code, data = doRequest(t, "GET", srv.URL+"/s/"+link.Token, ""); code != http.StatusUnauthorized || !strings.Contains(string(data), "/problems/unauthorized") {
+		t.Errorf("expected 401 without a password, got %d: %s", code, data)
+	}
+
+	links := service.LinkList{}
+	_, _, data = doRequestHeader(t, "GET", srv.URL+"/note/1/links", "", ann.Clone())
+
+	if err := json.Unmarshal(data, &links); err != nil || len(links.Links) != 1 || links.Links[0].Views != 2 || links.Links[0].Token != "" {
+		t.Errorf("expected one link viewed twice, got %s", data)
+	}
+
+	if code, data = doRequest(t, "GET", srv.URL+"/note/1/links", ""); code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for links listed without a session, got %d: %s", code, data)
+	}
+
+	if code, _, data = doRequestHeader(t, "DELETE", srv.URL+"/note/1/links/1", "", ann.Clone()); code != http.StatusOK {
+		t.Errorf("unexpected response %d: %s", code, data)
+	}
+
+	if code, _, data = doRequestHeader(t, "GET", srv.URL+"/s/"+link.Token, "", browser.Clone()); code != http.StatusNotFound || !strings.Contains(string(data), "expired") {
+		t.Errorf("expected 404 for a revoked link, got %d: %s", code, data)
+	}
+
+	if code, data = doRequest(t, "GET", srv.URL+"/s/"+link.Token, ""); code != http.StatusNotFound {
+		t.Errorf("expected 404 for a revoked link, got %d: %s", code, data)
+	}
+}
+
+func TestTokensEndToEnd(t *testing.T) {
+	srv := newAuthTestServer(t)
+	ann := login(t, srv, "ann@example.com")
+
+	if code, _, data := doRequestHeader(t, "POST", srv.URL+"/note", `{"text": "plan"}`, ann.Clone()); code != http.StatusCreated {
+		t.Fatalf("unexpected response %d: %s", code, data)
+	}
+
+	if code, _, data := doRequestHeader(t, "POST", srv.URL+"/tokens", `{"name": "backup", "scopes": ["notes:admin"]}`, ann.Clone()); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown scope, got %d: %s", code, data)
+	}
+
+	token := models.AccessToken{}
+	code, header, data := doRequestHeader(t, "POST", srv.URL+"/tokens", `{"name": "backup", "scopes": ["notes:read"]}`, ann.Clone())
+
+	if err := json.Unmarshal(data, &token); err != nil || code != http.StatusCreated || token.Token == "" || header.Get("Cache-Control") != "no-store" {
+		t.Fatalf("unexpected token response %d %v: %s", code, header, data)
+	}
+
+	script := http.Header{"Authorization": {"Bearer " + token.Token}}
+
+	if code, _, data = doRequestHeader(t, "GET", srv.URL+"/note/1", "", script.Clone()); code != http.StatusOK {
+		t.Errorf("expected a read token to get the note, got %d: %s", code, data)
+	}
+
+	code, header, data = doRequestHeader(t, "PUT", srv.URL+"/note/1", `{"text": "changed"}`, script.Clone())
+
+	if code != http.StatusForbidden || !strings.Contains(header.Get("WWW-Authenticate"), `scope="notes:write"`) {
+		t.Errorf("expected 403 for an update with a read token, got %d %v: %s", code, header, data)
+	}
+
+	// A token can't make or list tokens, even with the scope of the method.
+	if code, _, data = doRequestHeader(t, "GET", srv.URL+"/tokens", "", script.Clone()); code != http.StatusForbidden {
+		t.Errorf("expected 403 for tokens listed with a token, got %d: %s", code, data)
+	}
+
+	tokens := service.TokenList{}
+	_, _, data = doRequestHeader(t, "GET", srv.URL+"/tokens", "", ann.Clone())
+
+	if err := json.Unmarshal(data, &tokens); err != nil || len(tokens.Tokens) != 1 || tokens.Tokens[0].Token != "" || tokens.Tokens[0].LastUsedAt == nil {
+		t.Errorf("expected the used token without its secret, got %s", data)
+	}
+
+	if code, _, data = doRequestHeader(t, "DELETE", srv.URL+"/tokens/1", "", ann.Clone()); code != http.StatusOK {
+		t.Errorf("unexpected response %d: %s", code, data)
+	}
+
+	code, header, data = doRequestHeader(t, "GET", srv.URL+"/note/1", "", script.Clone())
+
+	if code != http.StatusUnauthorized || !strings.Contains(header.Get("WWW-Authenticate"), "invalid_token") {
+		t.Errorf("expected 401 for a revoked token, got %d %v: %s", code, header, data)
+	}
+}
+
+// newOIDCTestServer is newAuthTestServer with a login at idp. The provider
+// is discovered once the server has the URL it sends the browser back to.
+func newOIDCTestServer(t *testing.T, idp *oidctest.Provider) *httptest.Server {
+	storage := repository.NewMemoryStorage()
+	authService := service.NewAuthService(storage, time.Hour)
+	handler := NewNoteHandler(service.NewService(storage), zap.NewNop())
+	router := mux.NewRouter()
+
+	srv := httptest.NewServer(middleware.RequestID(router))
+	t.Cleanup(srv.Close)
+
+	config := oidc.Config{
+		Issuer: idp.URL, ClientID: idp.ClientID, ClientSecret: idp.ClientSecret, RedirectURL: srv.URL + "/auth/oidc/callback", Scopes: []string{"email"},
+	}
+	provider, err := oidc.New(context.Background(), config, idp.Client())
+
+	if err != nil {
+		t.Fatalf("can't discover the provider: %s", err)
+	}
+
+	oidcHandler := NewOIDCHandler(service.NewOIDCService(storage, time.Hour, provider), zap.NewNop())
+	oidcHandler.Register(router)
+
+	notes := router.NewRoute().Subrouter()
+	notes.Use(func(next http.Handler) http.Handler { return middleware.Auth(next, authService, zap.NewNop()) })
+	handler.Register(notes)
+
+	return srv
+}
+
+func TestOIDCEndToEnd(t *testing.T) {
+	idp := oidctest.New(t, "notes", "secret")
+	idp.Subject, idp.Email = "248289761001", "ann@example.com"
+	srv := newOIDCTestServer(t, idp)
+	jar, err := cookiejar.New(nil)
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	// The browser follows redirects to the provider only, so the callback
+	// can also be sent without the cookie.
+	browser := &http.Client{Jar: jar, CheckRedirect: func(req *http.Request, _ []*http.Request) error {
+		if strings.HasPrefix(req.URL.String(), srv.URL) {
+			return http.ErrUseLastResponse
+		}
+
+		return nil
+	}}
+	start := func() string {
+		resp, getErr := browser.Get(srv.URL + "/auth/oidc/login")
+
+		if getErr != nil {
+			t.Fatalf("can't log in: %s", getErr)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusFound {
+			t.Fatalf("expected a redirect to the callback, got %d", resp.StatusCode)
+		}
+
+		return resp.Header.Get("Location")
+	}
+	callback := start()
+
+	if code, _ := doRequest(t, "GET", callback, ""); code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for a callback without the login cookie, got %d", code)
+	}
+
+	resp, err := browser.Get(callback)
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	session := service.Session{}
+	err = json.NewDecoder(resp.Body).Decode(&session)
+	resp.Body.Close()
+
+	if err != nil || resp.StatusCode != http.StatusOK || session.User.Email != "ann@example.com" || resp.Header.Get("Cache-Control") != "no-store" {
+		t.Fatalf("unexpected session %d %+v: %v", resp.StatusCode, session, err)
+	}
+
+	ann := http.Header{"Authorization": {"Bearer " + session.Token}}
+
+	if code, _, data := doRequestHeader(t, "POST", srv.URL+"/note", `{"text": "plan"}`, ann); code != http.StatusCreated {
+		t.Errorf("expected the session to create a note, got %d: %s", code, data)
+	}
+
+	if resp, err = browser.Get(callback); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected 401 for a callback used twice, got %d", resp.StatusCode)
+	}
+
+	// A browser is sent to the front page with the token in the fragment.
+	req, err := http.NewRequest("GET", start(), nil)
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	req.Header.Set("Accept", "text/html")
+
+	if resp, err = browser.Do(req); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	resp.Body.Close()
+
+	if location := resp.Header.Get("Location"); resp.StatusCode != http.StatusSeeOther || !strings.HasPrefix(location, "/#token=") {
+		t.Errorf("expected a redirect to the front page, got %d %s", resp.StatusCode, location)
+	}
+
+	idp.Refuse = "access_denied"
+
+	if code, _ := doRequest(t, "GET", start(), ""); code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for a refused login, got %d", code)
+	}
+}
@@ -0,0 +1,104 @@
+package v1
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"note/internal/service"
+	"note/internal/tools"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// loginCookie keeps the state of a login at the identity provider in the
+// browser that started it.
+const loginCookie = "note_login"
+
+type OIDCService interface {
+	BeginLogin(context.Context) (*service.LoginRedirect, error)
+	FinishLogin(context.Context, service.FinishLogin) (*service.Session, error)
+}
+
+type oidcHandlers struct {
+	handlers
+	oidcService OIDCService
+}
+
+func NewOIDCHandler(service OIDCService, logger *zap.Logger) oidcHandlers {
+	return oidcHandlers{handlers: handlers{Logger: logger}, oidcService: service}
+}
+
+// Register adds the routes of a login at the identity provider, which
+// don't need a session.
+func (h *oidcHandlers) Register(router *mux.Router) {
+	router.HandleFunc("/auth/oidc/login", h.Login).Methods("GET")
+	router.HandleFunc("/auth/oidc/callback", h.Callback).Methods("GET")
+}
+
+// Login sends the browser to the identity provider.
+func (h *oidcHandlers) Login(w http.ResponseWriter, r *http.Request) {
+	login, err := h.oidcService.BeginLogin(r.Context())
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't start a login")
+
+		return
+	}
+
+	cookie := h.cookie(r, login.State)
+	cookie.Expires = login.ExpiresAt
+	http.SetCookie(w, cookie)
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, login.URL, http.StatusFound)
+}
+
+// Callback finishes a login. A browser is sent to the front page with the
+// session token in the fragment, which is never sent to a server; other
+// clients get the session as JSON, like from /auth/login.
+func (h *oidcHandlers) Callback(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	fl := service.FinishLogin{State: q.Get("state"), Code: q.Get("code"), Error: q.Get("error"), ErrorDescription: q.Get("error_description")}
+
+	if cookie, err := r.Cookie(loginCookie); err == nil {
+		fl.BrowserState = cookie.Value
+	}
+
+	// The state is used once, whatever comes of it.
+	expired := h.cookie(r, "")
+	expired.MaxAge = -1
+	http.SetCookie(w, expired)
+
+	session, err := h.oidcService.FinishLogin(r.Context(), fl)
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't log in")
+
+		return
+	}
+
+	if prefersHTML(r) {
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		http.Redirect(w, r, "/#"+url.Values{"token": {session.Token}}.Encode(), http.StatusSeeOther)
+
+		return
+	}
+
+	headers := http.Header{}
+	headers.Set("Cache-Control", "no-store")
+	err = tools.WriteJSON(w, session, headers)
+
+	if err != nil {
+		h.Logger.Warn(err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// cookie is the login cookie. SameSite=Lax still sends it with the
+// redirect back from the identity provider.
+func (h *oidcHandlers) cookie(r *http.Request, state string) *http.Cookie {
+	return &http.Cookie{
+		Name: loginCookie, Value: state, Path: "/auth/oidc", HttpOnly: true, Secure: r.TLS != nil, SameSite: http.SameSiteLaxMode,
+	}
+}
@@ -0,0 +1,173 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"mime"
+	"net/http"
+	"note/internal/models"
+	"note/internal/service"
+	"note/internal/tools"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+type PublicService interface {
+	OpenLink(context.Context, service.OpenLink) (*models.PublicNote, error)
+}
+
+type publicHandlers struct {
+	handlers
+	publicService PublicService
+}
+
+func NewPublicHandler(service PublicService, logger *zap.Logger) publicHandlers {
+	return publicHandlers{handlers: handlers{Logger: logger}, publicService: service}
+}
+
+// Register adds the public link routes, which need no session. A password
+// is posted, as JSON or as the form of the HTML page, so it never ends up
+// in a URL.
+func (h *publicHandlers) Register(router *mux.Router) {
+	router.HandleFunc("/s/{token}", h.OpenLink).Methods("GET", "POST")
+}
+
+// linkPage renders a note, or the password form of a protected link when
+// Note is nil.
+var linkPage = template.Must(template.New("link").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="robots" content="noindex">
+    <title>{{if .Note}}{{if .Note.Title}}{{.Note.Title}}{{else}}Note{{end}}{{else}}{{.Heading}}{{end}}</title>
+    <style>
+        body { font-family: Arial, sans-serif; background-color: #f5f5f5; margin: 0; padding: 20px; }
+        .note { max-width: 720px; margin: 0 auto; background-color: #fff; border: 1px solid #3498db; border-radius: 5px; padding: 20px; }
+        .text { white-space: pre-wrap; overflow-wrap: break-word; }
+        .meta { color: #777; font-size: 0.9em; }
+        .error { color: #c0392b; }
+    </style>
+</head>
+<body>
+    <div class="note">
+    {{- if .Note}}
+        {{if .Note.Title}}<h2>{{.Note.Title}}</h2>{{end}}
+        <div class="text">{{.Note.Text}}</div>
+        {{if .Note.Summary}}<p><em>{{.Note.Summary}}</em></p>{{end}}
+        {{if .Note.Tags}}<p class="meta">Tags: {{range $i, $tag := .Note.Tags}}{{if $i}}, {{end}}{{$tag}}{{end}}</p>{{end}}
+        <p class="meta">Updated {{.Note.UpdatedAt.Format "2006-01-02 15:04 MST"}}</p>
+    {{- else}}
+        <h2>{{.Heading}}</h2>
+        {{if .Detail}}<p class="error">{{.Detail}}</p>{{end}}
+        {{if .Form}}
+        <form method="post">
+            <input name="password" type="password" placeholder="password" autofocus>
+            <button type="submit">Open</button>
+        </form>
+        {{end}}
+    {{- end}}
+    </div>
+</body>
+</html>
+`))
+
+type linkView struct {
+	Note    *models.PublicNote
+	Heading string
+	Detail  string
+	Form    bool
+}
+
+// OpenLink answers with the note of a link as JSON, or as an HTML page
+// when the client prefers text/html, like a browser does.
+func (h *publicHandlers) OpenLink(w http.ResponseWriter, r *http.Request) {
+	// The token is in the URL: keep it out of caches, search engines and
+	// the Referer of links in the note.
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("X-Robots-Tag", "noindex")
+
+	html := prefersHTML(r)
+	ol := service.OpenLink{}
+
+	if r.Method == http.MethodPost {
+		if r.Header.Get("Content-type") == contentType {
+			if !h.readJSON(w, r, &ol) {
+				return
+			}
+		} else {
+			ol.Password = r.PostFormValue("password")
+		}
+	}
+
+	ol.Token = mux.Vars(r)["token"]
+	note, err := h.publicService.OpenLink(r.Context(), ol)
+
+	switch {
+	case err != nil && html:
+		h.errorPage(w, err, r.Method == http.MethodPost)
+	case err != nil:
+		h.serviceError(w, r, err, "can't open a link")
+	case html:
+		h.page(w, http.StatusOK, linkView{Note: note})
+	default:
+		if err = tools.WriteJSON(w, note); err != nil {
+			h.Logger.Warn("server can't write")
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}
+}
+
+func (h *publicHandlers) errorPage(w http.ResponseWriter, err error, posted bool) {
+	h.Logger.Warn(err.Error())
+
+	switch {
+	case errors.Is(err, service.ErrUnauthorized):
+		view := linkView{Heading: "This note is protected", Form: true}
+
+		if posted {
+			view.Detail = "Wrong password, try again."
+		}
+
+		h.page(w, http.StatusUnauthorized, view)
+	case errors.Is(err, service.ErrNotFound):
+		h.page(w, http.StatusNotFound, linkView{Heading: "This link doesn't exist or has expired"})
+	default:
+		h.page(w, http.StatusInternalServerError, linkView{Heading: "Something went wrong, try again later"})
+	}
+}
+
+func (h *publicHandlers) page(w http.ResponseWriter, status int, view linkView) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; form-action 'self'")
+	w.WriteHeader(status)
+
+	if err := linkPage.Execute(w, view); err != nil {
+		h.Logger.Warn(err.Error())
+	}
+}
+
+// prefersHTML tells whether text/html comes before application/json in
+// the Accept header. Clients that name neither get JSON.
+func prefersHTML(r *http.Request) bool {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+
+		if err != nil || params["q"] == "0" {
+			continue
+		}
+
+		switch mediaType {
+		case "text/html":
+			return true
+		case contentType:
+			return false
+		}
+	}
+
+	return false
+}
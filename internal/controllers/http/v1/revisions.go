@@ -0,0 +1,89 @@
+package v1
+
+import (
+	"net/http"
+	"note/internal/service"
+	"note/internal/tools"
+
+	"github.com/gorilla/mux"
+)
+
+func (h *handlers) registerRevisions(router *mux.Router) {
+	router.HandleFunc("/note/{id}/revisions", h.Revisions).Methods("GET")
+	router.HandleFunc("/note/{id}/revisions/{rev}", h.Revision).Methods("GET")
+	router.HandleFunc("/note/{id}/revisions/{rev}/diff", h.DiffRevisions).Methods("GET")
+	router.HandleFunc("/note/{id}/revisions/{rev}/restore", h.RestoreRevision).Methods("POST")
+}
+
+func (h *handlers) Revisions(w http.ResponseWriter, r *http.Request) {
+	revisions, err := h.noteService.Revisions(r.Context(), service.GetRevisions{ID: mux.Vars(r)["id"]})
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't get revisions")
+
+		return
+	}
+
+	err = tools.WriteJSON(w, revisions)
+
+	if err != nil {
+		h.Logger.Warn("server can't write")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (h *handlers) Revision(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	revision, err := h.noteService.Revision(r.Context(), service.GetRevision{ID: vars["id"], Rev: vars["rev"]})
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't get a revision")
+
+		return
+	}
+
+	err = tools.WriteJSON(w, revision)
+
+	if err != nil {
+		h.Logger.Warn("server can't write")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// DiffRevisions compares a revision with ?to=N, or with the latest one.
+func (h *handlers) DiffRevisions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	dr := service.DiffRevisions{ID: vars["id"], Rev: vars["rev"], To: r.URL.Query().Get("to")}
+	diff, err := h.noteService.DiffRevisions(r.Context(), dr)
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't diff revisions")
+
+		return
+	}
+
+	err = tools.WriteJSON(w, diff)
+
+	if err != nil {
+		h.Logger.Warn("server can't write")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (h *handlers) RestoreRevision(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	note, err := h.noteService.RestoreRevision(r.Context(), service.RestoreRevision{ID: vars["id"], Rev: vars["rev"]})
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't restore a revision")
+
+		return
+	}
+
+	err = tools.WriteJSON(w, note)
+
+	if err != nil {
+		h.Logger.Warn("server can't write")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,80 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"note/internal/service"
+	"note/internal/tools"
+
+	"github.com/gorilla/mux"
+)
+
+func (h *handlers) registerShares(router *mux.Router) {
+	router.HandleFunc("/note/{id}/shares", h.Shares).Methods("GET")
+	router.HandleFunc("/note/{id}/shares", h.ShareNote).Methods("POST")
+	router.HandleFunc("/note/{id}/shares/{shareId}", h.DeleteShare).Methods("DELETE")
+}
+
+func (h *handlers) Shares(w http.ResponseWriter, r *http.Request) {
+	shares, err := h.noteService.Shares(r.Context(), service.GetShares{ID: mux.Vars(r)["id"]})
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't get shares")
+
+		return
+	}
+
+	err = tools.WriteJSON(w, shares)
+
+	if err != nil {
+		h.Logger.Warn("server can't write")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (h *handlers) ShareNote(w http.ResponseWriter, r *http.Request) {
+	sn := service.ShareNote{}
+
+	if !h.readJSON(w, r, &sn) {
+		return
+	}
+
+	sn.ID = mux.Vars(r)["id"]
+	share, err := h.noteService.ShareNote(r.Context(), sn)
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't share a note")
+
+		return
+	}
+
+	headers := http.Header{}
+	headers.Set("Location", fmt.Sprintf("/note/%d/shares/%d", share.NoteID, share.ID))
+
+	err = tools.WriteJSONStatus(w, http.StatusCreated, share, headers)
+
+	if err != nil {
+		h.Logger.Warn(err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (h *handlers) DeleteShare(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	err := h.noteService.DeleteShare(r.Context(), service.DeleteShare{ID: vars["id"], ShareID: vars["shareId"]})
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't delete a share")
+
+		return
+	}
+
+	err = tools.WriteJSON(w, struct {
+		Response string `json:"response"`
+	}{"successfully deleted"})
+
+	if err != nil {
+		h.Logger.Warn("server can't write")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,143 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"note/internal/service"
+	"note/internal/tools"
+
+	"github.com/gorilla/mux"
+)
+
+func (h *handlers) registerTeams(router *mux.Router) {
+	router.HandleFunc("/team", h.Teams).Methods("GET")
+	router.HandleFunc("/team", h.CreateTeam).Methods("POST")
+	router.HandleFunc("/team/{id}", h.GetTeam).Methods("GET")
+	router.HandleFunc("/team/{id}", h.DeleteTeam).Methods("DELETE")
+	router.HandleFunc("/team/{id}/members", h.AddMember).Methods("POST")
+	router.HandleFunc("/team/{id}/members/{userId}", h.RemoveMember).Methods("DELETE")
+}
+
+func (h *handlers) Teams(w http.ResponseWriter, r *http.Request) {
+	teams, err := h.noteService.Teams(r.Context())
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't get teams")
+
+		return
+	}
+
+	err = tools.WriteJSON(w, teams)
+
+	if err != nil {
+		h.Logger.Warn("server can't write")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (h *handlers) CreateTeam(w http.ResponseWriter, r *http.Request) {
+	ct := service.CreateTeam{}
+
+	if !h.readJSON(w, r, &ct) {
+		return
+	}
+
+	team, err := h.noteService.CreateTeam(r.Context(), ct)
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't create a team")
+
+		return
+	}
+
+	headers := http.Header{}
+	headers.Set("Location", fmt.Sprintf("/team/%d", team.ID))
+
+	err = tools.WriteJSONStatus(w, http.StatusCreated, team, headers)
+
+	if err != nil {
+		h.Logger.Warn(err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (h *handlers) GetTeam(w http.ResponseWriter, r *http.Request) {
+	team, err := h.noteService.GetTeam(r.Context(), service.GetTeam{ID: mux.Vars(r)["id"]})
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't get a team")
+
+		return
+	}
+
+	err = tools.WriteJSON(w, team)
+
+	if err != nil {
+		h.Logger.Warn("server can't write")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (h *handlers) DeleteTeam(w http.ResponseWriter, r *http.Request) {
+	err := h.noteService.DeleteTeam(r.Context(), service.DeleteTeam{ID: mux.Vars(r)["id"]})
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't delete a team")
+
+		return
+	}
+
+	err = tools.WriteJSON(w, struct {
+		Response string `json:"response"`
+	}{"successfully deleted"})
+
+	if err != nil {
+		h.Logger.Warn("server can't write")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// AddMember answers with the team and its members.
+func (h *handlers) AddMember(w http.ResponseWriter, r *http.Request) {
+	am := service.AddMember{}
+
+	if !h.readJSON(w, r, &am) {
+		return
+	}
+
+	am.ID = mux.Vars(r)["id"]
+	team, err := h.noteService.AddMember(r.Context(), am)
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't add a member")
+
+		return
+	}
+
+	err = tools.WriteJSON(w, team)
+
+	if err != nil {
+		h.Logger.Warn("server can't write")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (h *handlers) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	err := h.noteService.RemoveMember(r.Context(), service.RemoveMember{ID: vars["id"], UserID: vars["userId"]})
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't remove a member")
+
+		return
+	}
+
+	err = tools.WriteJSON(w, struct {
+		Response string `json:"response"`
+	}{"successfully removed"})
+
+	if err != nil {
+		h.Logger.Warn("server can't write")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
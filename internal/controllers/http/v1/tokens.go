@@ -0,0 +1,82 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"note/internal/service"
+	"note/internal/tools"
+
+	"github.com/gorilla/mux"
+)
+
+// RegisterTokens adds the routes to manage personal access tokens. They go
+// behind middleware.Auth and answer only to a session.
+func (h *authHandlers) RegisterTokens(router *mux.Router) {
+	router.HandleFunc("/tokens", h.Tokens).Methods("GET")
+	router.HandleFunc("/tokens", h.CreateToken).Methods("POST")
+	router.HandleFunc("/tokens/{id}", h.RevokeToken).Methods("DELETE")
+}
+
+func (h *authHandlers) Tokens(w http.ResponseWriter, r *http.Request) {
+	tokens, err := h.authService.Tokens(r.Context())
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't get tokens")
+
+		return
+	}
+
+	err = tools.WriteJSON(w, tokens)
+
+	if err != nil {
+		h.Logger.Warn("server can't write")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// CreateToken answers with the token, which is never shown again.
+func (h *authHandlers) CreateToken(w http.ResponseWriter, r *http.Request) {
+	ct := service.CreateToken{}
+
+	if !h.readJSON(w, r, &ct) {
+		return
+	}
+
+	token, err := h.authService.CreateToken(r.Context(), ct)
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't create a token")
+
+		return
+	}
+
+	headers := http.Header{}
+	headers.Set("Location", fmt.Sprintf("/tokens/%d", token.ID))
+	headers.Set("Cache-Control", "no-store")
+
+	err = tools.WriteJSONStatus(w, http.StatusCreated, token, headers)
+
+	if err != nil {
+		h.Logger.Warn(err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (h *authHandlers) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	err := h.authService.RevokeToken(r.Context(), service.RevokeToken{ID: mux.Vars(r)["id"]})
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't revoke a token")
+
+		return
+	}
+
+	err = tools.WriteJSON(w, struct {
+		Response string `json:"response"`
+	}{"successfully revoked"})
+
+	if err != nil {
+		h.Logger.Warn("server can't write")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
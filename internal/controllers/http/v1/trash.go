@@ -0,0 +1,80 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"note/internal/service"
+	"note/internal/tools"
+
+	"github.com/gorilla/mux"
+)
+
+func (h *handlers) registerTrash(router *mux.Router) {
+	router.HandleFunc("/trash", h.Trash).Methods("GET")
+	router.HandleFunc("/trash/{id}", h.PurgeByID).Methods("DELETE")
+	router.HandleFunc("/note/{id}/restore", h.RestoreByID).Methods("POST")
+}
+
+// Trash lists deleted notes with the parameters of GetAll.
+func (h *handlers) Trash(w http.ResponseWriter, r *http.Request) {
+	queries := r.URL.Query()
+	page, err := h.noteService.Trash(r.Context(), getNotes(queries))
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't get the trash")
+
+		return
+	}
+
+	headers := http.Header{}
+
+	if page.NextCursor != "" {
+		next := *r.URL
+		queries.Set("cursor", page.NextCursor)
+		next.RawQuery = queries.Encode()
+		headers.Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
+	}
+
+	err = tools.WriteJSON(w, page, headers)
+
+	if err != nil {
+		h.Logger.Warn("server can't write")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (h *handlers) RestoreByID(w http.ResponseWriter, r *http.Request) {
+	note, err := h.noteService.Restore(r.Context(), service.RestoreNote{ID: mux.Vars(r)["id"]})
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't restore a note")
+
+		return
+	}
+
+	err = tools.WriteJSON(w, note)
+
+	if err != nil {
+		h.Logger.Warn("server can't write")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func (h *handlers) PurgeByID(w http.ResponseWriter, r *http.Request) {
+	err := h.noteService.Purge(r.Context(), service.PurgeNote{ID: mux.Vars(r)["id"]})
+
+	if err != nil {
+		h.serviceError(w, r, err, "can't purge a note")
+
+		return
+	}
+
+	err = tools.WriteJSON(w, struct {
+		Response string `json:"response"`
+	}{"successfully purged"})
+
+	if err != nil {
+		h.Logger.Warn("server can't write")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
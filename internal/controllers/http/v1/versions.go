@@ -0,0 +1,37 @@
+package v1
+
+import (
+	"net/http"
+	"note/internal/models"
+	"strconv"
+	"strings"
+)
+
+// etag is the strong entity tag of a note, its quoted version.
+func etag(note *models.Note) string {
+	return strconv.Quote(strconv.FormatInt(note.Version, 10))
+}
+
+// ifMatch reads the version a write is conditional on. No If-Match header or
+// "*" mean any version and give 0, so clients that don't send the header
+// keep working and the last write wins; a tag that can't be a version never
+// matches, so the request fails with 412 right away.
+func (h *handlers) ifMatch(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+
+	if header == "" || header == "*" {
+		return 0, true
+	}
+
+	unquoted, err := strconv.Unquote(header)
+	version, parseErr := strconv.ParseInt(unquoted, 10, 64)
+
+	if err != nil || parseErr != nil || version < 1 {
+		h.Logger.Warn("can't match If-Match " + header)
+		h.writeProblem(w, r, preconditionFailed("If-Match "+header+" doesn't match any version of the note"))
+
+		return 0, false
+	}
+
+	return version, true
+}
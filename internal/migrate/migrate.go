@@ -0,0 +1,328 @@
+// Package migrate applies the numbered schema migrations embedded in the
+// binary. Files live in migrations/<dialect>/NNNN_name.up.sql with a
+// matching .down.sql, and applied versions are tracked in schema_migrations.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations
+var files embed.FS
+
+const (
+	MySQL    = "mysql"
+	Postgres = "postgres"
+	SQLite   = "sqlite"
+)
+
+type migration struct {
+	Version int64
+	Name    string
+	up      string
+	down    string
+}
+
+type Status struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time
+}
+
+type Migrator struct {
+	db         *sql.DB
+	dialect    string
+	migrations []migration
+}
+
+func New(db *sql.DB, dialect string) (*Migrator, error) {
+	migrations, err := load(dialect)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, dialect: dialect, migrations: migrations}, nil
+}
+
+func load(dialect string) ([]migration, error) {
+	dir := path.Join("migrations", dialect)
+	entries, err := fs.ReadDir(files, dir)
+
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for dialect %q", dialect)
+	}
+
+	byVersion := map[int64]*migration{}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		base, direction, ok := parseName(name)
+
+		if !ok {
+			return nil, fmt.Errorf("bad migration file name %q", name)
+		}
+
+		versionStr, title, _ := strings.Cut(base, "_")
+		version, err := strconv.ParseInt(versionStr, 10, 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("bad migration version in %q", name)
+		}
+
+		data, err := files.ReadFile(path.Join(dir, name))
+
+		if err != nil {
+			return nil, err
+		}
+
+		m, in := byVersion[version]
+
+		if !in {
+			m = &migration{Version: version, Name: title}
+			byVersion[version] = m
+		}
+
+		if direction == "up" {
+			m.up = string(data)
+		} else {
+			m.down = string(data)
+		}
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("migration %d must have both up and down files", m.Version)
+		}
+
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+func parseName(name string) (string, string, bool) {
+	if base, ok := strings.CutSuffix(name, ".up.sql"); ok {
+		return base, "up", true
+	}
+
+	if base, ok := strings.CutSuffix(name, ".down.sql"); ok {
+		return base, "down", true
+	}
+
+	return "", "", false
+}
+
+func (m *Migrator) placeholder(n int) string {
+	if m.dialect == Postgres {
+		return "$" + strconv.Itoa(n)
+	}
+
+	return "?"
+}
+
+func (m *Migrator) ensureTable(ctx context.Context) error {
+	_, err := m.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version BIGINT PRIMARY KEY,
+    applied_at TIMESTAMP NOT NULL
+)`)
+
+	return err
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int64]time.Time, error) {
+	if err := m.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.db.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int64]time.Time{}
+
+	for rows.Next() {
+		var (
+			version   int64
+			appliedAt time.Time
+		)
+
+		if err = rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+// Up applies every pending migration in order and returns their versions.
+func (m *Migrator) Up(ctx context.Context) ([]int64, error) {
+	applied, err := m.applied(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	done := []int64{}
+
+	for _, mig := range m.migrations {
+		if _, in := applied[mig.Version]; in {
+			continue
+		}
+
+		record := fmt.Sprintf(`INSERT INTO schema_migrations (version, applied_at) VALUES (%s, %s)`, m.placeholder(1), m.placeholder(2))
+
+		if err = m.run(ctx, mig.up, record, mig.Version, time.Now().UTC().Truncate(time.Second)); err != nil {
+			return done, fmt.Errorf("migration %d_%s up: %w", mig.Version, mig.Name, err)
+		}
+
+		done = append(done, mig.Version)
+	}
+
+	return done, nil
+}
+
+// Down rolls back the last steps applied migrations, newest first.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]int64, error) {
+	applied, err := m.applied(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	done := []int64{}
+
+	for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		mig := m.migrations[i]
+
+		if _, in := applied[mig.Version]; !in {
+			continue
+		}
+
+		record := fmt.Sprintf(`DELETE FROM schema_migrations WHERE version = %s`, m.placeholder(1))
+
+		if err = m.run(ctx, mig.down, record, mig.Version); err != nil {
+			return done, fmt.Errorf("migration %d_%s down: %w", mig.Version, mig.Name, err)
+		}
+
+		done = append(done, mig.Version)
+	}
+
+	return done, nil
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+
+	for _, mig := range m.migrations {
+		st := Status{Version: mig.Version, Name: mig.Name}
+
+		if at, in := applied[mig.Version]; in {
+			st.AppliedAt = &at
+		}
+
+		statuses = append(statuses, st)
+	}
+
+	return statuses, nil
+}
+
+// run executes a migration script and its bookkeeping statement in one
+// transaction. MySQL commits DDL implicitly, so there a failed script may
+// leave partial changes behind; scripts use IF [NOT] EXISTS to be re-runnable.
+func (m *Migrator) run(ctx context.Context, script, record string, args ...interface{}) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return err
+	}
+
+	for _, stmt := range splitStatements(script) {
+		if _, err = tx.ExecContext(ctx, stmt); err != nil {
+			return errors.Join(err, tx.Rollback())
+		}
+	}
+
+	if _, err = tx.ExecContext(ctx, record, args...); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+
+	return tx.Commit()
+}
+
+// splitStatements splits a script on semicolons that end a line, keeping
+// BEGIN ... END; blocks (trigger bodies) in one piece.
+func splitStatements(script string) []string {
+	stmts := []string{}
+	cur := strings.Builder{}
+	inBlock := false
+
+	flush := func() {
+		stmt := strings.TrimSuffix(strings.TrimSpace(cur.String()), ";")
+
+		if stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+
+		cur.Reset()
+	}
+
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		if cur.Len() == 0 && (trimmed == "" || strings.HasPrefix(trimmed, "--")) {
+			continue
+		}
+
+		cur.WriteString(line)
+		cur.WriteByte('\n')
+
+		upper := strings.ToUpper(trimmed)
+
+		if strings.HasSuffix(upper, "BEGIN") {
+			inBlock = true
+		}
+
+		if inBlock {
+			if upper == "END;" {
+				inBlock = false
+				flush()
+			}
+
+			continue
+		}
+
+		if strings.HasSuffix(trimmed, ";") {
+			flush()
+		}
+	}
+
+	flush()
+
+	return stmts
+}
@@ -0,0 +1,125 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- leading comment
+CREATE TABLE a (id INT);
+
+CREATE TRIGGER a_ai AFTER INSERT ON a BEGIN
+    INSERT INTO b VALUES (new.id);
+    INSERT INTO c VALUES (new.id);
+END;
+DROP TABLE d`
+
+	expect := []string{
+		"CREATE TABLE a (id INT)",
+		"CREATE TRIGGER a_ai AFTER INSERT ON a BEGIN\n    INSERT INTO b VALUES (new.id);\n    INSERT INTO c VALUES (new.id);\nEND",
+		"DROP TABLE d",
+	}
+
+	if got := splitStatements(script); !reflect.DeepEqual(got, expect) {
+		t.Errorf("results not match, want %q, have %q", expect, got)
+	}
+}
+
+func TestDialectsHaveSameVersions(t *testing.T) {
+	var reference []int64
+
+	for _, dialect := range []string{MySQL, Postgres, SQLite} {
+		migrations, err := load(dialect)
+
+		if err != nil {
+			t.Fatalf("%s: %s", dialect, err)
+		}
+
+		versions := []int64{}
+
+		for _, m := range migrations {
+			versions = append(versions, m.Version)
+		}
+
+		if reference == nil {
+			reference = versions
+			continue
+		}
+
+		if !reflect.DeepEqual(reference, versions) {
+			t.Errorf("%s migrations %v differ from %v", dialect, versions, reference)
+		}
+	}
+}
+
+func TestUpDownStatus(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite3", "file::memory:")
+
+	if err != nil {
+		t.Fatalf("can't open sqlite: %s", err)
+	}
+	defer db.Close()
+
+	db.SetMaxOpenConns(1)
+
+	migrator, err := New(db, SQLite)
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	total := len(migrator.migrations)
+	applied, err := migrator.Up(ctx)
+
+	if err != nil || len(applied) != total {
+		t.Fatalf("expected %d applied migrations, got %v, %v", total, applied, err)
+	}
+
+	if _, err = db.Exec("INSERT INTO note (text) VALUES ('kept')"); err != nil {
+		t.Fatalf("schema not created: %s", err)
+	}
+
+	applied, err = migrator.Up(ctx)
+
+	if err != nil || len(applied) != 0 {
+		t.Fatalf("expected second up to be a no-op, got %v, %v", applied, err)
+	}
+
+	statuses, err := migrator.Status(ctx)
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	for _, st := range statuses {
+		if st.AppliedAt == nil {
+			t.Errorf("migration %d reported as pending", st.Version)
+		}
+	}
+
+	rolledBack, err := migrator.Down(ctx, total)
+
+	if err != nil || len(rolledBack) != total || rolledBack[0] != statuses[total-1].Version {
+		t.Fatalf("expected newest-first rollback of %d migrations, got %v, %v", total, rolledBack, err)
+	}
+
+	if _, err = db.Exec("SELECT id FROM note"); err == nil {
+		t.Error("expected note table to be dropped")
+	}
+
+	statuses, err = migrator.Status(ctx)
+
+	if err != nil || statuses[0].AppliedAt != nil {
+		t.Errorf("expected pending migrations after down, got %v, %v", statuses, err)
+	}
+
+	if _, err = New(db, "oracle"); err == nil {
+		t.Error("expected error for unknown dialect, got nil")
+	}
+}
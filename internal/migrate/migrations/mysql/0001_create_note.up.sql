@@ -1,7 +1,6 @@
-DROP TABLE IF EXISTS `note`;
-CREATE TABLE `note` (
+CREATE TABLE IF NOT EXISTS `note` (
     `id` INT(11) PRIMARY KEY AUTO_INCREMENT,
     `text` TEXT,
     `created_at` DATETIME,
     `updated_at` DATETIME
-) ENGINE=InnoDB DEFAULT CHARSET=utf8;
\ No newline at end of file
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
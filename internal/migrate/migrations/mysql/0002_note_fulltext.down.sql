@@ -0,0 +1 @@
+ALTER TABLE `note` DROP INDEX `note_text_fulltext`;
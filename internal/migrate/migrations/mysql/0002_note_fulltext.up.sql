@@ -0,0 +1 @@
+ALTER TABLE `note` ADD FULLTEXT INDEX `note_text_fulltext` (`text`);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS `tag` (
+    `id` INT(11) PRIMARY KEY AUTO_INCREMENT,
+    `name` VARCHAR(32) NOT NULL,
+    UNIQUE KEY `tag_name` (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE IF NOT EXISTS `note_tag` (
+    `note_id` INT(11) NOT NULL,
+    `tag_id` INT(11) NOT NULL,
+    PRIMARY KEY (`note_id`, `tag_id`),
+    KEY `note_tag_tag_id` (`tag_id`),
+    CONSTRAINT `note_tag_note` FOREIGN KEY (`note_id`) REFERENCES `note` (`id`) ON DELETE CASCADE,
+    CONSTRAINT `note_tag_tag` FOREIGN KEY (`tag_id`) REFERENCES `tag` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
@@ -0,0 +1,6 @@
+ALTER TABLE `note`
+    DROP FOREIGN KEY `note_notebook`,
+    DROP KEY `note_notebook_id`,
+    DROP COLUMN `notebook_id`;
+
+DROP TABLE IF EXISTS `notebook`;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS `notebook` (
+    `id` INT(11) PRIMARY KEY AUTO_INCREMENT,
+    `name` VARCHAR(255) NOT NULL,
+    `parent_id` INT(11) NULL,
+    `created_at` DATETIME,
+    `updated_at` DATETIME,
+    KEY `notebook_parent_id` (`parent_id`),
+    CONSTRAINT `notebook_parent` FOREIGN KEY (`parent_id`) REFERENCES `notebook` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+ALTER TABLE `note`
+    ADD COLUMN `notebook_id` INT(11) NULL,
+    ADD KEY `note_notebook_id` (`notebook_id`),
+    ADD CONSTRAINT `note_notebook` FOREIGN KEY (`notebook_id`) REFERENCES `notebook` (`id`) ON DELETE CASCADE;
@@ -0,0 +1,7 @@
+ALTER TABLE `note`
+    DROP KEY `note_archived_pinned`,
+    DROP COLUMN `archived`,
+    DROP COLUMN `pinned`,
+    DROP COLUMN `color`,
+    DROP COLUMN `summary`,
+    DROP COLUMN `title`;
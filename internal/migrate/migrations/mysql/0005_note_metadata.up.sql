@@ -0,0 +1,7 @@
+ALTER TABLE `note`
+    ADD COLUMN `title` VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN `summary` VARCHAR(1000) NOT NULL DEFAULT '',
+    ADD COLUMN `color` VARCHAR(16) NOT NULL DEFAULT '',
+    ADD COLUMN `pinned` BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN `archived` BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD KEY `note_archived_pinned` (`archived`, `pinned`);
@@ -0,0 +1,3 @@
+ALTER TABLE `note`
+    DROP KEY `note_deleted_at`,
+    DROP COLUMN `deleted_at`;
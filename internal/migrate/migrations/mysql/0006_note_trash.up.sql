@@ -0,0 +1,3 @@
+ALTER TABLE `note`
+    ADD COLUMN `deleted_at` DATETIME NULL,
+    ADD KEY `note_deleted_at` (`deleted_at`);
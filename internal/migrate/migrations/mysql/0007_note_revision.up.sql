@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS `note_revision` (
+    `note_id` INT(11) NOT NULL,
+    `revision` INT(11) NOT NULL,
+    `title` VARCHAR(255) NOT NULL DEFAULT '',
+    `text` TEXT,
+    `summary` VARCHAR(1000) NOT NULL DEFAULT '',
+    `created_at` DATETIME,
+    PRIMARY KEY (`note_id`, `revision`),
+    CONSTRAINT `note_revision_note` FOREIGN KEY (`note_id`) REFERENCES `note` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+INSERT INTO `note_revision` (`note_id`, `revision`, `title`, `text`, `summary`, `created_at`)
+    SELECT `id`, 1, `title`, `text`, `summary`, `updated_at` FROM `note`;
@@ -0,0 +1 @@
+ALTER TABLE `note` ADD COLUMN `version` BIGINT NOT NULL DEFAULT 1;
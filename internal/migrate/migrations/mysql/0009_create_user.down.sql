@@ -0,0 +1,13 @@
+ALTER TABLE `notebook`
+    DROP FOREIGN KEY `notebook_owner`,
+    DROP KEY `notebook_owner_id`,
+    DROP COLUMN `owner_id`;
+
+ALTER TABLE `note`
+    DROP FOREIGN KEY `note_owner`,
+    DROP KEY `note_owner_id`,
+    DROP COLUMN `owner_id`;
+
+DROP TABLE IF EXISTS `session`;
+
+DROP TABLE IF EXISTS `users`;
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS `users` (
+    `id` INT(11) PRIMARY KEY AUTO_INCREMENT,
+    `email` VARCHAR(255) NOT NULL,
+    `password_hash` VARCHAR(255) NOT NULL,
+    `created_at` DATETIME,
+    UNIQUE KEY `users_email` (`email`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE IF NOT EXISTS `session` (
+    `token_hash` CHAR(64) PRIMARY KEY,
+    `user_id` INT(11) NOT NULL,
+    `created_at` DATETIME,
+    `expires_at` DATETIME NOT NULL,
+    KEY `session_user_id` (`user_id`),
+    CONSTRAINT `session_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+ALTER TABLE `note`
+    ADD COLUMN `owner_id` INT(11) NULL,
+    ADD KEY `note_owner_id` (`owner_id`),
+    ADD CONSTRAINT `note_owner` FOREIGN KEY (`owner_id`) REFERENCES `users` (`id`) ON DELETE CASCADE;
+
+ALTER TABLE `notebook`
+    ADD COLUMN `owner_id` INT(11) NULL,
+    ADD KEY `notebook_owner_id` (`owner_id`),
+    ADD CONSTRAINT `notebook_owner` FOREIGN KEY (`owner_id`) REFERENCES `users` (`id`) ON DELETE CASCADE;
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS `note_share`;
+
+DROP TABLE IF EXISTS `team_member`;
+
+DROP TABLE IF EXISTS `team`;
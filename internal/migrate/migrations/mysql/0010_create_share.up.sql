@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS `team` (
+    `id` INT(11) PRIMARY KEY AUTO_INCREMENT,
+    `name` VARCHAR(255) NOT NULL,
+    `owner_id` INT(11) NOT NULL,
+    `created_at` DATETIME,
+    KEY `team_owner_id` (`owner_id`),
+    CONSTRAINT `team_owner` FOREIGN KEY (`owner_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE IF NOT EXISTS `team_member` (
+    `team_id` INT(11) NOT NULL,
+    `user_id` INT(11) NOT NULL,
+    PRIMARY KEY (`team_id`, `user_id`),
+    KEY `team_member_user_id` (`user_id`),
+    CONSTRAINT `team_member_team` FOREIGN KEY (`team_id`) REFERENCES `team` (`id`) ON DELETE CASCADE,
+    CONSTRAINT `team_member_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE IF NOT EXISTS `note_share` (
+    `id` INT(11) PRIMARY KEY AUTO_INCREMENT,
+    `note_id` INT(11) NOT NULL,
+    `user_id` INT(11) NULL,
+    `team_id` INT(11) NULL,
+    `role` VARCHAR(16) NOT NULL,
+    `created_at` DATETIME,
+    UNIQUE KEY `note_share_user` (`note_id`, `user_id`),
+    UNIQUE KEY `note_share_team` (`note_id`, `team_id`),
+    KEY `note_share_user_id` (`user_id`),
+    KEY `note_share_team_id` (`team_id`),
+    CONSTRAINT `note_share_note` FOREIGN KEY (`note_id`) REFERENCES `note` (`id`) ON DELETE CASCADE,
+    CONSTRAINT `note_share_users` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE,
+    CONSTRAINT `note_share_teams` FOREIGN KEY (`team_id`) REFERENCES `team` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS `note_link` (
+    `id` INT(11) PRIMARY KEY AUTO_INCREMENT,
+    `note_id` INT(11) NOT NULL,
+    `token_hash` CHAR(64) NOT NULL,
+    `password_hash` VARCHAR(255) NULL,
+    `views` BIGINT NOT NULL DEFAULT 0,
+    `expires_at` DATETIME NOT NULL,
+    `created_at` DATETIME,
+    `revoked_at` DATETIME NULL,
+    UNIQUE KEY `note_link_token_hash` (`token_hash`),
+    KEY `note_link_note_id` (`note_id`),
+    CONSTRAINT `note_link_note` FOREIGN KEY (`note_id`) REFERENCES `note` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS `access_token` (
+    `id` INT(11) PRIMARY KEY AUTO_INCREMENT,
+    `user_id` INT(11) NOT NULL,
+    `name` VARCHAR(255) NOT NULL,
+    `token_hash` CHAR(64) NOT NULL,
+    `scopes` VARCHAR(255) NOT NULL,
+    `created_at` DATETIME,
+    `last_used_at` DATETIME NULL,
+    UNIQUE KEY `access_token_token_hash` (`token_hash`),
+    UNIQUE KEY `access_token_user_name` (`user_id`, `name`),
+    CONSTRAINT `access_token_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS `login_state`;
+
+DROP TABLE IF EXISTS `user_identity`;
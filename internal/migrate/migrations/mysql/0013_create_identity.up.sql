@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS `user_identity` (
+    `id` INT(11) PRIMARY KEY AUTO_INCREMENT,
+    `user_id` INT(11) NOT NULL,
+    `issuer` VARCHAR(255) NOT NULL,
+    `subject` VARCHAR(255) NOT NULL,
+    `created_at` DATETIME,
+    UNIQUE KEY `user_identity_subject` (`issuer`, `subject`),
+    CONSTRAINT `user_identity_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+
+CREATE TABLE IF NOT EXISTS `login_state` (
+    `state_hash` CHAR(64) PRIMARY KEY,
+    `nonce` VARCHAR(255) NOT NULL,
+    `code_verifier` VARCHAR(255) NOT NULL,
+    `expires_at` DATETIME NOT NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8;
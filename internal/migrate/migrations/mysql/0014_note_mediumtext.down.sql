@@ -0,0 +1,2 @@
+ALTER TABLE `note_revision` MODIFY `text` TEXT;
+ALTER TABLE `note` MODIFY `text` TEXT;
@@ -0,0 +1,3 @@
+-- TEXT holds 65,535 bytes, less than the longest note the service accepts.
+ALTER TABLE `note` MODIFY `text` MEDIUMTEXT;
+ALTER TABLE `note_revision` MODIFY `text` MEDIUMTEXT;
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS note (
+    id BIGSERIAL PRIMARY KEY,
+    text TEXT,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
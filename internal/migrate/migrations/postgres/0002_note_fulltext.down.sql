@@ -0,0 +1 @@
+DROP INDEX IF EXISTS note_text_fulltext;
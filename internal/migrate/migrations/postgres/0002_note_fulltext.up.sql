@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS note_text_fulltext ON note USING GIN (to_tsvector('simple', COALESCE(text, '')));
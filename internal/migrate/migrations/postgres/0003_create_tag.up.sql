@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS tag (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(32) NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS note_tag (
+    note_id BIGINT NOT NULL REFERENCES note (id) ON DELETE CASCADE,
+    tag_id BIGINT NOT NULL REFERENCES tag (id) ON DELETE CASCADE,
+    PRIMARY KEY (note_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS note_tag_tag_id ON note_tag (tag_id);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS notebook (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    parent_id BIGINT NULL REFERENCES notebook (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS notebook_parent_id ON notebook (parent_id);
+
+ALTER TABLE note ADD COLUMN IF NOT EXISTS notebook_id BIGINT NULL REFERENCES notebook (id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS note_notebook_id ON note (notebook_id);
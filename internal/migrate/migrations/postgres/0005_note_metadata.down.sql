@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS note_archived_pinned;
+
+ALTER TABLE note
+    DROP COLUMN IF EXISTS archived,
+    DROP COLUMN IF EXISTS pinned,
+    DROP COLUMN IF EXISTS color,
+    DROP COLUMN IF EXISTS summary,
+    DROP COLUMN IF EXISTS title;
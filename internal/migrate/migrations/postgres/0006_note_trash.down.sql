@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS note_deleted_at;
+
+ALTER TABLE note DROP COLUMN IF EXISTS deleted_at;
@@ -0,0 +1,3 @@
+ALTER TABLE note ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ NULL;
+
+CREATE INDEX IF NOT EXISTS note_deleted_at ON note (deleted_at);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS note_revision (
+    note_id BIGINT NOT NULL REFERENCES note (id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    title VARCHAR(255) NOT NULL DEFAULT '',
+    text TEXT,
+    summary VARCHAR(1000) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ,
+    PRIMARY KEY (note_id, revision)
+);
+
+INSERT INTO note_revision (note_id, revision, title, text, summary, created_at)
+    SELECT id, 1, title, text, summary, updated_at FROM note;
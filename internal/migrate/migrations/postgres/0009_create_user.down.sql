@@ -0,0 +1,7 @@
+ALTER TABLE notebook DROP COLUMN IF EXISTS owner_id;
+
+ALTER TABLE note DROP COLUMN IF EXISTS owner_id;
+
+DROP TABLE IF EXISTS session;
+
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS users (
+    id BIGSERIAL PRIMARY KEY,
+    email VARCHAR(255) NOT NULL UNIQUE,
+    password_hash VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS session (
+    token_hash CHAR(64) PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS session_user_id ON session (user_id);
+
+ALTER TABLE note ADD COLUMN IF NOT EXISTS owner_id BIGINT NULL REFERENCES users (id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS note_owner_id ON note (owner_id);
+
+ALTER TABLE notebook ADD COLUMN IF NOT EXISTS owner_id BIGINT NULL REFERENCES users (id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS notebook_owner_id ON notebook (owner_id);
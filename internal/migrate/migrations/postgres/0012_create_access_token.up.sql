@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS access_token (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    scopes VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ NULL,
+    UNIQUE (user_id, name)
+);
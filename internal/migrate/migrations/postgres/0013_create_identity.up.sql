@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS user_identity (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    issuer VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ,
+    UNIQUE (issuer, subject)
+);
+
+CREATE TABLE IF NOT EXISTS login_state (
+    state_hash CHAR(64) PRIMARY KEY,
+    nonce VARCHAR(255) NOT NULL,
+    code_verifier VARCHAR(255) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
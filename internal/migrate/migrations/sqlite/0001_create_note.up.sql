@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS note (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    text TEXT,
+    created_at DATETIME,
+    updated_at DATETIME
+);
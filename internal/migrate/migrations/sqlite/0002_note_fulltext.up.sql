@@ -0,0 +1,19 @@
+CREATE VIRTUAL TABLE IF NOT EXISTS note_fts USING fts4(content="note", text, tokenize=unicode61);
+
+INSERT INTO note_fts(note_fts) VALUES ('rebuild');
+
+CREATE TRIGGER IF NOT EXISTS note_fts_before_update BEFORE UPDATE ON note BEGIN
+    DELETE FROM note_fts WHERE docid = old.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS note_fts_before_delete BEFORE DELETE ON note BEGIN
+    DELETE FROM note_fts WHERE docid = old.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS note_fts_after_update AFTER UPDATE ON note BEGIN
+    INSERT INTO note_fts(docid, text) VALUES (new.id, new.text);
+END;
+
+CREATE TRIGGER IF NOT EXISTS note_fts_after_insert AFTER INSERT ON note BEGIN
+    INSERT INTO note_fts(docid, text) VALUES (new.id, new.text);
+END;
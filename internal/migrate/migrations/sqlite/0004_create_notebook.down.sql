@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS note_notebook_id;
+
+ALTER TABLE note DROP COLUMN notebook_id;
+
+DROP TABLE IF EXISTS notebook;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS notebook (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    parent_id INTEGER NULL REFERENCES notebook (id) ON DELETE CASCADE,
+    created_at DATETIME,
+    updated_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS notebook_parent_id ON notebook (parent_id);
+
+ALTER TABLE note ADD COLUMN notebook_id INTEGER NULL REFERENCES notebook (id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS note_notebook_id ON note (notebook_id);
@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS note_archived_pinned;
+
+ALTER TABLE note DROP COLUMN archived;
+
+ALTER TABLE note DROP COLUMN pinned;
+
+ALTER TABLE note DROP COLUMN color;
+
+ALTER TABLE note DROP COLUMN summary;
+
+ALTER TABLE note DROP COLUMN title;
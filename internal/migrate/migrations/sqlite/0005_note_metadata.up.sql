@@ -0,0 +1,11 @@
+ALTER TABLE note ADD COLUMN title TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE note ADD COLUMN summary TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE note ADD COLUMN color TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE note ADD COLUMN pinned BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE note ADD COLUMN archived BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS note_archived_pinned ON note (archived, pinned);
@@ -0,0 +1,3 @@
+ALTER TABLE note ADD COLUMN deleted_at DATETIME NULL;
+
+CREATE INDEX IF NOT EXISTS note_deleted_at ON note (deleted_at);
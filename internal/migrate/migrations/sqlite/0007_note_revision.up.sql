@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS note_revision (
+    note_id INTEGER NOT NULL REFERENCES note (id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    title TEXT NOT NULL DEFAULT '',
+    text TEXT,
+    summary TEXT NOT NULL DEFAULT '',
+    created_at DATETIME,
+    PRIMARY KEY (note_id, revision)
+);
+
+INSERT INTO note_revision (note_id, revision, title, text, summary, created_at)
+    SELECT id, 1, title, text, summary, updated_at FROM note;
@@ -0,0 +1 @@
+ALTER TABLE note ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
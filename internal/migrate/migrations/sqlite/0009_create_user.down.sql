@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS notebook_owner_id;
+
+ALTER TABLE notebook DROP COLUMN owner_id;
+
+DROP INDEX IF EXISTS note_owner_id;
+
+ALTER TABLE note DROP COLUMN owner_id;
+
+DROP TABLE IF EXISTS session;
+
+DROP TABLE IF EXISTS users;
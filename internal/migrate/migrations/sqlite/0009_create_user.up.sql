@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    email TEXT NOT NULL UNIQUE,
+    password_hash TEXT NOT NULL,
+    created_at DATETIME
+);
+
+CREATE TABLE IF NOT EXISTS session (
+    token_hash TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at DATETIME,
+    expires_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS session_user_id ON session (user_id);
+
+ALTER TABLE note ADD COLUMN owner_id INTEGER NULL REFERENCES users (id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS note_owner_id ON note (owner_id);
+
+ALTER TABLE notebook ADD COLUMN owner_id INTEGER NULL REFERENCES users (id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS notebook_owner_id ON notebook (owner_id);
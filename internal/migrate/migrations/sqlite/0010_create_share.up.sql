@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS team (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    owner_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS team_owner_id ON team (owner_id);
+
+CREATE TABLE IF NOT EXISTS team_member (
+    team_id INTEGER NOT NULL REFERENCES team (id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    PRIMARY KEY (team_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS team_member_user_id ON team_member (user_id);
+
+CREATE TABLE IF NOT EXISTS note_share (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    note_id INTEGER NOT NULL REFERENCES note (id) ON DELETE CASCADE,
+    user_id INTEGER NULL REFERENCES users (id) ON DELETE CASCADE,
+    team_id INTEGER NULL REFERENCES team (id) ON DELETE CASCADE,
+    role TEXT NOT NULL,
+    created_at DATETIME,
+    UNIQUE (note_id, user_id),
+    UNIQUE (note_id, team_id)
+);
+
+CREATE INDEX IF NOT EXISTS note_share_user_id ON note_share (user_id);
+
+CREATE INDEX IF NOT EXISTS note_share_team_id ON note_share (team_id);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS note_link (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    note_id INTEGER NOT NULL REFERENCES note (id) ON DELETE CASCADE,
+    token_hash TEXT NOT NULL UNIQUE,
+    password_hash TEXT NULL,
+    views INTEGER NOT NULL DEFAULT 0,
+    expires_at DATETIME NOT NULL,
+    created_at DATETIME,
+    revoked_at DATETIME NULL
+);
+
+CREATE INDEX IF NOT EXISTS note_link_note_id ON note_link (note_id);
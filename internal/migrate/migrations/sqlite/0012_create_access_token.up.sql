@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS access_token (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    scopes TEXT NOT NULL,
+    created_at DATETIME,
+    last_used_at DATETIME NULL,
+    UNIQUE (user_id, name)
+);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS user_identity (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    issuer TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    created_at DATETIME,
+    UNIQUE (issuer, subject)
+);
+
+CREATE TABLE IF NOT EXISTS login_state (
+    state_hash TEXT PRIMARY KEY,
+    nonce TEXT NOT NULL,
+    code_verifier TEXT NOT NULL,
+    expires_at DATETIME NOT NULL
+);
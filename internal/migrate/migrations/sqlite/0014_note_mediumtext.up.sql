@@ -0,0 +1 @@
+-- TEXT has no length limit here, only MySQL needs a wider column.
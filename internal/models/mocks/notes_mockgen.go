@@ -36,12 +36,43 @@ func (m *MockService) EXPECT() *MockServiceMockRecorder {
 	return m.recorder
 }
 
+// AddMember mocks base method.
+func (m *MockService) AddMember(arg0 context.Context, arg1 service.AddMember) (*models.Team, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddMember", arg0, arg1)
+	ret0, _ := ret[0].(*models.Team)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddMember indicates an expected call of AddMember.
+func (mr *MockServiceMockRecorder) AddMember(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddMember", reflect.TypeOf((*MockService)(nil).AddMember), arg0, arg1)
+}
+
+// Batch mocks base method.
+func (m *MockService) Batch(arg0 context.Context, arg1 service.Batch) (*service.BatchResults, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Batch", arg0, arg1)
+	ret0, _ := ret[0].(*service.BatchResults)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Batch indicates an expected call of Batch.
+func (mr *MockServiceMockRecorder) Batch(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Batch", reflect.TypeOf((*MockService)(nil).Batch), arg0, arg1)
+}
+
 // Create mocks base method.
-func (m *MockService) Create(arg0 context.Context, arg1 service.CreateNote) error {
+func (m *MockService) Create(arg0 context.Context, arg1 service.CreateNote) (*models.Note, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Create", arg0, arg1)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret0, _ := ret[0].(*models.Note)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // Create indicates an expected call of Create.
@@ -50,6 +81,51 @@ func (mr *MockServiceMockRecorder) Create(arg0, arg1 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockService)(nil).Create), arg0, arg1)
 }
 
+// CreateLink mocks base method.
+func (m *MockService) CreateLink(arg0 context.Context, arg1 service.CreateLink) (*models.Link, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateLink", arg0, arg1)
+	ret0, _ := ret[0].(*models.Link)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateLink indicates an expected call of CreateLink.
+func (mr *MockServiceMockRecorder) CreateLink(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateLink", reflect.TypeOf((*MockService)(nil).CreateLink), arg0, arg1)
+}
+
+// CreateNotebook mocks base method.
+func (m *MockService) CreateNotebook(arg0 context.Context, arg1 service.CreateNotebook) (*models.Notebook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateNotebook", arg0, arg1)
+	ret0, _ := ret[0].(*models.Notebook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateNotebook indicates an expected call of CreateNotebook.
+func (mr *MockServiceMockRecorder) CreateNotebook(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateNotebook", reflect.TypeOf((*MockService)(nil).CreateNotebook), arg0, arg1)
+}
+
+// CreateTeam mocks base method.
+func (m *MockService) CreateTeam(arg0 context.Context, arg1 service.CreateTeam) (*models.Team, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateTeam", arg0, arg1)
+	ret0, _ := ret[0].(*models.Team)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateTeam indicates an expected call of CreateTeam.
+func (mr *MockServiceMockRecorder) CreateTeam(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateTeam", reflect.TypeOf((*MockService)(nil).CreateTeam), arg0, arg1)
+}
+
 // Delete mocks base method.
 func (m *MockService) Delete(arg0 context.Context, arg1 service.DeleteNote) error {
 	m.ctrl.T.Helper()
@@ -64,6 +140,63 @@ func (mr *MockServiceMockRecorder) Delete(arg0, arg1 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockService)(nil).Delete), arg0, arg1)
 }
 
+// DeleteNotebook mocks base method.
+func (m *MockService) DeleteNotebook(arg0 context.Context, arg1 service.DeleteNotebook) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteNotebook", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteNotebook indicates an expected call of DeleteNotebook.
+func (mr *MockServiceMockRecorder) DeleteNotebook(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteNotebook", reflect.TypeOf((*MockService)(nil).DeleteNotebook), arg0, arg1)
+}
+
+// DeleteShare mocks base method.
+func (m *MockService) DeleteShare(arg0 context.Context, arg1 service.DeleteShare) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteShare", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteShare indicates an expected call of DeleteShare.
+func (mr *MockServiceMockRecorder) DeleteShare(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteShare", reflect.TypeOf((*MockService)(nil).DeleteShare), arg0, arg1)
+}
+
+// DeleteTeam mocks base method.
+func (m *MockService) DeleteTeam(arg0 context.Context, arg1 service.DeleteTeam) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteTeam", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteTeam indicates an expected call of DeleteTeam.
+func (mr *MockServiceMockRecorder) DeleteTeam(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteTeam", reflect.TypeOf((*MockService)(nil).DeleteTeam), arg0, arg1)
+}
+
+// DiffRevisions mocks base method.
+func (m *MockService) DiffRevisions(arg0 context.Context, arg1 service.DiffRevisions) (*service.Diff, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DiffRevisions", arg0, arg1)
+	ret0, _ := ret[0].(*service.Diff)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DiffRevisions indicates an expected call of DiffRevisions.
+func (mr *MockServiceMockRecorder) DiffRevisions(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DiffRevisions", reflect.TypeOf((*MockService)(nil).DiffRevisions), arg0, arg1)
+}
+
 // Get mocks base method.
 func (m *MockService) Get(arg0 context.Context, arg1 service.GetNote) (*models.Note, error) {
 	m.ctrl.T.Helper()
@@ -80,10 +213,10 @@ func (mr *MockServiceMockRecorder) Get(arg0, arg1 interface{}) *gomock.Call {
 }
 
 // GetAll mocks base method.
-func (m *MockService) GetAll(arg0 context.Context, arg1 service.GetNotes) ([]*models.Note, error) {
+func (m *MockService) GetAll(arg0 context.Context, arg1 service.GetNotes) (*service.NotesPage, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetAll", arg0, arg1)
-	ret0, _ := ret[0].([]*models.Note)
+	ret0, _ := ret[0].(*service.NotesPage)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -94,16 +227,327 @@ func (mr *MockServiceMockRecorder) GetAll(arg0, arg1 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAll", reflect.TypeOf((*MockService)(nil).GetAll), arg0, arg1)
 }
 
-// Update mocks base method.
-func (m *MockService) Update(arg0 context.Context, arg1 service.UpdateNote) error {
+// GetNotebook mocks base method.
+func (m *MockService) GetNotebook(arg0 context.Context, arg1 service.GetNotebook) (*models.Notebook, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Update", arg0, arg1)
+	ret := m.ctrl.Call(m, "GetNotebook", arg0, arg1)
+	ret0, _ := ret[0].(*models.Notebook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetNotebook indicates an expected call of GetNotebook.
+func (mr *MockServiceMockRecorder) GetNotebook(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNotebook", reflect.TypeOf((*MockService)(nil).GetNotebook), arg0, arg1)
+}
+
+// GetNotebookContents mocks base method.
+func (m *MockService) GetNotebookContents(arg0 context.Context, arg1 service.GetNotebookContents) (*service.NotebookContents, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetNotebookContents", arg0, arg1)
+	ret0, _ := ret[0].(*service.NotebookContents)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetNotebookContents indicates an expected call of GetNotebookContents.
+func (mr *MockServiceMockRecorder) GetNotebookContents(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetNotebookContents", reflect.TypeOf((*MockService)(nil).GetNotebookContents), arg0, arg1)
+}
+
+// GetTeam mocks base method.
+func (m *MockService) GetTeam(arg0 context.Context, arg1 service.GetTeam) (*models.Team, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTeam", arg0, arg1)
+	ret0, _ := ret[0].(*models.Team)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTeam indicates an expected call of GetTeam.
+func (mr *MockServiceMockRecorder) GetTeam(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTeam", reflect.TypeOf((*MockService)(nil).GetTeam), arg0, arg1)
+}
+
+// Links mocks base method.
+func (m *MockService) Links(arg0 context.Context, arg1 service.GetLinks) (*service.LinkList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Links", arg0, arg1)
+	ret0, _ := ret[0].(*service.LinkList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Links indicates an expected call of Links.
+func (mr *MockServiceMockRecorder) Links(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Links", reflect.TypeOf((*MockService)(nil).Links), arg0, arg1)
+}
+
+// MoveNote mocks base method.
+func (m *MockService) MoveNote(arg0 context.Context, arg1 service.MoveNote) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MoveNote", arg0, arg1)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
+// MoveNote indicates an expected call of MoveNote.
+func (mr *MockServiceMockRecorder) MoveNote(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MoveNote", reflect.TypeOf((*MockService)(nil).MoveNote), arg0, arg1)
+}
+
+// Notebooks mocks base method.
+func (m *MockService) Notebooks(arg0 context.Context) (*service.NotebookList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Notebooks", arg0)
+	ret0, _ := ret[0].(*service.NotebookList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Notebooks indicates an expected call of Notebooks.
+func (mr *MockServiceMockRecorder) Notebooks(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Notebooks", reflect.TypeOf((*MockService)(nil).Notebooks), arg0)
+}
+
+// Patch mocks base method.
+func (m *MockService) Patch(arg0 context.Context, arg1 service.PatchNote) (*models.Note, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Patch", arg0, arg1)
+	ret0, _ := ret[0].(*models.Note)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Patch indicates an expected call of Patch.
+func (mr *MockServiceMockRecorder) Patch(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Patch", reflect.TypeOf((*MockService)(nil).Patch), arg0, arg1)
+}
+
+// Purge mocks base method.
+func (m *MockService) Purge(arg0 context.Context, arg1 service.PurgeNote) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Purge", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Purge indicates an expected call of Purge.
+func (mr *MockServiceMockRecorder) Purge(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Purge", reflect.TypeOf((*MockService)(nil).Purge), arg0, arg1)
+}
+
+// RemoveMember mocks base method.
+func (m *MockService) RemoveMember(arg0 context.Context, arg1 service.RemoveMember) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveMember", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveMember indicates an expected call of RemoveMember.
+func (mr *MockServiceMockRecorder) RemoveMember(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveMember", reflect.TypeOf((*MockService)(nil).RemoveMember), arg0, arg1)
+}
+
+// Restore mocks base method.
+func (m *MockService) Restore(arg0 context.Context, arg1 service.RestoreNote) (*models.Note, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Restore", arg0, arg1)
+	ret0, _ := ret[0].(*models.Note)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Restore indicates an expected call of Restore.
+func (mr *MockServiceMockRecorder) Restore(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restore", reflect.TypeOf((*MockService)(nil).Restore), arg0, arg1)
+}
+
+// RestoreRevision mocks base method.
+func (m *MockService) RestoreRevision(arg0 context.Context, arg1 service.RestoreRevision) (*models.Note, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreRevision", arg0, arg1)
+	ret0, _ := ret[0].(*models.Note)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RestoreRevision indicates an expected call of RestoreRevision.
+func (mr *MockServiceMockRecorder) RestoreRevision(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreRevision", reflect.TypeOf((*MockService)(nil).RestoreRevision), arg0, arg1)
+}
+
+// Revision mocks base method.
+func (m *MockService) Revision(arg0 context.Context, arg1 service.GetRevision) (*models.Revision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Revision", arg0, arg1)
+	ret0, _ := ret[0].(*models.Revision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Revision indicates an expected call of Revision.
+func (mr *MockServiceMockRecorder) Revision(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Revision", reflect.TypeOf((*MockService)(nil).Revision), arg0, arg1)
+}
+
+// Revisions mocks base method.
+func (m *MockService) Revisions(arg0 context.Context, arg1 service.GetRevisions) (*service.RevisionList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Revisions", arg0, arg1)
+	ret0, _ := ret[0].(*service.RevisionList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Revisions indicates an expected call of Revisions.
+func (mr *MockServiceMockRecorder) Revisions(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Revisions", reflect.TypeOf((*MockService)(nil).Revisions), arg0, arg1)
+}
+
+// RevokeLink mocks base method.
+func (m *MockService) RevokeLink(arg0 context.Context, arg1 service.RevokeLink) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeLink", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeLink indicates an expected call of RevokeLink.
+func (mr *MockServiceMockRecorder) RevokeLink(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeLink", reflect.TypeOf((*MockService)(nil).RevokeLink), arg0, arg1)
+}
+
+// Search mocks base method.
+func (m *MockService) Search(arg0 context.Context, arg1 service.SearchNotes) (*service.SearchResults, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Search", arg0, arg1)
+	ret0, _ := ret[0].(*service.SearchResults)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Search indicates an expected call of Search.
+func (mr *MockServiceMockRecorder) Search(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Search", reflect.TypeOf((*MockService)(nil).Search), arg0, arg1)
+}
+
+// ShareNote mocks base method.
+func (m *MockService) ShareNote(arg0 context.Context, arg1 service.ShareNote) (*models.Share, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ShareNote", arg0, arg1)
+	ret0, _ := ret[0].(*models.Share)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ShareNote indicates an expected call of ShareNote.
+func (mr *MockServiceMockRecorder) ShareNote(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ShareNote", reflect.TypeOf((*MockService)(nil).ShareNote), arg0, arg1)
+}
+
+// Shares mocks base method.
+func (m *MockService) Shares(arg0 context.Context, arg1 service.GetShares) (*service.ShareList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Shares", arg0, arg1)
+	ret0, _ := ret[0].(*service.ShareList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Shares indicates an expected call of Shares.
+func (mr *MockServiceMockRecorder) Shares(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Shares", reflect.TypeOf((*MockService)(nil).Shares), arg0, arg1)
+}
+
+// Tags mocks base method.
+func (m *MockService) Tags(arg0 context.Context) (*service.TagList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Tags", arg0)
+	ret0, _ := ret[0].(*service.TagList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Tags indicates an expected call of Tags.
+func (mr *MockServiceMockRecorder) Tags(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Tags", reflect.TypeOf((*MockService)(nil).Tags), arg0)
+}
+
+// Teams mocks base method.
+func (m *MockService) Teams(arg0 context.Context) (*service.TeamList, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Teams", arg0)
+	ret0, _ := ret[0].(*service.TeamList)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Teams indicates an expected call of Teams.
+func (mr *MockServiceMockRecorder) Teams(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Teams", reflect.TypeOf((*MockService)(nil).Teams), arg0)
+}
+
+// Trash mocks base method.
+func (m *MockService) Trash(arg0 context.Context, arg1 service.GetNotes) (*service.NotesPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Trash", arg0, arg1)
+	ret0, _ := ret[0].(*service.NotesPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Trash indicates an expected call of Trash.
+func (mr *MockServiceMockRecorder) Trash(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Trash", reflect.TypeOf((*MockService)(nil).Trash), arg0, arg1)
+}
+
+// Update mocks base method.
+func (m *MockService) Update(arg0 context.Context, arg1 service.UpdateNote) (*models.Note, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Update", arg0, arg1)
+	ret0, _ := ret[0].(*models.Note)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
 // Update indicates an expected call of Update.
 func (mr *MockServiceMockRecorder) Update(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Update", reflect.TypeOf((*MockService)(nil).Update), arg0, arg1)
 }
+
+// UpdateNotebook mocks base method.
+func (m *MockService) UpdateNotebook(arg0 context.Context, arg1 service.UpdateNotebook) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateNotebook", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateNotebook indicates an expected call of UpdateNotebook.
+func (mr *MockServiceMockRecorder) UpdateNotebook(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateNotebook", reflect.TypeOf((*MockService)(nil).UpdateNotebook), arg0, arg1)
+}
@@ -3,8 +3,119 @@ package models
 import "time"
 
 type Note struct {
+	ID         int64     `json:"id"`
+	Title      string    `json:"title"`
+	Text       string    `json:"text"`
+	Summary    string    `json:"summary"`
+	Tags       []string  `json:"tags"`
+	Color      string    `json:"color"`
+	Pinned     bool      `json:"pinned"`
+	Archived   bool      `json:"archived"`
+	NotebookID *int64    `json:"notebookId"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+	// Version starts at 1 and grows with every change of the note.
+	Version int64 `json:"version"`
+	// DeletedAt is set while the note is in the trash.
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+}
+
+type Tag struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// Notebook groups notes. Notebooks nest; ParentID is nil at the top level.
+type Notebook struct {
 	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	ParentID  *int64    `json:"parentId"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Revision is the content of a note as one create or update saved it.
+// Revisions of a note are numbered from 1.
+type Revision struct {
+	NoteID    int64     `json:"noteId"`
+	Revision  int       `json:"revision"`
+	Title     string    `json:"title"`
+	Text      string    `json:"text"`
+	Summary   string    `json:"summary"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// PublicNote is what a public link shows of a note: its content, without
+// the id, notebook, version and other fields of its owner.
+type PublicNote struct {
+	Title     string    `json:"title"`
 	Text      string    `json:"text"`
+	Summary   string    `json:"summary"`
+	Tags      []string  `json:"tags"`
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 }
+
+// User owns notes and notebooks. PasswordHash is a bcrypt hash and never
+// leaves the server.
+type User struct {
+	ID           int64     `json:"id"`
+	Email        string    `json:"email"`
+	PasswordHash string    `json:"-"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// AccessToken lets a script act for a user without logging in, as far as
+// its scopes allow. Token is only set when the token is created.
+type AccessToken struct {
+	ID         int64      `json:"id"`
+	UserID     int64      `json:"-"`
+	Name       string     `json:"name"`
+	Token      string     `json:"token,omitempty"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+}
+
+// Share gives a user, or every member of a team, a role on a note: editor
+// or viewer. Email and Team name whom the note is shared with.
+type Share struct {
+	ID        int64     `json:"id"`
+	NoteID    int64     `json:"noteId"`
+	UserID    *int64    `json:"userId,omitempty"`
+	Email     string    `json:"email,omitempty"`
+	TeamID    *int64    `json:"teamId,omitempty"`
+	Team      string    `json:"team,omitempty"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Team is a group of users notes can be shared with at once. Its owner is
+// a member too and the only one who manages it.
+type Team struct {
+	ID        int64         `json:"id"`
+	Name      string        `json:"name"`
+	OwnerID   int64         `json:"ownerId"`
+	CreatedAt time.Time     `json:"createdAt"`
+	Members   []*TeamMember `json:"members,omitempty"`
+}
+
+type TeamMember struct {
+	UserID int64  `json:"userId"`
+	Email  string `json:"email"`
+}
+
+// Link is a public read-only link to a note, opened at /s/{token} without
+// signing in. Only the hash of the token is stored, so Token is set once,
+// when the link is created. Protected links also ask for a password.
+type Link struct {
+	ID           int64      `json:"id"`
+	NoteID       int64      `json:"noteId"`
+	Token        string     `json:"token,omitempty"`
+	PasswordHash string     `json:"-"`
+	Protected    bool       `json:"protected"`
+	Views        int64      `json:"views"`
+	ExpiresAt    time.Time  `json:"expiresAt"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	RevokedAt    *time.Time `json:"revokedAt"`
+}
@@ -0,0 +1,46 @@
+package service
+
+import "context"
+
+// Roles a user can have on a note. The owner may do anything with it, an
+// editor may change its content and a viewer may only read it. Notes are
+// shared with editors and viewers; notebooks and the trash stay personal.
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+var roleRank = map[string]int{RoleViewer: 1, RoleEditor: 2, RoleOwner: 3}
+
+// Grants tells whether a user with role may do what needs the role need.
+func Grants(role, need string) bool {
+	return roleRank[role] > 0 && roleRank[role] >= roleRank[need]
+}
+
+type roleKey struct{}
+
+// WithRole sets the role the user of ctx needs on the notes a storage call
+// reaches; other notes are not found, or ErrForbidden for a write to a note
+// the user may still see. The service sets it for every call, so the rules
+// of who may do what live in one place.
+func WithRole(ctx context.Context, role string) context.Context {
+	return context.WithValue(ctx, roleKey{}, role)
+}
+
+// RoleFrom returns the role set with WithRole, RoleOwner without one.
+func RoleFrom(ctx context.Context) string {
+	if role, ok := ctx.Value(roleKey{}).(string); ok && role != "" {
+		return role
+	}
+
+	return RoleOwner
+}
+
+func reading(ctx context.Context) context.Context {
+	return WithRole(ctx, RoleViewer)
+}
+
+func editing(ctx context.Context) context.Context {
+	return WithRole(ctx, RoleEditor)
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/mail"
+	"note/internal/models"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	maxEmailLength    = 255
+	minPasswordLength = 8
+	// bcrypt ignores whatever follows the 72nd byte.
+	maxPasswordLength = 72
+	tokenBytes        = 32
+)
+
+// Session is a token to authenticate requests with. Only its hash is
+// stored, so the token is shown once, when the user logs in.
+type Session struct {
+	Token     string       `json:"token"`
+	ExpiresAt time.Time    `json:"expiresAt"`
+	User      *models.User `json:"user"`
+}
+
+type authService struct {
+	storage UserStorage
+	ttl     time.Duration
+	// unknownHash is checked when the email isn't registered, so that a
+	// login takes as long whether the user exists or not.
+	unknownHash []byte
+}
+
+func NewAuthService(storage UserStorage, sessionTTL time.Duration) *authService {
+	unknownHash, _ := bcrypt.GenerateFromPassword([]byte("unknown user"), bcrypt.DefaultCost)
+
+	return &authService{storage: storage, ttl: sessionTTL, unknownHash: unknownHash}
+}
+
+// Register creates a user. The email is stored lower-cased.
+func (s *authService) Register(ctx context.Context, dto Credentials) (*models.User, error) {
+	email := normalizeEmail(dto.Email)
+
+	if err := validate(checkEmail(email), checkPassword(dto.Password)); err != nil {
+		return nil, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(dto.Password), bcrypt.DefaultCost)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.storage.CreateUser(ctx, email, string(hash))
+}
+
+// Login checks the password and starts a session. A wrong email and a wrong
+// password are the same ErrUnauthorized.
+func (s *authService) Login(ctx context.Context, dto Credentials) (*Session, error) {
+	email := normalizeEmail(dto.Email)
+	checks := []*FieldError{}
+
+	if email == "" {
+		checks = append(checks, &FieldError{Field: "email", Reason: "must not be empty"})
+	}
+
+	if dto.Password == "" {
+		checks = append(checks, &FieldError{Field: "password", Reason: "must not be empty"})
+	}
+
+	if err := validate(checks...); err != nil {
+		return nil, err
+	}
+
+	user, err := s.storage.UserByEmail(ctx, email)
+
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	hash := s.unknownHash
+
+	if user != nil {
+		hash = []byte(user.PasswordHash)
+	}
+
+	if bcrypt.CompareHashAndPassword(hash, []byte(dto.Password)) != nil || user == nil {
+		return nil, fmt.Errorf("%w: wrong email or password", ErrUnauthorized)
+	}
+
+	return s.startSession(ctx, user)
+}
+
+func (s *authService) startSession(ctx context.Context, user *models.User) (*Session, error) {
+	token, err := newToken()
+
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().UTC().Add(s.ttl).Truncate(time.Second)
+
+	if err = s.storage.CreateSession(ctx, hashToken(token), user.ID, expiresAt); err != nil {
+		return nil, err
+	}
+
+	return &Session{Token: token, ExpiresAt: expiresAt, User: user}, nil
+}
+
+// Logout ends the session of the token.
+func (s *authService) Logout(ctx context.Context, token string) error {
+	err := s.storage.DeleteSession(ctx, hashToken(token))
+
+	if errors.Is(err, ErrNotFound) {
+		return fmt.Errorf("%w: the session has ended", ErrUnauthorized)
+	}
+
+	return err
+}
+
+// Authenticate finds the user of a session token.
+func (s *authService) Authenticate(ctx context.Context, token string) (*models.User, error) {
+	if token == "" {
+		return nil, fmt.Errorf("%w: no session token", ErrUnauthorized)
+	}
+
+	user, err := s.storage.SessionUser(ctx, hashToken(token), time.Now().UTC())
+
+	if errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("%w: the session token is invalid or expired", ErrUnauthorized)
+	}
+
+	return user, err
+}
+
+func newToken() (string, error) {
+	b := make([]byte, tokenBytes)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken is what storages keep of a token. Tokens are random, so a plain
+// SHA-256 is enough to make a leaked table useless.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func checkEmail(email string) *FieldError {
+	address, err := mail.ParseAddress(email)
+
+	switch {
+	case email == "":
+		return &FieldError{Field: "email", Reason: "must not be empty"}
+	case len(email) > maxEmailLength:
+		return &FieldError{Field: "email", Reason: fmt.Sprintf("must be at most %d characters", maxEmailLength)}
+	case err != nil || address.Address != email:
+		return &FieldError{Field: "email", Reason: fmt.Sprintf("must be an email address, got %q", email)}
+	}
+
+	return nil
+}
+
+func checkPassword(password string) *FieldError {
+	if len([]rune(password)) < minPasswordLength || len(password) > maxPasswordLength {
+		return &FieldError{Field: "password", Reason: fmt.Sprintf("must be from %d characters to %d bytes", minPasswordLength, maxPasswordLength)}
+	}
+
+	return nil
+}
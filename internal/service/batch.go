@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"note/internal/models"
+)
+
+// Operations of a batch.
+const (
+	BatchCreate = "create"
+	BatchUpdate = "update"
+	BatchDelete = "delete"
+)
+
+// Modes of a batch: an atomic batch is applied entirely or not at all, a
+// partial one keeps the operations that succeed.
+const (
+	BatchAtomic  = "atomic"
+	BatchPartial = "partial"
+)
+
+const maxBatch = 1000
+
+// ErrBatchAborted is the result of the operations of an atomic batch that
+// weren't applied because another one failed.
+var ErrBatchAborted = errors.New("batch aborted")
+
+// BatchStorage runs many writes of notes in one transaction.
+type BatchStorage interface {
+	// Batch runs the operations in order and reports the result of each.
+	// When atomic, the first failure rolls back the batch: its result holds
+	// the error and every other one ErrBatchAborted. Otherwise a failed
+	// operation is undone alone and the others are committed. The error is
+	// for a batch that couldn't run at all.
+	Batch(ctx context.Context, operations []NoteOperation, atomic bool) ([]OperationResult, error)
+}
+
+// NoteOperation is a validated operation of a batch. Fields are unused by
+// BatchDelete but for IfVersion. Role is the role the operation needs on the
+// note, for the storage to apply it with WithRole.
+type NoteOperation struct {
+	Op     string
+	ID     string
+	Role   string
+	Fields NoteFields
+}
+
+// OperationResult has the created note of a BatchCreate or the error that
+// failed an operation.
+type OperationResult struct {
+	Note *models.Note
+	Err  error
+}
+
+// BatchResults has a result for every operation of a batch. Committed
+// tells whether anything was saved.
+type BatchResults struct {
+	Mode      string
+	Committed bool
+	Results   []OperationResult
+}
+
+// Batch validates every operation, then runs the valid ones in one
+// transaction. An atomic batch with an invalid operation doesn't reach the
+// storage.
+func (s *service) Batch(ctx context.Context, dto Batch) (*BatchResults, error) {
+	mode := dto.Mode
+
+	if mode == "" {
+		mode = BatchAtomic
+	}
+
+	if err := validate(checkBatch(mode, len(dto.Operations))); err != nil {
+		return nil, err
+	}
+
+	results := make([]OperationResult, len(dto.Operations))
+	operations := make([]NoteOperation, 0, len(dto.Operations))
+	index := make([]int, 0, len(dto.Operations))
+
+	for i, op := range dto.Operations {
+		operation, err := s.prepare(ctx, op)
+
+		if err != nil {
+			results[i].Err = fmt.Errorf("operations[%d]: %w", i, err)
+
+			continue
+		}
+
+		operations = append(operations, operation)
+		index = append(index, i)
+	}
+
+	batch := &BatchResults{Mode: mode, Results: results}
+
+	if mode == BatchAtomic && len(operations) < len(results) {
+		for i := range results {
+			if results[i].Err == nil {
+				results[i].Err = ErrBatchAborted
+			}
+		}
+
+		return batch, nil
+	}
+
+	if len(operations) == 0 {
+		return batch, nil
+	}
+
+	stored, err := s.storage.Batch(ctx, operations, mode == BatchAtomic)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i, result := range stored {
+		if result.Err != nil && !errors.Is(result.Err, ErrBatchAborted) {
+			result.Err = fmt.Errorf("operations[%d]: %w", index[i], result.Err)
+		}
+
+		results[index[i]] = result
+		batch.Committed = batch.Committed || result.Err == nil
+	}
+
+	return batch, nil
+}
+
+// prepare validates an operation of a batch like the request doing it alone.
+func (s *service) prepare(ctx context.Context, op BatchOperation) (NoteOperation, error) {
+	id := op.ID.String()
+
+	switch op.Op {
+	case BatchCreate:
+		dto := CreateNote{}
+
+		if err := decodeNote(op, &dto); err != nil {
+			return NoteOperation{}, err
+		}
+
+		fields, err := s.createFields(ctx, dto)
+
+		return NoteOperation{Op: op.Op, Role: RoleOwner, Fields: fields}, err
+	case BatchUpdate:
+		dto := UpdateNote{}
+
+		if err := decodeNote(op, &dto); err != nil {
+			return NoteOperation{}, err
+		}
+
+		dto.ID, dto.IfVersion = id, op.IfVersion
+		fields, err := updateFields(dto)
+
+		return NoteOperation{Op: op.Op, ID: id, Role: RoleEditor, Fields: fields}, err
+	case BatchDelete:
+		err := validate(checkID(id))
+
+		return NoteOperation{Op: op.Op, ID: id, Role: RoleOwner, Fields: NoteFields{IfVersion: op.IfVersion}}, err
+	}
+
+	return NoteOperation{}, validate(&FieldError{Field: "op", Reason: fmt.Sprintf("must be %s, %s or %s, got %q", BatchCreate, BatchUpdate, BatchDelete, op.Op)})
+}
+
+func decodeNote(op BatchOperation, dto interface{}) error {
+	if op.Note == nil {
+		return validate(&FieldError{Field: "note", Reason: "is required for " + op.Op})
+	}
+
+	if err := decodeJSON(op.Note, dto); err != nil {
+		return validate(&FieldError{Field: "note", Reason: err.Error()})
+	}
+
+	return nil
+}
+
+func checkBatch(mode string, size int) *FieldError {
+	switch {
+	case mode != BatchAtomic && mode != BatchPartial:
+		return &FieldError{Field: "mode", Reason: fmt.Sprintf("must be %s or %s, got %q", BatchAtomic, BatchPartial, mode)}
+	case size == 0 || size > maxBatch:
+		return &FieldError{Field: "operations", Reason: fmt.Sprintf("must have from 1 to %d operations, got %d", maxBatch, size)}
+	}
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package service
+
+// maxEditDistance bounds the search of editScript, whose trace grows with
+// the square of the edit distance. Texts further apart are shown as
+// replaced whole, which is what such a diff amounts to anyway.
+const maxEditDistance = 1000
+
+// diffLines returns the shortest edit script turning lines a into lines b.
+// Common leading and trailing lines are matched first, which keeps the
+// search small for the usual edit in the middle of a note.
+func diffLines(a, b []string) []DiffLine {
+	prefix := 0
+
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	lines := make([]DiffLine, 0, len(a)+len(b)-prefix-suffix)
+
+	for _, line := range a[:prefix] {
+		lines = append(lines, DiffLine{Op: OpEqual, Text: line})
+	}
+
+	lines = append(lines, editScript(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+
+	for _, line := range a[len(a)-suffix:] {
+		lines = append(lines, DiffLine{Op: OpEqual, Text: line})
+	}
+
+	return lines
+}
+
+// editScript is Myers' O((N+M)D) difference algorithm. trace keeps the
+// furthest reaching x of every diagonal k in -d..d after each step d, which
+// is what walking back from the end needs. Past maxEditDistance it gives up
+// and replaces a with b.
+func editScript(a, b []string) []DiffLine {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	trace := [][]int{}
+
+	for d := 0; d <= n+m && d <= maxEditDistance; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+
+			y := x - k
+
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				return backtrack(a, b, append(trace, append([]int{}, v[offset-d:offset+d+1]...)))
+			}
+		}
+
+		trace = append(trace, append([]int{}, v[offset-d:offset+d+1]...))
+	}
+
+	return replace(a, b)
+}
+
+// replace is the edit script deleting all of a and inserting all of b.
+func replace(a, b []string) []DiffLine {
+	lines := make([]DiffLine, 0, len(a)+len(b))
+
+	for _, line := range a {
+		lines = append(lines, DiffLine{Op: OpDelete, Text: line})
+	}
+
+	for _, line := range b {
+		lines = append(lines, DiffLine{Op: OpInsert, Text: line})
+	}
+
+	return lines
+}
+
+func backtrack(a, b []string, trace [][]int) []DiffLine {
+	x, y := len(a), len(b)
+	reversed := []DiffLine{}
+
+	for d := len(trace) - 1; d > 0; d-- {
+		// trace[d-1] covers the diagonals -(d-1)..d-1.
+		prev := trace[d-1]
+		k := x - y
+		prevK := k - 1
+
+		if k == -d || (k != d && prev[k-1+d-1] < prev[k+1+d-1]) {
+			prevK = k + 1
+		}
+
+		prevX := prev[prevK+d-1]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			reversed = append(reversed, DiffLine{Op: OpEqual, Text: a[x-1]})
+			x--
+			y--
+		}
+
+		if prevK == k+1 {
+			reversed = append(reversed, DiffLine{Op: OpInsert, Text: b[y-1]})
+		} else {
+			reversed = append(reversed, DiffLine{Op: OpDelete, Text: a[x-1]})
+		}
+
+		x, y = prevX, prevY
+	}
+
+	for ; x > 0; x-- {
+		reversed = append(reversed, DiffLine{Op: OpEqual, Text: a[x-1]})
+	}
+
+	lines := make([]DiffLine, 0, len(reversed))
+
+	for i := len(reversed) - 1; i >= 0; i-- {
+		lines = append(lines, reversed[i])
+	}
+
+	return lines
+}
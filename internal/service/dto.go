@@ -1,22 +1,239 @@
 package service
 
+import "encoding/json"
+
 type CreateNote struct {
-	Text string `json:"text"`
+	Title      string   `json:"title"`
+	Text       string   `json:"text"`
+	Summary    string   `json:"summary"`
+	Tags       []string `json:"tags"`
+	Color      string   `json:"color"`
+	Pinned     bool     `json:"pinned"`
+	Archived   bool     `json:"archived"`
+	NotebookID *int64   `json:"notebookId"`
 }
 
+// UpdateNote replaces the text. Tags and the other fields are replaced when
+// present and kept when omitted; an empty tag list removes the tags.
+// IfVersion, when not 0, is the version the note must still have.
 type UpdateNote struct {
-	ID   string `json:"id"`
-	Text string `json:"text"`
+	ID        string   `json:"id"`
+	Title     *string  `json:"title"`
+	Text      string   `json:"text"`
+	Summary   *string  `json:"summary"`
+	Tags      []string `json:"tags"`
+	Color     *string  `json:"color"`
+	Pinned    *bool    `json:"pinned"`
+	Archived  *bool    `json:"archived"`
+	IfVersion int64    `json:"-"`
+}
+
+// PatchNote changes a note with Patch, a document of media type Type:
+// MergePatch or JSONPatch. IfVersion, when not 0, is the version the patch
+// must be applied to.
+type PatchNote struct {
+	ID        string
+	Type      string
+	Patch     []byte
+	IfVersion int64
+}
+
+// Batch is a list of writes of notes to run in one transaction. Mode is
+// BatchAtomic, the default, or BatchPartial.
+type Batch struct {
+	Mode       string           `json:"mode"`
+	Operations []BatchOperation `json:"operations"`
+}
+
+// BatchOperation is BatchCreate, BatchUpdate or BatchDelete. Note holds the
+// body the operation would have alone: CreateNote or UpdateNote. ID, a
+// number or a string, and IfVersion are for updates and deletes.
+type BatchOperation struct {
+	Op        string          `json:"op"`
+	ID        json.Number     `json:"id"`
+	IfVersion int64           `json:"ifVersion"`
+	Note      json.RawMessage `json:"note"`
 }
 
 type DeleteNote struct {
-	ID string `json:"id"`
+	ID        string `json:"id"`
+	IfVersion int64  `json:"-"`
+}
+
+type RestoreNote struct {
+	ID string
+}
+
+type PurgeNote struct {
+	ID string
 }
 
 type GetNote struct {
 	ID string `json:"id"`
 }
 
+type GetRevisions struct {
+	ID string
+}
+
+type GetRevision struct {
+	ID  string
+	Rev string
+}
+
+// DiffRevisions compares revision Rev of a note with revision To, or with
+// the latest one when To is empty.
+type DiffRevisions struct {
+	ID  string
+	Rev string
+	To  string
+}
+
+// RestoreRevision saves the title, text and summary of a revision as the
+// new content of the note.
+type RestoreRevision struct {
+	ID  string
+	Rev string
+}
+
 type GetNotes struct {
-	OrderBy string
+	OrderBy  string
+	Limit    string
+	Cursor   string
+	Tags     []string
+	TagMode  string
+	Pinned   string
+	Archived string
+	Color    string
+}
+
+type SearchNotes struct {
+	Query string
+	Limit string
+}
+
+// MoveNote puts a note into a notebook, or to the top level when
+// NotebookID is nil.
+type MoveNote struct {
+	ID         string `json:"id"`
+	NotebookID *int64 `json:"notebookId"`
+}
+
+type CreateNotebook struct {
+	Name     string `json:"name"`
+	ParentID *int64 `json:"parentId"`
+}
+
+// UpdateNotebook renames a notebook and moves it under ParentID, or to the
+// top level when ParentID is nil.
+type UpdateNotebook struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	ParentID *int64 `json:"parentId"`
+}
+
+type DeleteNotebook struct {
+	ID        string
+	Recursive bool
+}
+
+type GetNotebook struct {
+	ID string
+}
+
+type GetNotebookContents struct {
+	ID        string
+	Recursive bool
+	Notes     GetNotes
+}
+
+// Credentials register a user or log one in.
+type Credentials struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// FinishLogin is the callback of the identity provider: State, Code or
+// Error from the query and BrowserState from the cookie of the login.
+type FinishLogin struct {
+	State            string
+	Code             string
+	Error            string
+	ErrorDescription string
+	BrowserState     string
+}
+
+// CreateToken makes a personal access token with Scopes, notes:read and
+// notes:write.
+type CreateToken struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+type RevokeToken struct {
+	ID string
+}
+
+// ShareNote gives Role, editor or viewer, on a note to the user with Email
+// or to the members of team TeamID.
+type ShareNote struct {
+	ID     string `json:"-"`
+	Email  string `json:"email"`
+	TeamID *int64 `json:"teamId"`
+	Role   string `json:"role"`
+}
+
+type GetShares struct {
+	ID string
+}
+
+type DeleteShare struct {
+	ID      string
+	ShareID string
+}
+
+type CreateTeam struct {
+	Name string `json:"name"`
+}
+
+type GetTeam struct {
+	ID string
+}
+
+type DeleteTeam struct {
+	ID string
+}
+
+// AddMember adds the user with Email to a team.
+type AddMember struct {
+	ID    string `json:"-"`
+	Email string `json:"email"`
+}
+
+type RemoveMember struct {
+	ID     string
+	UserID string
+}
+
+// CreateLink makes a public link to a note. ExpiresIn is a duration like
+// "72h", a week when empty; Password is optional.
+type CreateLink struct {
+	ID        string `json:"-"`
+	ExpiresIn string `json:"expiresIn"`
+	Password  string `json:"password"`
+}
+
+type GetLinks struct {
+	ID string
+}
+
+type RevokeLink struct {
+	ID     string
+	LinkID string
+}
+
+// OpenLink opens a public link; Password is for protected ones.
+type OpenLink struct {
+	Token    string
+	Password string `json:"password"`
 }
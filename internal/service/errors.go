@@ -0,0 +1,66 @@
+package service
+
+import (
+	"errors"
+	"strings"
+)
+
+// Domain errors shared by every layer. Storages wrap them with details
+// (fmt.Errorf("note %s %w", id, ErrNotFound)) and handlers map them to
+// HTTP statuses with errors.Is.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrInvalidInput = errors.New("invalid input")
+	ErrConflict     = errors.New("conflict")
+	// ErrPreconditionFailed means the note changed since the version the
+	// client based its change on.
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrUnauthorized means the credentials or the session token are wrong
+	// or expired.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrForbidden means the user may see the note but lacks the role to
+	// change it.
+	ErrForbidden = errors.New("forbidden")
+)
+
+type FieldError struct {
+	Field  string
+	Reason string
+}
+
+// ValidationError lists every invalid field of a request; it matches
+// ErrInvalidInput with errors.Is.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, 0, len(e.Fields))
+
+	for _, f := range e.Fields {
+		parts = append(parts, f.Field+" "+f.Reason)
+	}
+
+	return ErrInvalidInput.Error() + ": " + strings.Join(parts, "; ")
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrInvalidInput
+}
+
+// validate collects the failed checks into a ValidationError.
+func validate(checks ...*FieldError) error {
+	fields := []FieldError{}
+
+	for _, check := range checks {
+		if check != nil {
+			fields = append(fields, *check)
+		}
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+
+	return &ValidationError{Fields: fields}
+}
@@ -0,0 +1,507 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Media types of the patch formats PATCH accepts.
+const (
+	MergePatch = "application/merge-patch+json"
+	JSONPatch  = "application/json-patch+json"
+)
+
+// patch changes a JSON document decoded by decodeJSON. It may change the
+// document in place, so it is given a fresh one each time.
+type patch func(doc interface{}) (interface{}, error)
+
+// parsePatch reads a patch in the given format. A malformed patch is
+// invalid input.
+func parsePatch(format string, data []byte) (patch, error) {
+	switch format {
+	case MergePatch:
+		var merge interface{}
+
+		if err := decodeJSON(data, &merge); err != nil {
+			return nil, validate(&FieldError{Field: "patch", Reason: "must be a JSON document: " + err.Error()})
+		}
+
+		return func(doc interface{}) (interface{}, error) {
+			return mergePatch(doc, merge), nil
+		}, nil
+	case JSONPatch:
+		operations, err := parseOperations(data)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return func(doc interface{}) (interface{}, error) {
+			return applyOperations(doc, operations)
+		}, nil
+	}
+
+	return nil, validate(&FieldError{Field: "patch", Reason: fmt.Sprintf("must be %s or %s, got %q", MergePatch, JSONPatch, format)})
+}
+
+// decodeJSON decodes a single JSON value keeping numbers as json.Number, so
+// that ids survive the trip unchanged.
+func decodeJSON(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+
+	if decoder.More() {
+		return fmt.Errorf("unexpected data after the JSON value")
+	}
+
+	return nil
+}
+
+// mergePatch applies an RFC 7396 merge patch: members of an object patch
+// replace those of the target, null removes them, anything else replaces
+// the whole target.
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+
+			continue
+		}
+
+		targetObject[key] = mergePatch(targetObject[key], value)
+	}
+
+	return targetObject
+}
+
+// operation is one step of an RFC 6902 JSON Patch.
+type operation struct {
+	Op    string
+	Path  []string
+	From  []string
+	Value interface{}
+}
+
+// operationFields tells which of from and value every op takes.
+var operationFields = map[string]struct{ from, value bool }{
+	"add":     {value: true},
+	"remove":  {},
+	"replace": {value: true},
+	"move":    {from: true},
+	"copy":    {from: true},
+	"test":    {value: true},
+}
+
+type rawOperation struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+func parseOperations(data []byte) ([]operation, error) {
+	raw := []rawOperation{}
+
+	if err := decodeJSON(data, &raw); err != nil {
+		return nil, validate(&FieldError{Field: "patch", Reason: "must be an array of operations: " + err.Error()})
+	}
+
+	operations := make([]operation, 0, len(raw))
+
+	for i := range raw {
+		op, err := parseOperation(raw[i])
+
+		if err != nil {
+			return nil, validate(&FieldError{Field: fmt.Sprintf("patch[%d]", i), Reason: err.Error()})
+		}
+
+		operations = append(operations, op)
+	}
+
+	return operations, nil
+}
+
+func parseOperation(raw rawOperation) (operation, error) {
+	fields, known := operationFields[raw.Op]
+	op := operation{Op: raw.Op}
+
+	switch {
+	case !known:
+		return op, fmt.Errorf("has unknown op %q", raw.Op)
+	case raw.Path == nil:
+		return op, fmt.Errorf("has no path")
+	case fields.from && raw.From == nil:
+		return op, fmt.Errorf("has no from")
+	case fields.value && raw.Value == nil:
+		return op, fmt.Errorf("has no value")
+	}
+
+	var err error
+
+	if op.Path, err = parsePointer(*raw.Path); err != nil {
+		return op, err
+	}
+
+	if fields.from {
+		if op.From, err = parsePointer(*raw.From); err != nil {
+			return op, err
+		}
+	}
+
+	if fields.value {
+		err = decodeJSON(raw.Value, &op.Value)
+	}
+
+	return op, err
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into its reference tokens.
+// The empty pointer is the whole document.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("pointer %q must start with /", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+
+	return tokens, nil
+}
+
+func formatPointer(tokens []string) string {
+	var pointer strings.Builder
+
+	for _, token := range tokens {
+		pointer.WriteString("/" + strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1"))
+	}
+
+	return pointer.String()
+}
+
+// applyOperations runs the operations in order and stops at the first one
+// that doesn't apply; such a patch conflicts with the document.
+func applyOperations(doc interface{}, operations []operation) (interface{}, error) {
+	for i, op := range operations {
+		var err error
+
+		doc, err = applyOperation(doc, op)
+
+		if err != nil {
+			return nil, fmt.Errorf("%w: patch[%d] %s %s %s", ErrConflict, i, op.Op, formatPointer(op.Path), err)
+		}
+	}
+
+	return doc, nil
+}
+
+func applyOperation(doc interface{}, op operation) (interface{}, error) {
+	switch op.Op {
+	case "add":
+		return add(doc, op.Path, copyJSON(op.Value))
+	case "remove":
+		removed, _, err := remove(doc, op.Path)
+
+		return removed, err
+	case "replace":
+		removed, _, err := remove(doc, op.Path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return add(removed, op.Path, copyJSON(op.Value))
+	case "move":
+		if len(op.From) < len(op.Path) && formatPointer(op.Path[:len(op.From)]) == formatPointer(op.From) {
+			return nil, fmt.Errorf("can't move %s into itself", formatPointer(op.From))
+		}
+
+		removed, value, err := remove(doc, op.From)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return add(removed, op.Path, value)
+	case "copy":
+		value, err := get(doc, op.From)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return add(doc, op.Path, copyJSON(value))
+	}
+
+	value, err := get(doc, op.Path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !equalJSON(value, op.Value) {
+		return nil, fmt.Errorf("failed: the value differs")
+	}
+
+	return doc, nil
+}
+
+func get(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		var err error
+
+		if doc, err = child(doc, token); err != nil {
+			return nil, err
+		}
+	}
+
+	return doc, nil
+}
+
+func add(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	return edit(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		switch parent := parent.(type) {
+		case map[string]interface{}:
+			parent[token] = value
+
+			return parent, nil
+		case []interface{}:
+			i, err := index(token, len(parent)+1)
+
+			if err != nil {
+				return nil, err
+			}
+
+			parent = append(parent, nil)
+			copy(parent[i+1:], parent[i:])
+			parent[i] = value
+
+			return parent, nil
+		}
+
+		return nil, fmt.Errorf("can't add %q to a %s", token, kind(parent))
+	})
+}
+
+func remove(doc interface{}, path []string) (interface{}, interface{}, error) {
+	var removed interface{}
+
+	doc, err := edit(doc, path, func(parent interface{}, token string) (interface{}, error) {
+		value, err := child(parent, token)
+
+		if err != nil {
+			return nil, err
+		}
+
+		removed = value
+
+		if object, ok := parent.(map[string]interface{}); ok {
+			delete(object, token)
+
+			return object, nil
+		}
+
+		array := parent.([]interface{})
+		i, _ := index(token, len(array))
+
+		return append(array[:i:i], array[i+1:]...), nil
+	})
+
+	return doc, removed, err
+}
+
+// edit lets change alter the parent of the last token of path and stores
+// the parent it returns back in the document. The empty path edits the whole
+// document, which change sees as the only member of an object.
+func edit(doc interface{}, path []string, change func(parent interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 0 {
+		root := map[string]interface{}{"": doc}
+
+		if _, err := change(root, ""); err != nil {
+			return nil, err
+		}
+
+		return root[""], nil
+	}
+
+	if len(path) == 1 {
+		return change(doc, path[0])
+	}
+
+	next, err := child(doc, path[0])
+
+	if err != nil {
+		return nil, err
+	}
+
+	if next, err = edit(next, path[1:], change); err != nil {
+		return nil, err
+	}
+
+	if object, ok := doc.(map[string]interface{}); ok {
+		object[path[0]] = next
+	} else {
+		i, _ := index(path[0], len(doc.([]interface{})))
+		doc.([]interface{})[i] = next
+	}
+
+	return doc, nil
+}
+
+func child(doc interface{}, token string) (interface{}, error) {
+	switch doc := doc.(type) {
+	case map[string]interface{}:
+		value, ok := doc[token]
+
+		if !ok {
+			return nil, fmt.Errorf("member %q doesn't exist", token)
+		}
+
+		return value, nil
+	case []interface{}:
+		if token == "-" {
+			return nil, fmt.Errorf("- is past the end of the array")
+		}
+
+		i, err := index(token, len(doc))
+
+		if err != nil {
+			return nil, err
+		}
+
+		return doc[i], nil
+	}
+
+	return nil, fmt.Errorf("can't look up %q in a %s", token, kind(doc))
+}
+
+// index reads an array index below size. "-" stands for the end of the
+// array, which is only a valid index when adding.
+func index(token string, size int) (int, error) {
+	i, err := strconv.Atoi(token)
+
+	if token == "-" {
+		i, err = size-1, nil
+	} else if err != nil || i < 0 || token != strconv.Itoa(i) {
+		return 0, fmt.Errorf("%q is not an array index", token)
+	}
+
+	if i >= size {
+		return 0, fmt.Errorf("index %s is out of range", token)
+	}
+
+	return i, nil
+}
+
+func kind(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	}
+
+	return "value"
+}
+
+func copyJSON(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(value))
+
+		for key, member := range value {
+			object[key] = copyJSON(member)
+		}
+
+		return object
+	case []interface{}:
+		array := make([]interface{}, len(value))
+
+		for i, element := range value {
+			array[i] = copyJSON(element)
+		}
+
+		return array
+	}
+
+	return value
+}
+
+// equalJSON compares JSON values as the test operation does: numbers by
+// value, objects regardless of member order.
+func equalJSON(a, b interface{}) bool {
+	switch a := a.(type) {
+	case map[string]interface{}:
+		object, ok := b.(map[string]interface{})
+
+		if !ok || len(a) != len(object) {
+			return false
+		}
+
+		for key, member := range a {
+			other, in := object[key]
+
+			if !in || !equalJSON(member, other) {
+				return false
+			}
+		}
+
+		return true
+	case []interface{}:
+		array, ok := b.([]interface{})
+
+		if !ok || len(a) != len(array) {
+			return false
+		}
+
+		for i := range a {
+			if !equalJSON(a[i], array[i]) {
+				return false
+			}
+		}
+
+		return true
+	case json.Number:
+		number, ok := b.(json.Number)
+
+		if !ok {
+			return false
+		}
+
+		x, errA := a.Float64()
+		y, errB := number.Float64()
+
+		return errA == nil && errB == nil && x == y
+	}
+
+	return a == b
+}
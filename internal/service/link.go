@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"note/internal/models"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	defaultLinkTTL = 7 * 24 * time.Hour
+	maxLinkTTL     = 365 * 24 * time.Hour
+)
+
+// LinkStorage keeps public links to notes by the hash of their token.
+// CreateLink, Links and RevokeLink act on a note the user of ctx owns.
+type LinkStorage interface {
+	CreateLink(context.Context, LinkFields) (*models.Link, error)
+	Links(ctx context.Context, noteID string) ([]*models.Link, error)
+	RevokeLink(ctx context.Context, noteID string, linkID int64) error
+	// Link finds a link by the hash of its token, revoked and expired ones
+	// too.
+	Link(ctx context.Context, tokenHash string) (*models.Link, error)
+	// LinkedNote reads the live note of a link for whoever holds the link,
+	// with no user in ctx. It is the only way to a note without one.
+	LinkedNote(ctx context.Context, linkID int64) (*models.Note, error)
+	CountView(ctx context.Context, linkID int64) error
+}
+
+// LinkFields are a validated link to store. PasswordHash is empty for a
+// link without a password.
+type LinkFields struct {
+	NoteID       string
+	TokenHash    string
+	PasswordHash string
+	ExpiresAt    time.Time
+}
+
+type LinkList struct {
+	Links []*models.Link `json:"links"`
+}
+
+// CreateLink makes a public link to a note of the caller. The token is
+// returned only here.
+func (s *service) CreateLink(ctx context.Context, dto CreateLink) (*models.Link, error) {
+	ttl, invalidTTL := parseLinkTTL(dto.ExpiresIn)
+	checks := []*FieldError{checkID(dto.ID), invalidTTL}
+
+	if dto.Password != "" {
+		checks = append(checks, checkPassword(dto.Password))
+	}
+
+	if err := validate(checks...); err != nil {
+		return nil, err
+	}
+
+	token, err := newToken()
+
+	if err != nil {
+		return nil, err
+	}
+
+	fields := LinkFields{NoteID: dto.ID, TokenHash: hashToken(token), ExpiresAt: time.Now().UTC().Add(ttl).Truncate(time.Second)}
+
+	if dto.Password != "" {
+		hash, hashErr := bcrypt.GenerateFromPassword([]byte(dto.Password), bcrypt.DefaultCost)
+
+		if hashErr != nil {
+			return nil, hashErr
+		}
+
+		fields.PasswordHash = string(hash)
+	}
+
+	link, err := s.storage.CreateLink(ctx, fields)
+
+	if err != nil {
+		return nil, err
+	}
+
+	link.Token = token
+
+	return link, nil
+}
+
+// Links lists the links to a note of the caller, revoked and expired ones
+// too.
+func (s *service) Links(ctx context.Context, dto GetLinks) (*LinkList, error) {
+	if err := validate(checkID(dto.ID)); err != nil {
+		return nil, err
+	}
+
+	links, err := s.storage.Links(ctx, dto.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &LinkList{Links: links}, nil
+}
+
+// RevokeLink stops a link from opening the note; it stays listed.
+func (s *service) RevokeLink(ctx context.Context, dto RevokeLink) error {
+	linkID, invalidLink := parseKey("linkId", dto.LinkID)
+
+	if err := validate(checkID(dto.ID), invalidLink); err != nil {
+		return err
+	}
+
+	return s.storage.RevokeLink(ctx, dto.ID, linkID)
+}
+
+// OpenLink returns the public view of the note of a link and counts the
+// view. Unknown, revoked and expired links are all not found; a protected
+// link without the right password is ErrUnauthorized. The note is read
+// through the link, not as any user.
+func (s *service) OpenLink(ctx context.Context, dto OpenLink) (*models.PublicNote, error) {
+	if dto.Token == "" {
+		return nil, fmt.Errorf("link %w", ErrNotFound)
+	}
+
+	link, err := s.storage.Link(ctx, hashToken(dto.Token))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if link.RevokedAt != nil || !time.Now().Before(link.ExpiresAt) {
+		return nil, fmt.Errorf("link %w", ErrNotFound)
+	}
+
+	if link.PasswordHash != "" {
+		if dto.Password == "" {
+			return nil, fmt.Errorf("%w: the link needs a password", ErrUnauthorized)
+		}
+
+		if bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(dto.Password)) != nil {
+			return nil, fmt.Errorf("%w: wrong password", ErrUnauthorized)
+		}
+	}
+
+	note, err := s.storage.LinkedNote(ctx, link.ID)
+
+	if errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("link %w", ErrNotFound)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.storage.CountView(ctx, link.ID); err != nil {
+		return nil, err
+	}
+
+	return &models.PublicNote{
+		Title: note.Title, Text: note.Text, Summary: note.Summary, Tags: note.Tags, CreatedAt: note.CreatedAt, UpdatedAt: note.UpdatedAt,
+	}, nil
+}
+
+func parseLinkTTL(value string) (time.Duration, *FieldError) {
+	if value == "" {
+		return defaultLinkTTL, nil
+	}
+
+	ttl, err := time.ParseDuration(value)
+
+	if err != nil || ttl <= 0 || ttl > maxLinkTTL {
+		return 0, &FieldError{Field: "expiresIn", Reason: fmt.Sprintf("must be a duration like 72h up to %.0fh, got %q", maxLinkTTL.Hours(), value)}
+	}
+
+	return ttl, nil
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxTitleLength   = 255
+	maxSummaryLength = 1000
+)
+
+// Colors are the labels a note can be marked with; an empty color means
+// none.
+var Colors = []string{"red", "orange", "yellow", "green", "blue", "purple", "gray"}
+
+// checkMeta validates the metadata fields that are set.
+func checkMeta(title, summary, color *string) []*FieldError {
+	checks := []*FieldError{}
+
+	if title != nil && len([]rune(*title)) > maxTitleLength {
+		checks = append(checks, &FieldError{Field: "title", Reason: fmt.Sprintf("must be at most %d characters", maxTitleLength)})
+	}
+
+	if summary != nil && len([]rune(*summary)) > maxSummaryLength {
+		checks = append(checks, &FieldError{Field: "summary", Reason: fmt.Sprintf("must be at most %d characters", maxSummaryLength)})
+	}
+
+	if color != nil && *color != "" {
+		checks = append(checks, checkColor("color", *color))
+	}
+
+	return checks
+}
+
+func checkColor(field, color string) *FieldError {
+	for _, c := range Colors {
+		if c == color {
+			return nil
+		}
+	}
+
+	return &FieldError{Field: field, Reason: "must be one of " + strings.Join(Colors, ", ")}
+}
+
+// parseFlag reads an optional true/false filter; "" means no filter.
+func parseFlag(field, value string) (*bool, *FieldError) {
+	if value == "" {
+		return nil, nil
+	}
+
+	flag, err := strconv.ParseBool(value)
+
+	if err != nil {
+		return nil, &FieldError{Field: field, Reason: "must be true or false"}
+	}
+
+	return &flag, nil
+}
@@ -2,15 +2,48 @@ package service
 
 import (
 	"context"
+	"fmt"
 	"note/internal/models"
+	"strconv"
+	"strings"
 )
 
+// MaxTextLength bounds the text of a note, which is kept in every revision
+// and diffed whole.
+const MaxTextLength = 100000
+
 type Storage interface {
 	Get(context.Context, string) (*models.Note, error)
-	Create(context.Context, string) error
-	Update(context.Context, string, string) error
-	Delete(context.Context, string) error
-	GetAll(context.Context, string) ([]*models.Note, error)
+	Create(context.Context, NoteFields) (*models.Note, error)
+	Update(context.Context, string, NoteFields) error
+	Delete(context.Context, string, int64) error
+	GetAll(context.Context, NotesQuery) ([]*models.Note, error)
+	Search(context.Context, SearchQuery) ([]*SearchResult, error)
+	Tags(context.Context) ([]*models.Tag, error)
+	NotebookStorage
+	TrashStorage
+	ShareStorage
+	LinkStorage
+	RevisionStorage
+	BatchStorage
+	UserStorage
+}
+
+// NoteFields are the validated fields of a note to store. Nil Tags and
+// metadata leave those of an updated note as they are; Create stores them
+// as empty or false. NotebookID is only used on create, notes change
+// notebooks with MoveNote. An update with IfVersion set applies only to that
+// version of the note and fails with ErrPreconditionFailed otherwise.
+type NoteFields struct {
+	Text       string
+	Tags       []string
+	NotebookID *int64
+	Title      *string
+	Summary    *string
+	Color      *string
+	Pinned     *bool
+	Archived   *bool
+	IfVersion  int64
 }
 
 type service struct {
@@ -21,22 +54,187 @@ func NewService(storage Storage) *service {
 	return &service{storage: storage}
 }
 
-func (s *service) Create(ctx context.Context, dto CreateNote) error {
-	return s.storage.Create(ctx, dto.Text)
+func (s *service) Create(ctx context.Context, dto CreateNote) (*models.Note, error) {
+	fields, err := s.createFields(ctx, dto)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.storage.Create(ctx, fields)
 }
 
-func (s *service) Update(ctx context.Context, dto UpdateNote) error {
-	return s.storage.Update(ctx, dto.ID, dto.Text)
+// createFields validates a new note.
+func (s *service) createFields(ctx context.Context, dto CreateNote) (NoteFields, error) {
+	tags, invalidTags := normalizeTags(dto.Tags)
+
+	title := strings.TrimSpace(dto.Title)
+	checks := []*FieldError{checkText(dto.Text), invalidTags, checkRef("notebookId", dto.NotebookID)}
+
+	if err := validate(append(checks, checkMeta(&title, &dto.Summary, &dto.Color)...)...); err != nil {
+		return NoteFields{}, err
+	}
+
+	if err := s.checkNotebookExists(ctx, "notebookId", dto.NotebookID); err != nil {
+		return NoteFields{}, err
+	}
+
+	if tags == nil {
+		tags = []string{}
+	}
+
+	return NoteFields{
+		Text:       dto.Text,
+		Tags:       tags,
+		NotebookID: dto.NotebookID,
+		Title:      &title,
+		Summary:    &dto.Summary,
+		Color:      &dto.Color,
+		Pinned:     &dto.Pinned,
+		Archived:   &dto.Archived,
+	}, nil
 }
 
+// Update changes a note and returns it with its new version.
+func (s *service) Update(ctx context.Context, dto UpdateNote) (*models.Note, error) {
+	fields, err := updateFields(dto)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.storage.Update(editing(ctx), dto.ID, fields); err != nil {
+		return nil, err
+	}
+
+	return s.storage.Get(reading(ctx), dto.ID)
+}
+
+// updateFields validates an update of a note.
+func updateFields(dto UpdateNote) (NoteFields, error) {
+	tags, invalidTags := normalizeTags(dto.Tags)
+
+	if dto.Title != nil {
+		title := strings.TrimSpace(*dto.Title)
+		dto.Title = &title
+	}
+
+	checks := []*FieldError{checkID(dto.ID), checkText(dto.Text), invalidTags}
+
+	if err := validate(append(checks, checkMeta(dto.Title, dto.Summary, dto.Color)...)...); err != nil {
+		return NoteFields{}, err
+	}
+
+	return NoteFields{
+		Text:      dto.Text,
+		Tags:      tags,
+		Title:     dto.Title,
+		Summary:   dto.Summary,
+		Color:     dto.Color,
+		Pinned:    dto.Pinned,
+		Archived:  dto.Archived,
+		IfVersion: dto.IfVersion,
+	}, nil
+}
+
+// Delete moves a note to the trash; only its owner may.
 func (s *service) Delete(ctx context.Context, dto DeleteNote) error {
-	return s.storage.Delete(ctx, dto.ID)
+	if err := validate(checkID(dto.ID)); err != nil {
+		return err
+	}
+
+	return s.storage.Delete(ctx, dto.ID, dto.IfVersion)
 }
 
 func (s *service) Get(ctx context.Context, dto GetNote) (*models.Note, error) {
-	return s.storage.Get(ctx, dto.ID)
+	if err := validate(checkID(dto.ID)); err != nil {
+		return nil, err
+	}
+
+	return s.storage.Get(reading(ctx), dto.ID)
 }
 
-func (s *service) GetAll(ctx context.Context, dto GetNotes) ([]*models.Note, error) {
-	return s.storage.GetAll(ctx, dto.OrderBy)
+// GetAll returns one page of the notes the user may see, shared ones too;
+// NextCursor is set when more notes follow.
+func (s *service) GetAll(ctx context.Context, dto GetNotes) (*NotesPage, error) {
+	query, err := newNotesQuery(dto)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.page(reading(ctx), query)
+}
+
+func (s *service) page(ctx context.Context, query NotesQuery) (*NotesPage, error) {
+	// One extra row tells whether there is a next page.
+	limit := query.Limit
+	query.Limit++
+
+	notes, err := s.storage.GetAll(ctx, query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	page := &NotesPage{Notes: notes}
+
+	if len(notes) > limit {
+		page.Notes = notes[:limit]
+		page.NextCursor = encodeCursor(query.Sort, notes[limit-1])
+	}
+
+	return page, nil
+}
+
+// Tags lists the tags in use, most used first.
+func (s *service) Tags(ctx context.Context) (*TagList, error) {
+	tags, err := s.storage.Tags(reading(ctx))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &TagList{Tags: tags}, nil
+}
+
+// Search returns notes matching every term of the query, best match first,
+// each with a highlighted snippet.
+func (s *service) Search(ctx context.Context, dto SearchNotes) (*SearchResults, error) {
+	query, err := newSearchQuery(dto)
+
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := s.storage.Search(reading(ctx), query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, result := range results {
+		result.Snippet = snippet(result.Note.Text, query.Terms)
+	}
+
+	return &SearchResults{Results: results}, nil
+}
+
+func checkID(id string) *FieldError {
+	if n, err := strconv.ParseInt(id, 10, 64); err != nil || n <= 0 {
+		return &FieldError{Field: "id", Reason: fmt.Sprintf("must be a positive integer, got %q", id)}
+	}
+
+	return nil
+}
+
+func checkText(text string) *FieldError {
+	switch {
+	case strings.TrimSpace(text) == "":
+		return &FieldError{Field: "text", Reason: "must not be empty"}
+	case len([]rune(text)) > MaxTextLength:
+		return &FieldError{Field: "text", Reason: fmt.Sprintf("must be at most %d characters", MaxTextLength)}
+	}
+
+	return nil
 }
@@ -0,0 +1,1274 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"note/internal/adapter/repository"
+	"note/internal/models"
+	"note/internal/service"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidation(t *testing.T) {
+	ctx := service.AsSystem(context.Background())
+	srv := service.NewService(repository.NewMemoryStorage())
+
+	for _, id := range []string{"", "abc", "0", "-1", "1.5"} {
+		if _, err := srv.Get(ctx, service.GetNote{ID: id}); !errors.Is(err, service.ErrInvalidInput) {
+			t.Errorf("Get(%q): expected ErrInvalidInput, got %v", id, err)
+		}
+
+		if _, err := srv.Update(ctx, service.UpdateNote{ID: id, Text: "text"}); !errors.Is(err, service.ErrInvalidInput) {
+			t.Errorf("Update(%q): expected ErrInvalidInput, got %v", id, err)
+		}
+
+		if err := srv.Delete(ctx, service.DeleteNote{ID: id}); !errors.Is(err, service.ErrInvalidInput) {
+			t.Errorf("Delete(%q): expected ErrInvalidInput, got %v", id, err)
+		}
+	}
+
+	for _, text := range []string{"", " ", "\n\t", strings.Repeat("я", 100001)} {
+		if _, err := srv.Create(ctx, service.CreateNote{Text: text}); !errors.Is(err, service.ErrInvalidInput) {
+			t.Errorf("Create(%.20q): expected ErrInvalidInput, got %v", text, err)
+		}
+	}
+
+	if _, err := srv.Update(ctx, service.UpdateNote{ID: "1", Text: strings.Repeat("a", 100001)}); !errors.Is(err, service.ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput for a text over the limit, got %v", err)
+	}
+
+	if _, err := srv.Get(ctx, service.GetNote{ID: "42"}); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a missing note, got %v", err)
+	}
+}
+
+func TestPagination(t *testing.T) {
+	ctx := service.AsSystem(context.Background())
+	srv := service.NewService(repository.NewMemoryStorage())
+
+	for _, text := range []string{"e", "d", "c", "b", "a"} {
+		if _, err := srv.Create(ctx, service.CreateNote{Text: text}); err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+	}
+
+	got := []string{}
+	dto := service.GetNotes{OrderBy: "text", Limit: "2"}
+
+	for pages := 1; ; pages++ {
+		page, err := srv.GetAll(ctx, dto)
+
+		if err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+
+		for _, note := range page.Notes {
+			got = append(got, note.Text)
+		}
+
+		if page.NextCursor == "" {
+			if pages != 3 {
+				t.Errorf("expected 3 pages, got %d", pages)
+			}
+
+			break
+		}
+
+		// The cursor carries the order, so order_by may be omitted.
+		dto = service.GetNotes{Limit: "2", Cursor: page.NextCursor}
+	}
+
+	if strings.Join(got, "") != "abcde" {
+		t.Errorf("unexpected notes across pages: %v", got)
+	}
+
+	first, err := srv.GetAll(ctx, service.GetNotes{Limit: "1"})
+
+	if err != nil || first.NextCursor == "" {
+		t.Fatalf("expected a next cursor, got %v, %v", first, err)
+	}
+
+	invalid := []service.GetNotes{
+		{Limit: "0"},
+		{Limit: "101"},
+		{Limit: "ten"},
+		{OrderBy: "password"},
+		{Cursor: "not a cursor"},
+		{Cursor: "e30"}, // {}
+		{OrderBy: "text", Cursor: first.NextCursor},
+	}
+
+	for _, bad := range invalid {
+		if _, err = srv.GetAll(ctx, bad); !errors.Is(err, service.ErrInvalidInput) {
+			t.Errorf("GetAll(%+v): expected ErrInvalidInput, got %v", bad, err)
+		}
+	}
+}
+
+func TestSorting(t *testing.T) {
+	ctx := service.AsSystem(context.Background())
+	srv := service.NewService(repository.NewMemoryStorage())
+
+	for _, text := range []string{"b", "a", "b", "c"} {
+		if _, err := srv.Create(ctx, service.CreateNote{Text: text}); err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+	}
+
+	cases := []struct {
+		orderBy string
+		ids     []int64
+	}{
+		{"", []int64{1, 2, 3, 4}},
+		{"-id", []int64{4, 3, 2, 1}},
+		{"text", []int64{2, 1, 3, 4}},
+		{"+text,-id", []int64{2, 3, 1, 4}},
+		{" -text , id ", []int64{4, 1, 3, 2}},
+	}
+
+	for _, c := range cases {
+		page, err := srv.GetAll(ctx, service.GetNotes{OrderBy: c.orderBy})
+
+		if err != nil {
+			t.Fatalf("order_by=%q: unexpected err: %s", c.orderBy, err)
+		}
+
+		ids := []int64{}
+
+		for _, note := range page.Notes {
+			ids = append(ids, note.ID)
+		}
+
+		if !reflect.DeepEqual(ids, c.ids) {
+			t.Errorf("order_by=%q: expected %v, got %v", c.orderBy, c.ids, ids)
+		}
+	}
+
+	// Equivalent specs share cursors.
+	page, err := srv.GetAll(ctx, service.GetNotes{OrderBy: "-text", Limit: "1"})
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if _, err = srv.GetAll(ctx, service.GetNotes{OrderBy: "-text,+id", Cursor: page.NextCursor}); err != nil {
+		t.Errorf("expected cursor to match an equivalent order_by, got %v", err)
+	}
+
+	for _, orderBy := range []string{"text;DROP TABLE note", "-", "text,,id", "text,-text", "--id", "a,b,c,d,e", "TEXT"} {
+		if _, err = srv.GetAll(ctx, service.GetNotes{OrderBy: orderBy}); !errors.Is(err, service.ErrInvalidInput) {
+			t.Errorf("order_by=%q: expected ErrInvalidInput, got %v", orderBy, err)
+		}
+	}
+}
+
+func TestSortingLongText(t *testing.T) {
+	ctx := service.AsSystem(context.Background())
+	srv := service.NewService(repository.NewMemoryStorage())
+	long := strings.Repeat("x", 5000)
+
+	for _, text := range []string{long + "b", long + "a", "y"} {
+		if _, err := srv.Create(ctx, service.CreateNote{Text: text}); err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+	}
+
+	ids := []int64{}
+	dto := service.GetNotes{OrderBy: "text", Limit: "1"}
+
+	for {
+		page, err := srv.GetAll(ctx, dto)
+
+		if err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+
+		ids = append(ids, page.Notes[0].ID)
+
+		if page.NextCursor == "" {
+			break
+		}
+
+		// The cursor holds the first SortTextLength characters, not the text.
+		if len(page.NextCursor) > 4*service.SortTextLength {
+			t.Fatalf("expected a bounded cursor, got %d bytes", len(page.NextCursor))
+		}
+
+		dto = service.GetNotes{OrderBy: "text", Limit: "1", Cursor: page.NextCursor}
+	}
+
+	// Texts sharing their first SortTextLength characters are in id order.
+	if want := []int64{1, 2, 3}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("expected %v, got %v", want, ids)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	ctx := service.AsSystem(context.Background())
+	srv := service.NewService(repository.NewMemoryStorage())
+
+	for _, text := range []string{"Quick brown <i>fox</i>", "a quick brown dog", "slow fox"} {
+		if _, err := srv.Create(ctx, service.CreateNote{Text: text}); err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+	}
+
+	found, err := srv.Search(ctx, service.SearchNotes{Query: `"quick brown" fo*`})
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if len(found.Results) != 1 || found.Results[0].Note.ID != 1 {
+		t.Fatalf("unexpected results: %+v", found.Results)
+	}
+
+	want := "<mark>Quick brown</mark> &lt;i&gt;<mark>fox</mark>&lt;/i&gt;"
+
+	if got := found.Results[0].Snippet; got != want {
+		t.Errorf("expected snippet %q, got %q", want, got)
+	}
+
+	long := strings.Repeat("filler ", 30) + "needle" + strings.Repeat(" filler", 30)
+
+	if _, err = srv.Create(ctx, service.CreateNote{Text: long}); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	found, err = srv.Search(ctx, service.SearchNotes{Query: "needle"})
+
+	if err != nil || len(found.Results) != 1 {
+		t.Fatalf("unexpected results: %+v, %v", found, err)
+	}
+
+	if got := found.Results[0].Snippet; !strings.HasPrefix(got, "…") || !strings.HasSuffix(got, "…") || !strings.Contains(got, "<mark>needle</mark>") {
+		t.Errorf("expected a cut snippet around the match, got %q", got)
+	}
+
+	for _, bad := range []service.SearchNotes{{Query: ""}, {Query: `"" * +-`}, {Query: "fox", Limit: "0"}, {Query: strings.Repeat("word ", 11)}} {
+		if _, err = srv.Search(ctx, bad); !errors.Is(err, service.ErrInvalidInput) {
+			t.Errorf("Search(%+v): expected ErrInvalidInput, got %v", bad, err)
+		}
+	}
+}
+
+func TestTags(t *testing.T) {
+	ctx := service.AsSystem(context.Background())
+	srv := service.NewService(repository.NewMemoryStorage())
+
+	note, err := srv.Create(ctx, service.CreateNote{Text: "text", Tags: []string{" Work ", "work", "to-do"}})
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if !reflect.DeepEqual(note.Tags, []string{"to-do", "work"}) {
+		t.Errorf("expected normalized tags, got %v", note.Tags)
+	}
+
+	note, err = srv.Create(ctx, service.CreateNote{Text: "text"})
+
+	if err != nil || note.Tags == nil {
+		t.Errorf("expected an empty tag list, got %+v, %v", note, err)
+	}
+
+	if _, err = srv.Update(ctx, service.UpdateNote{ID: "2", Text: "text", Tags: []string{"WORK"}}); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	page, err := srv.GetAll(ctx, service.GetNotes{Tags: []string{"Work"}})
+
+	if err != nil || len(page.Notes) != 2 {
+		t.Errorf("expected both notes tagged work, got %+v, %v", page, err)
+	}
+
+	list, err := srv.Tags(ctx)
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if len(list.Tags) != 2 || list.Tags[0].Name != "work" || list.Tags[0].Count != 2 {
+		t.Errorf("unexpected tags: %+v", list.Tags)
+	}
+
+	tooMany := make([]string, 21)
+
+	for i := range tooMany {
+		tooMany[i] = "t" + strconv.Itoa(i)
+	}
+
+	for _, tags := range [][]string{{""}, {"two words"}, {"semi;colon"}, {strings.Repeat("x", 33)}, tooMany} {
+		if _, err = srv.Create(ctx, service.CreateNote{Text: "text", Tags: tags}); !errors.Is(err, service.ErrInvalidInput) {
+			t.Errorf("Create(tags=%q): expected ErrInvalidInput, got %v", tags, err)
+		}
+	}
+
+	for _, dto := range []service.GetNotes{{Tags: []string{"a b"}}, {TagMode: "some"}} {
+		if _, err = srv.GetAll(ctx, dto); !errors.Is(err, service.ErrInvalidInput) {
+			t.Errorf("GetAll(%+v): expected ErrInvalidInput, got %v", dto, err)
+		}
+	}
+}
+
+func TestNotebooks(t *testing.T) {
+	ctx := service.AsSystem(context.Background())
+	srv := service.NewService(repository.NewMemoryStorage())
+
+	work, err := srv.CreateNotebook(ctx, service.CreateNotebook{Name: " work "})
+
+	if err != nil || work.Name != "work" {
+		t.Fatalf("unexpected notebook: %+v, %v", work, err)
+	}
+
+	projects, err := srv.CreateNotebook(ctx, service.CreateNotebook{Name: "projects", ParentID: &work.ID})
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if _, err = srv.Create(ctx, service.CreateNote{Text: "top", NotebookID: &work.ID}); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err = srv.Create(ctx, service.CreateNote{Text: "nested", NotebookID: &projects.ID}); err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+	}
+
+	workID := strconv.FormatInt(work.ID, 10)
+	contents, err := srv.GetNotebookContents(ctx, service.GetNotebookContents{ID: workID})
+
+	if err != nil || len(contents.Notebooks) != 1 || len(contents.Notes) != 1 {
+		t.Errorf("expected one child and one note, got %+v, %v", contents, err)
+	}
+
+	contents, err = srv.GetNotebookContents(ctx, service.GetNotebookContents{ID: workID, Recursive: true, Notes: service.GetNotes{Limit: "3"}})
+
+	if err != nil || len(contents.Notes) != 3 || contents.NextCursor == "" {
+		t.Fatalf("expected a first page of the subtree, got %+v, %v", contents, err)
+	}
+
+	next, err := srv.GetNotebookContents(ctx, service.GetNotebookContents{ID: workID, Recursive: true, Notes: service.GetNotes{Cursor: contents.NextCursor}})
+
+	if err != nil || len(next.Notes) != 1 || next.NextCursor != "" {
+		t.Errorf("expected the last note of the subtree, got %+v, %v", next, err)
+	}
+
+	missing := int64(42)
+	negative := int64(-1)
+
+	for _, dto := range []service.CreateNotebook{{Name: "  "}, {Name: strings.Repeat("x", 256)}, {Name: "a", ParentID: &missing}, {Name: "a", ParentID: &negative}} {
+		if _, err = srv.CreateNotebook(ctx, dto); !errors.Is(err, service.ErrInvalidInput) {
+			t.Errorf("CreateNotebook(%+v): expected ErrInvalidInput, got %v", dto, err)
+		}
+	}
+
+	if err = srv.MoveNote(ctx, service.MoveNote{ID: "1", NotebookID: &missing}); !errors.Is(err, service.ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput moving into a missing notebook, got %v", err)
+	}
+
+	if err = srv.MoveNote(ctx, service.MoveNote{ID: "1"}); err != nil {
+		t.Errorf("unexpected err: %s", err)
+	}
+
+	err = srv.UpdateNotebook(ctx, service.UpdateNotebook{ID: workID, Name: "work", ParentID: &projects.ID})
+
+	if !errors.Is(err, service.ErrConflict) {
+		t.Errorf("expected ErrConflict for a cycle, got %v", err)
+	}
+
+	if _, err = srv.GetNotebookContents(ctx, service.GetNotebookContents{ID: "42"}); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	ctx := service.AsSystem(context.Background())
+	srv := service.NewService(repository.NewMemoryStorage())
+
+	note, err := srv.Create(ctx, service.CreateNote{Title: "  Plan  ", Text: "text", Color: "blue", Pinned: true})
+
+	if err != nil || note.Title != "Plan" || note.Color != "blue" || !note.Pinned {
+		t.Fatalf("unexpected note: %+v, %v", note, err)
+	}
+
+	archived := true
+
+	if _, err = srv.Update(ctx, service.UpdateNote{ID: "1", Text: "text", Archived: &archived}); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	page, err := srv.GetAll(ctx, service.GetNotes{Pinned: "true", Archived: "true", Color: "blue"})
+
+	if err != nil || len(page.Notes) != 1 || page.Notes[0].Title != "Plan" {
+		t.Errorf("expected the pinned archived note, got %+v, %v", page, err)
+	}
+
+	long := strings.Repeat("x", 256)
+	magenta := "magenta"
+
+	for _, dto := range []service.CreateNote{{Text: "t", Title: long}, {Text: "t", Summary: strings.Repeat("x", 1001)}, {Text: "t", Color: "magenta"}} {
+		if _, err = srv.Create(ctx, dto); !errors.Is(err, service.ErrInvalidInput) {
+			t.Errorf("Create(%+v): expected ErrInvalidInput, got %v", dto, err)
+		}
+	}
+
+	if _, err = srv.Update(ctx, service.UpdateNote{ID: "1", Text: "t", Color: &magenta}); !errors.Is(err, service.ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput, got %v", err)
+	}
+
+	for _, dto := range []service.GetNotes{{Pinned: "yes"}, {Archived: "2"}, {Color: "pink"}} {
+		if _, err = srv.GetAll(ctx, dto); !errors.Is(err, service.ErrInvalidInput) {
+			t.Errorf("GetAll(%+v): expected ErrInvalidInput, got %v", dto, err)
+		}
+	}
+}
+
+func TestTrash(t *testing.T) {
+	ctx := service.AsSystem(context.Background())
+	srv := service.NewService(repository.NewMemoryStorage())
+
+	for _, text := range []string{"first", "second"} {
+		if _, err := srv.Create(ctx, service.CreateNote{Text: text}); err != nil {
+			t.Fatalf("can't create note: %s", err)
+		}
+	}
+
+	if err := srv.Delete(ctx, service.DeleteNote{ID: "1"}); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	page, err := srv.Trash(ctx, service.GetNotes{})
+
+	if err != nil || len(page.Notes) != 1 || page.Notes[0].ID != 1 {
+		t.Fatalf("expected the deleted note in the trash, got %+v, %v", page, err)
+	}
+
+	if _, err = srv.Trash(ctx, service.GetNotes{Limit: "0"}); !errors.Is(err, service.ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput, got %v", err)
+	}
+
+	note, err := srv.Restore(ctx, service.RestoreNote{ID: "1"})
+
+	if err != nil || note.Text != "first" || note.DeletedAt != nil {
+		t.Fatalf("unexpected restored note: %+v, %v", note, err)
+	}
+
+	if err = srv.Purge(ctx, service.PurgeNote{ID: "1"}); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound purging a note that isn't trashed, got %v", err)
+	}
+
+	if err = srv.Purge(ctx, service.PurgeNote{ID: "x"}); !errors.Is(err, service.ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput, got %v", err)
+	}
+
+	if err = srv.Delete(ctx, service.DeleteNote{ID: "2"}); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if purged, purgeErr := srv.PurgeTrash(ctx, time.Hour); purgeErr != nil || purged != 0 {
+		t.Errorf("expected nothing to expire yet, got %d, %v", purged, purgeErr)
+	}
+
+	if purged, purgeErr := srv.PurgeTrash(ctx, -time.Hour); purgeErr != nil || purged != 1 {
+		t.Errorf("expected one expired note, got %d, %v", purged, purgeErr)
+	}
+}
+
+func TestRevisions(t *testing.T) {
+	ctx := service.AsSystem(context.Background())
+	srv := service.NewService(repository.NewMemoryStorage())
+
+	if _, err := srv.Create(ctx, service.CreateNote{Title: "Plan", Text: "one\ntwo\nthree", Tags: []string{"work"}}); err != nil {
+		t.Fatalf("can't create note: %s", err)
+	}
+
+	title := "New plan"
+
+	if _, err := srv.Update(ctx, service.UpdateNote{ID: "1", Title: &title, Text: "one\n2\nthree\nfour"}); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	list, err := srv.Revisions(ctx, service.GetRevisions{ID: "1"})
+
+	if err != nil || len(list.Revisions) != 2 {
+		t.Fatalf("unexpected revisions: %+v, %v", list, err)
+	}
+
+	diff, err := srv.DiffRevisions(ctx, service.DiffRevisions{ID: "1", Rev: "1"})
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	want := []service.DiffLine{
+		{Op: service.OpEqual, Text: "one"},
+		{Op: service.OpDelete, Text: "two"},
+		{Op: service.OpInsert, Text: "2"},
+		{Op: service.OpEqual, Text: "three"},
+		{Op: service.OpInsert, Text: "four"},
+	}
+
+	if diff.From != 1 || diff.To != 2 || !reflect.DeepEqual(diff.Lines, want) {
+		t.Errorf("unexpected diff: %+v", diff)
+	}
+
+	if diff, err = srv.DiffRevisions(ctx, service.DiffRevisions{ID: "1", Rev: "2", To: "1"}); err != nil || diff.Lines[1].Op != service.OpDelete || diff.Lines[1].Text != "2" {
+		t.Errorf("unexpected reverse diff: %+v, %v", diff, err)
+	}
+
+	note, err := srv.RestoreRevision(ctx, service.RestoreRevision{ID: "1", Rev: "1"})
+
+	if err != nil || note.Text != "one\ntwo\nthree" || note.Title != "Plan" || !reflect.DeepEqual(note.Tags, []string{"work"}) {
+		t.Errorf("unexpected restored note: %+v, %v", note, err)
+	}
+
+	if revision, revErr := srv.Revision(ctx, service.GetRevision{ID: "1", Rev: "3"}); revErr != nil || revision.Text != note.Text {
+		t.Errorf("expected the restore to be revision 3, got %+v, %v", revision, revErr)
+	}
+
+	for _, dto := range []service.DiffRevisions{{ID: "1", Rev: "0"}, {ID: "x", Rev: "1"}, {ID: "1", Rev: "1", To: "last"}} {
+		if _, err = srv.DiffRevisions(ctx, dto); !errors.Is(err, service.ErrInvalidInput) {
+			t.Errorf("DiffRevisions(%+v): expected ErrInvalidInput, got %v", dto, err)
+		}
+	}
+
+	if _, err = srv.DiffRevisions(ctx, service.DiffRevisions{ID: "1", Rev: "1", To: "9"}); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	// Texts too far apart are shown as replaced whole rather than searched.
+	older, newer := []string{"title"}, []string{"title"}
+
+	for i := 0; i < 3000; i++ {
+		older = append(older, fmt.Sprintf("old %d", i))
+		newer = append(newer, fmt.Sprintf("new %d", i))
+	}
+
+	if _, err = srv.Create(ctx, service.CreateNote{Text: strings.Join(older, "\n")}); err != nil {
+		t.Fatalf("can't create note: %s", err)
+	}
+
+	if _, err = srv.Update(ctx, service.UpdateNote{ID: "2", Text: strings.Join(newer, "\n")}); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if diff, err = srv.DiffRevisions(ctx, service.DiffRevisions{ID: "2", Rev: "1"}); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if len(diff.Lines) != 6001 {
+		t.Fatalf("expected 6001 lines, got %d", len(diff.Lines))
+	}
+
+	for i, line := range diff.Lines {
+		op := service.OpEqual
+
+		switch {
+		case i > 3000:
+			op = service.OpInsert
+		case i > 0:
+			op = service.OpDelete
+		}
+
+		if line.Op != op {
+			t.Fatalf("line %d: expected %s, got %+v", i, op, line)
+		}
+	}
+}
+
+func TestVersions(t *testing.T) {
+	ctx := service.AsSystem(context.Background())
+	srv := service.NewService(repository.NewMemoryStorage())
+
+	if _, err := srv.Create(ctx, service.CreateNote{Text: "first"}); err != nil {
+		t.Fatalf("can't create note: %s", err)
+	}
+
+	if _, err := srv.Update(ctx, service.UpdateNote{ID: "1", Text: "second", IfVersion: 1}); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if _, err := srv.Update(ctx, service.UpdateNote{ID: "1", Text: "third", IfVersion: 1}); !errors.Is(err, service.ErrPreconditionFailed) {
+		t.Errorf("expected ErrPreconditionFailed, got %v", err)
+	}
+
+	if err := srv.Delete(ctx, service.DeleteNote{ID: "1", IfVersion: 1}); !errors.Is(err, service.ErrPreconditionFailed) {
+		t.Errorf("expected ErrPreconditionFailed, got %v", err)
+	}
+
+	note, err := srv.Get(ctx, service.GetNote{ID: "1"})
+
+	if err != nil || note.Version != 2 || note.Text != "second" {
+		t.Fatalf("unexpected note: %+v, %v", note, err)
+	}
+
+	if err = srv.Delete(ctx, service.DeleteNote{ID: "1", IfVersion: 2}); err != nil {
+		t.Errorf("unexpected err: %s", err)
+	}
+}
+
+func TestPatch(t *testing.T) {
+	ctx := service.AsSystem(context.Background())
+	srv := service.NewService(repository.NewMemoryStorage())
+
+	_, err := srv.Create(ctx, service.CreateNote{Title: "Plan", Text: "milk", Tags: []string{"home", "shop"}, Color: "red"})
+
+	if err != nil {
+		t.Fatalf("can't create note: %s", err)
+	}
+
+	tests := []struct {
+		name  string
+		kind  string
+		patch string
+		err   error
+		check func(*models.Note) bool
+	}{
+		{"merge", service.MergePatch, `{"text": "bread", "title": null, "pinned": true}`, nil,
+			func(n *models.Note) bool { return n.Text == "bread" && n.Title == "" && n.Pinned && n.Color == "red" }},
+		{"merge tags", service.MergePatch, `{"tags": ["Work"]}`, nil,
+			func(n *models.Note) bool { return len(n.Tags) == 1 && n.Tags[0] == "work" }},
+		{"json patch", service.JSONPatch, `[
+			{"op": "test", "path": "/text", "value": "bread"},
+			{"op": "add", "path": "/tags/-", "value": "home"},
+			{"op": "copy", "from": "/text", "path": "/summary"},
+			{"op": "move", "from": "/tags/0", "path": "/tags/1"},
+			{"op": "replace", "path": "/color", "value": "blue"},
+			{"op": "remove", "path": "/pinned"}
+		]`, nil, func(n *models.Note) bool {
+			return n.Summary == "bread" && n.Color == "blue" && !n.Pinned && len(n.Tags) == 2 && n.Version == 4
+		}},
+		{"failed test", service.JSONPatch, `[{"op": "replace", "path": "/text", "value": "x"}, {"op": "test", "path": "/text", "value": "bread"}]`, service.ErrConflict, nil},
+		{"missing member", service.JSONPatch, `[{"op": "remove", "path": "/tags/5"}]`, service.ErrConflict, nil},
+		{"read-only", service.MergePatch, `{"version": 9}`, service.ErrInvalidInput, nil},
+		{"unknown member", service.JSONPatch, `[{"op": "add", "path": "/owner", "value": "me"}]`, service.ErrInvalidInput, nil},
+		{"wrong type", service.MergePatch, `{"pinned": "yes"}`, service.ErrInvalidInput, nil},
+		{"empty text", service.MergePatch, `{"text": null}`, service.ErrInvalidInput, nil},
+		{"unknown op", service.JSONPatch, `[{"op": "append", "path": "/tags"}]`, service.ErrInvalidInput, nil},
+		{"bad pointer", service.JSONPatch, `[{"op": "remove", "path": "tags"}]`, service.ErrInvalidInput, nil},
+		{"bad format", "text/plain", `{}`, service.ErrInvalidInput, nil},
+	}
+
+	for _, tt := range tests {
+		note, patchErr := srv.Patch(ctx, service.PatchNote{ID: "1", Type: tt.kind, Patch: []byte(tt.patch)})
+
+		if tt.err != nil {
+			if !errors.Is(patchErr, tt.err) {
+				t.Errorf("%s: expected %v, got %v", tt.name, tt.err, patchErr)
+			}
+
+			continue
+		}
+
+		if patchErr != nil || !tt.check(note) {
+			t.Errorf("%s: unexpected note %+v, %v", tt.name, note, patchErr)
+		}
+	}
+
+	if _, err = srv.Patch(ctx, service.PatchNote{ID: "1", Type: service.MergePatch, Patch: []byte(`{}`), IfVersion: 1}); !errors.Is(err, service.ErrPreconditionFailed) {
+		t.Errorf("expected ErrPreconditionFailed, got %v", err)
+	}
+
+	if _, err = srv.Patch(ctx, service.PatchNote{ID: "2", Type: service.MergePatch, Patch: []byte(`{}`)}); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	ctx := service.AsSystem(context.Background())
+	srv := service.NewService(repository.NewMemoryStorage())
+
+	if _, err := srv.Create(ctx, service.CreateNote{Text: "first"}); err != nil {
+		t.Fatalf("can't create note: %s", err)
+	}
+
+	for _, batch := range []service.Batch{
+		{Mode: "all", Operations: []service.BatchOperation{{Op: service.BatchDelete, ID: "1"}}},
+		{},
+	} {
+		if _, err := srv.Batch(ctx, batch); !errors.Is(err, service.ErrInvalidInput) {
+			t.Errorf("expected ErrInvalidInput for %+v, got %v", batch, err)
+		}
+	}
+
+	operations := []service.BatchOperation{
+		{Op: service.BatchCreate, Note: []byte(`{"text": "second", "tags": ["Work"]}`)},
+		{Op: service.BatchUpdate, ID: "1", Note: []byte(`{"text": ""}`)},
+		{Op: "rename", ID: "1"},
+		{Op: service.BatchDelete, ID: "1", IfVersion: 1},
+	}
+
+	// An invalid operation stops an atomic batch before the storage.
+	results, err := srv.Batch(ctx, service.Batch{Operations: operations})
+
+	if err != nil || results.Mode != service.BatchAtomic || results.Committed {
+		t.Fatalf("unexpected results: %+v, %v", results, err)
+	}
+
+	for i, want := range []error{service.ErrBatchAborted, service.ErrInvalidInput, service.ErrInvalidInput, service.ErrBatchAborted} {
+		if !errors.Is(results.Results[i].Err, want) {
+			t.Errorf("operation %d: expected %v, got %v", i, want, results.Results[i].Err)
+		}
+	}
+
+	results, err = srv.Batch(ctx, service.Batch{Mode: service.BatchPartial, Operations: operations})
+
+	if err != nil || !results.Committed || results.Results[0].Note == nil || results.Results[0].Note.Tags[0] != "work" || results.Results[3].Err != nil {
+		t.Fatalf("unexpected results: %+v, %v", results, err)
+	}
+
+	if _, err = srv.Get(ctx, service.GetNote{ID: "1"}); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected the note to be deleted, got %v", err)
+	}
+}
+
+func TestAuth(t *testing.T) {
+	ctx := service.AsSystem(context.Background())
+	storage := repository.NewMemoryStorage()
+	auth := service.NewAuthService(storage, time.Hour)
+
+	invalid := []service.Credentials{
+		{Email: "", Password: "long enough"},
+		{Email: "not an email", Password: "long enough"},
+		{Email: "Ann <ann@example.com>", Password: "long enough"},
+		{Email: "ann@example.com", Password: "short"},
+		{Email: "ann@example.com", Password: strings.Repeat("x", 73)},
+	}
+
+	for _, c := range invalid {
+		if _, err := auth.Register(ctx, c); !errors.Is(err, service.ErrInvalidInput) {
+			t.Errorf("Register(%q, %q): expected ErrInvalidInput, got %v", c.Email, c.Password, err)
+		}
+	}
+
+	user, err := auth.Register(ctx, service.Credentials{Email: " Ann@Example.com ", Password: "long enough"})
+
+	if err != nil || user.Email != "ann@example.com" {
+		t.Fatalf("expected a lower-cased email, got %+v, %v", user, err)
+	}
+
+	if user.PasswordHash == "" || strings.Contains(user.PasswordHash, "long enough") {
+		t.Errorf("expected a password hash, got %q", user.PasswordHash)
+	}
+
+	if _, err = auth.Register(ctx, service.Credentials{Email: "ann@example.com", Password: "other password"}); !errors.Is(err, service.ErrConflict) {
+		t.Errorf("expected ErrConflict for a taken email, got %v", err)
+	}
+
+	for _, c := range []service.Credentials{{Email: "ann@example.com", Password: "wrong password"}, {Email: "bob@example.com", Password: "long enough"}} {
+		if _, err = auth.Login(ctx, c); !errors.Is(err, service.ErrUnauthorized) {
+			t.Errorf("Login(%q): expected ErrUnauthorized, got %v", c.Email, err)
+		}
+	}
+
+	session, err := auth.Login(ctx, service.Credentials{Email: "ANN@example.com", Password: "long enough"})
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if session.Token == "" || session.User.ID != user.ID || !session.ExpiresAt.After(time.Now()) {
+		t.Errorf("unexpected session %+v", session)
+	}
+
+	got, err := auth.Authenticate(ctx, session.Token)
+
+	if err != nil || got.ID != user.ID {
+		t.Errorf("expected user %d, got %+v, %v", user.ID, got, err)
+	}
+
+	for _, token := range []string{"", "made up", session.Token + "x"} {
+		if _, err = auth.Authenticate(ctx, token); !errors.Is(err, service.ErrUnauthorized) {
+			t.Errorf("Authenticate(%q): expected ErrUnauthorized, got %v", token, err)
+		}
+	}
+
+	if err = auth.Logout(ctx, session.Token); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if _, err = auth.Authenticate(ctx, session.Token); !errors.Is(err, service.ErrUnauthorized) {
+		t.Errorf("expected ErrUnauthorized after logout, got %v", err)
+	}
+
+	if err = auth.Logout(ctx, session.Token); !errors.Is(err, service.ErrUnauthorized) {
+		t.Errorf("expected ErrUnauthorized for a second logout, got %v", err)
+	}
+
+	// A session of no time ends at once.
+	expired, err := service.NewAuthService(storage, 0).Login(ctx, service.Credentials{Email: "ann@example.com", Password: "long enough"})
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if _, err = auth.Authenticate(ctx, expired.Token); !errors.Is(err, service.ErrUnauthorized) {
+		t.Errorf("expected ErrUnauthorized for an expired session, got %v", err)
+	}
+}
+
+func TestOwners(t *testing.T) {
+	storage := repository.NewMemoryStorage()
+	srv := service.NewService(storage)
+	ann := service.WithUser(context.Background(), &models.User{ID: 1})
+	bob := service.WithUser(context.Background(), &models.User{ID: 2})
+
+	notebook, err := srv.CreateNotebook(ann, service.CreateNotebook{Name: "ann"})
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	// A notebook of another user is as good as missing.
+	_, err = srv.Create(bob, service.CreateNote{Text: "bob", NotebookID: &notebook.ID})
+
+	if !errors.Is(err, service.ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput for a notebook of another user, got %v", err)
+	}
+
+	note, err := srv.Create(ann, service.CreateNote{Text: "ann", NotebookID: &notebook.ID})
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if _, err = srv.Patch(bob, service.PatchNote{ID: strconv.FormatInt(note.ID, 10), Type: service.MergePatch, Patch: []byte(`{"text":"bob"}`)}); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	page, err := srv.GetAll(bob, service.GetNotes{})
+
+	if err != nil || len(page.Notes) != 0 {
+		t.Errorf("expected no notes for bob, got %v, %v", page, err)
+	}
+}
+
+func TestShares(t *testing.T) {
+	storage := repository.NewMemoryStorage()
+	srv := service.NewService(storage)
+	auth := service.NewAuthService(storage, time.Hour)
+	users := map[string]context.Context{}
+
+	for _, name := range []string{"ann", "bob", "cara"} {
+		user, err := auth.Register(context.Background(), service.Credentials{Email: name + "@example.com", Password: "password"})
+
+		if err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+
+		users[name] = service.WithUser(context.Background(), user)
+	}
+
+	ann, bob, cara := users["ann"], users["bob"], users["cara"]
+	note, err := srv.Create(ann, service.CreateNote{Text: "plan", Tags: []string{"work"}})
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	noteID := strconv.FormatInt(note.ID, 10)
+
+	for name, c := range map[string]struct {
+		ctx context.Context
+		dto service.ShareNote
+		err error
+	}{
+		"no user":    {context.Background(), service.ShareNote{ID: noteID, Email: "bob@example.com", Role: service.RoleViewer}, service.ErrUnauthorized},
+		"owner role": {ann, service.ShareNote{ID: noteID, Email: "bob@example.com", Role: service.RoleOwner}, service.ErrInvalidInput},
+		"both":       {ann, service.ShareNote{ID: noteID, Email: "bob@example.com", TeamID: &note.ID, Role: service.RoleViewer}, service.ErrInvalidInput},
+		"unknown":    {ann, service.ShareNote{ID: noteID, Email: "dan@example.com", Role: service.RoleViewer}, service.ErrInvalidInput},
+		"own email":  {ann, service.ShareNote{ID: noteID, Email: "ANN@example.com", Role: service.RoleViewer}, service.ErrInvalidInput},
+		"not a team": {ann, service.ShareNote{ID: noteID, TeamID: &note.ID, Role: service.RoleViewer}, service.ErrInvalidInput},
+		"not owner":  {bob, service.ShareNote{ID: noteID, Email: "cara@example.com", Role: service.RoleViewer}, service.ErrNotFound},
+	} {
+		if _, shareErr := srv.ShareNote(c.ctx, c.dto); !errors.Is(shareErr, c.err) {
+			t.Errorf("%s: expected %v, got %v", name, c.err, shareErr)
+		}
+	}
+
+	share, err := srv.ShareNote(ann, service.ShareNote{ID: noteID, Email: " Bob@example.com", Role: service.RoleViewer})
+
+	if err != nil || share.Email != "bob@example.com" {
+		t.Fatalf("unexpected share %+v, %v", share, err)
+	}
+
+	// A viewer reads the note everywhere notes are listed but can't change it.
+	if _, err = srv.Get(bob, service.GetNote{ID: noteID}); err != nil {
+		t.Errorf("expected a viewer to get the note, got %v", err)
+	}
+
+	if page, _ := srv.GetAll(bob, service.GetNotes{}); len(page.Notes) != 1 {
+		t.Errorf("expected the shared note in the list of a viewer, got %+v", page)
+	}
+
+	if tags, _ := srv.Tags(bob); len(tags.Tags) != 1 {
+		t.Errorf("expected the tags of the shared note, got %+v", tags)
+	}
+
+	if _, err = srv.Update(bob, service.UpdateNote{ID: noteID, Text: "changed"}); !errors.Is(err, service.ErrForbidden) {
+		t.Errorf("expected ErrForbidden for an update by a viewer, got %v", err)
+	}
+
+	if _, err = srv.Patch(bob, service.PatchNote{ID: noteID, Type: service.MergePatch, Patch: []byte(`{"text":"changed"}`)}); !errors.Is(err, service.ErrForbidden) {
+		t.Errorf("expected ErrForbidden for a patch by a viewer, got %v", err)
+	}
+
+	team, err := srv.CreateTeam(ann, service.CreateTeam{Name: " crew "})
+
+	if err != nil || team.Name != "crew" {
+		t.Fatalf("unexpected team %+v, %v", team, err)
+	}
+
+	teamID := strconv.FormatInt(team.ID, 10)
+
+	if _, err = srv.AddMember(bob, service.AddMember{ID: teamID, Email: "cara@example.com"}); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a team of another user, got %v", err)
+	}
+
+	if team, err = srv.AddMember(ann, service.AddMember{ID: teamID, Email: "cara@example.com"}); err != nil || len(team.Members) != 2 {
+		t.Fatalf("unexpected team %+v, %v", team, err)
+	}
+
+	if err = srv.DeleteTeam(cara, service.DeleteTeam{ID: teamID}); !errors.Is(err, service.ErrForbidden) {
+		t.Errorf("expected ErrForbidden for a team deleted by a member, got %v", err)
+	}
+
+	if _, err = srv.ShareNote(ann, service.ShareNote{ID: noteID, TeamID: &team.ID, Role: service.RoleEditor}); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if _, err = srv.Update(cara, service.UpdateNote{ID: noteID, Text: "team plan"}); err != nil {
+		t.Errorf("expected a team editor to update the note, got %v", err)
+	}
+
+	if err = srv.Delete(cara, service.DeleteNote{ID: noteID}); !errors.Is(err, service.ErrForbidden) {
+		t.Errorf("expected ErrForbidden for a delete by an editor, got %v", err)
+	}
+
+	if err = srv.RemoveMember(ann, service.RemoveMember{ID: teamID, UserID: strconv.FormatInt(team.OwnerID, 10)}); !errors.Is(err, service.ErrConflict) {
+		t.Errorf("expected ErrConflict for the owner leaving, got %v", err)
+	}
+
+	if err = srv.RemoveMember(cara, service.RemoveMember{ID: teamID, UserID: strconv.FormatInt(team.Members[1].UserID, 10)}); err != nil {
+		t.Errorf("expected a member to leave, got %v", err)
+	}
+
+	if _, err = srv.Get(cara, service.GetNote{ID: noteID}); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound once cara left, got %v", err)
+	}
+}
+
+func TestLinks(t *testing.T) {
+	storage := repository.NewMemoryStorage()
+	srv := service.NewService(storage)
+	auth := service.NewAuthService(storage, time.Hour)
+	users := map[string]context.Context{}
+
+	for _, name := range []string{"ann", "bob"} {
+		user, err := auth.Register(context.Background(), service.Credentials{Email: name + "@example.com", Password: "password"})
+
+		if err != nil {
+			t.Fatalf("unexpected err: %s", err)
+		}
+
+		users[name] = service.WithUser(context.Background(), user)
+	}
+
+	ann, bob, anyone := users["ann"], users["bob"], context.Background()
+	note, err := srv.Create(ann, service.CreateNote{Text: "public plan"})
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	noteID := strconv.FormatInt(note.ID, 10)
+
+	for name, c := range map[string]struct {
+		ctx context.Context
+		dto service.CreateLink
+		err error
+	}{
+		"bad ttl":   {ann, service.CreateLink{ID: noteID, ExpiresIn: "soon"}, service.ErrInvalidInput},
+		"long ttl":  {ann, service.CreateLink{ID: noteID, ExpiresIn: "9000h"}, service.ErrInvalidInput},
+		"short pw":  {ann, service.CreateLink{ID: noteID, Password: "short"}, service.ErrInvalidInput},
+		"not owner": {bob, service.CreateLink{ID: noteID}, service.ErrNotFound},
+	} {
+		if _, linkErr := srv.CreateLink(c.ctx, c.dto); !errors.Is(linkErr, c.err) {
+			t.Errorf("%s: expected %v, got %v", name, c.err, linkErr)
+		}
+	}
+
+	link, err := srv.CreateLink(ann, service.CreateLink{ID: noteID})
+
+	if err != nil || link.Token == "" || link.Protected || !link.ExpiresAt.After(time.Now().Add(6*24*time.Hour)) {
+		t.Fatalf("unexpected link %+v, %v", link, err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if opened, openErr := srv.OpenLink(anyone, service.OpenLink{Token: link.Token}); openErr != nil || opened.Text != "public plan" {
+			t.Fatalf("unexpected note %+v, %v", opened, openErr)
+		}
+	}
+
+	if _, err = srv.OpenLink(anyone, service.OpenLink{Token: link.Token + "x"}); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an unknown token, got %v", err)
+	}
+
+	protected, err := srv.CreateLink(ann, service.CreateLink{ID: noteID, Password: "open sesame"})
+
+	if err != nil || !protected.Protected {
+		t.Fatalf("unexpected link %+v, %v", protected, err)
+	}
+
+	for password, want := range map[string]error{"": service.ErrUnauthorized, "wrong one": service.ErrUnauthorized, "open sesame": nil} {
+		if _, err = srv.OpenLink(anyone, service.OpenLink{Token: protected.Token, Password: password}); !errors.Is(err, want) {
+			t.Errorf("password %q: expected %v, got %v", password, want, err)
+		}
+	}
+
+	// Tokens are never listed again, but the views are.
+	links, err := srv.Links(ann, service.GetLinks{ID: noteID})
+
+	if err != nil || len(links.Links) != 2 || links.Links[0].Token != "" || links.Links[0].Views != 2 || links.Links[1].Views != 1 {
+		t.Fatalf("unexpected links %+v, %v", links, err)
+	}
+
+	linkID := strconv.FormatInt(link.ID, 10)
+
+	if err = srv.RevokeLink(bob, service.RevokeLink{ID: noteID, LinkID: linkID}); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a link revoked by another user, got %v", err)
+	}
+
+	if err = srv.RevokeLink(ann, service.RevokeLink{ID: noteID, LinkID: linkID}); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if _, err = srv.OpenLink(anyone, service.OpenLink{Token: link.Token}); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a revoked link, got %v", err)
+	}
+
+	expiring, err := srv.CreateLink(ann, service.CreateLink{ID: noteID, ExpiresIn: "1s"})
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	time.Sleep(time.Second)
+
+	if _, err = srv.OpenLink(anyone, service.OpenLink{Token: expiring.Token}); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an expired link, got %v", err)
+	}
+
+	if err = srv.Delete(ann, service.DeleteNote{ID: noteID}); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if _, err = srv.OpenLink(anyone, service.OpenLink{Token: protected.Token, Password: "open sesame"}); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a link to a trashed note, got %v", err)
+	}
+}
+
+func TestTokens(t *testing.T) {
+	storage := repository.NewMemoryStorage()
+	auth := service.NewAuthService(storage, time.Hour)
+	user, err := auth.Register(context.Background(), service.Credentials{Email: "ann@example.com", Password: "password"})
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	ctx := service.WithUser(context.Background(), user)
+
+	for name, c := range map[string]struct {
+		ctx context.Context
+		dto service.CreateToken
+		err error
+	}{
+		"no user":   {context.Background(), service.CreateToken{Name: "backup", Scopes: []string{service.ScopeNotesRead}}, service.ErrUnauthorized},
+		"no name":   {ctx, service.CreateToken{Name: " ", Scopes: []string{service.ScopeNotesRead}}, service.ErrInvalidInput},
+		"no scopes": {ctx, service.CreateToken{Name: "backup"}, service.ErrInvalidInput},
+		"unknown":   {ctx, service.CreateToken{Name: "backup", Scopes: []string{"notes:admin"}}, service.ErrInvalidInput},
+		"scoped":    {service.WithScopes(ctx, []string{service.ScopeNotesWrite}), service.CreateToken{Name: "more", Scopes: []string{service.ScopeNotesWrite}}, service.ErrForbidden},
+	} {
+		if _, tokenErr := auth.CreateToken(c.ctx, c.dto); !errors.Is(tokenErr, c.err) {
+			t.Errorf("%s: expected %v, got %v", name, c.err, tokenErr)
+		}
+	}
+
+	scopes := []string{service.ScopeNotesWrite, service.ScopeNotesRead, service.ScopeNotesWrite}
+	token, err := auth.CreateToken(ctx, service.CreateToken{Name: " backup ", Scopes: scopes})
+
+	if err != nil || token.Name != "backup" || !service.IsAccessToken(token.Token) || !reflect.DeepEqual(token.Scopes, []string{"notes:read", "notes:write"}) {
+		t.Fatalf("unexpected token %+v, %v", token, err)
+	}
+
+	if _, err = auth.CreateToken(ctx, service.CreateToken{Name: "backup", Scopes: scopes}); !errors.Is(err, service.ErrConflict) {
+		t.Errorf("expected ErrConflict for a repeated name, got %v", err)
+	}
+
+	if found, granted, authErr := auth.AuthenticateToken(context.Background(), token.Token); authErr != nil || found.ID != user.ID || len(granted) != 2 {
+		t.Fatalf("unexpected user %+v with %v, %v", found, granted, authErr)
+	}
+
+	// A session token is no access token and the other way around.
+	if _, _, err = auth.AuthenticateToken(context.Background(), token.Token+"x"); !errors.Is(err, service.ErrUnauthorized) {
+		t.Errorf("expected ErrUnauthorized for an unknown token, got %v", err)
+	}
+
+	if _, err = auth.Authenticate(context.Background(), token.Token); !errors.Is(err, service.ErrUnauthorized) {
+		t.Errorf("expected ErrUnauthorized for an access token used as a session, got %v", err)
+	}
+
+	tokens, err := auth.Tokens(ctx)
+
+	if err != nil || len(tokens.Tokens) != 1 || tokens.Tokens[0].Token != "" || tokens.Tokens[0].LastUsedAt == nil {
+		t.Fatalf("unexpected tokens %+v, %v", tokens, err)
+	}
+
+	tokenID := strconv.FormatInt(token.ID, 10)
+
+	if err = auth.RevokeToken(ctx, service.RevokeToken{ID: "x"}); !errors.Is(err, service.ErrInvalidInput) {
+		t.Errorf("expected ErrInvalidInput for a bad id, got %v", err)
+	}
+
+	if err = auth.RevokeToken(ctx, service.RevokeToken{ID: tokenID}); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if _, _, err = auth.AuthenticateToken(context.Background(), token.Token); !errors.Is(err, service.ErrUnauthorized) {
+		t.Errorf("expected ErrUnauthorized for a revoked token, got %v", err)
+	}
+
+	if err = auth.RevokeToken(ctx, service.RevokeToken{ID: tokenID}); !errors.Is(err, service.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a revoked token, got %v", err)
+	}
+}
+
+// identityProvider logs in identity for the code "code", with the nonce of
+// the last login unless the identity has one.
+type identityProvider struct {
+	identity service.Identity
+	nonce    string
+}
+
+func (p *identityProvider) AuthURL(state, nonce, codeChallenge string) string {
+	p.nonce = nonce
+
+	return "https://idp.example.com/authorize?state=" + state + "&code_challenge=" + codeChallenge
+}
+
+func (p *identityProvider) Exchange(ctx context.Context, code, codeVerifier string) (*service.Identity, error) {
+	if code != "code" || codeVerifier == "" {
+		return nil, fmt.Errorf("%w: bad code", service.ErrUnauthorized)
+	}
+
+	identity := p.identity
+
+	if identity.Nonce == "" {
+		identity.Nonce = p.nonce
+	}
+
+	return &identity, nil
+}
+
+func TestOIDC(t *testing.T) {
+	storage := repository.NewMemoryStorage()
+	auth := service.NewAuthService(storage, time.Hour)
+	provider := &identityProvider{}
+	srv := service.NewOIDCService(storage, time.Hour, provider)
+	ctx := service.AsSystem(context.Background())
+	ann, err := auth.Register(ctx, service.Credentials{Email: "ann@example.com", Password: "password"})
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	login := func(identity service.Identity) (*service.Session, error) {
+		provider.identity = identity
+		redirect, beginErr := srv.BeginLogin(ctx)
+
+		if beginErr != nil || !strings.Contains(redirect.URL, redirect.State) {
+			t.Fatalf("unexpected redirect %+v, %v", redirect, beginErr)
+		}
+
+		return srv.FinishLogin(ctx, service.FinishLogin{State: redirect.State, Code: "code", BrowserState: redirect.State})
+	}
+
+	issuer := "https://idp.example.com"
+
+	// A new identity joins the user with its verified email, then its
+	// subject is enough.
+	session, err := login(service.Identity{Issuer: issuer, Subject: "1", Email: " Ann@example.com", EmailVerified: true})
+
+	if err != nil || session.User.ID != ann.ID || session.Token == "" {
+		t.Fatalf("unexpected session %+v, %v", session, err)
+	}
+
+	if session, err = login(service.Identity{Issuer: issuer, Subject: "1", Email: "ann@other.example.com"}); err != nil || session.User.ID != ann.ID {
+		t.Errorf("expected the subject to find ann, got %+v, %v", session, err)
+	}
+
+	if user, authErr := auth.Authenticate(ctx, session.Token); authErr != nil || user.ID != ann.ID {
+		t.Errorf("expected the session to authenticate, got %+v, %v", user, authErr)
+	}
+
+	session, err = login(service.Identity{Issuer: issuer, Subject: "2", Email: "bob@example.com", EmailVerified: true})
+
+	if err != nil || session.User.Email != "bob@example.com" || session.User.ID == ann.ID {
+		t.Fatalf("expected a new user, got %+v, %v", session, err)
+	}
+
+	if _, err = auth.Login(ctx, service.Credentials{Email: "bob@example.com", Password: ""}); err == nil {
+		t.Error("expected a user of the identity provider to have no password")
+	}
+
+	for name, identity := range map[string]service.Identity{
+		"unverified": {Issuer: issuer, Subject: "3", Email: "cara@example.com"},
+		"no email":   {Issuer: issuer, Subject: "3", EmailVerified: true},
+		"nonce":      {Issuer: issuer, Subject: "1", Nonce: "another login"},
+		"issuer":     {Issuer: "https://evil.example.com", Subject: "1", Email: "ann@example.com"},
+	} {
+		if _, err = login(identity); !errors.Is(err, service.ErrUnauthorized) {
+			t.Errorf("%s: expected ErrUnauthorized, got %v", name, err)
+		}
+	}
+
+	redirect, err := srv.BeginLogin(ctx)
+
+	if err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	for name, c := range map[string]struct {
+		dto service.FinishLogin
+		err error
+	}{
+		"refused":       {service.FinishLogin{State: redirect.State, Error: "access_denied", BrowserState: redirect.State}, service.ErrUnauthorized},
+		"other browser": {service.FinishLogin{State: redirect.State, Code: "code", BrowserState: "mine"}, service.ErrUnauthorized},
+		"no cookie":     {service.FinishLogin{State: redirect.State, Code: "code"}, service.ErrUnauthorized},
+		"unknown state": {service.FinishLogin{State: "made up", Code: "code", BrowserState: "made up"}, service.ErrUnauthorized},
+	} {
+		if _, finishErr := srv.FinishLogin(ctx, c.dto); !errors.Is(finishErr, c.err) {
+			t.Errorf("%s: expected %v, got %v", name, c.err, finishErr)
+		}
+	}
+
+	provider.identity = service.Identity{Issuer: issuer, Subject: "1"}
+	finish := service.FinishLogin{State: redirect.State, Code: "code", BrowserState: redirect.State}
+
+	if _, err = srv.FinishLogin(ctx, finish); err != nil {
+		t.Fatalf("unexpected err: %s", err)
+	}
+
+	if _, err = srv.FinishLogin(ctx, finish); !errors.Is(err, service.ErrUnauthorized) {
+		t.Errorf("expected ErrUnauthorized for a state used twice, got %v", err)
+	}
+}
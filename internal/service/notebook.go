@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"note/internal/models"
+	"strconv"
+	"strings"
+)
+
+const maxNotebookName = 255
+
+type NotebookStorage interface {
+	GetNotebook(context.Context, string) (*models.Notebook, error)
+	CreateNotebook(context.Context, NotebookFields) (*models.Notebook, error)
+	UpdateNotebook(context.Context, string, NotebookFields) error
+	DeleteNotebook(context.Context, string, bool) error
+	Notebooks(context.Context) ([]*models.Notebook, error)
+	MoveNote(context.Context, string, *int64) error
+}
+
+// NotebookFields are the validated fields of a notebook to store. Storages
+// refuse to move a notebook under itself or its descendants with
+// ErrConflict.
+type NotebookFields struct {
+	Name     string
+	ParentID *int64
+}
+
+type NotebookList struct {
+	Notebooks []*models.Notebook `json:"notebooks"`
+}
+
+// NotebookContents is a notebook with its child notebooks, or all of its
+// descendants when listed recursively, and one page of their notes.
+type NotebookContents struct {
+	Notebook   *models.Notebook   `json:"notebook"`
+	Notebooks  []*models.Notebook `json:"notebooks"`
+	Notes      []*models.Note     `json:"notes"`
+	NextCursor string             `json:"nextCursor,omitempty"`
+}
+
+func (s *service) CreateNotebook(ctx context.Context, dto CreateNotebook) (*models.Notebook, error) {
+	if err := validate(checkName(dto.Name), checkRef("parentId", dto.ParentID)); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkNotebookExists(ctx, "parentId", dto.ParentID); err != nil {
+		return nil, err
+	}
+
+	return s.storage.CreateNotebook(ctx, NotebookFields{Name: strings.TrimSpace(dto.Name), ParentID: dto.ParentID})
+}
+
+func (s *service) UpdateNotebook(ctx context.Context, dto UpdateNotebook) error {
+	if err := validate(checkID(dto.ID), checkName(dto.Name), checkRef("parentId", dto.ParentID)); err != nil {
+		return err
+	}
+
+	if err := s.checkNotebookExists(ctx, "parentId", dto.ParentID); err != nil {
+		return err
+	}
+
+	return s.storage.UpdateNotebook(ctx, dto.ID, NotebookFields{Name: strings.TrimSpace(dto.Name), ParentID: dto.ParentID})
+}
+
+// DeleteNotebook removes an empty notebook. With Recursive it also removes
+// the child notebooks and moves every note in them to the trash.
+func (s *service) DeleteNotebook(ctx context.Context, dto DeleteNotebook) error {
+	if err := validate(checkID(dto.ID)); err != nil {
+		return err
+	}
+
+	return s.storage.DeleteNotebook(ctx, dto.ID, dto.Recursive)
+}
+
+func (s *service) GetNotebook(ctx context.Context, dto GetNotebook) (*models.Notebook, error) {
+	if err := validate(checkID(dto.ID)); err != nil {
+		return nil, err
+	}
+
+	return s.storage.GetNotebook(ctx, dto.ID)
+}
+
+func (s *service) Notebooks(ctx context.Context) (*NotebookList, error) {
+	notebooks, err := s.storage.Notebooks(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &NotebookList{Notebooks: notebooks}, nil
+}
+
+func (s *service) GetNotebookContents(ctx context.Context, dto GetNotebookContents) (*NotebookContents, error) {
+	if err := validate(checkID(dto.ID)); err != nil {
+		return nil, err
+	}
+
+	query, err := newNotesQuery(dto.Notes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	notebook, err := s.storage.GetNotebook(ctx, dto.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	all, err := s.storage.Notebooks(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	contents := &NotebookContents{Notebook: notebook, Notebooks: subtree(all, notebook.ID, dto.Recursive)}
+	query.Notebooks = []int64{notebook.ID}
+
+	if dto.Recursive {
+		for _, child := range contents.Notebooks {
+			query.Notebooks = append(query.Notebooks, child.ID)
+		}
+	}
+
+	page, err := s.page(ctx, query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	contents.Notes, contents.NextCursor = page.Notes, page.NextCursor
+
+	return contents, nil
+}
+
+func (s *service) MoveNote(ctx context.Context, dto MoveNote) error {
+	if err := validate(checkID(dto.ID), checkRef("notebookId", dto.NotebookID)); err != nil {
+		return err
+	}
+
+	if err := s.checkNotebookExists(ctx, "notebookId", dto.NotebookID); err != nil {
+		return err
+	}
+
+	return s.storage.MoveNote(ctx, dto.ID, dto.NotebookID)
+}
+
+// subtree returns the children of a notebook, or all of its descendants
+// parents first, from the list of every notebook.
+func subtree(all []*models.Notebook, id int64, recursive bool) []*models.Notebook {
+	children := map[int64][]*models.Notebook{}
+
+	for _, notebook := range all {
+		if notebook.ParentID != nil {
+			children[*notebook.ParentID] = append(children[*notebook.ParentID], notebook)
+		}
+	}
+
+	result := []*models.Notebook{}
+	queue := []int64{id}
+
+	for len(queue) > 0 {
+		next := children[queue[0]]
+		queue = queue[1:]
+		result = append(result, next...)
+
+		if !recursive {
+			break
+		}
+
+		for _, child := range next {
+			queue = append(queue, child.ID)
+		}
+	}
+
+	return result
+}
+
+// checkNotebookExists reports a missing notebook as an invalid field rather
+// than a missing resource, since it is referenced from the request body.
+func (s *service) checkNotebookExists(ctx context.Context, field string, id *int64) error {
+	if id == nil {
+		return nil
+	}
+
+	_, err := s.storage.GetNotebook(ctx, strconv.FormatInt(*id, 10))
+
+	if errors.Is(err, ErrNotFound) {
+		return validate(&FieldError{Field: field, Reason: fmt.Sprintf("notebook %d does not exist", *id)})
+	}
+
+	return err
+}
+
+func checkName(name string) *FieldError {
+	name = strings.TrimSpace(name)
+
+	if name == "" || len([]rune(name)) > maxNotebookName {
+		return &FieldError{Field: "name", Reason: fmt.Sprintf("must be 1-%d characters", maxNotebookName)}
+	}
+
+	return nil
+}
+
+func checkRef(field string, id *int64) *FieldError {
+	if id != nil && *id <= 0 {
+		return &FieldError{Field: field, Reason: "must be a positive integer or null"}
+	}
+
+	return nil
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"note/internal/models"
+	"time"
+)
+
+// loginStateTTL is how long a user has to log in at the identity provider.
+const loginStateTTL = 10 * time.Minute
+
+// Identity is who the identity provider says the user is, taken from a
+// verified ID token.
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Nonce         string
+}
+
+// IdentityProvider runs the OpenID Connect authorization code flow with
+// PKCE at an identity provider.
+type IdentityProvider interface {
+	// AuthURL is where to send the user to log in.
+	AuthURL(state, nonce, codeChallenge string) string
+	// Exchange trades a code for an ID token, verifies it and returns its
+	// identity. A token that fails verification is ErrUnauthorized.
+	Exchange(ctx context.Context, code, codeVerifier string) (*Identity, error)
+}
+
+// IdentityStorage keeps the external identities of users and the logins
+// in progress at the identity provider.
+type IdentityStorage interface {
+	IdentityUser(ctx context.Context, issuer, subject string) (*models.User, error)
+	// CreateIdentity fails with ErrConflict when the identity belongs to a
+	// user already.
+	CreateIdentity(ctx context.Context, issuer, subject string, userID int64) error
+	CreateLoginState(context.Context, LoginState) error
+	// TakeLoginState finds a login that hasn't expired at now and deletes
+	// it, so a state is used once.
+	TakeLoginState(ctx context.Context, stateHash string, now time.Time) (*LoginState, error)
+}
+
+// LoginState is what a login keeps while the user is at the identity
+// provider. Like tokens, the state is stored by its hash.
+type LoginState struct {
+	StateHash    string
+	Nonce        string
+	CodeVerifier string
+	ExpiresAt    time.Time
+}
+
+// LoginRedirect starts a login: the client goes to URL and has to bring
+// State back, which the handler keeps in a cookie.
+type LoginRedirect struct {
+	URL       string
+	State     string
+	ExpiresAt time.Time
+}
+
+type oidcService struct {
+	auth     *authService
+	provider IdentityProvider
+}
+
+// NewOIDCService logs users in at provider with sessions that last
+// sessionTTL, like those of NewAuthService.
+func NewOIDCService(storage UserStorage, sessionTTL time.Duration, provider IdentityProvider) *oidcService {
+	return &oidcService{auth: &authService{storage: storage, ttl: sessionTTL}, provider: provider}
+}
+
+// BeginLogin makes the state, nonce and PKCE verifier of a login and
+// returns where to send the user.
+func (s *oidcService) BeginLogin(ctx context.Context) (*LoginRedirect, error) {
+	secrets := make([]string, 3)
+
+	for i := range secrets {
+		secret, err := newToken()
+
+		if err != nil {
+			return nil, err
+		}
+
+		secrets[i] = secret
+	}
+
+	state, nonce, verifier := secrets[0], secrets[1], secrets[2]
+	expiresAt := time.Now().UTC().Add(loginStateTTL).Truncate(time.Second)
+	login := LoginState{StateHash: hashToken(state), Nonce: nonce, CodeVerifier: verifier, ExpiresAt: expiresAt}
+
+	if err := s.auth.storage.CreateLoginState(ctx, login); err != nil {
+		return nil, err
+	}
+
+	return &LoginRedirect{URL: s.provider.AuthURL(state, nonce, codeChallenge(verifier)), State: state, ExpiresAt: expiresAt}, nil
+}
+
+// FinishLogin checks the callback of the identity provider, verifies the
+// ID token and starts a session for the local user of the identity.
+func (s *oidcService) FinishLogin(ctx context.Context, dto FinishLogin) (*Session, error) {
+	if dto.Error != "" {
+		return nil, fmt.Errorf("%w: the identity provider refused the login: %s %s", ErrUnauthorized, dto.Error, dto.ErrorDescription)
+	}
+
+	// The state has to come back to the browser that started the login,
+	// or anyone could log a victim into their own account.
+	if dto.State == "" || subtle.ConstantTimeCompare([]byte(dto.State), []byte(dto.BrowserState)) != 1 {
+		return nil, fmt.Errorf("%w: the login state doesn't match", ErrUnauthorized)
+	}
+
+	login, err := s.auth.storage.TakeLoginState(ctx, hashToken(dto.State), time.Now().UTC())
+
+	if errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("%w: the login has expired or was finished already", ErrUnauthorized)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if dto.Code == "" {
+		return nil, validate(&FieldError{Field: "code", Reason: "must not be empty"})
+	}
+
+	identity, err := s.provider.Exchange(ctx, dto.Code, login.CodeVerifier)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(identity.Nonce), []byte(login.Nonce)) != 1 {
+		return nil, fmt.Errorf("%w: the ID token is for another login", ErrUnauthorized)
+	}
+
+	user, err := s.localUser(ctx, identity)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.auth.startSession(ctx, user)
+}
+
+// localUser finds the user of an identity. A new identity joins the user
+// with its email, which the identity provider has to have verified, or
+// registers one without a password.
+func (s *oidcService) localUser(ctx context.Context, identity *Identity) (*models.User, error) {
+	user, err := s.auth.storage.IdentityUser(ctx, identity.Issuer, identity.Subject)
+
+	if !errors.Is(err, ErrNotFound) {
+		return user, err
+	}
+
+	email := normalizeEmail(identity.Email)
+
+	if !identity.EmailVerified || checkEmail(email) != nil {
+		return nil, fmt.Errorf("%w: the identity provider didn't give a verified email", ErrUnauthorized)
+	}
+
+	user, err = s.auth.storage.UserByEmail(ctx, email)
+
+	if errors.Is(err, ErrNotFound) {
+		user, err = s.auth.storage.CreateUser(ctx, email, "")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.auth.storage.CreateIdentity(ctx, identity.Issuer, identity.Subject, user.ID); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// codeChallenge is the S256 PKCE challenge of a verifier.
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
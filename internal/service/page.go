@@ -0,0 +1,201 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"note/internal/models"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 100
+)
+
+// NotesQuery is a validated GetNotes. Storages return up to Limit notes
+// ordered by Sort, strictly after After when it is set. Notes must carry
+// all of Tags, or any of them with AnyTag, and lie in one of Notebooks
+// when it is set. Nil Pinned and Archived and an empty Color don't filter.
+// Trashed lists the notes in the trash instead of the others.
+type NotesQuery struct {
+	Sort      Sort
+	Limit     int
+	After     *models.Note
+	Tags      []string
+	AnyTag    bool
+	Notebooks []int64
+	Pinned    *bool
+	Archived  *bool
+	Color     string
+	Trashed   bool
+}
+
+type NotesPage struct {
+	Notes      []*models.Note `json:"notes"`
+	NextCursor string         `json:"nextCursor,omitempty"`
+}
+
+// cursor is the keyset position after the last note of a page: the values
+// of its sort fields. It is encoded as opaque base64 JSON. Only SortText of
+// the text is kept, so the cursor is bounded however long the note is.
+type cursor struct {
+	Sort      string     `json:"o"`
+	ID        int64      `json:"id"`
+	Text      *string    `json:"t,omitempty"`
+	CreatedAt *time.Time `json:"c,omitempty"`
+	UpdatedAt *time.Time `json:"u,omitempty"`
+}
+
+func encodeCursor(sort Sort, note *models.Note) string {
+	c := cursor{Sort: sort.String(), ID: note.ID}
+
+	for _, f := range sort {
+		switch f.Field {
+		case "text":
+			text := SortText(note.Text)
+			c.Text = &text
+		case "created_at":
+			c.CreatedAt = &note.CreatedAt
+		case "updated_at":
+			c.UpdatedAt = &note.UpdatedAt
+		}
+	}
+
+	data, _ := json.Marshal(c)
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(token string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+
+	if err != nil {
+		return nil, err
+	}
+
+	c := &cursor{}
+
+	if err = json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// anchor turns the cursor back into a note carrying the sort field values,
+// or reports that a value the sort needs is missing.
+func (c *cursor) anchor(sort Sort) (*models.Note, bool) {
+	note := &models.Note{ID: c.ID}
+
+	for _, f := range sort {
+		switch f.Field {
+		case "text":
+			if c.Text == nil || *c.Text != SortText(*c.Text) {
+				return nil, false
+			}
+
+			note.Text = *c.Text
+		case "created_at":
+			if c.CreatedAt == nil {
+				return nil, false
+			}
+
+			note.CreatedAt = c.CreatedAt.UTC()
+		case "updated_at":
+			if c.UpdatedAt == nil {
+				return nil, false
+			}
+
+			note.UpdatedAt = c.UpdatedAt.UTC()
+		}
+	}
+
+	return note, true
+}
+
+func newNotesQuery(dto GetNotes) (NotesQuery, error) {
+	query := NotesQuery{Limit: DefaultLimit}
+	fields := []*FieldError{}
+
+	if dto.Limit != "" {
+		limit, err := strconv.Atoi(dto.Limit)
+
+		if err != nil || limit < 1 || limit > MaxLimit {
+			fields = append(fields, &FieldError{Field: "limit", Reason: fmt.Sprintf("must be an integer between 1 and %d", MaxLimit)})
+		}
+
+		query.Limit = limit
+	}
+
+	fields = append(fields, query.filter(dto)...)
+
+	if dto.Cursor == "" {
+		sort, invalid := parseSort(dto.OrderBy)
+		query.Sort = sort
+
+		return query, validate(append(fields, invalid)...)
+	}
+
+	// A cursor carries its own order, order_by may only repeat it.
+	c, err := decodeCursor(dto.Cursor)
+
+	if err != nil {
+		return query, validate(append(fields, &FieldError{Field: "cursor", Reason: "is malformed"})...)
+	}
+
+	sort, invalid := parseSort(c.Sort)
+	anchor, ok := c.anchor(sort)
+
+	if c.Sort == "" || invalid != nil || !ok {
+		fields = append(fields, &FieldError{Field: "cursor", Reason: "is malformed"})
+	} else if dto.OrderBy != "" {
+		requested, invalidOrder := parseSort(dto.OrderBy)
+
+		switch {
+		case invalidOrder != nil:
+			fields = append(fields, invalidOrder)
+		case requested.String() != sort.String():
+			fields = append(fields, &FieldError{Field: "cursor", Reason: "was issued for a different order_by"})
+		}
+	}
+
+	query.Sort, query.After = sort, anchor
+
+	return query, validate(fields...)
+}
+
+// filter reads the filters of a GetNotes.
+func (q *NotesQuery) filter(dto GetNotes) []*FieldError {
+	fields := []*FieldError{}
+	tags, invalidTags := normalizeTags(dto.Tags)
+	q.Tags = tags
+
+	if invalidTags != nil {
+		// The query parameter is "tag", repeated.
+		invalidTags.Field = "tag"
+		fields = append(fields, invalidTags)
+	}
+
+	switch dto.TagMode {
+	case "", "all":
+	case "any":
+		q.AnyTag = true
+	default:
+		fields = append(fields, &FieldError{Field: "tag_mode", Reason: "must be all or any"})
+	}
+
+	var invalidPinned, invalidArchived *FieldError
+
+	q.Pinned, invalidPinned = parseFlag("pinned", dto.Pinned)
+	q.Archived, invalidArchived = parseFlag("archived", dto.Archived)
+	fields = append(fields, invalidPinned, invalidArchived)
+
+	if dto.Color != "" {
+		q.Color = dto.Color
+		fields = append(fields, checkColor("color", dto.Color))
+	}
+
+	return fields
+}
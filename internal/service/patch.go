@@ -0,0 +1,171 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"note/internal/models"
+	"reflect"
+	"strconv"
+)
+
+// patchRetries bounds how often Patch starts over when the note changes
+// between reading and writing it.
+const patchRetries = 3
+
+// readOnly are the members of a note a patch must leave as they are. Notes
+// change notebooks with MoveNote.
+var readOnly = []string{"id", "notebookId", "createdAt", "updatedAt", "version", "deletedAt"}
+
+// patchedNote is what a patch may change.
+type patchedNote struct {
+	Title    string   `json:"title"`
+	Text     string   `json:"text"`
+	Summary  string   `json:"summary"`
+	Tags     []string `json:"tags"`
+	Color    string   `json:"color"`
+	Pinned   bool     `json:"pinned"`
+	Archived bool     `json:"archived"`
+}
+
+// Patch applies a merge patch or a JSON patch to the JSON of the note and
+// saves the result as an update of the version it was applied to, so a
+// concurrent change can't be lost: Patch then starts over with the new
+// version, or fails with ErrPreconditionFailed if dto.IfVersion is set.
+func (s *service) Patch(ctx context.Context, dto PatchNote) (*models.Note, error) {
+	if err := validate(checkID(dto.ID)); err != nil {
+		return nil, err
+	}
+
+	apply, err := parsePatch(dto.Type, dto.Patch)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		note, getErr := s.storage.Get(reading(ctx), dto.ID)
+
+		if getErr != nil {
+			return nil, getErr
+		}
+
+		if dto.IfVersion != 0 && note.Version != dto.IfVersion {
+			return nil, fmt.Errorf("%w: note %s is no longer at version %d", ErrPreconditionFailed, dto.ID, dto.IfVersion)
+		}
+
+		update, patchErr := patchNote(note, apply)
+
+		if patchErr != nil {
+			return nil, patchErr
+		}
+
+		update.IfVersion = note.Version
+		updated, updateErr := s.Update(ctx, update)
+
+		if errors.Is(updateErr, ErrPreconditionFailed) && dto.IfVersion == 0 {
+			if attempt < patchRetries {
+				continue
+			}
+
+			return nil, fmt.Errorf("%w: note %s keeps changing, try again", ErrConflict, dto.ID)
+		}
+
+		if updateErr != nil {
+			return nil, updateErr
+		}
+
+		return updated, nil
+	}
+}
+
+// patchNote applies a patch to the JSON of a note and turns the result into
+// an update replacing every field a patch may change.
+func patchNote(note *models.Note, apply patch) (UpdateNote, error) {
+	data, err := json.Marshal(note)
+
+	if err != nil {
+		return UpdateNote{}, err
+	}
+
+	var original map[string]interface{}
+
+	if err = decodeJSON(data, &original); err != nil {
+		return UpdateNote{}, err
+	}
+
+	result, err := apply(copyJSON(original))
+
+	if err != nil {
+		return UpdateNote{}, err
+	}
+
+	patched, ok := result.(map[string]interface{})
+
+	if !ok {
+		return UpdateNote{}, validate(&FieldError{Field: "patch", Reason: "must leave the note an object"})
+	}
+
+	checks := []*FieldError{}
+
+	for _, field := range readOnly {
+		if !reflect.DeepEqual(original[field], patched[field]) {
+			checks = append(checks, &FieldError{Field: field, Reason: "can't be changed by a patch"})
+		}
+
+		delete(patched, field)
+	}
+
+	if err = validate(checks...); err != nil {
+		return UpdateNote{}, err
+	}
+
+	fields, err := decodePatched(patched)
+
+	if err != nil {
+		return UpdateNote{}, err
+	}
+
+	if fields.Tags == nil {
+		fields.Tags = []string{}
+	}
+
+	return UpdateNote{
+		ID:       strconv.FormatInt(note.ID, 10),
+		Title:    &fields.Title,
+		Text:     fields.Text,
+		Summary:  &fields.Summary,
+		Tags:     fields.Tags,
+		Color:    &fields.Color,
+		Pinned:   &fields.Pinned,
+		Archived: &fields.Archived,
+	}, nil
+}
+
+// decodePatched reads the changeable fields of a patched note. Unknown
+// members and values of the wrong type are invalid input.
+func decodePatched(patched map[string]interface{}) (*patchedNote, error) {
+	data, err := json.Marshal(patched)
+
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	fields := &patchedNote{}
+
+	if err = decoder.Decode(fields); err != nil {
+		typeErr := &json.UnmarshalTypeError{}
+
+		if errors.As(err, &typeErr) {
+			return nil, validate(&FieldError{Field: typeErr.Field, Reason: "must be a " + typeErr.Type.String()})
+		}
+
+		return nil, validate(&FieldError{Field: "patch", Reason: err.Error()})
+	}
+
+	return fields, nil
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"note/internal/models"
+	"strconv"
+	"strings"
+)
+
+// RevisionStorage keeps the history of notes: every create and update
+// stores a revision with the content it saved. Revisions of notes in the
+// trash are not found.
+type RevisionStorage interface {
+	Revisions(context.Context, string) ([]*models.Revision, error)
+	Revision(context.Context, string, int) (*models.Revision, error)
+}
+
+// Operations of a diff line.
+const (
+	OpEqual  = "equal"
+	OpDelete = "delete"
+	OpInsert = "insert"
+)
+
+type RevisionList struct {
+	Revisions []*models.Revision `json:"revisions"`
+}
+
+type DiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// Diff turns the text of revision From into that of revision To line by
+// line.
+type Diff struct {
+	NoteID int64      `json:"noteId"`
+	From   int        `json:"from"`
+	To     int        `json:"to"`
+	Lines  []DiffLine `json:"lines"`
+}
+
+// Revisions lists the revisions of a note, latest first.
+func (s *service) Revisions(ctx context.Context, dto GetRevisions) (*RevisionList, error) {
+	if err := validate(checkID(dto.ID)); err != nil {
+		return nil, err
+	}
+
+	revisions, err := s.storage.Revisions(reading(ctx), dto.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &RevisionList{Revisions: revisions}, nil
+}
+
+func (s *service) Revision(ctx context.Context, dto GetRevision) (*models.Revision, error) {
+	rev, invalidRev := parseRev("rev", dto.Rev)
+
+	if err := validate(checkID(dto.ID), invalidRev); err != nil {
+		return nil, err
+	}
+
+	return s.storage.Revision(reading(ctx), dto.ID, rev)
+}
+
+func (s *service) DiffRevisions(ctx context.Context, dto DiffRevisions) (*Diff, error) {
+	from, invalidFrom := parseRev("rev", dto.Rev)
+	checks := []*FieldError{checkID(dto.ID), invalidFrom}
+	to := 0
+
+	if dto.To != "" {
+		var invalidTo *FieldError
+
+		to, invalidTo = parseRev("to", dto.To)
+		checks = append(checks, invalidTo)
+	}
+
+	if err := validate(checks...); err != nil {
+		return nil, err
+	}
+
+	older, err := s.storage.Revision(reading(ctx), dto.ID, from)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var newer *models.Revision
+
+	if to == 0 {
+		newer, err = s.latest(ctx, dto.ID)
+	} else {
+		newer, err = s.storage.Revision(reading(ctx), dto.ID, to)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Diff{
+		NoteID: older.NoteID,
+		From:   older.Revision,
+		To:     newer.Revision,
+		Lines:  diffLines(strings.Split(older.Text, "\n"), strings.Split(newer.Text, "\n")),
+	}, nil
+}
+
+// RestoreRevision brings back the content of a revision with an update, so
+// the restore is itself a new revision. Tags and the other fields are kept.
+func (s *service) RestoreRevision(ctx context.Context, dto RestoreRevision) (*models.Note, error) {
+	rev, invalidRev := parseRev("rev", dto.Rev)
+
+	if err := validate(checkID(dto.ID), invalidRev); err != nil {
+		return nil, err
+	}
+
+	revision, err := s.storage.Revision(reading(ctx), dto.ID, rev)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.storage.Update(editing(ctx), dto.ID, NoteFields{Text: revision.Text, Title: &revision.Title, Summary: &revision.Summary})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.storage.Get(reading(ctx), dto.ID)
+}
+
+func (s *service) latest(ctx context.Context, id string) (*models.Revision, error) {
+	revisions, err := s.storage.Revisions(reading(ctx), id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(revisions) == 0 {
+		return nil, fmt.Errorf("note %s has no revisions: %w", id, ErrNotFound)
+	}
+
+	return revisions[0], nil
+}
+
+func parseRev(field, value string) (int, *FieldError) {
+	rev, err := strconv.Atoi(value)
+
+	if err != nil || rev <= 0 {
+		return 0, &FieldError{Field: field, Reason: fmt.Sprintf("must be a positive integer, got %q", value)}
+	}
+
+	return rev, nil
+}
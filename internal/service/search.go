@@ -0,0 +1,226 @@
+package service
+
+import (
+	"fmt"
+	"html"
+	"note/internal/models"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	DefaultSearchLimit = 20
+	maxSearchTerms     = 10
+	snippetBefore      = 40
+	snippetLength      = 160
+)
+
+// SearchTerm is a word, a prefix ("pre*") or a phrase ("quick brown fox").
+// Words are lower case runs of letters and digits, so they are safe to put
+// into any backend's query syntax.
+type SearchTerm struct {
+	Words  []string
+	Prefix bool
+}
+
+// SearchQuery matches notes containing every term. Storages return up to
+// Limit results, best rank first.
+type SearchQuery struct {
+	Terms []SearchTerm
+	Limit int
+}
+
+type SearchResult struct {
+	Note    *models.Note `json:"note"`
+	Rank    float64      `json:"rank"`
+	Snippet string       `json:"snippet"`
+}
+
+type SearchResults struct {
+	Results []*SearchResult `json:"results"`
+}
+
+type token struct {
+	word       string
+	start, end int
+}
+
+// tokenize splits text into lower case words with their byte offsets.
+func tokenize(text string) []token {
+	tokens := []token{}
+	start := -1
+
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+
+		switch {
+		case isWord && start < 0:
+			start = i
+		case !isWord && start >= 0:
+			tokens = append(tokens, token{strings.ToLower(text[start:i]), start, i})
+			start = -1
+		}
+	}
+
+	if start >= 0 {
+		tokens = append(tokens, token{strings.ToLower(text[start:]), start, len(text)})
+	}
+
+	return tokens
+}
+
+// parseSearch reads words, "quoted phrases" and prefixes ending with "*".
+// Anything else is a separator, so no operator of the user reaches SQL.
+func parseSearch(q string) ([]SearchTerm, *FieldError) {
+	terms := []SearchTerm{}
+
+	for i, part := range strings.Split(q, `"`) {
+		words := tokenize(part)
+
+		if len(words) == 0 {
+			continue
+		}
+
+		// Odd parts are inside quotes.
+		if i%2 == 1 {
+			term := SearchTerm{}
+
+			for _, w := range words {
+				term.Words = append(term.Words, w.word)
+			}
+
+			terms = append(terms, term)
+
+			continue
+		}
+
+		for _, w := range words {
+			prefix := strings.HasPrefix(part[w.end:], "*")
+			terms = append(terms, SearchTerm{Words: []string{w.word}, Prefix: prefix})
+		}
+	}
+
+	switch {
+	case len(terms) == 0:
+		return nil, &FieldError{Field: "q", Reason: "must contain at least one word"}
+	case len(terms) > maxSearchTerms:
+		return nil, &FieldError{Field: "q", Reason: fmt.Sprintf("must have at most %d terms", maxSearchTerms)}
+	}
+
+	return terms, nil
+}
+
+func newSearchQuery(dto SearchNotes) (SearchQuery, error) {
+	query := SearchQuery{Limit: DefaultSearchLimit}
+	terms, invalid := parseSearch(dto.Query)
+	fields := []*FieldError{invalid}
+
+	if dto.Limit != "" {
+		limit, err := strconv.Atoi(dto.Limit)
+
+		if err != nil || limit < 1 || limit > MaxLimit {
+			fields = append(fields, &FieldError{Field: "limit", Reason: fmt.Sprintf("must be an integer between 1 and %d", MaxLimit)})
+		}
+
+		query.Limit = limit
+	}
+
+	query.Terms = terms
+
+	return query, validate(fields...)
+}
+
+// Occurrences returns the byte spans of text where the term occurs, using
+// the same word rules as the query parser.
+func (t SearchTerm) Occurrences(text string) [][2]int {
+	tokens := tokenize(text)
+	spans := [][2]int{}
+
+	for i := 0; i+len(t.Words) <= len(tokens); i++ {
+		if t.matchesAt(tokens[i:]) {
+			spans = append(spans, [2]int{tokens[i].start, tokens[i+len(t.Words)-1].end})
+		}
+	}
+
+	return spans
+}
+
+func (t SearchTerm) matchesAt(tokens []token) bool {
+	last := len(t.Words) - 1
+
+	for i, w := range t.Words {
+		if i == last && t.Prefix {
+			return strings.HasPrefix(tokens[i].word, w)
+		}
+
+		if tokens[i].word != w {
+			return false
+		}
+	}
+
+	return true
+}
+
+// snippet cuts the text around the first match and wraps every match in
+// <mark>. The rest of the text is HTML escaped.
+func snippet(text string, terms []SearchTerm) string {
+	spans := [][2]int{}
+
+	for _, term := range terms {
+		spans = append(spans, term.Occurrences(text)...)
+	}
+
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+
+	start := 0
+
+	if len(spans) > 0 {
+		start = spans[0][0]
+
+		for n := 0; n < snippetBefore && start > 0; n++ {
+			_, size := utf8.DecodeLastRuneInString(text[:start])
+			start -= size
+		}
+	}
+
+	end := start
+
+	for n := 0; n < snippetLength && end < len(text); n++ {
+		_, size := utf8.DecodeRuneInString(text[end:])
+		end += size
+	}
+
+	b := strings.Builder{}
+
+	if start > 0 {
+		b.WriteString("…")
+	}
+
+	pos := start
+
+	for _, span := range spans {
+		// Skip overlapping matches and the ones past the cut.
+		if span[0] < pos || span[0] >= end {
+			continue
+		}
+
+		if span[1] > end {
+			end = span[1]
+		}
+
+		b.WriteString(html.EscapeString(text[pos:span[0]]))
+		b.WriteString("<mark>" + html.EscapeString(text[span[0]:span[1]]) + "</mark>")
+		pos = span[1]
+	}
+
+	b.WriteString(html.EscapeString(text[pos:end]))
+
+	if end < len(text) {
+		b.WriteString("…")
+	}
+
+	return b.String()
+}
@@ -0,0 +1,359 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"note/internal/models"
+	"strconv"
+	"strings"
+)
+
+// ShareStorage keeps whom notes are shared with and the teams they can be
+// shared with. CreateShare, Shares and DeleteShare act on a note the user of
+// ctx owns and fail like any write to it otherwise.
+type ShareStorage interface {
+	// CreateShare fails with ErrConflict when the note is already shared
+	// with the user or team.
+	CreateShare(context.Context, ShareFields) (*models.Share, error)
+	Shares(ctx context.Context, noteID string) ([]*models.Share, error)
+	DeleteShare(ctx context.Context, noteID string, shareID int64) error
+	// CreateTeam makes the owner the first member of the team.
+	CreateTeam(ctx context.Context, name string, ownerID int64) (*models.Team, error)
+	// Teams lists the teams the user is a member of.
+	Teams(ctx context.Context, userID int64) ([]*models.Team, error)
+	// Team returns a team with its members.
+	Team(ctx context.Context, id int64) (*models.Team, error)
+	DeleteTeam(ctx context.Context, id int64) error
+	// AddMember fails with ErrConflict when the user is a member already.
+	AddMember(ctx context.Context, teamID, userID int64) error
+	RemoveMember(ctx context.Context, teamID, userID int64) error
+}
+
+// ShareFields are a validated share of note NoteID with either UserID or
+// TeamID.
+type ShareFields struct {
+	NoteID string
+	UserID *int64
+	TeamID *int64
+	Role   string
+}
+
+type ShareList struct {
+	Shares []*models.Share `json:"shares"`
+}
+
+type TeamList struct {
+	Teams []*models.Team `json:"teams"`
+}
+
+// ShareNote gives a user or a team a role on a note of the caller. The
+// team has to be one the caller is a member of.
+func (s *service) ShareNote(ctx context.Context, dto ShareNote) (*models.Share, error) {
+	user, err := caller(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	email := normalizeEmail(dto.Email)
+	checks := []*FieldError{checkID(dto.ID), checkShareRole(dto.Role), checkRef("teamId", dto.TeamID)}
+
+	if (email == "") == (dto.TeamID == nil) {
+		checks = append(checks, &FieldError{Field: "email", Reason: "or teamId must be set, but not both"})
+	}
+
+	if err = validate(checks...); err != nil {
+		return nil, err
+	}
+
+	fields := ShareFields{NoteID: dto.ID, TeamID: dto.TeamID, Role: dto.Role}
+	name, err := s.grantee(ctx, user, email, &fields)
+
+	if err != nil {
+		return nil, err
+	}
+
+	share, err := s.storage.CreateShare(ctx, fields)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if fields.UserID != nil {
+		share.Email = name
+	} else {
+		share.Team = name
+	}
+
+	return share, nil
+}
+
+// grantee finds the user or team a note is shared with and returns its
+// email or name. Both come from the request body, so a missing one is an
+// invalid field.
+func (s *service) grantee(ctx context.Context, user *models.User, email string, fields *ShareFields) (string, error) {
+	if fields.TeamID != nil {
+		team, err := s.memberTeam(ctx, user, *fields.TeamID)
+
+		if errors.Is(err, ErrNotFound) {
+			return "", validate(&FieldError{Field: "teamId", Reason: fmt.Sprintf("team %d is not a team of yours", *fields.TeamID)})
+		}
+
+		if err != nil {
+			return "", err
+		}
+
+		return team.Name, nil
+	}
+
+	grantee, err := s.userByEmail(ctx, email)
+
+	if err != nil {
+		return "", err
+	}
+
+	if grantee.ID == user.ID {
+		return "", validate(&FieldError{Field: "email", Reason: "must not be your own"})
+	}
+
+	fields.UserID = &grantee.ID
+
+	return grantee.Email, nil
+}
+
+// Shares lists whom a note of the caller is shared with.
+func (s *service) Shares(ctx context.Context, dto GetShares) (*ShareList, error) {
+	if _, err := caller(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := validate(checkID(dto.ID)); err != nil {
+		return nil, err
+	}
+
+	shares, err := s.storage.Shares(ctx, dto.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &ShareList{Shares: shares}, nil
+}
+
+// DeleteShare takes back the role a share gave.
+func (s *service) DeleteShare(ctx context.Context, dto DeleteShare) error {
+	if _, err := caller(ctx); err != nil {
+		return err
+	}
+
+	shareID, invalidShare := parseKey("shareId", dto.ShareID)
+
+	if err := validate(checkID(dto.ID), invalidShare); err != nil {
+		return err
+	}
+
+	return s.storage.DeleteShare(ctx, dto.ID, shareID)
+}
+
+// CreateTeam creates a team owned by the caller.
+func (s *service) CreateTeam(ctx context.Context, dto CreateTeam) (*models.Team, error) {
+	user, err := caller(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = validate(checkName(dto.Name)); err != nil {
+		return nil, err
+	}
+
+	return s.storage.CreateTeam(ctx, strings.TrimSpace(dto.Name), user.ID)
+}
+
+// Teams lists the teams the caller is a member of.
+func (s *service) Teams(ctx context.Context) (*TeamList, error) {
+	user, err := caller(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	teams, err := s.storage.Teams(ctx, user.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &TeamList{Teams: teams}, nil
+}
+
+// GetTeam returns a team of the caller with its members.
+func (s *service) GetTeam(ctx context.Context, dto GetTeam) (*models.Team, error) {
+	user, err := caller(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	id, invalidID := parseKey("id", dto.ID)
+
+	if err = validate(invalidID); err != nil {
+		return nil, err
+	}
+
+	return s.memberTeam(ctx, user, id)
+}
+
+// DeleteTeam deletes a team the caller owns, with its shares.
+func (s *service) DeleteTeam(ctx context.Context, dto DeleteTeam) error {
+	team, err := s.ownTeam(ctx, dto.ID)
+
+	if err != nil {
+		return err
+	}
+
+	return s.storage.DeleteTeam(ctx, team.ID)
+}
+
+// AddMember adds a user to a team the caller owns and returns the team.
+func (s *service) AddMember(ctx context.Context, dto AddMember) (*models.Team, error) {
+	team, err := s.ownTeam(ctx, dto.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	member, err := s.userByEmail(ctx, normalizeEmail(dto.Email))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.storage.AddMember(ctx, team.ID, member.ID); err != nil {
+		return nil, err
+	}
+
+	return s.storage.Team(ctx, team.ID)
+}
+
+// RemoveMember takes a user out of a team. The owner may remove anyone but
+// themselves, other members only themselves.
+func (s *service) RemoveMember(ctx context.Context, dto RemoveMember) error {
+	user, err := caller(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	id, invalidID := parseKey("id", dto.ID)
+	userID, invalidUser := parseKey("userId", dto.UserID)
+
+	if err = validate(invalidID, invalidUser); err != nil {
+		return err
+	}
+
+	team, err := s.memberTeam(ctx, user, id)
+
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case userID == team.OwnerID:
+		return fmt.Errorf("%w: the owner can't leave team %d, delete it instead", ErrConflict, id)
+	case user.ID != team.OwnerID && userID != user.ID:
+		return fmt.Errorf("%w: only the owner of team %d may remove other members", ErrForbidden, id)
+	}
+
+	return s.storage.RemoveMember(ctx, id, userID)
+}
+
+// memberTeam finds a team the user is a member of; other teams are not
+// found.
+func (s *service) memberTeam(ctx context.Context, user *models.User, id int64) (*models.Team, error) {
+	team, err := s.storage.Team(ctx, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, member := range team.Members {
+		if member.UserID == user.ID {
+			return team, nil
+		}
+	}
+
+	return nil, fmt.Errorf("team %d %w", id, ErrNotFound)
+}
+
+// ownTeam finds a team the caller may change.
+func (s *service) ownTeam(ctx context.Context, id string) (*models.Team, error) {
+	user, err := caller(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	key, invalidID := parseKey("id", id)
+
+	if err = validate(invalidID); err != nil {
+		return nil, err
+	}
+
+	team, err := s.memberTeam(ctx, user, key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if team.OwnerID != user.ID {
+		return nil, fmt.Errorf("%w: only the owner of team %d may change it", ErrForbidden, key)
+	}
+
+	return team, nil
+}
+
+// userByEmail finds a user named in the request body.
+func (s *service) userByEmail(ctx context.Context, email string) (*models.User, error) {
+	if email == "" {
+		return nil, validate(&FieldError{Field: "email", Reason: "must not be empty"})
+	}
+
+	user, err := s.storage.UserByEmail(ctx, email)
+
+	if errors.Is(err, ErrNotFound) {
+		return nil, validate(&FieldError{Field: "email", Reason: fmt.Sprintf("no user is registered as %s", email)})
+	}
+
+	return user, err
+}
+
+// caller is the user acting in ctx; sharing needs one.
+func caller(ctx context.Context) (*models.User, error) {
+	user, ok := UserFrom(ctx)
+
+	if !ok {
+		return nil, fmt.Errorf("%w: sharing needs a signed in user", ErrUnauthorized)
+	}
+
+	return user, nil
+}
+
+func checkShareRole(role string) *FieldError {
+	if role != RoleEditor && role != RoleViewer {
+		return &FieldError{Field: "role", Reason: fmt.Sprintf("must be %s or %s, got %q", RoleEditor, RoleViewer, role)}
+	}
+
+	return nil
+}
+
+func parseKey(field, value string) (int64, *FieldError) {
+	key, err := strconv.ParseInt(value, 10, 64)
+
+	if err != nil || key <= 0 {
+		return 0, &FieldError{Field: field, Reason: fmt.Sprintf("must be a positive integer, got %q", value)}
+	}
+
+	return key, nil
+}
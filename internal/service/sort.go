@@ -0,0 +1,111 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+)
+
+const maxSortFields = 4
+
+// SortTextLength is how many characters of the text order_by=text compares,
+// so that a cursor of that order stays short. Notes alike up to there are
+// ordered by the next field, or by id.
+const SortTextLength = 100
+
+// SortFields are the note fields GET /note can be ordered by. Storages
+// only ever see these names, never raw order_by input.
+var SortFields = []string{"id", "text", "created_at", "updated_at"}
+
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// Sort is a validated order such as "-updated_at,id". It always ends with
+// id, so the order is total and usable as a keyset.
+type Sort []SortField
+
+var defaultSort = Sort{{Field: "id"}}
+
+func (s Sort) String() string {
+	parts := make([]string, 0, len(s))
+
+	for _, f := range s {
+		if f.Desc {
+			parts = append(parts, "-"+f.Field)
+		} else {
+			parts = append(parts, f.Field)
+		}
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// SortText is the part of a text order_by=text compares.
+func SortText(text string) string {
+	if runes := []rune(text); len(runes) > SortTextLength {
+		return string(runes[:SortTextLength])
+	}
+
+	return text
+}
+
+func isSortField(name string) bool {
+	for _, f := range SortFields {
+		if f == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseSort reads a comma separated list of fields, each optionally
+// prefixed with "-" for descending or "+" for ascending order.
+func parseSort(spec string) (Sort, *FieldError) {
+	if strings.TrimSpace(spec) == "" {
+		return defaultSort, nil
+	}
+
+	invalid := func(reason string) (Sort, *FieldError) {
+		return nil, &FieldError{Field: "order_by", Reason: reason}
+	}
+
+	parts := strings.Split(spec, ",")
+
+	if len(parts) > maxSortFields {
+		return invalid(fmt.Sprintf("must have at most %d fields", maxSortFields))
+	}
+
+	sort := Sort{}
+	seen := map[string]bool{}
+
+	for _, part := range parts {
+		f := SortField{Field: strings.TrimSpace(part)}
+
+		switch {
+		case strings.HasPrefix(f.Field, "-"):
+			f.Field, f.Desc = f.Field[1:], true
+		case strings.HasPrefix(f.Field, "+"):
+			f.Field = f.Field[1:]
+		}
+
+		if !isSortField(f.Field) {
+			return invalid(fmt.Sprintf("has unknown field %q, must be one of %s", f.Field, strings.Join(SortFields, ", ")))
+		}
+
+		if seen[f.Field] {
+			return invalid(fmt.Sprintf("has field %q more than once", f.Field))
+		}
+
+		seen[f.Field] = true
+		sort = append(sort, f)
+
+		// id is unique, fields after it can't change the order.
+		if f.Field == "id" {
+			return sort, nil
+		}
+	}
+
+	return append(sort, SortField{Field: "id"}), nil
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"fmt"
+	"note/internal/models"
+	"regexp"
+	"strings"
+)
+
+const (
+	maxTags      = 20
+	maxTagLength = 32
+)
+
+var tagPattern = regexp.MustCompile(`^[\p{L}\p{N}_-]+$`)
+
+type TagList struct {
+	Tags []*models.Tag `json:"tags"`
+}
+
+// normalizeTags trims, lower cases and deduplicates tags. Nil stays nil so
+// an update can tell "keep" from "clear".
+func normalizeTags(tags []string) ([]string, *FieldError) {
+	if tags == nil {
+		return nil, nil
+	}
+
+	normalized := make([]string, 0, len(tags))
+	seen := map[string]bool{}
+
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+
+		if !tagPattern.MatchString(tag) || len([]rune(tag)) > maxTagLength {
+			return nil, &FieldError{Field: "tags", Reason: fmt.Sprintf("has invalid tag %q, tags are 1-%d letters, digits, '-' or '_'", tag, maxTagLength)}
+		}
+
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+
+	if len(normalized) > maxTags {
+		return nil, &FieldError{Field: "tags", Reason: fmt.Sprintf("must have at most %d tags", maxTags)}
+	}
+
+	return normalized, nil
+}
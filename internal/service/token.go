@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"note/internal/models"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	ScopeNotesRead  = "notes:read"
+	ScopeNotesWrite = "notes:write"
+	// accessTokenPrefix tells access tokens from session tokens, so a bearer
+	// token is looked up in one table only.
+	accessTokenPrefix = "pat_"
+)
+
+// TokenStorage keeps personal access tokens by the hash of the token.
+type TokenStorage interface {
+	// CreateToken fails with ErrConflict when the user has a token with the
+	// name already.
+	CreateToken(context.Context, TokenFields) (*models.AccessToken, error)
+	Tokens(ctx context.Context, userID int64) ([]*models.AccessToken, error)
+	DeleteToken(ctx context.Context, userID, id int64) error
+	// TokenUser finds a token with its user and records now as its last use.
+	TokenUser(ctx context.Context, tokenHash string, now time.Time) (*models.AccessToken, *models.User, error)
+}
+
+// TokenFields are a validated access token to store.
+type TokenFields struct {
+	UserID    int64
+	Name      string
+	TokenHash string
+	Scopes    []string
+}
+
+type TokenList struct {
+	Tokens []*models.AccessToken `json:"tokens"`
+}
+
+type scopesKey struct{}
+
+// WithScopes marks ctx as acting with an access token. A context without
+// scopes acts with a session and may do whatever its user may.
+func WithScopes(ctx context.Context, scopes []string) context.Context {
+	return context.WithValue(ctx, scopesKey{}, scopes)
+}
+
+// ScopesFrom returns the scopes set with WithScopes.
+func ScopesFrom(ctx context.Context) ([]string, bool) {
+	scopes, ok := ctx.Value(scopesKey{}).([]string)
+
+	return scopes, ok
+}
+
+// HasScope tells whether scopes include scope.
+func HasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsAccessToken tells a personal access token from a session token.
+func IsAccessToken(token string) bool {
+	return strings.HasPrefix(token, accessTokenPrefix)
+}
+
+// CreateToken makes an access token for the caller. The token is returned
+// only here.
+func (s *authService) CreateToken(ctx context.Context, dto CreateToken) (*models.AccessToken, error) {
+	user, err := sessionUser(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	scopes, invalidScopes := normalizeScopes(dto.Scopes)
+
+	if err = validate(checkName(dto.Name), invalidScopes); err != nil {
+		return nil, err
+	}
+
+	token, err := newToken()
+
+	if err != nil {
+		return nil, err
+	}
+
+	token = accessTokenPrefix + token
+	fields := TokenFields{UserID: user.ID, Name: strings.TrimSpace(dto.Name), TokenHash: hashToken(token), Scopes: scopes}
+	accessToken, err := s.storage.CreateToken(ctx, fields)
+
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken.Token = token
+
+	return accessToken, nil
+}
+
+// Tokens lists the access tokens of the caller.
+func (s *authService) Tokens(ctx context.Context) (*TokenList, error) {
+	user, err := sessionUser(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.storage.Tokens(ctx, user.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenList{Tokens: tokens}, nil
+}
+
+// RevokeToken deletes an access token of the caller; requests with it are
+// unauthorized from then on.
+func (s *authService) RevokeToken(ctx context.Context, dto RevokeToken) error {
+	user, err := sessionUser(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	id, invalidID := parseKey("id", dto.ID)
+
+	if err = validate(invalidID); err != nil {
+		return err
+	}
+
+	return s.storage.DeleteToken(ctx, user.ID, id)
+}
+
+// AuthenticateToken finds the user of an access token and the scopes the
+// token grants.
+func (s *authService) AuthenticateToken(ctx context.Context, token string) (*models.User, []string, error) {
+	accessToken, user, err := s.storage.TokenUser(ctx, hashToken(token), time.Now().UTC().Truncate(time.Second))
+
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil, fmt.Errorf("%w: the access token is invalid or revoked", ErrUnauthorized)
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return user, accessToken.Scopes, nil
+}
+
+// sessionUser is the user acting in ctx with a session. Access tokens can't
+// manage tokens, so a leaked one can't make others.
+func sessionUser(ctx context.Context) (*models.User, error) {
+	user, ok := UserFrom(ctx)
+
+	if !ok {
+		return nil, fmt.Errorf("%w: access tokens need a signed in user", ErrUnauthorized)
+	}
+
+	if _, scoped := ScopesFrom(ctx); scoped {
+		return nil, fmt.Errorf("%w: access tokens are managed with a session, not with an access token", ErrForbidden)
+	}
+
+	return user, nil
+}
+
+// normalizeScopes sorts scopes and drops repeated ones.
+func normalizeScopes(scopes []string) ([]string, *FieldError) {
+	known := []string{ScopeNotesRead, ScopeNotesWrite}
+	normalized := []string{}
+
+	for i, scope := range scopes {
+		if !HasScope(known, scope) {
+			return nil, &FieldError{Field: fmt.Sprintf("scopes[%d]", i), Reason: fmt.Sprintf("must be %s or %s, got %q", ScopeNotesRead, ScopeNotesWrite, scope)}
+		}
+
+		if !HasScope(normalized, scope) {
+			normalized = append(normalized, scope)
+		}
+	}
+
+	if len(normalized) == 0 {
+		return nil, &FieldError{Field: "scopes", Reason: fmt.Sprintf("must name %s, %s or both", ScopeNotesRead, ScopeNotesWrite)}
+	}
+
+	sort.Strings(normalized)
+
+	return normalized, nil
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"note/internal/models"
+	"time"
+)
+
+// TrashStorage keeps deleted notes until they are restored or purged. Notes
+// in the trash are found only by these methods and by NotesQuery.Trashed.
+type TrashStorage interface {
+	Restore(context.Context, string) error
+	Purge(context.Context, string) error
+	PurgeTrashed(context.Context, time.Time) (int64, error)
+}
+
+// Trash returns one page of the notes in the trash, with the same filters
+// and order as GetAll.
+func (s *service) Trash(ctx context.Context, dto GetNotes) (*NotesPage, error) {
+	query, err := newNotesQuery(dto)
+
+	if err != nil {
+		return nil, err
+	}
+
+	query.Trashed = true
+
+	return s.page(ctx, query)
+}
+
+func (s *service) Restore(ctx context.Context, dto RestoreNote) (*models.Note, error) {
+	if err := validate(checkID(dto.ID)); err != nil {
+		return nil, err
+	}
+
+	if err := s.storage.Restore(ctx, dto.ID); err != nil {
+		return nil, err
+	}
+
+	return s.storage.Get(ctx, dto.ID)
+}
+
+// Purge removes a note in the trash for good.
+func (s *service) Purge(ctx context.Context, dto PurgeNote) error {
+	if err := validate(checkID(dto.ID)); err != nil {
+		return err
+	}
+
+	return s.storage.Purge(ctx, dto.ID)
+}
+
+// PurgeTrash removes the notes that have been in the trash for longer than
+// retention and returns how many there were.
+func (s *service) PurgeTrash(ctx context.Context, retention time.Duration) (int64, error) {
+	return s.storage.PurgeTrashed(ctx, time.Now().Add(-retention))
+}
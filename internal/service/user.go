@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"note/internal/models"
+	"time"
+)
+
+// UserStorage keeps users, their sessions, access tokens and external
+// identities. Sessions and tokens are stored by the hash of their token, so
+// the tokens themselves are never saved.
+type UserStorage interface {
+	// CreateUser fails with ErrConflict when the email is taken.
+	CreateUser(ctx context.Context, email, passwordHash string) (*models.User, error)
+	UserByEmail(ctx context.Context, email string) (*models.User, error)
+	// CreateSession also drops the expired sessions of the user.
+	CreateSession(ctx context.Context, tokenHash string, userID int64, expiresAt time.Time) error
+	// SessionUser finds the user of a session that hasn't expired at now.
+	SessionUser(ctx context.Context, tokenHash string, now time.Time) (*models.User, error)
+	DeleteSession(ctx context.Context, tokenHash string) error
+	TokenStorage
+	IdentityStorage
+}
+
+type userKey struct{}
+
+type systemKey struct{}
+
+// WithUser makes the storage act for the user: notes and notebooks of other
+// users are then not found. A context with no user and not marked with
+// AsSystem finds nothing, so a path that forgets the user leaks no notes.
+func WithUser(ctx context.Context, user *models.User) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// UserFrom returns the user set with WithUser.
+func UserFrom(ctx context.Context) (*models.User, bool) {
+	user, ok := ctx.Value(userKey{}).(*models.User)
+
+	return user, ok && user != nil
+}
+
+// AsSystem makes the storage act for the service itself, which sees the
+// notes and notebooks of every user. It is meant for background jobs like
+// purging the trash; a user set with WithUser still comes first.
+func AsSystem(ctx context.Context) context.Context {
+	return context.WithValue(ctx, systemKey{}, true)
+}
+
+// IsSystem tells whether ctx was marked with AsSystem.
+func IsSystem(ctx context.Context) bool {
+	system, _ := ctx.Value(systemKey{}).(bool)
+
+	return system
+}
@@ -0,0 +1,18 @@
+package tools
+
+import (
+	"net/http"
+	"strings"
+)
+
+// BearerToken returns the token of an "Authorization: Bearer" header, or ""
+// without one.
+func BearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+
+	return strings.TrimSpace(token)
+}
@@ -5,24 +5,27 @@ import (
 	"net/http"
 )
 
-type JSONResponse struct {
-	Message string      `json:"error"`
-	Payload interface{} `json:"payload,omitempty"`
-}
-
 func WriteJSON(w http.ResponseWriter, data interface{}, headers ...http.Header) error {
-	jsonData, err := json.MarshalIndent(data, "", "\t")
+	jsonData, err := prepareJSON(w, data, headers...)
 	if err != nil {
 		return err
 	}
 
-	if len(headers) > 0 {
-		for k, v := range headers[0] {
-			w.Header()[k] = v
-		}
+	_, err = w.Write(jsonData)
+	if err != nil {
+		return err
 	}
 
-	w.Header().Set("Content-Type", "application/json")
+	return nil
+}
+
+func WriteJSONStatus(w http.ResponseWriter, status int, data interface{}, headers ...http.Header) error {
+	jsonData, err := prepareJSON(w, data, headers...)
+	if err != nil {
+		return err
+	}
+
+	w.WriteHeader(status)
 	_, err = w.Write(jsonData)
 	if err != nil {
 		return err
@@ -31,12 +34,19 @@ func WriteJSON(w http.ResponseWriter, data interface{}, headers ...http.Header)
 	return nil
 }
 
-func ErrorJSON(w http.ResponseWriter, err error, status int) error {
-	w.WriteHeader(status)
+func prepareJSON(w http.ResponseWriter, data interface{}, headers ...http.Header) ([]byte, error) {
+	jsonData, err := json.MarshalIndent(data, "", "\t")
+	if err != nil {
+		return nil, err
+	}
 
-	errorPayload := JSONResponse{
-		Message: err.Error(),
+	if len(headers) > 0 {
+		for k, v := range headers[0] {
+			w.Header()[k] = v
+		}
 	}
 
-	return WriteJSON(w, errorPayload)
+	w.Header().Set("Content-Type", "application/json")
+
+	return jsonData, nil
 }
@@ -0,0 +1,71 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+)
+
+const problemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 error body. InvalidParams is an extension member
+// listing field-level validation failures.
+type Problem struct {
+	Type          string         `json:"type"`
+	Title         string         `json:"title"`
+	Status        int            `json:"status"`
+	Detail        string         `json:"detail,omitempty"`
+	Instance      string         `json:"instance,omitempty"`
+	RequestID     string         `json:"requestId,omitempty"`
+	InvalidParams []InvalidParam `json:"invalid-params,omitempty"`
+}
+
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// WriteProblem fills instance and request id from the request and writes
+// the problem with its status code.
+func WriteProblem(w http.ResponseWriter, r *http.Request, problem *Problem) error {
+	if problem.Instance == "" {
+		problem.Instance = r.URL.RequestURI()
+	}
+
+	if problem.RequestID == "" {
+		problem.RequestID = RequestID(r.Context())
+	}
+
+	jsonData, err := prepareJSON(w, problem)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(problem.Status)
+	_, err = w.Write(jsonData)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+type requestIDKey struct{}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}